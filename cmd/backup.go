@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/t-bfame/diago/config"
+	"github.com/t-bfame/diago/pkg/storage"
+)
+
+const commandUsage = `Usage:
+  diago                                   run the leader
+  diago backup [-format snapshot|json] FILE
+  diago restore [-conflict fail|skip|overwrite] FILE
+  diago token NAME viewer|runner|admin
+
+backup and restore open the configured storage directly, so the leader using it must be stopped.
+FILE may be - for stdout or stdin.
+token generates an API token, and prints the entry to add to DIAGO_AUTH_TOKENS_FILE for it.
+`
+
+// Internal function used to run the backup, restore and token commands, returning the exit code
+func runCommand(args []string) int {
+	var err error
+	switch args[0] {
+	case "backup":
+		err = runBackup(args[1:])
+	case "restore":
+		err = runRestore(args[1:])
+	case "token":
+		err = runToken(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	format := flags.String("format", "snapshot", "snapshot of the storage file, or a portable json bundle")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(commandUsage)
+	}
+	if *format != "snapshot" && *format != "json" {
+		return fmt.Errorf("unknown backup format %s", *format)
+	}
+
+	if err := storage.Init(config.Diago.StorageBackend, config.Diago.StoragePath); err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	out := io.Writer(os.Stdout)
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "snapshot" {
+		return storage.Snapshot(out)
+	}
+
+	bundle, err := storage.Export()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(bundle)
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	conflict := flags.String("conflict", string(storage.ConflictFail), "what to do with objects whose ID already exists")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New(commandUsage)
+	}
+
+	policy, err := storage.ParseConflictPolicy(*conflict)
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	bundle, err := storage.ReadBundle(in)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.Init(config.Diago.StorageBackend, config.Diago.StoragePath); err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	result, err := storage.Import(bundle, policy)
+	if err != nil {
+		return err
+	}
+
+	// The leader starts restored schedules and interrupts restored instances which had not finished
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
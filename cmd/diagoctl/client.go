@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// client calls the API of a Diago leader
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// apiError is a failure reported by the API
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// response is the envelope of every JSON response of the API
+type response struct {
+	Success bool            `json:"success"`
+	Payload json.RawMessage `json:"payload"`
+	Error   *apiError       `json:"error"`
+}
+
+func newClient(server string, token string) *client {
+	return &client{
+		base:  strings.TrimSuffix(server, "/") + "/api",
+		token: token,
+		http:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Internal function used to call the API and decode its payload into payload, if not nil
+func (c *client) do(method string, path string, body io.Reader, payload interface{}) error {
+	res, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %s", method, path, err)
+	}
+	if !resp.Success {
+		if resp.Error == nil {
+			return &apiError{res.StatusCode, http.StatusText(res.StatusCode)}
+		}
+		return resp.Error
+	}
+
+	if payload == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Payload, payload)
+}
+
+// Internal function used to download a file served by the API to w, returning its file name
+func (c *client) download(path string, w io.Writer) (string, error) {
+	res, err := c.send(http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	// Failures are reported as JSON
+	if res.StatusCode != http.StatusOK {
+		var resp response
+		if err := json.NewDecoder(res.Body).Decode(&resp); err != nil || resp.Error == nil {
+			return "", &apiError{res.StatusCode, http.StatusText(res.StatusCode)}
+		}
+		return "", resp.Error
+	}
+
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return "", err
+	}
+
+	_, params, _ := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+	return params["filename"], nil
+}
+
+func (c *client) send(method string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+// Internal function used to retrieve a TestInstance by its ID
+func (c *client) instance(id m.TestInstanceID) (*m.TestInstance, error) {
+	// Instances are named after their Test followed by their creation time,
+	// which lists a few instances of the Test instead of every instance
+	instances := []*m.TestInstance{}
+	if i := strings.LastIndex(string(id), "-"); i > 0 {
+		query := "/test-instances?testid=" + url.QueryEscape(string(id[:i]))
+		if err := c.do(http.MethodGet, query, nil, &instances); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, instance := range instances {
+		if instance.ID == id {
+			return instance, nil
+		}
+	}
+	return nil, &apiError{http.StatusNotFound, fmt.Sprintf("Cannot find TestInstance<%s>", id)}
+}
+
+// Internal function used to retrieve the latest TestInstance of a Test, nil if it has none
+func (c *client) latestInstance(testID m.TestID) (*m.TestInstance, error) {
+	instances := []*m.TestInstance{}
+	query := "/test-instances?testid=" + url.QueryEscape(string(testID))
+	if err := c.do(http.MethodGet, query, nil, &instances); err != nil {
+		return nil, err
+	}
+
+	var latest *m.TestInstance
+	for _, instance := range instances {
+		if latest == nil || instance.CreatedAt >= latest.CreatedAt {
+			latest = instance
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// pollInterval is how often the status and metrics of a running instance are retrieved
+var pollInterval = 2 * time.Second
+
+func runStart(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("start", flag.ContinueOnError)
+	follow := flags.Bool("follow", false, "print the metrics of the instance until it finishes")
+	wait := flags.Bool("wait", false, "wait for the instance to finish, and exit with whether it passed")
+	timeout := flags.Duration("timeout", 0, "stop the instance if it has not finished after DURATION")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	testID := m.TestID(flags.Arg(0))
+
+	if err := c.do(http.MethodPost, "/tests/"+url.PathEscape(string(testID))+"/start", nil, nil); err != nil {
+		return err
+	}
+
+	// Only one instance of a Test runs at a time, which is the one just submitted
+	instance, err := c.latestInstance(testID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("Cannot find the instance of Test<%s>", testID)
+	}
+	fmt.Fprintf(out, "Started TestInstance<%s>\n", instance.ID)
+
+	if !*follow && !*wait {
+		return nil
+	}
+
+	var w io.Writer
+	if *follow {
+		w = out
+	}
+	instance, err = watch(c, instance.ID, *timeout, w)
+	if err != nil {
+		return err
+	}
+	return verdict(instance, out)
+}
+
+func runStop(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := c.do(http.MethodPost, "/tests/"+url.PathEscape(args[0])+"/stop", nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Stopped Test<%s>\n", args[0])
+	return nil
+}
+
+func runFollow(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	instance, err := watch(c, m.TestInstanceID(args[0]), 0, out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "TestInstance<%s> is %s\n", instance.ID, instance.Status)
+	return nil
+}
+
+func runWait(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("wait", flag.ContinueOnError)
+	timeout := flags.Duration("timeout", 0, "stop the instance if it has not finished after DURATION")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	instance, err := watch(c, m.TestInstanceID(flags.Arg(0)), *timeout, nil)
+	if err != nil {
+		return err
+	}
+	return verdict(instance, out)
+}
+
+func runReport(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the report, json by default")
+	output := flags.String("o", "", "write the report to FILE instead of its default file name, - for stdout")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	path := "/test-instances/" + url.PathEscape(flags.Arg(0)) + "/report"
+	if *format != "" {
+		path += "?format=" + url.QueryEscape(*format)
+	}
+
+	switch *output {
+	case "-":
+		_, err := c.download(path, out)
+		return err
+	case "":
+		// The report is named by the server, once the response is received
+		tmp, err := ioutil.TempFile(".", ".diago-report-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		filename, err := save(c, path, tmp)
+		if err != nil {
+			return err
+		}
+		if filename == "" {
+			filename = flags.Arg(0)
+		}
+		// Never written outside of the working directory
+		filename = filepath.Base(filename)
+		if err := os.Rename(tmp.Name(), filename); err != nil {
+			return err
+		}
+		*output = filename
+	default:
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if _, err := save(c, path, file); err != nil {
+			os.Remove(*output)
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "Saved report to %s\n", *output)
+	return nil
+}
+
+// Internal function used to download a file served by the API to file, which is closed
+func save(c *client, path string, file *os.File) (string, error) {
+	filename, err := c.download(path, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return filename, err
+}
+
+// Internal function used to poll an instance until it finishes. The instance is stopped once
+// timeout, if not 0, has elapsed. The metrics of the instance are printed to out, if not nil.
+func watch(c *client, id m.TestInstanceID, timeout time.Duration, out io.Writer) (*m.TestInstance, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	var printer *metricsPrinter
+	if out != nil {
+		printer = newMetricsPrinter(out)
+	}
+
+	for stopping := false; ; time.Sleep(pollInterval) {
+		instance, err := c.instance(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if printer != nil {
+			if err := printer.update(c, id, instance.IsTerminal()); err != nil {
+				return nil, err
+			}
+		}
+
+		if instance.IsTerminal() {
+			return instance, nil
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) && !stopping {
+			fmt.Fprintf(os.Stderr, "TestInstance<%s> did not finish within %s, stopping it\n", id, timeout)
+			if err := c.do(http.MethodPost, "/tests/"+url.PathEscape(string(instance.TestID))+"/stop", nil, nil); err != nil {
+				return nil, err
+			}
+			stopping = true
+		}
+	}
+}
+
+// Internal function used to print the outcome of a finished instance, returning errNotPassed
+// unless the instance is done and none of the criteria of its Test failed
+func verdict(instance *m.TestInstance, out io.Writer) error {
+	outcome := instance.Status
+	if instance.Verdict != "" {
+		outcome += ", " + string(instance.Verdict)
+	}
+	fmt.Fprintf(out, "TestInstance<%s> is %s\n", instance.ID, outcome)
+
+	for _, result := range instance.CriteriaResults {
+		if result.Error != "" {
+			fmt.Fprintf(out, "  failed %s: %s\n", result.Criterion.String(), result.Error)
+		} else if !result.Passed {
+			fmt.Fprintf(out, "  failed %s, got %g\n", result.Criterion.String(), result.Value)
+		}
+	}
+	if instance.AbortReason != "" {
+		fmt.Fprintf(out, "  aborted: %s\n", instance.AbortReason)
+	}
+	if instance.Error != "" {
+		fmt.Fprintf(out, "  error: %s\n", instance.Error)
+	}
+
+	if instance.Status != "done" || instance.Verdict == m.VerdictFailed {
+		return errNotPassed
+	}
+	return nil
+}
+
+// metricsPrinter prints the metrics of every interval of a running instance once
+type metricsPrinter struct {
+	out     io.Writer
+	printed time.Time
+}
+
+const metricsRow = "%-8s  %8s  %8s  %8s  %10s  %10s  %10s\n"
+
+func newMetricsPrinter(out io.Writer) *metricsPrinter {
+	fmt.Fprintf(out, metricsRow, "TIME", "REQUESTS", "SUCCESS", "ERRORS", "P50", "P95", "P99")
+	return &metricsPrinter{out: out}
+}
+
+// Internal function used to print the intervals of the instance which were not printed yet.
+// The latest interval is still receiving results until the instance is finished.
+func (p *metricsPrinter) update(c *client, id m.TestInstanceID, finished bool) error {
+	var timeSeries struct {
+		Total *metrics.TimeSeries `json:"total"`
+	}
+	if err := c.do(http.MethodGet, "/test-instances/"+url.PathEscape(string(id))+"/timeseries", nil, &timeSeries); err != nil {
+		return err
+	}
+	if timeSeries.Total == nil {
+		return nil
+	}
+
+	buckets := timeSeries.Total.Buckets
+	if !finished && len(buckets) > 0 {
+		buckets = buckets[:len(buckets)-1]
+	}
+	for _, bucket := range buckets {
+		if !bucket.Start.After(p.printed) {
+			continue
+		}
+		fmt.Fprintf(p.out, metricsRow,
+			bucket.Start.Local().Format("15:04:05"),
+			strconv.FormatUint(bucket.Requests, 10),
+			fmt.Sprintf("%.1f%%", bucket.Success*100),
+			strconv.FormatUint(bucket.Errors, 10),
+			bucket.Latencies.P50.Round(time.Microsecond),
+			bucket.Latencies.P95.Round(time.Microsecond),
+			bucket.Latencies.P99.Round(time.Microsecond),
+		)
+		p.printed = bucket.Start
+	}
+	return nil
+}
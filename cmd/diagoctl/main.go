@@ -0,0 +1,164 @@
+// diagoctl is a command-line client of the API of a Diago leader
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const usage = `Usage: diagoctl [-server URL] [-token TOKEN] COMMAND [ARGS]
+
+Tests:
+  tests list [-prefix PREFIX]
+  tests get TESTID
+  tests create FILE
+  tests delete TESTID
+  tests revisions TESTID
+  apply [-dry-run] [-prune] FILE
+
+Test instances:
+  start [-follow] [-wait] [-timeout DURATION] TESTID
+  stop TESTID
+  instances list [-test TESTID]
+  instances delete INSTANCEID
+  follow INSTANCEID
+  wait [-timeout DURATION] INSTANCEID
+  report [-format json|html|csv|junit] [-o FILE] INSTANCEID
+
+Test schedules:
+  schedules list [-test TESTID]
+  schedules get SCHEDULEID
+  schedules create FILE
+  schedules delete SCHEDULEID
+
+FILE may be - for stdin, tests and schedules are read as JSON or YAML.
+The server defaults to $DIAGO_SERVER, or http://localhost.
+The token, an API token or JWT sent to servers which require authentication, defaults to $DIAGO_TOKEN.
+
+Exit codes:
+  0  success, or the waited for instance passed
+  1  the command failed
+  2  invalid usage
+  3  the waited for instance did not pass
+`
+
+const (
+	exitSuccess   = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitNotPassed = 3
+)
+
+// errUsage is returned by commands called with invalid arguments
+var errUsage = errors.New("invalid usage")
+
+// errNotPassed is returned when the instance waited for did not pass
+var errNotPassed = errors.New("test instance did not pass")
+
+// command runs with the arguments following its name and writes its output to out
+type command func(c *client, args []string, out io.Writer) error
+
+// Commands which act on a kind of object, such as tests list
+var resourceCommands = map[string]map[string]command{
+	"tests": {
+		"list":      runTestsList,
+		"get":       runTestsGet,
+		"create":    runTestsCreate,
+		"delete":    runTestsDelete,
+		"revisions": runTestsRevisions,
+	},
+	"instances": {
+		"list":   runInstancesList,
+		"delete": runInstancesDelete,
+	},
+	"schedules": {
+		"list":   runSchedulesList,
+		"get":    runSchedulesGet,
+		"create": runSchedulesCreate,
+		"delete": runSchedulesDelete,
+	},
+}
+
+var commands = map[string]command{
+	"apply":  runApply,
+	"start":  runStart,
+	"stop":   runStop,
+	"follow": runFollow,
+	"wait":   runWait,
+	"report": runReport,
+}
+
+func main() {
+	server := os.Getenv("DIAGO_SERVER")
+	if server == "" {
+		server = "http://localhost"
+	}
+
+	flag.StringVar(&server, "server", server, "URL of the Diago leader")
+	token := flag.String("token", os.Getenv("DIAGO_TOKEN"), "API token or JWT of the caller")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	os.Exit(run(newClient(server, *token), flag.Args(), os.Stdout))
+}
+
+// Internal function used to run the command named by args, returning the exit code
+func run(c *client, args []string, out io.Writer) int {
+	cmd, args := lookup(args)
+	if cmd == nil {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	switch err := cmd(c, args, out); err {
+	case nil:
+		return exitSuccess
+	case errUsage:
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	case errNotPassed:
+		fmt.Fprintln(os.Stderr, err)
+		return exitNotPassed
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+}
+
+// Internal function used to find the command named by args, and the arguments which follow its name
+func lookup(args []string) (command, []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if cmd, exists := commands[args[0]]; exists {
+		return cmd, args[1:]
+	}
+	if len(args) < 2 {
+		return nil, nil
+	}
+	if cmd, exists := resourceCommands[args[0]][args[1]]; exists {
+		return cmd, args[2:]
+	}
+	return nil, nil
+}
+
+// Internal function used to parse the flags of a command, which must be followed by nargs arguments
+func parseFlags(flags *flag.FlagSet, args []string, nargs int) error {
+	flags.SetOutput(ioutil.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != nargs {
+		return errUsage
+	}
+	return nil
+}
+
+// Internal function used to read the file named by path, or stdin for -
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// fakeAPI serves a Test whose instance finishes with status and verdict after a few polls
+type fakeAPI struct {
+	status  string
+	verdict m.Verdict
+	polls   int
+	started bool
+	stopped bool
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	success := func(payload interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "payload": payload})
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "POST /api/tests/Test1/start":
+		api.started = true
+		success("Successfully submitted Test<Test1>")
+	case "POST /api/tests/Test1/stop":
+		api.stopped = true
+		success("Successfully stopped Test<Test1>")
+	case "GET /api/test-instances":
+		if r.FormValue("testid") != "Test1" || !api.started {
+			success([]*m.TestInstance{})
+			return
+		}
+		instance := &m.TestInstance{ID: "Test1-1618100600", TestID: "Test1", Status: "running", CreatedAt: 1618100600}
+		if api.polls++; api.polls > 2 {
+			instance.Status = api.status
+			instance.Verdict = api.verdict
+		}
+		success([]*m.TestInstance{instance})
+	case "GET /api/test-instances/Test1-1618100600/timeseries":
+		start := time.Unix(1618100600, 0)
+		success(map[string]interface{}{
+			"total": &metrics.TimeSeries{
+				Interval: time.Second,
+				Buckets: []*metrics.Bucket{
+					{Start: start, Requests: 5, Successes: 5, Success: 1},
+					{Start: start.Add(time.Second), Requests: 4, Successes: 3, Success: 0.75, Errors: 1},
+				},
+			},
+		})
+	case "GET /api/test-instances/Test1-1618100600/report":
+		w.Header().Set("Content-Disposition", `attachment; filename="Test1-1618100600.html"`)
+		w.Write([]byte("<html></html>"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   map[string]interface{}{"code": http.StatusNotFound, "message": "Not found"},
+		})
+	}
+}
+
+func runAgainst(api *fakeAPI, args ...string) (int, string) {
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	var out bytes.Buffer
+	code := run(newClient(server.URL, ""), args, &out)
+	return code, out.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"tests"}, {"tests", "unknown"}, {"start"}, {"wait", "-unknown", "x"}} {
+		if code, _ := runAgainst(&fakeAPI{}, args...); code != exitUsage {
+			t.Errorf("Expected %v to be invalid usage, got exit code %d", args, code)
+		}
+	}
+
+	if code, _ := runAgainst(&fakeAPI{}, "tests", "get", "Missing"); code != exitError {
+		t.Errorf("Expected a failed request to exit with %d, got %d", exitError, code)
+	}
+}
+
+func TestRunStartAndWait(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	tests := []struct {
+		name    string
+		status  string
+		verdict m.Verdict
+		code    int
+	}{
+		{"done", "done", "", exitSuccess},
+		{"passed", "done", m.VerdictPassed, exitSuccess},
+		{"failed criteria", "done", m.VerdictFailed, exitNotPassed},
+		{"aborted", "aborted", "", exitNotPassed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{status: tt.status, verdict: tt.verdict}
+			code, out := runAgainst(api, "start", "-wait", "Test1")
+			if code != tt.code {
+				t.Errorf("Expected exit code %d, got %d: %s", tt.code, code, out)
+			}
+			if !strings.Contains(out, "Started TestInstance<Test1-1618100600>") {
+				t.Errorf("Expected the started instance to be printed, got %s", out)
+			}
+		})
+	}
+}
+
+func TestRunStartFollow(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	api := &fakeAPI{status: "done"}
+	code, out := runAgainst(api, "start", "-follow", "Test1")
+	if code != exitSuccess {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitSuccess, code, out)
+	}
+
+	// Each interval is printed once, the latest only when the instance finished
+	for _, row := range []string{"100.0%", "75.0%"} {
+		if strings.Count(out, row) != 1 {
+			t.Errorf("Expected one row with %s, got %s", row, out)
+		}
+	}
+}
+
+func TestRunWaitTimeout(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	api := &fakeAPI{status: "stopped", started: true}
+	if code, _ := runAgainst(api, "wait", "-timeout", "1ns", "Test1-1618100600"); code != exitNotPassed {
+		t.Errorf("Expected a stopped instance not to pass, got exit code %d", code)
+	}
+	if !api.stopped {
+		t.Error("Expected the instance to be stopped after the timeout")
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagoctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	api := &fakeAPI{started: true}
+	if code, out := runAgainst(api, "report", "-format", "html", "Test1-1618100600"); code != exitSuccess {
+		t.Fatalf("Expected the report to be downloaded, got %s", out)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "Test1-1618100600.html")); err != nil || string(content) != "<html></html>" {
+		t.Errorf("Expected the report to be saved under its name, got %q", content)
+	}
+
+	if code, out := runAgainst(api, "report", "-o", "-", "Test1-1618100600"); code != exitSuccess || out != "<html></html>" {
+		t.Errorf("Expected the report on stdout, got %q", out)
+	}
+
+	if code, _ := runAgainst(api, "report", "Missing-1"); code != exitError {
+		t.Errorf("Expected a missing report to fail, got exit code %d", code)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only the report to be saved, got %d files", len(files))
+	}
+}
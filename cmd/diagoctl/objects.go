@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func runTestsList(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tests list", flag.ContinueOnError)
+	prefix := flags.String("prefix", "", "only list tests whose ID starts with PREFIX")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	path := "/tests"
+	if *prefix != "" {
+		path += "?prefix=" + url.QueryEscape(*prefix)
+	}
+	tests := []*m.Test{}
+	if err := c.do(http.MethodGet, path, nil, &tests); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tREVISION\tJOBS\tMANIFEST")
+	for _, test := range tests {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", test.ID, test.Revision, len(test.Jobs), test.Manifest)
+	}
+	return tw.Flush()
+}
+
+func runTestsGet(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return get(c, "/tests/"+url.PathEscape(args[0]), out)
+}
+
+func runTestsCreate(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var created struct {
+		TestID   string `json:"testid"`
+		Revision int    `json:"revision"`
+	}
+	if err := create(c, "/tests", args[0], &created); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created Test<%s> at revision %d\n", created.TestID, created.Revision)
+	return nil
+}
+
+func runTestsDelete(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := c.do(http.MethodDelete, "/tests/"+url.PathEscape(args[0]), nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deleted Test<%s>\n", args[0])
+	return nil
+}
+
+func runTestsRevisions(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return get(c, "/tests/"+url.PathEscape(args[0])+"/revisions", out)
+}
+
+func runInstancesList(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("instances list", flag.ContinueOnError)
+	testID := flags.String("test", "", "only list instances of TESTID")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	path := "/test-instances"
+	if *testID != "" {
+		path += "?testid=" + url.QueryEscape(*testID)
+	}
+	instances := []*m.TestInstance{}
+	if err := c.do(http.MethodGet, path, nil, &instances); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTEST\tTYPE\tSTATUS\tVERDICT\tCREATED")
+	for _, instance := range instances {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			instance.ID, instance.TestID, instance.Type, instance.Status, instance.Verdict,
+			time.Unix(instance.CreatedAt, 0).Format(time.RFC3339),
+		)
+	}
+	return tw.Flush()
+}
+
+func runInstancesDelete(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := c.do(http.MethodDelete, "/test-instances/"+url.PathEscape(args[0]), nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deleted TestInstance<%s>\n", args[0])
+	return nil
+}
+
+func runSchedulesList(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("schedules list", flag.ContinueOnError)
+	testID := flags.String("test", "", "only list schedules of TESTID")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	path := "/test-schedules"
+	if *testID != "" {
+		path += "?testid=" + url.QueryEscape(*testID)
+	}
+	schedules := []*m.TestSchedule{}
+	if err := c.do(http.MethodGet, path, nil, &schedules); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTEST\tCRONSPEC\tREVISION\tMANIFEST")
+	for _, schedule := range schedules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n",
+			schedule.ID, schedule.TestID, schedule.CronSpec, schedule.Revision, schedule.Manifest,
+		)
+	}
+	return tw.Flush()
+}
+
+func runSchedulesGet(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return get(c, "/test-schedules/"+url.PathEscape(args[0]), out)
+}
+
+func runSchedulesCreate(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var created struct {
+		ScheduleID string `json:"scheduleid"`
+	}
+	if err := create(c, "/test-schedules", args[0], &created); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created TestSchedule<%s>\n", created.ScheduleID)
+	return nil
+}
+
+func runSchedulesDelete(c *client, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := c.do(http.MethodDelete, "/test-schedules/"+url.PathEscape(args[0]), nil, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deleted TestSchedule<%s>\n", args[0])
+	return nil
+}
+
+// applied is the payload of the apply API
+type applied struct {
+	Manifest string `json:"manifest"`
+	DryRun   bool   `json:"dryrun"`
+	Changes  []struct {
+		Kind   string
+		Name   string
+		Action string
+		Fields []struct {
+			Path   string
+			Before interface{}
+			After  interface{}
+		}
+	} `json:"changes"`
+}
+
+func runApply(c *client, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only print the changes")
+	prune := flags.Bool("prune", false, "delete the objects of the manifest it no longer declares")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	manifest, err := readInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("/apply?dryRun=%t&prune=%t", *dryRun, *prune)
+	var result applied
+	if err := c.do(http.MethodPost, path, bytes.NewReader(manifest), &result); err != nil {
+		return err
+	}
+
+	for _, change := range result.Changes {
+		fmt.Fprintf(out, "%s %s<%s>\n", change.Action, change.Kind, change.Name)
+		if change.Action != "update" {
+			continue
+		}
+		for _, field := range change.Fields {
+			fmt.Fprintf(out, "  %s: %s -> %s\n", field.Path, formatValue(field.Before), formatValue(field.After))
+		}
+	}
+	if result.DryRun {
+		fmt.Fprintln(out, "Nothing was applied (dry run)")
+	}
+	return nil
+}
+
+// Internal function used to print the payload of a GET as indented JSON
+func get(c *client, path string, out io.Writer) error {
+	var payload json.RawMessage
+	if err := c.do(http.MethodGet, path, nil, &payload); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+// Internal function used to POST the object of a JSON or YAML file
+func create(c *client, path string, file string, payload interface{}) error {
+	data, err := readInput(file)
+	if err != nil {
+		return err
+	}
+	body, err := yaml.ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, path, bytes.NewReader(body), payload)
+}
+
+// Internal function used to format a value of a field change, absent values are shown as -
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
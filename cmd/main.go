@@ -3,14 +3,18 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/t-bfame/diago/cmd/server"
 	"github.com/t-bfame/diago/config"
+	"github.com/t-bfame/diago/pkg/auth"
 	"github.com/t-bfame/diago/pkg/chaosmgr"
 	"github.com/t-bfame/diago/pkg/manager"
 	"github.com/t-bfame/diago/pkg/scheduler"
 	"github.com/t-bfame/diago/pkg/storage"
+	"github.com/t-bfame/diago/pkg/webhook"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
@@ -20,7 +24,11 @@ import (
 func main() {
 	config.Init()
 
-	if err := storage.InitDatabase(config.Diago.StoragePath); err != nil {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	if err := storage.Init(config.Diago.StorageBackend, config.Diago.StoragePath); err != nil {
 		panic("Failed to init database.")
 	}
 
@@ -28,18 +36,56 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	// Instances of a previous leader cannot continue without their job state
+	if err := manager.RecoverInstances(); err != nil {
+		log.WithError(err).Error("Failed to recover test instances")
+	}
+
+	retention := manager.RetentionPolicy{
+		KeepLast:   int(config.Diago.RetentionKeepLast),
+		MaxAge:     time.Duration(config.Diago.RetentionMaxAge) * time.Hour,
+		KeepFailed: config.Diago.RetentionKeepFailed,
+	}
+	if retention.Enabled() {
+		janitor := manager.NewJanitor(retention, time.Duration(config.Diago.RetentionInterval)*time.Second)
+		go janitor.Run(nil)
+	}
+
 	s := scheduler.NewScheduler()
 	cm := chaosmgr.NewChaosManager()
+
+	go func() {
+		grace := time.Duration(config.Diago.RecoveryGracePeriod) * time.Second
+		if err := s.ReconcileWorkers(grace); err != nil {
+			log.WithError(err).Error("Failed to reconcile workers")
+		}
+	}()
+
+	authenticator, err := auth.FromConfig(config.Diago)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to configure authentication")
+	} else if authenticator == nil {
+		log.Warn("Authentication is disabled, anyone who can reach the API may use it")
+	}
+
 	var opts []grpc.ServerOption
 
 	router := mux.NewRouter()
 
 	go func() {
-		jf := manager.NewJobFunnel(s, cm)
-		sm := manager.NewScheduleManager(jf)
+		dispatcher := webhook.NewDispatcher(webhook.Options{
+			Timeout:     time.Duration(config.Diago.WebhookTimeout) * time.Second,
+			MaxAttempts: int(config.Diago.WebhookMaxAttempts),
+			Backoff:     time.Duration(config.Diago.WebhookBackoff) * time.Second,
+		})
+		jf := manager.NewJobFunnel(s, cm, dispatcher)
+		sm := manager.NewScheduleManager(jf, dispatcher)
 
 		// Set prefix for api paths
 		apiRouter := router.PathPrefix("/api").Subrouter()
+		if authenticator != nil {
+			apiRouter.Use(server.Authenticate(authenticator))
+		}
 		apiServer := server.NewAPIServer(jf, sm)
 		apiServer.Start(apiRouter)
 
@@ -1,17 +1,24 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"github.com/t-bfame/diago/pkg/auth"
 	dash "github.com/t-bfame/diago/pkg/dashboard"
 	mgr "github.com/t-bfame/diago/pkg/manager"
+	"github.com/t-bfame/diago/pkg/manifest"
+	"github.com/t-bfame/diago/pkg/metrics"
 	m "github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/report"
 	sto "github.com/t-bfame/diago/pkg/storage"
 )
 
@@ -79,33 +86,201 @@ func handleTestCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testid := test.Name
-	test.ID = m.TestID(testid)
+	test.AssignIDs()
+	testid := string(test.ID)
+	if !allowProject(w, r, test.Project) || !checkProject(w, &test) {
+		return
+	}
+
+	// Tests are changed with PUT or PATCH, so their revisions are not lost
+	existing, err := sto.GetTestByTestId(test.ID)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if existing != nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Test<%s> already exists", testid),
+			http.StatusConflict,
+			w,
+		))
+		return
+	}
+
+	// Only manifests own the tests they apply
+	test.Manifest = ""
+	test.CreatedBy = actor(r)
+	test.UpdatedBy = test.CreatedBy
+	err = sto.SaveTest(&test, 0)
+	if _, ok := err.(*sto.RevisionConflictError); ok {
+		w.Write(buildFailure(
+			fmt.Sprintf("Test<%s> already exists", testid),
+			http.StatusConflict,
+			w,
+		))
+		return
+	} else if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Header().Set("ETag", revisionETag(test.Revision))
+	w.Write(
+		buildSuccess(
+			map[string]string{
+				"testid":   testid,
+				"revision": strconv.Itoa(test.Revision),
+			},
+			w,
+		),
+	)
+}
+
+func handleTestUpdate(w http.ResponseWriter, r *http.Request) {
+	testid := mux.Vars(r)["testid"]
+
+	bodyContent, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	current, err := sto.GetTestByTestId(m.TestID(testid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if current == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find Test<%s>", testid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// PATCH merges the body into the current test
+	if r.Method == http.MethodPatch {
+		bodyContent, err = mergePatchJSON(current, bodyContent)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+	}
+
+	err = m.Validate(reflect.TypeOf(m.Test{}), bodyContent)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	var test m.Test
+	err = json.Unmarshal(bodyContent, &test)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
 
-	for i := range test.Jobs {
-		test.Jobs[i].ID = m.JobID(fmt.Sprintf("%s-%d", test.ID, i))
+	if test.Name == "" {
+		test.Name = current.Name
+	} else if test.Name != current.Name {
+		w.Write(buildFailure(
+			fmt.Sprintf("Name of Test<%s> cannot change", testid),
+			http.StatusBadRequest,
+			w,
+		))
+		return
 	}
 
-	for i := range test.Chaos {
-		test.Chaos[i].ID = m.ChaosID(fmt.Sprintf("%s-%d", test.ID, i))
+	if test.Project = m.NormalizeProject(test.Project); test.Project == "" {
+		test.Project = current.Project
+	} else if test.Project != current.Project {
+		w.Write(buildFailure(
+			fmt.Sprintf("Project of Test<%s> cannot change", testid),
+			http.StatusBadRequest,
+			w,
+		))
+		return
 	}
 
-	err = sto.AddTest(&test)
+	expected, err := expectedRevision(r, test.Revision)
 	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	test.AssignIDs()
+	if !checkProject(w, &test) {
+		return
+	}
+	test.Manifest = current.Manifest
+	test.CreatedBy = current.CreatedBy
+	test.UpdatedBy = actor(r)
+	err = sto.SaveTest(&test, expected)
+	if _, ok := err.(*sto.RevisionConflictError); ok {
+		w.Write(buildFailure(
+			fmt.Sprintf("Test<%s> was modified: %s", testid, err),
+			http.StatusPreconditionFailed,
+			w,
+		))
+		return
+	} else if err != nil {
 		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
 		return
 	}
 
+	w.Header().Set("ETag", revisionETag(test.Revision))
 	w.Write(
 		buildSuccess(
 			map[string]string{
-				"testid": testid,
+				"testid":   testid,
+				"revision": strconv.Itoa(test.Revision),
 			},
 			w,
 		),
 	)
 }
 
+func handleTestRevisionReadAll(w http.ResponseWriter, r *http.Request) {
+	testid := mux.Vars(r)["testid"]
+
+	tests, err := sto.GetTestRevisions(m.TestID(testid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+	w.Write(buildSuccess(tests, w))
+}
+
+func handleTestRevisionRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	testid := vars["testid"]
+
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Invalid revision %s", vars["revision"]),
+			http.StatusBadRequest,
+			w,
+		))
+		return
+	}
+
+	test, err := sto.GetTestRevision(m.TestID(testid), revision)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if test == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find revision %d of Test<%s>", revision, testid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	w.Header().Set("ETag", revisionETag(test.Revision))
+	w.Write(buildSuccess(test, w))
+}
+
 func handleTestReadForPrefix(w http.ResponseWriter, r *http.Request) {
 	prefix := r.FormValue("prefix")
 	tests, err := sto.GetAllTestsWithPrefix(prefix)
@@ -115,7 +290,7 @@ func handleTestReadForPrefix(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	w.Write(buildSuccess(tests, w))
+	w.Write(buildSuccess(visibleTests(r, tests), w))
 }
 
 func handleTestReadAll(w http.ResponseWriter, r *http.Request) {
@@ -126,7 +301,7 @@ func handleTestReadAll(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	w.Write(buildSuccess(tests, w))
+	w.Write(buildSuccess(visibleTests(r, tests), w))
 }
 
 func handleTestRead(w http.ResponseWriter, r *http.Request) {
@@ -146,6 +321,7 @@ func handleTestRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", revisionETag(test.Revision))
 	w.Write(buildSuccess(test, w))
 }
 
@@ -189,7 +365,7 @@ func handleTestStartBuilder(
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		testid := vars["testid"]
-		err := server.jf.BeginTest(m.TestID(testid), "adhoc")
+		err := server.jf.BeginTest(m.TestID(testid), "adhoc", actor(r))
 		if err != nil {
 			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
 			return
@@ -243,7 +419,251 @@ func handleTestInstanceReadAll(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	w.Write(buildSuccess(tis, w))
+	w.Write(buildSuccess(visibleTestInstances(r, tis), w))
+}
+
+func handleTestInstanceDelete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceid := vars["instanceid"]
+
+	instance, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if instance == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Running instances are still written to by their jobs
+	if !instance.IsTerminal() {
+		w.Write(buildFailure(
+			fmt.Sprintf("TestInstance<%s> has not finished", instanceid),
+			http.StatusConflict,
+			w,
+		))
+		return
+	}
+
+	if err := sto.DeleteTestInstance(instance.ID); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(
+		buildSuccess(
+			map[string]string{
+				"instanceid": instanceid,
+			},
+			w,
+		),
+	)
+}
+
+func handleTestInstanceTimeSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceid := vars["instanceid"]
+
+	interval := metrics.TimeSeriesInterval
+	if value := r.FormValue("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < metrics.TimeSeriesInterval || parsed%metrics.TimeSeriesInterval != 0 {
+			w.Write(buildFailure(
+				fmt.Sprintf("Interval must be a multiple of %s", metrics.TimeSeriesInterval),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+		interval = parsed
+	}
+
+	instance, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if instance == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Instances which have not recorded any metrics yet have empty time series
+	jobMetrics, _ := instance.Metrics.(map[string]*metrics.Metrics)
+	job := r.FormValue("job")
+
+	jobs := map[string]*metrics.TimeSeries{}
+	all := []*metrics.TimeSeries{}
+	for jobID, jm := range jobMetrics {
+		if jm == nil || (job != "" && jobID != job) {
+			continue
+		}
+		jobs[jobID] = metrics.Resample(interval, jm.TimeSeries)
+		all = append(all, jm.TimeSeries)
+	}
+
+	w.Write(
+		buildSuccess(
+			map[string]interface{}{
+				"interval": interval,
+				"jobs":     jobs,
+				"total":    metrics.Resample(interval, all...),
+			},
+			w,
+		),
+	)
+}
+
+func handleTestInstanceCompare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceid := vars["instanceid"]
+
+	tolerances := metrics.DefaultTolerances
+	for name, tolerance := range map[string]*float64{
+		"latency":      &tolerances.Latency,
+		"throughput":   &tolerances.Throughput,
+		"success":      &tolerances.Success,
+		"status_codes": &tolerances.StatusCodes,
+		"confidence":   &tolerances.Confidence,
+	} {
+		value := r.FormValue(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || (name == "confidence" && (parsed <= 0 || parsed >= 1)) {
+			w.Write(buildFailure(fmt.Sprintf("Invalid tolerance %s=%s", name, value), http.StatusBadRequest, w))
+			return
+		}
+		*tolerance = parsed
+	}
+
+	candidate, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if candidate == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Instances are compared to the baseline of their Test by default
+	var baseline *m.TestInstance
+	if baselineid := r.FormValue("baseline"); baselineid != "" {
+		baseline, err = sto.GetTestInstance(m.TestInstanceID(baselineid))
+	} else {
+		baseline, err = sto.GetBaseline(candidate.TestID)
+	}
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if baseline == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find baseline for TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	w.Write(buildSuccess(metrics.Compare(baseline, candidate, tolerances), w))
+}
+
+func handleTestInstanceSetBaseline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceid := vars["instanceid"]
+
+	instance, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if instance == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	if !instance.IsTerminal() {
+		w.Write(buildFailure(
+			fmt.Sprintf("TestInstance<%s> has not finished", instanceid),
+			http.StatusBadRequest,
+			w,
+		))
+		return
+	}
+
+	if err := sto.SetBaseline(instance.ID); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(
+		buildSuccess(
+			fmt.Sprintf("TestInstance<%s> is the baseline of Test<%s>", instanceid, instance.TestID),
+			w,
+		),
+	)
+}
+
+func handleTestInstanceReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceid := vars["instanceid"]
+
+	format := report.FormatJSON
+	if value := r.FormValue("format"); value != "" {
+		parsed, err := report.ParseFormat(value)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+		format = parsed
+	}
+
+	instance, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if instance == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Reports of instances whose Test was deleted name jobs by their JobID
+	test, err := testOfInstance(instance)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	rep := report.New(test, instance)
+
+	var buf bytes.Buffer
+	if err := rep.Render(&buf, format); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rep.Filename(format)))
+	w.Write(buf.Bytes())
 }
 
 func handleTestScheduleCreateBuilder(
@@ -292,7 +712,24 @@ func handleTestScheduleCreateBuilder(
 			return
 		}
 
-		schedule.ID = m.TestScheduleID(schedule.Name)
+		// Schedules run Tests of their own project
+		if schedule.Project = m.NormalizeProject(schedule.Project); schedule.Project == "" {
+			schedule.Project = test.Project
+		}
+		if schedule.Project != test.Project {
+			w.Write(buildFailure(
+				fmt.Sprintf("TestSchedule of Project<%s> cannot run Test<%s>", m.ProjectName(schedule.Project), test.ID),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+		if !allowProject(w, r, schedule.Project) {
+			return
+		}
+
+		schedule.AssignID()
+		schedule.Revision = 1
 		if err := server.sm.Add(&schedule, true); err != nil {
 			w.Write(
 				buildFailure(err.Error(), http.StatusInternalServerError, w),
@@ -311,6 +748,149 @@ func handleTestScheduleCreateBuilder(
 	}
 }
 
+func handleTestScheduleRead(w http.ResponseWriter, r *http.Request) {
+	scheduleid := mux.Vars(r)["scheduleid"]
+
+	schedule, err := sto.GetTestSchedule(m.TestScheduleID(scheduleid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if schedule == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find TestSchedule<%s>", scheduleid),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	w.Header().Set("ETag", revisionETag(schedule.Revision))
+	w.Write(buildSuccess(schedule, w))
+}
+
+func handleTestScheduleUpdateBuilder(
+	server *APIServer,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleid := mux.Vars(r)["scheduleid"]
+
+		bodyContent, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		current, err := sto.GetTestSchedule(m.TestScheduleID(scheduleid))
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		} else if current == nil {
+			w.Write(buildFailure(
+				fmt.Sprintf("Cannot find TestSchedule<%s>", scheduleid),
+				http.StatusNotFound,
+				w,
+			))
+			return
+		}
+
+		// PATCH merges the body into the current schedule
+		if r.Method == http.MethodPatch {
+			bodyContent, err = mergePatchJSON(current, bodyContent)
+			if err != nil {
+				w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+				return
+			}
+		}
+
+		err = m.Validate(reflect.TypeOf(m.TestSchedule{}), bodyContent)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		var schedule m.TestSchedule
+		err = json.Unmarshal(bodyContent, &schedule)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		if schedule.Name != current.Name {
+			w.Write(buildFailure(
+				fmt.Sprintf("Name of TestSchedule<%s> cannot change", scheduleid),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+
+		// make sure cron spec is valid
+		err = server.sm.ValidateSpec(schedule.CronSpec)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		// make sure specified Test exists
+		test, err := sto.GetTestByTestId(schedule.TestID)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		} else if test == nil {
+			w.Write(buildFailure(
+				fmt.Sprintf("Cannot find Test<%s>", schedule.TestID),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+
+		// Schedules run Tests of their own project, which cannot change
+		if schedule.Project = m.NormalizeProject(schedule.Project); schedule.Project == "" {
+			schedule.Project = current.Project
+		}
+		if schedule.Project != current.Project || test.Project != current.Project {
+			w.Write(buildFailure(
+				fmt.Sprintf("TestSchedule<%s> can only run Tests of Project<%s>", scheduleid, m.ProjectName(current.Project)),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+
+		expected, err := expectedRevision(r, schedule.Revision)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		schedule.ID = m.TestScheduleID(scheduleid)
+		err = server.sm.Update(&schedule, expected)
+		if _, ok := err.(*sto.RevisionConflictError); ok {
+			w.Write(buildFailure(
+				fmt.Sprintf("TestSchedule<%s> was modified: %s", scheduleid, err),
+				http.StatusPreconditionFailed,
+				w,
+			))
+			return
+		} else if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		}
+
+		w.Header().Set("ETag", revisionETag(schedule.Revision))
+		w.Write(
+			buildSuccess(
+				map[string]string{
+					"scheduleid": scheduleid,
+					"revision":   strconv.Itoa(schedule.Revision),
+				},
+				w,
+			),
+		)
+	}
+}
+
 func handleTestScheduleReadForTest(w http.ResponseWriter, r *http.Request) {
 	testid := r.FormValue("testid")
 
@@ -335,7 +915,7 @@ func handleTestScheduleReadAll(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	w.Write(buildSuccess(schedules, w))
+	w.Write(buildSuccess(visibleTestSchedules(r, schedules), w))
 }
 
 func handleTestScheduleDeleteBuilder(
@@ -381,39 +961,249 @@ func handleTestScheduleDeleteBuilder(
 	}
 }
 
+func handleBackup(w http.ResponseWriter, r *http.Request) {
+	name := "diago-" + time.Now().UTC().Format("20060102T150405")
+
+	switch format := r.FormValue("format"); format {
+	case "", "snapshot":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".db"))
+
+		// The snapshot is streamed, so a failure cannot be reported once it started
+		if err := sto.Snapshot(w); err != nil {
+			log.WithError(err).Error("Failed to stream storage snapshot")
+		}
+	case "json":
+		bundle, err := sto.Export()
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		}
+
+		body, err := json.Marshal(bundle)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		w.Write(body)
+	default:
+		w.Write(buildFailure(fmt.Sprintf("Unknown backup format %s", format), http.StatusBadRequest, w))
+	}
+}
+
+func handleRestoreBuilder(
+	server *APIServer,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy, err := sto.ParseConflictPolicy(r.FormValue("conflict"))
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		bundle, err := sto.ReadBundle(r.Body)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		result, err := sto.Import(bundle, policy)
+		if _, ok := err.(*sto.ConflictError); ok {
+			w.Write(buildFailure(err.Error(), http.StatusConflict, w))
+			return
+		} else if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		}
+
+		// Restored schedules replace the ones the ScheduleManager is running
+		schedules := make(map[string]*m.TestSchedule, len(bundle.TestSchedules))
+		for _, schedule := range bundle.TestSchedules {
+			schedules[string(schedule.ID)] = schedule
+		}
+		for _, id := range result.TestSchedules.Replaced {
+			// Fails if the replaced schedule was not running, which leaves nothing to stop
+			server.sm.Remove(m.TestScheduleID(id))
+			if err := server.sm.Add(schedules[id], true); err != nil {
+				log.WithError(err).WithField("TestScheduleID", id).Error("Failed to start restored schedule")
+			}
+		}
+		for _, id := range result.TestSchedules.Added {
+			if err := server.sm.Add(schedules[id], false); err != nil {
+				log.WithError(err).WithField("TestScheduleID", id).Error("Failed to start restored schedule")
+			}
+		}
+
+		restored := []m.TestInstanceID{}
+		for _, ids := range [][]string{result.TestInstances.Added, result.TestInstances.Replaced} {
+			for _, id := range ids {
+				restored = append(restored, m.TestInstanceID(id))
+			}
+		}
+		if err := mgr.RecoverRestoredInstances(restored); err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		}
+
+		w.Write(buildSuccess(result, w))
+	}
+}
+
+func handleApplyBuilder(
+	server *APIServer,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := r.FormValue("dryRun") == "true"
+		prune := r.FormValue("prune") == "true"
+
+		mf, err := manifest.Parse(r.Body)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		if !allowProject(w, r, mf.Project) {
+			return
+		}
+
+		if err := mf.Check(server.jf.GroupExists, server.sm.ValidateSpec, prune); err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		plan, err := manifest.NewPlan(mf, prune)
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+			return
+		}
+
+		if !dryRun {
+			err := plan.Apply(server.sm, actor(r))
+			if _, ok := err.(*sto.RevisionConflictError); ok {
+				w.Write(buildFailure(err.Error(), http.StatusConflict, w))
+				return
+			} else if err != nil {
+				w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+				return
+			}
+		}
+
+		w.Write(
+			buildSuccess(
+				map[string]interface{}{
+					"manifest": plan.Manifest,
+					"dryrun":   dryRun,
+					"changes":  plan.Changes,
+				},
+				w,
+			),
+		)
+	}
+}
+
 // Start starts the APIServer
 func (server *APIServer) Start(router *mux.Router) {
 	router.Use(preResponse)
 
+	// Routes require the role of the callers they allow, see Authenticate.
+	// Calls of the routes which change objects or start tests are audited.
+
 	// tests
-	router.HandleFunc("/tests", handleTestCreate).Methods(http.MethodPost)
-	router.HandleFunc("/tests", handleTestReadForPrefix).Methods(http.MethodGet).
+	router.Handle("/tests", audited(m.AuditTestCreate, requireRole(auth.RoleAdmin, handleTestCreate))).
+		Methods(http.MethodPost)
+	router.HandleFunc("/tests", requireRole(auth.RoleViewer, handleTestReadForPrefix)).Methods(http.MethodGet).
 		Queries("prefix", "{prefix}")
-	router.HandleFunc("/tests", handleTestReadAll).Methods(http.MethodGet)
-	router.HandleFunc("/tests/{testid}", handleTestRead).Methods(http.MethodGet)
-	router.HandleFunc("/tests/{testid}", handleTestDelete).Methods(http.MethodDelete)
-	router.HandleFunc("/tests/{testid}/start", handleTestStartBuilder(server)).
+	router.HandleFunc("/tests", requireRole(auth.RoleViewer, handleTestReadAll)).Methods(http.MethodGet)
+	router.HandleFunc("/tests/{testid}", requireRole(auth.RoleViewer, handleTestRead)).Methods(http.MethodGet)
+	router.Handle("/tests/{testid}", audited(m.AuditTestUpdate, requireRole(auth.RoleAdmin, handleTestUpdate))).
+		Methods(http.MethodPut, http.MethodPatch)
+	router.Handle("/tests/{testid}", audited(m.AuditTestDelete, requireRole(auth.RoleAdmin, handleTestDelete))).
+		Methods(http.MethodDelete)
+	router.HandleFunc("/tests/{testid}/revisions", requireRole(auth.RoleViewer, handleTestRevisionReadAll)).Methods(http.MethodGet)
+	router.HandleFunc("/tests/{testid}/revisions/{revision}", requireRole(auth.RoleViewer, handleTestRevisionRead)).
+		Methods(http.MethodGet)
+	router.Handle("/tests/{testid}/start", audited(m.AuditTestStart, requireRole(auth.RoleRunner, handleTestStartBuilder(server)))).
 		Methods(http.MethodPost)
-	router.HandleFunc("/tests/{testid}/stop", handleTestStopBuilder(server)).
+	router.Handle("/tests/{testid}/stop", audited(m.AuditTestStop, requireRole(auth.RoleRunner, handleTestStopBuilder(server)))).
 		Methods(http.MethodPost)
 
 	// test-instances
-	router.HandleFunc("/test-instances", handleTestInstanceReadForTest).
+	router.HandleFunc("/test-instances", requireRole(auth.RoleViewer, handleTestInstanceReadForTest)).
 		Methods(http.MethodGet).Queries("testid", "{testid}")
-	router.HandleFunc("/test-instances", handleTestInstanceReadAll).Methods(http.MethodGet)
+	router.HandleFunc("/test-instances", requireRole(auth.RoleViewer, handleTestInstanceReadAll)).Methods(http.MethodGet)
+	router.Handle("/test-instances/{instanceid}", audited(m.AuditTestInstanceDelete, requireRole(auth.RoleAdmin, handleTestInstanceDelete))).
+		Methods(http.MethodDelete)
+	router.HandleFunc("/test-instances/{instanceid}/timeseries", requireRole(auth.RoleViewer, handleTestInstanceTimeSeries)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/test-instances/{instanceid}/compare", requireRole(auth.RoleViewer, handleTestInstanceCompare)).
+		Methods(http.MethodGet)
+	router.Handle("/test-instances/{instanceid}/baseline", audited(m.AuditTestInstanceBaseline, requireRole(auth.RoleRunner, handleTestInstanceSetBaseline))).
+		Methods(http.MethodPost)
+	router.HandleFunc("/test-instances/{instanceid}/report", requireRole(auth.RoleViewer, handleTestInstanceReport)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/test-instances/{instanceid}/live", requireRole(auth.RoleViewer, handleTestInstanceLiveBuilder(server))).
+		Methods(http.MethodGet)
 
 	// test-schedules
-	router.HandleFunc("/test-schedules", handleTestScheduleCreateBuilder(server)).
+	router.Handle("/test-schedules", audited(m.AuditTestScheduleCreate, requireRole(auth.RoleAdmin, handleTestScheduleCreateBuilder(server)))).
 		Methods(http.MethodPost)
-	router.HandleFunc("/test-schedules", handleTestScheduleReadForTest).
+	router.HandleFunc("/test-schedules", requireRole(auth.RoleViewer, handleTestScheduleReadForTest)).
 		Methods(http.MethodGet).Queries("testid", "{testid}")
-	router.HandleFunc("/test-schedules", handleTestScheduleReadAll).
+	router.HandleFunc("/test-schedules", requireRole(auth.RoleViewer, handleTestScheduleReadAll)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/test-schedules/{scheduleid}", requireRole(auth.RoleViewer, handleTestScheduleRead)).
+		Methods(http.MethodGet)
+	router.Handle("/test-schedules/{scheduleid}", audited(m.AuditTestScheduleUpdate, requireRole(auth.RoleAdmin, handleTestScheduleUpdateBuilder(server)))).
+		Methods(http.MethodPut, http.MethodPatch)
+	router.Handle("/test-schedules/{scheduleid}", audited(m.AuditTestScheduleDelete, requireRole(auth.RoleAdmin, handleTestScheduleDeleteBuilder(server)))).
+		Methods(http.MethodDelete)
+
+	// projects
+	router.Handle("/projects", audited(m.AuditProjectCreate, requireRole(auth.RoleAdmin, requireAllProjects(handleProjectCreate)))).
+		Methods(http.MethodPost)
+	router.HandleFunc("/projects", requireRole(auth.RoleViewer, handleProjectReadAll)).Methods(http.MethodGet)
+	router.HandleFunc("/projects/{project}", requireRole(auth.RoleViewer, handleProjectRead)).Methods(http.MethodGet)
+	router.Handle("/projects/{project}", audited(m.AuditProjectUpdate, requireRole(auth.RoleAdmin, requireAllProjects(handleProjectUpdate)))).
+		Methods(http.MethodPut)
+	router.Handle("/projects/{project}", audited(m.AuditProjectDelete, requireRole(auth.RoleAdmin, requireAllProjects(handleProjectDelete)))).
+		Methods(http.MethodDelete)
+	router.HandleFunc("/projects/{project}/tests", requireRole(auth.RoleViewer, handleProjectTestReadAll)).
 		Methods(http.MethodGet)
-	router.HandleFunc("/test-schedules/{scheduleid}", handleTestScheduleDeleteBuilder(server)).
+	router.HandleFunc("/projects/{project}/test-instances", requireRole(auth.RoleViewer, handleProjectTestInstanceReadAll)).
+		Methods(http.MethodGet)
+	router.HandleFunc("/projects/{project}/test-schedules", requireRole(auth.RoleViewer, handleProjectTestScheduleReadAll)).
+		Methods(http.MethodGet)
+
+	// webhooks
+	router.Handle("/webhooks", audited(m.AuditWebhookCreate, requireRole(auth.RoleAdmin, handleWebhookCreate))).
+		Methods(http.MethodPost)
+	router.HandleFunc("/webhooks", requireRole(auth.RoleAdmin, handleWebhookReadAll)).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{webhookid}", requireRole(auth.RoleAdmin, handleWebhookRead)).Methods(http.MethodGet)
+	router.Handle("/webhooks/{webhookid}", audited(m.AuditWebhookUpdate, requireRole(auth.RoleAdmin, handleWebhookUpdate))).
+		Methods(http.MethodPut)
+	router.Handle("/webhooks/{webhookid}", audited(m.AuditWebhookDelete, requireRole(auth.RoleAdmin, handleWebhookDelete))).
 		Methods(http.MethodDelete)
+	router.HandleFunc("/webhooks/{webhookid}/deliveries", requireRole(auth.RoleAdmin, handleWebhookDeliveries)).
+		Methods(http.MethodGet)
+	router.Handle("/webhooks/{webhookid}/test", audited(m.AuditWebhookTest, requireRole(auth.RoleAdmin, handleWebhookTest))).
+		Methods(http.MethodPost)
+
+	// backup
+	router.HandleFunc("/backup", requireRole(auth.RoleAdmin, requireAllProjects(handleBackup))).Methods(http.MethodGet)
+	router.Handle("/restore", audited(m.AuditRestore, requireRole(auth.RoleAdmin, requireAllProjects(handleRestoreBuilder(server))))).
+		Methods(http.MethodPost)
+
+	// audit
+	router.HandleFunc("/audit", requireRole(auth.RoleAdmin, handleAuditRead)).Methods(http.MethodGet)
+
+	// apply
+	router.Handle("/apply", audited(m.AuditApply, requireRole(auth.RoleAdmin, handleApplyBuilder(server)))).
+		Methods(http.MethodPost)
 
 	// Get grafana dashboard metadata
-	router.HandleFunc("/dashboard-metadata", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("/dashboard-metadata", requireRole(auth.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
 		if server.db == nil {
 			w.Write(buildFailure("Grafana dashboards are not available", http.StatusNotFound, w))
 			return
@@ -425,7 +1215,7 @@ func (server *APIServer) Start(router *mux.Router) {
 			return
 		}
 		w.Write(body)
-	}).Methods(http.MethodGet)
+	})).Methods(http.MethodGet)
 }
 
 // NewAPIServer create a new APIServer
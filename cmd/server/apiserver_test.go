@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	mgr "github.com/t-bfame/diago/pkg/manager"
+	"github.com/t-bfame/diago/pkg/metrics"
 	m "github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
 	sto "github.com/t-bfame/diago/pkg/storage"
 )
 
@@ -57,6 +62,20 @@ func TestHandleTestCreate(t *testing.T) {
 		t.Errorf("Expected TestCreate to fail")
 	}
 
+	invalid = []byte(`{"Name": "Test1", "Jobs": [{"HTTPMethod": "GET", "HTTPUrl": "www.google.com"}]}`)
+	r, _ = http.NewRequest(http.MethodPost, uri, bytes.NewReader(invalid))
+	content, status = []byte(``), http.StatusOK
+	w = TestResponseWriter{
+		http.Header{},
+		&content,
+		&status,
+	}
+
+	handleTestCreate(w, r)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected TestCreate to fail for relative url")
+	}
+
 	test := []byte(
 		`{
 			"Name": "Test1",
@@ -88,6 +107,62 @@ func TestHandleTestCreate(t *testing.T) {
 	tm, err := sto.GetTestByTestId(m.TestID("Test1"))
 	if err != nil || tm == nil {
 		t.Errorf("Expected TestCreate to persist")
+	} else if tm.Revision != 1 || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("Expected TestCreate to save revision 1")
+	}
+
+	// Existing tests are not overwritten
+	r, _ = http.NewRequest(http.MethodPost, uri, bytes.NewReader(test))
+	handleTestCreate(w, r)
+	if status != http.StatusConflict {
+		t.Errorf("Expected TestCreate to fail for an existing test")
+	}
+
+	// Created tests always start at revision 1
+	r, _ = http.NewRequest(http.MethodPost, uri, bytes.NewReader([]byte(`{"Name": "Test3", "Jobs": [], "Revision": 7}`)))
+	content, status = []byte(``), http.StatusOK
+	handleTestCreate(w, r)
+	if tm, _ := sto.GetTestByTestId("Test3"); status != http.StatusOK || tm == nil || tm.Revision != 1 {
+		t.Errorf("Expected TestCreate to ignore the revision of the body, got %v", tm)
+	}
+
+	// Jobs describe the whole request
+	test = []byte(
+		`{
+			"Name": "Test2",
+			"Jobs": [
+				{
+					"Name": "Job1",
+					"Group": "test-worker",
+					"Priority": 0,
+					"Frequency": 5,
+					"Duration": 30,
+					"HTTPMethod": "POST",
+					"HTTPUrl": "https://www.google.com",
+					"HTTPHeaders": {"Content-Type": "application/json"},
+					"HTTPBody": "{}",
+					"HTTPTimeout": 2000
+				}
+			]
+		}`,
+	)
+	r, _ = http.NewRequest(http.MethodPost, uri, bytes.NewReader(test))
+	content, status = []byte(``), http.StatusOK
+	w = TestResponseWriter{
+		http.Header{},
+		&content,
+		&status,
+	}
+
+	handleTestCreate(w, r)
+	if status != http.StatusOK {
+		t.Errorf("Expected TestCreate to pass for a request with headers and body")
+	}
+	tm, err = sto.GetTestByTestId(m.TestID("Test2"))
+	if err != nil || tm == nil || len(tm.Jobs) != 1 {
+		t.Errorf("Expected TestCreate to persist")
+	} else if j := tm.Jobs[0]; j.HTTPHeaders["Content-Type"] != "application/json" || j.HTTPBody != "{}" || j.HTTPTimeout != 2000 {
+		t.Errorf("Expected TestCreate to persist the request of the job, got %+v", j)
 	}
 }
 
@@ -394,6 +469,254 @@ func TestHandleTestInstanceRead(t *testing.T) {
 	}
 }
 
+func TestHandleTestInstanceDelete(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	sto.AddTestInstance(&m.TestInstance{ID: "Test1-1", TestID: "Test1", Status: "done"})
+	sto.AddTestInstance(&m.TestInstance{ID: "Test1-2", TestID: "Test1", Status: "running"})
+
+	request := func(instanceid string) int {
+		r, _ := http.NewRequest(http.MethodDelete, uri, bytes.NewReader([]byte(``)))
+		r = mux.SetURLVars(r, map[string]string{
+			"instanceid": instanceid,
+		})
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{
+			http.Header{},
+			&content,
+			&status,
+		}
+
+		handleTestInstanceDelete(w, r)
+		return status
+	}
+
+	if status := request("Test1-3"); status != http.StatusNotFound {
+		t.Error("Expected TestInstanceDelete to fail for a missing instance")
+	}
+
+	if status := request("Test1-2"); status != http.StatusConflict {
+		t.Error("Expected TestInstanceDelete to fail for a running instance")
+	}
+
+	if status := request("Test1-1"); status != http.StatusOK {
+		t.Fatal("Expected TestInstanceDelete to succeed")
+	}
+	if instance, _ := sto.GetTestInstance("Test1-1"); instance != nil {
+		t.Error("Expected TestInstance Test1-1 to be deleted")
+	}
+	if instances, _ := sto.GetTestInstancesByTestID("Test1"); len(instances) != 1 {
+		t.Errorf("Expected 1 remaining TestInstance of Test1, got %d", len(instances))
+	}
+}
+
+func TestHandleTestInstanceTimeSeries(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	jobMetrics := map[string]*metrics.Metrics{}
+	for _, jobID := range []string{"job1", "job2"} {
+		jm := metrics.NewMetricAggregator("Test1", "Test1-1618100600", jobID)
+		for i := 0; i < 30; i++ {
+			jm.Add(&scheduler.Metrics{
+				Code:      200,
+				Timestamp: time.Unix(1618100600+int64(i), 0),
+				Latency:   time.Millisecond,
+			})
+		}
+		jm.Close()
+		jobMetrics[jobID] = jm
+	}
+	sto.AddTestInstance(&m.TestInstance{
+		ID:      "Test1-1618100600",
+		TestID:  "Test1",
+		Status:  "done",
+		Metrics: jobMetrics,
+	})
+
+	read := func(instanceid string, query string) (map[string]interface{}, int) {
+		r, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?%s", uri, query), bytes.NewReader([]byte(``)))
+		r = mux.SetURLVars(r, map[string]string{
+			"instanceid": instanceid,
+		})
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{
+			http.Header{},
+			&content,
+			&status,
+		}
+
+		handleTestInstanceTimeSeries(w, r)
+
+		var result map[string]interface{}
+		json.Unmarshal(content, &result)
+		payload, _ := result["payload"].(map[string]interface{})
+		return payload, status
+	}
+
+	payload, status := read("Test1-1618100600", "interval=10s")
+	if status != http.StatusOK {
+		t.Fatal("Expected TestInstanceTimeSeries to succeed")
+	}
+	jobs := payload["jobs"].(map[string]interface{})
+	job1 := jobs["job1"].(map[string]interface{})["buckets"].([]interface{})
+	if len(jobs) != 2 || len(job1) != 3 {
+		t.Errorf("Expected 3 buckets for each of 2 jobs, got %d jobs", len(jobs))
+	}
+	total := payload["total"].(map[string]interface{})["buckets"].([]interface{})
+	if requests := total[0].(map[string]interface{})["requests"]; requests != float64(20) {
+		t.Errorf("Expected 20 requests in first bucket of total, got %v", requests)
+	}
+
+	payload, _ = read("Test1-1618100600", "job=job2")
+	if jobs := payload["jobs"].(map[string]interface{}); len(jobs) != 1 || jobs["job2"] == nil {
+		t.Errorf("Expected only job2 to be returned, got %v", jobs)
+	}
+
+	if _, status := read("Test1-1618100600", "interval=1500ms"); status != http.StatusBadRequest {
+		t.Error("Expected TestInstanceTimeSeries to fail for an invalid interval")
+	}
+	if _, status := read("Unknown", ""); status != http.StatusNotFound {
+		t.Error("Expected TestInstanceTimeSeries to fail for an unknown instance")
+	}
+}
+
+func TestHandleTestInstanceCompare(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	run := func(id m.TestInstanceID, latency time.Duration) {
+		jm := metrics.NewMetricAggregator("Test1", string(id), "job1")
+		for i := 0; i < 30; i++ {
+			jm.Add(&scheduler.Metrics{
+				Code:      200,
+				Timestamp: time.Unix(1618100600+int64(i), 0),
+				Latency:   latency + time.Duration(i%3)*time.Millisecond,
+			})
+		}
+		jm.Close()
+		sto.AddTestInstance(&m.TestInstance{
+			ID:      id,
+			TestID:  "Test1",
+			Status:  "done",
+			Metrics: map[string]*metrics.Metrics{"job1": jm},
+		})
+	}
+	run("Test1-1", 10*time.Millisecond)
+	run("Test1-2", 50*time.Millisecond)
+
+	request := func(handler http.HandlerFunc, method string, instanceid string, query string) (map[string]interface{}, int) {
+		r, _ := http.NewRequest(method, fmt.Sprintf("%s?%s", uri, query), bytes.NewReader([]byte(``)))
+		r = mux.SetURLVars(r, map[string]string{
+			"instanceid": instanceid,
+		})
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{
+			http.Header{},
+			&content,
+			&status,
+		}
+
+		handler(w, r)
+
+		var result map[string]interface{}
+		json.Unmarshal(content, &result)
+		return result, status
+	}
+
+	if _, status := request(handleTestInstanceCompare, http.MethodGet, "Test1-2", ""); status != http.StatusNotFound {
+		t.Error("Expected TestInstanceCompare to fail without a baseline")
+	}
+
+	if _, status := request(handleTestInstanceSetBaseline, http.MethodPost, "Test1-1", ""); status != http.StatusOK {
+		t.Fatal("Expected TestInstanceSetBaseline to succeed")
+	}
+
+	result, status := request(handleTestInstanceCompare, http.MethodGet, "Test1-2", "")
+	if status != http.StatusOK {
+		t.Fatal("Expected TestInstanceCompare to succeed")
+	}
+	comparison := result["payload"].(map[string]interface{})
+	if comparison["baseline"] != "Test1-1" || comparison["regression"] != true {
+		t.Errorf("Expected regression against baseline Test1-1, got %v", comparison)
+	}
+
+	result, _ = request(handleTestInstanceCompare, http.MethodGet, "Test1-2", "baseline=Test1-2")
+	if comparison := result["payload"].(map[string]interface{}); comparison["regression"] != false {
+		t.Errorf("Expected no regression against itself, got %v", comparison)
+	}
+
+	if _, status := request(handleTestInstanceCompare, http.MethodGet, "Test1-2", "latency=fast"); status != http.StatusBadRequest {
+		t.Error("Expected TestInstanceCompare to fail for an invalid tolerance")
+	}
+}
+
+func TestHandleTestInstanceReport(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	jm := metrics.NewMetricAggregator("Test1", "Test1-1", "job1")
+	for i := 0; i < 10; i++ {
+		jm.Add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(1618100600+int64(i), 0),
+			Latency:   10 * time.Millisecond,
+		})
+	}
+	jm.Close()
+	sto.AddTestInstance(&m.TestInstance{
+		ID:      "Test1-1",
+		TestID:  "Test1",
+		Status:  "done",
+		Metrics: map[string]*metrics.Metrics{"job1": jm},
+	})
+
+	request := func(instanceid string, query string) (TestResponseWriter, string, int) {
+		r, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?%s", uri, query), bytes.NewReader([]byte(``)))
+		r = mux.SetURLVars(r, map[string]string{
+			"instanceid": instanceid,
+		})
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{
+			http.Header{},
+			&content,
+			&status,
+		}
+
+		handleTestInstanceReport(w, r)
+		return w, string(content), status
+	}
+
+	if _, _, status := request("Test1-2", "format=csv"); status != http.StatusNotFound {
+		t.Error("Expected TestInstanceReport to fail for a missing instance")
+	}
+
+	if _, _, status := request("Test1-1", "format=pdf"); status != http.StatusBadRequest {
+		t.Error("Expected TestInstanceReport to fail for an unknown format")
+	}
+
+	w, content, status := request("Test1-1", "format=csv")
+	if status != http.StatusOK {
+		t.Fatal("Expected TestInstanceReport to succeed")
+	}
+	if w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Errorf("Expected csv content type, got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Content-Disposition") != `attachment; filename="Test1-1.csv"` {
+		t.Errorf("Unexpected content disposition %s", w.Header().Get("Content-Disposition"))
+	}
+	if !strings.Contains(content, "metric,job1,,requests,10,,") {
+		t.Errorf("Expected requests of job1 in report, got %s", content)
+	}
+
+	_, content, _ = request("Test1-1", "")
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &result); err != nil || result["test"] != "Test1" {
+		t.Errorf("Expected json report by default, got %s", content)
+	}
+}
+
 func TestHandleTestScheduleCreate(t *testing.T) {
 	initTestDB(t)
 	defer removeTestDB(t)
@@ -568,4 +891,314 @@ func removeTestDB(t *testing.T) {
 	if err := os.Remove(testDBName); err != nil {
 		t.Log("Failed to remove testDB after running a test")
 	}
+
+	// Migrations back up the database next to it before upgrading it
+	backups, _ := filepath.Glob(testDBName + ".*.bak")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+}
+
+func TestHandleBackupAndRestore(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	sto.AddTest(&m.Test{ID: "Test1", Name: "Test1", Jobs: []m.Job{}})
+	sto.AddTestSchedule(&m.TestSchedule{ID: "TestSchedule1", Name: "TestSchedule1", TestID: "Test1", CronSpec: "* * * * *"})
+	sto.AddTestInstance(&m.TestInstance{ID: "Instance1", TestID: "Test1", Status: "running"})
+
+	// JSON is the content type of every response set by preResponse
+	for format, contentType := range map[string]string{
+		"snapshot": "application/octet-stream",
+		"json":     "",
+	} {
+		r, _ := http.NewRequest(http.MethodGet, uri+"/backup?format="+format, nil)
+		backup := httptest.NewRecorder()
+		handleBackup(backup, r)
+		if backup.Code != http.StatusOK {
+			t.Fatalf("Expected %s backup to succeed, got %d", format, backup.Code)
+		}
+		if contentType != "" && backup.Header().Get("Content-Type") != contentType {
+			t.Errorf("Expected %s backup to be %s", format, contentType)
+		}
+		if !strings.HasPrefix(backup.Header().Get("Content-Disposition"), "attachment") {
+			t.Errorf("Expected %s backup to be an attachment", format)
+		}
+
+		// Restore into an empty storage
+		removeTestDB(t)
+		initTestDB(t)
+
+		sm := &mgr.TestingScheduleManager{}
+		restoreHandler := handleRestoreBuilder(&APIServer{&mgr.TestingJobFunnel{}, sm, nil})
+
+		r, _ = http.NewRequest(http.MethodPost, uri+"/restore", bytes.NewReader(backup.Body.Bytes()))
+		restore := httptest.NewRecorder()
+		restoreHandler(restore, r)
+		if restore.Code != http.StatusOK {
+			t.Fatalf("Expected restoring the %s backup to succeed, got %s", format, restore.Body.String())
+		}
+		if len(sm.Added) != 1 || sm.Added[0] != "TestSchedule1" {
+			t.Errorf("Expected the restored schedule to be started, got %v", sm.Added)
+		}
+		if instance, _ := sto.GetTestInstance("Instance1"); instance == nil || instance.Status != "interrupted" {
+			t.Errorf("Expected the restored running instance to be interrupted, got %v", instance)
+		}
+
+		// Restoring again conflicts with the restored objects
+		r, _ = http.NewRequest(http.MethodPost, uri+"/restore", bytes.NewReader(backup.Body.Bytes()))
+		restore = httptest.NewRecorder()
+		restoreHandler(restore, r)
+		if restore.Code != http.StatusConflict {
+			t.Errorf("Expected restoring existing objects to conflict, got %d", restore.Code)
+		}
+
+		r, _ = http.NewRequest(http.MethodPost, uri+"/restore?conflict=skip", bytes.NewReader(backup.Body.Bytes()))
+		restore = httptest.NewRecorder()
+		restoreHandler(restore, r)
+		if restore.Code != http.StatusOK {
+			t.Errorf("Expected restoring with skipped conflicts to succeed, got %d", restore.Code)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, uri+"/backup?format=tar", nil)
+	backup := httptest.NewRecorder()
+	handleBackup(backup, r)
+	if backup.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown backup format to fail, got %d", backup.Code)
+	}
+}
+
+func TestHandleTestUpdate(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	update := func(method string, testid string, ifMatch string, body string) (int, http.Header) {
+		r, _ := http.NewRequest(method, uri, bytes.NewReader([]byte(body)))
+		r = mux.SetURLVars(r, map[string]string{"testid": testid})
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{http.Header{}, &content, &status}
+		handleTestUpdate(w, r)
+		return status, w.headers
+	}
+
+	if status, _ := update(http.MethodPut, "Test1", "", `{"Name": "Test1"}`); status != http.StatusNotFound {
+		t.Errorf("Expected updating a missing test to fail, got %d", status)
+	}
+
+	test := m.Test{ID: "Test1", Name: "Test1", Jobs: []m.Job{{Name: "Job1", Frequency: 5, Duration: 30}}}
+	sto.SaveTest(&test, 0)
+
+	status, headers := update(http.MethodPut, "Test1", `"1"`, `{"Name": "Test1", "Jobs": [{"Name": "Job1", "Frequency": 10, "Duration": 30}]}`)
+	if status != http.StatusOK || headers.Get("ETag") != `"2"` {
+		t.Fatalf("Expected TestUpdate to save revision 2, got %d", status)
+	}
+	if tm, _ := sto.GetTestByTestId("Test1"); tm.Jobs[0].Frequency != 10 || tm.Jobs[0].ID != "Test1-0" {
+		t.Errorf("Expected TestUpdate to replace the test, got %v", tm)
+	}
+
+	if status, _ := update(http.MethodPut, "Test1", `"1"`, `{"Name": "Test1"}`); status != http.StatusPreconditionFailed {
+		t.Errorf("Expected updating a stale revision to fail, got %d", status)
+	}
+	if status, _ := update(http.MethodPut, "Test1", "", `{"Name": "Test1", "Revision": 1}`); status != http.StatusPreconditionFailed {
+		t.Errorf("Expected updating a stale revision of the body to fail, got %d", status)
+	}
+	if status, _ := update(http.MethodPut, "Test1", "", `{"Name": "Test2"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected renaming a test to fail, got %d", status)
+	}
+
+	// PATCH only changes the fields of the body
+	status, headers = update(http.MethodPatch, "Test1", "", `{"Jobs": [{"Name": "Job1", "Frequency": 20, "Duration": 60}]}`)
+	if status != http.StatusOK || headers.Get("ETag") != `"3"` {
+		t.Fatalf("Expected TestUpdate to patch revision 3, got %d", status)
+	}
+	if tm, _ := sto.GetTestByTestId("Test1"); tm.Name != "Test1" || tm.Jobs[0].Duration != 60 {
+		t.Errorf("Expected TestUpdate to patch the test, got %v", tm)
+	}
+	if status, _ := update(http.MethodPatch, "Test1", `"2"`, `{"Chaos": null}`); status != http.StatusPreconditionFailed {
+		t.Errorf("Expected patching a stale revision to fail, got %d", status)
+	}
+
+	// Every revision is kept
+	r, _ := http.NewRequest(http.MethodGet, uri, nil)
+	r = mux.SetURLVars(r, map[string]string{"testid": "Test1"})
+	content, status := []byte(``), http.StatusOK
+	handleTestRevisionReadAll(TestResponseWriter{http.Header{}, &content, &status}, r)
+
+	var result struct{ Payload []m.Test }
+	if err := json.Unmarshal(content, &result); err != nil || len(result.Payload) != 3 {
+		t.Fatalf("Expected 3 revisions, got %s", content)
+	}
+	if result.Payload[0].Jobs[0].Frequency != 5 {
+		t.Errorf("Expected the first revision to be unchanged, got %v", result.Payload[0])
+	}
+
+	r = mux.SetURLVars(r, map[string]string{"testid": "Test1", "revision": "2"})
+	handleTestRevisionRead(TestResponseWriter{http.Header{}, &content, &status}, r)
+	if status != http.StatusOK {
+		t.Errorf("Expected TestRevisionRead to succeed, got %d", status)
+	}
+	r = mux.SetURLVars(r, map[string]string{"testid": "Test1", "revision": "4"})
+	handleTestRevisionRead(TestResponseWriter{http.Header{}, &content, &status}, r)
+	if status != http.StatusNotFound {
+		t.Errorf("Expected TestRevisionRead to fail for a missing revision, got %d", status)
+	}
+}
+
+func TestHandleTestManifest(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	call := func(method string, body string) int {
+		r, _ := http.NewRequest(method, uri, bytes.NewReader([]byte(body)))
+		r = mux.SetURLVars(r, map[string]string{"testid": "Test1"})
+		content, status := []byte(``), http.StatusOK
+		w := TestResponseWriter{http.Header{}, &content, &status}
+		if method == http.MethodPost {
+			handleTestCreate(w, r)
+		} else {
+			handleTestUpdate(w, r)
+		}
+		return status
+	}
+
+	// Only applying a manifest sets the manifest of a test
+	if status := call(http.MethodPost, `{"Name": "Test1", "Jobs": [], "Manifest": "checkout"}`); status != http.StatusOK {
+		t.Fatalf("Expected TestCreate to pass, got %d", status)
+	}
+	if test, _ := sto.GetTestByTestId("Test1"); test == nil || test.Manifest != "" {
+		t.Errorf("Expected the created test to have no manifest, got %v", test)
+	}
+
+	test, _ := sto.GetTestByTestId("Test1")
+	test.Manifest = "checkout"
+	sto.SaveTest(test, sto.AnyRevision)
+
+	if status := call(http.MethodPut, `{"Name": "Test1", "Jobs": [], "Manifest": "other"}`); status != http.StatusOK {
+		t.Fatalf("Expected TestUpdate to pass, got %d", status)
+	}
+	if test, _ := sto.GetTestByTestId("Test1"); test.Manifest != "checkout" {
+		t.Errorf("Expected the updated test to keep its manifest, got %s", test.Manifest)
+	}
+}
+
+func TestHandleTestScheduleUpdate(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	sm := &mgr.TestingScheduleManager{}
+	server := &APIServer{&mgr.TestingJobFunnel{}, sm, nil}
+	tsUpdateHandler := handleTestScheduleUpdateBuilder(server)
+
+	sto.AddTest(&m.Test{ID: "Test1", Name: "Test1", Jobs: []m.Job{}})
+	sto.AddTestSchedule(&m.TestSchedule{ID: "TestSchedule1", Name: "TestSchedule1", TestID: "Test1", CronSpec: "* * * * *", Revision: 1})
+
+	update := func(method string, ifMatch string, body string) int {
+		r, _ := http.NewRequest(method, uri, bytes.NewReader([]byte(body)))
+		r = mux.SetURLVars(r, map[string]string{"scheduleid": "TestSchedule1"})
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		content, status := []byte(``), http.StatusOK
+		tsUpdateHandler(TestResponseWriter{http.Header{}, &content, &status}, r)
+		return status
+	}
+
+	if status := update(http.MethodPatch, `"1"`, `{"CronSpec": "0 * * * *"}`); status != http.StatusOK {
+		t.Fatalf("Expected TestScheduleUpdate to succeed, got %d", status)
+	}
+	if len(sm.Updated) != 1 || sm.Updated[0] != "TestSchedule1" {
+		t.Errorf("Expected TestScheduleUpdate to update the running schedule")
+	}
+	if schedule, _ := sto.GetTestSchedule("TestSchedule1"); schedule.CronSpec != "0 * * * *" || schedule.Revision != 2 {
+		t.Errorf("Expected TestScheduleUpdate to save revision 2, got %v", schedule)
+	}
+
+	if status := update(http.MethodPatch, `"1"`, `{"CronSpec": "5 * * * *"}`); status != http.StatusPreconditionFailed {
+		t.Errorf("Expected updating a stale revision to fail, got %d", status)
+	}
+	if status := update(http.MethodPatch, "", `{"TestID": "Test2"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected updating to a missing test to fail, got %d", status)
+	}
+	if status := update(http.MethodPut, "", `{"Name": "TestSchedule2", "TestID": "Test1", "CronSpec": "* * * * *"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected renaming a schedule to fail, got %d", status)
+	}
+}
+
+func TestHandleApply(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	manifest := `
+Name: checkout
+Tests:
+  - Name: browse
+    Jobs:
+      - Name: home
+        Group: test-worker
+        Priority: 0
+        Env: {}
+        Config: []
+        Frequency: 5
+        Duration: 10
+        HTTPMethod: GET
+        HTTPUrl: https://example.com
+TestSchedules:
+  - Name: browse-nightly
+    TestID: browse
+    CronSpec: "0 3 * * *"
+`
+	jf := &mgr.TestingJobFunnel{}
+	sm := &mgr.TestingScheduleManager{}
+	applyHandler := handleApplyBuilder(&APIServer{jf, sm, nil})
+
+	apply := func(query string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodPost, uri+"/apply"+query, strings.NewReader(manifest))
+		recorder := httptest.NewRecorder()
+		applyHandler(recorder, r)
+		return recorder
+	}
+
+	// A dry run only reports the changes
+	recorder := apply("?dryRun=true")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected dry run to succeed, got %s", recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), `"Action":"create"`) {
+		t.Errorf("Expected dry run to create the test, got %s", recorder.Body.String())
+	}
+	if test, _ := sto.GetTestByTestId("browse"); test != nil {
+		t.Error("Expected dry run not to create the test")
+	}
+
+	recorder = apply("")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected apply to succeed, got %s", recorder.Body.String())
+	}
+	if test, _ := sto.GetTestByTestId("browse"); test == nil || test.Manifest != "checkout" {
+		t.Errorf("Expected the test to be created by the manifest, got %v", test)
+	}
+	if schedule, _ := sto.GetTestSchedule("browse-nightly"); schedule == nil {
+		t.Error("Expected the schedule to be created")
+	}
+
+	recorder = apply("?prune=true")
+	if strings.Contains(recorder.Body.String(), `"Action":"create"`) ||
+		strings.Contains(recorder.Body.String(), `"Action":"update"`) {
+		t.Errorf("Expected applying again to change nothing, got %s", recorder.Body.String())
+	}
+
+	// Jobs must run on existing worker groups
+	jf.MissingGroups = []string{"test-worker"}
+	if recorder := apply(""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a missing worker group to be rejected, got %d", recorder.Code)
+	}
+
+	manifest = "Tests: {}"
+	if recorder := apply(""); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid manifest to be rejected, got %d", recorder.Code)
+	}
 }
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/t-bfame/diago/pkg/auth"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+const (
+	// defaultAuditLimit is the number of audit entries returned by a page unless limit is set
+	defaultAuditLimit = 100
+	// maxAuditLimit is the largest page of audit entries
+	maxAuditLimit = 1000
+)
+
+// auditRecorder captures the response to an audited call
+type auditRecorder struct {
+	http.ResponseWriter
+	status   int
+	response bytes.Buffer
+}
+
+func (a *auditRecorder) WriteHeader(status int) {
+	a.status = status
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *auditRecorder) Write(content []byte) (int, error) {
+	a.response.Write(content)
+	return a.ResponseWriter.Write(content)
+}
+
+// payloadHasher hashes the body of an audited call as the handler reads it
+type payloadHasher struct {
+	hash hash.Hash
+	size int64
+}
+
+func (p *payloadHasher) Write(content []byte) (int, error) {
+	p.size += int64(len(content))
+	return p.hash.Write(content)
+}
+
+// auditedHandler records every call of its handler in the audit log, with its outcome
+type auditedHandler struct {
+	action  m.AuditAction
+	handler http.HandlerFunc
+}
+
+// Internal function used to record every call of handler in the audit log, with its outcome.
+// Calls the role or projects of the caller do not allow are recorded as denied when
+// handler checks them, see requireRole, and so are calls without valid credentials, see Authenticate.
+func audited(action m.AuditAction, handler http.HandlerFunc) *auditedHandler {
+	return &auditedHandler{action, handler}
+}
+
+func (a *auditedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.serve(w, r, a.handler)
+}
+
+// Internal function used to record the call r of the route of a, as answered by handler
+func (a *auditedHandler) serve(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	payload := &payloadHasher{hash: sha256.New()}
+	body := r.Body
+	r.Body = ioutil.NopCloser(io.TeeReader(body, payload))
+
+	recorder := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+	handler(recorder, r)
+
+	// The payload is hashed whole even if handler did not read all of it
+	io.Copy(ioutil.Discard, r.Body)
+	body.Close()
+
+	entry := &m.AuditEntry{
+		Time:     time.Now().Unix(),
+		Action:   a.action,
+		Actor:    actor(r),
+		SourceIP: sourceIP(r),
+		Path:     r.URL.RequestURI(),
+		Status:   recorder.status,
+		Outcome:  m.AuditSucceeded,
+	}
+	if payload.size > 0 {
+		entry.PayloadHash = hex.EncodeToString(payload.hash.Sum(nil))
+	}
+
+	var response struct {
+		Payload interface{}
+		Error   struct{ Message string }
+	}
+	json.Unmarshal(recorder.response.Bytes(), &response)
+	entry.Target, entry.Project = auditTarget(r, response.Payload)
+
+	switch {
+	case recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden:
+		entry.Outcome = m.AuditDenied
+		entry.Error = response.Error.Message
+	case recorder.status >= http.StatusBadRequest:
+		entry.Outcome = m.AuditFailed
+		entry.Error = response.Error.Message
+	}
+
+	if err := sto.AddAuditEntry(entry); err != nil {
+		log.WithError(err).WithField("action", a.action).Error("Failed to audit call")
+	}
+}
+
+// Internal function used to find the object a call operated on and its project, named by the
+// path of the call or, for calls creating it, by the payload of the response
+func auditTarget(r *http.Request, payload interface{}) (string, string) {
+	vars := mux.Vars(r)
+	if project, exists := vars["project"]; exists {
+		return project, project
+	}
+	for _, key := range []string{"testid", "instanceid", "scheduleid", "webhookid"} {
+		if id, exists := vars[key]; exists {
+			return id, m.ProjectOfID(id)
+		}
+	}
+
+	created, _ := payload.(map[string]interface{})
+	if project, ok := created["project"].(string); ok {
+		return project, project
+	}
+	for _, key := range []string{"testid", "scheduleid", "webhookid"} {
+		if id, ok := created[key].(string); ok {
+			return id, m.ProjectOfID(id)
+		}
+	}
+	return "", ""
+}
+
+// Internal function used to find the address a call came from.
+// Proxies in front of the leader hide the address of their clients.
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Internal function used to parse the query of a page of the audit log
+func readAuditQuery(r *http.Request) (*sto.AuditQuery, error) {
+	values := r.URL.Query()
+	query := &sto.AuditQuery{
+		Limit:  defaultAuditLimit,
+		Actor:  values.Get("actor"),
+		Action: m.AuditAction(values.Get("action")),
+		Target: values.Get("target"),
+	}
+
+	for name, value := range map[string]*int64{"since": &query.Since, "until": &query.Until} {
+		if raw := values.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 time, got %s", name, raw)
+			}
+			*value = t.Unix()
+		}
+	}
+
+	if raw := values.Get("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("before must be the ID of an entry, got %s", raw)
+		}
+		query.Before = m.AuditID(before)
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d, got %s", maxAuditLimit, raw)
+		}
+		query.Limit = limit
+	}
+
+	// Callers restricted to projects only see the entries of their projects
+	if project := values.Get("project"); project != "" {
+		query.Projects = []string{project}
+	}
+	if identity := auth.IdentityOf(r); identity != nil && identity.Projects != nil {
+		allowed := []string{}
+		for _, project := range identity.Projects {
+			if query.Projects == nil || m.ProjectName(query.Projects[0]) == m.ProjectName(project) {
+				allowed = append(allowed, project)
+			}
+		}
+		query.Projects = allowed
+	}
+	return query, nil
+}
+
+func handleAuditRead(w http.ResponseWriter, r *http.Request) {
+	query, err := readAuditQuery(r)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	entries, err := sto.GetAuditEntries(*query)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	// A full page may be followed by older entries, which the next page starts before
+	page := map[string]interface{}{"entries": entries}
+	if len(entries) == query.Limit {
+		page["next"] = entries[len(entries)-1].ID
+	}
+	w.Write(buildSuccess(page, w))
+}
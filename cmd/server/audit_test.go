@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/t-bfame/diago/pkg/auth"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestHandleAudit(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	tokens := map[string]string{}
+	entries := []auth.Token{}
+	for _, token := range []auth.Token{
+		{Name: "admin", Role: auth.RoleAdmin},
+		{Name: "viewer", Role: auth.RoleViewer},
+		{Name: "member", Role: auth.RoleAdmin, Projects: []string{"team-a"}},
+	} {
+		tokens[token.Name], _ = auth.GenerateToken()
+		token.Hash = auth.HashToken(tokens[token.Name])
+		entries = append(entries, token)
+	}
+
+	router := mux.NewRouter()
+	router.Use(Authenticate(&auth.Chain{Authenticators: []auth.Authenticator{auth.NewTokenAuthenticator(entries)}}))
+	(&APIServer{&mgr.TestingJobFunnel{}, &mgr.TestingScheduleManager{}, nil}).Start(router)
+
+	call := func(method string, path string, body string, name string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+tokens[name])
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+	page := func(query string, name string) ([]*m.AuditEntry, *m.AuditID) {
+		recorder := call(http.MethodGet, "/audit"+query, "", name)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected to read the audit log, got %s", recorder.Body.String())
+		}
+		var response struct {
+			Payload struct {
+				Entries []*m.AuditEntry
+				Next    *m.AuditID
+			}
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Payload.Entries, response.Payload.Next
+	}
+
+	test := `{"Name": "Test1", "Jobs": []}`
+	call(http.MethodPost, "/tests", test, "admin")
+	call(http.MethodPost, "/tests/Test1/start", "", "viewer")
+	call(http.MethodPost, "/tests/Test1/start", "", "admin")
+	call(http.MethodPost, "/projects", `{"Name": "team-a"}`, "admin")
+	call(http.MethodPost, "/tests", `{"Name": "checkout", "Project": "team-a", "Jobs": []}`, "member")
+	call(http.MethodDelete, "/tests/Missing", "", "admin")
+
+	// Reads are not audited
+	call(http.MethodGet, "/tests/Test1", "", "admin")
+
+	audit, next := page("", "admin")
+	if len(audit) != 6 || next != nil {
+		t.Fatalf("Expected 6 audited calls, got %d", len(audit))
+	}
+
+	created := audit[5]
+	sum := sha256.Sum256([]byte(test))
+	if created.Action != m.AuditTestCreate || created.Actor != "admin" || created.Target != "Test1" ||
+		created.Outcome != m.AuditSucceeded || created.PayloadHash != hex.EncodeToString(sum[:]) ||
+		created.SourceIP != "192.0.2.1" {
+		t.Errorf("Unexpected entry of the created test %+v", created)
+	}
+	if denied := audit[4]; denied.Action != m.AuditTestStart || denied.Actor != "viewer" ||
+		denied.Outcome != m.AuditDenied || denied.Status != http.StatusForbidden || denied.PayloadHash != "" {
+		t.Errorf("Unexpected entry of the denied start %+v", denied)
+	}
+	if scoped := audit[1]; scoped.Target != "team-a:checkout" || scoped.Project != "team-a" {
+		t.Errorf("Unexpected entry of the created test of team-a %+v", scoped)
+	}
+	if failed := audit[0]; failed.Outcome != m.AuditFailed || !strings.Contains(failed.Error, "Missing") {
+		t.Errorf("Unexpected entry of the failed delete %+v", failed)
+	}
+
+	// Pages continue before the last entry of the previous page
+	first, next := page("?limit=4", "admin")
+	if len(first) != 4 || next == nil || *next != first[3].ID {
+		t.Fatalf("Expected a full first page, got %d entries", len(first))
+	}
+	second, _ := page("?limit=4&before="+strconv.FormatUint(uint64(*next), 10), "admin")
+	if len(second) != 2 || second[0].ID != first[3].ID-1 {
+		t.Errorf("Expected the 2 oldest entries, got %d", len(second))
+	}
+
+	if starts, _ := page("?action=test.start&actor=admin", "admin"); len(starts) != 1 {
+		t.Errorf("Expected a single start by admin, got %d", len(starts))
+	}
+
+	// Restricted callers only see the entries of their projects, including those of the project itself
+	scoped, _ := page("", "member")
+	if len(scoped) != 2 || scoped[0].Project != "team-a" || scoped[1].Action != m.AuditProjectCreate {
+		t.Errorf("Expected only the entries of team-a, got %d", len(scoped))
+	}
+	if other, _ := page("?project=default", "member"); len(other) != 0 {
+		t.Errorf("Expected no entry of the default project, got %d", len(other))
+	}
+	if recorder := call(http.MethodGet, "/audit", "", "viewer"); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected viewers not to read the audit log, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodGet, "/audit?since=yesterday", "", "admin"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid time to be rejected, got %d", recorder.Code)
+	}
+
+	// Entries are stored whatever the outcome of the call
+	if stored, _ := sto.GetAuditEntries(sto.AuditQuery{}); len(stored) != 6 {
+		t.Errorf("Expected 6 stored entries, got %d", len(stored))
+	}
+
+	// Calls without valid credentials are audited too, reads still are not
+	call(http.MethodPost, "/tests/Test1/stop", "", "unknown")
+	call(http.MethodGet, "/tests/Test1", "", "unknown")
+
+	audit, _ = page("", "admin")
+	if rejected := audit[0]; len(audit) != 7 || rejected.Action != m.AuditTestStop || rejected.Actor != "" ||
+		rejected.Outcome != m.AuditDenied || rejected.Status != http.StatusUnauthorized || rejected.Target != "Test1" {
+		t.Errorf("Unexpected entry of the unauthenticated stop %+v", rejected)
+	}
+}
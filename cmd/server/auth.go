@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/t-bfame/diago/pkg/auth"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// Authenticate returns the middleware which identifies the caller of every API call with authenticator.
+// Calls which fail authentication are rejected, and audited if their route is, the routes of the API
+// then require a role, see requireRole.
+func Authenticate(authenticator auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, err := authenticator.Authenticate(r)
+			if err == nil {
+				next.ServeHTTP(w, auth.WithIdentity(r, identity))
+				return
+			}
+
+			reject := func(w http.ResponseWriter, r *http.Request) {
+				if err == auth.ErrNoCredentials {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="diago"`)
+					w.Write(buildFailure("Authentication required", http.StatusUnauthorized, w))
+					return
+				}
+				log.WithError(err).WithField("path", r.URL.Path).Info("Rejected API call")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="diago", error="invalid_token"`)
+				w.Write(buildFailure(err.Error(), http.StatusUnauthorized, w))
+			}
+
+			if route := mux.CurrentRoute(r); route != nil {
+				if audit, ok := route.GetHandler().(*auditedHandler); ok {
+					audit.serve(w, r, reject)
+					return
+				}
+			}
+			reject(w, r)
+		})
+	}
+}
+
+// Internal function used to allow only callers with role to call handler, and which may access the
+// projects of the objects named by the path and query of the call. All callers are allowed when
+// authentication is disabled, which leaves them without identity.
+func requireRole(role auth.Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity := auth.IdentityOf(r)
+		if identity != nil && !identity.Role.Includes(role) {
+			w.Write(buildFailure(
+				fmt.Sprintf("%s has the %s role, but this requires the %s role", identity.Name, identity.Role, role),
+				http.StatusForbidden,
+				w,
+			))
+			return
+		}
+		for _, project := range requestProjects(r) {
+			if !allowProject(w, r, project) {
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// Internal function used to allow only callers which may access every project to call handler,
+// such as to back up the storage
+func requireAllProjects(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if identity := auth.IdentityOf(r); identity != nil && identity.Projects != nil {
+			w.Write(buildFailure(
+				fmt.Sprintf("%s is restricted to projects %v, but this requires every project", identity.Name, identity.Projects),
+				http.StatusForbidden,
+				w,
+			))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Internal function used to reject the call r unless its caller may access project,
+// returning whether the call may go on
+func allowProject(w http.ResponseWriter, r *http.Request, project string) bool {
+	if identity := auth.IdentityOf(r); identity != nil && !identity.CanAccess(project) {
+		w.Write(buildFailure(
+			fmt.Sprintf("%s cannot access Project<%s>", identity.Name, m.ProjectName(project)),
+			http.StatusForbidden,
+			w,
+		))
+		return false
+	}
+	return true
+}
+
+// Internal function used to check whether the caller of r may see the objects of project
+func visible(r *http.Request, project string) bool {
+	identity := auth.IdentityOf(r)
+	return identity == nil || identity.CanAccess(project)
+}
+
+// Internal function used to find the projects of the objects named by the path and query of r,
+// which are part of their IDs
+func requestProjects(r *http.Request) []string {
+	vars := mux.Vars(r)
+	projects := []string{}
+	if project, exists := vars["project"]; exists {
+		projects = append(projects, project)
+	}
+	for _, name := range []string{"testid", "instanceid", "scheduleid", "webhookid"} {
+		if id, exists := vars[name]; exists {
+			projects = append(projects, m.ProjectOfID(id))
+		}
+	}
+	for _, name := range []string{"testid", "baseline"} {
+		if id := r.URL.Query().Get(name); id != "" {
+			projects = append(projects, m.ProjectOfID(id))
+		}
+	}
+	return projects
+}
+
+// Internal function used to name the caller of r, empty when authentication is disabled
+func actor(r *http.Request) string {
+	if identity := auth.IdentityOf(r); identity != nil {
+		return identity.Name
+	}
+	return ""
+}
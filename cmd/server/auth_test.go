@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/t-bfame/diago/pkg/auth"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestAuthenticate(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	sto.AddTest(&m.Test{ID: "Test1", Name: "Test1", Jobs: []m.Job{}})
+
+	tokens := map[auth.Role]string{}
+	entries := []auth.Token{}
+	for _, role := range []auth.Role{auth.RoleViewer, auth.RoleRunner, auth.RoleAdmin} {
+		token, _ := auth.GenerateToken()
+		tokens[role] = token
+		entries = append(entries, auth.Token{Name: string(role) + "-user", Role: role, Hash: auth.HashToken(token)})
+	}
+
+	jf := &mgr.TestingJobFunnel{}
+	router := mux.NewRouter()
+	router.Use(Authenticate(&auth.Chain{Authenticators: []auth.Authenticator{auth.NewTokenAuthenticator(entries)}}))
+	(&APIServer{jf, &mgr.TestingScheduleManager{}, nil}).Start(router)
+
+	call := func(method string, path string, body string, role auth.Role) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if role != "" {
+			r.Header.Set("Authorization", "Bearer "+tokens[role])
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		role   auth.Role
+		code   int
+	}{
+		{"no credentials", http.MethodGet, "/tests", "", http.StatusUnauthorized},
+		{"viewer reads", http.MethodGet, "/tests/Test1", auth.RoleViewer, http.StatusOK},
+		{"viewer starts", http.MethodPost, "/tests/Test1/start", auth.RoleViewer, http.StatusForbidden},
+		{"runner starts", http.MethodPost, "/tests/Test1/start", auth.RoleRunner, http.StatusOK},
+		{"runner deletes", http.MethodDelete, "/tests/Test1", auth.RoleRunner, http.StatusForbidden},
+		{"runner backs up", http.MethodGet, "/backup", auth.RoleRunner, http.StatusForbidden},
+		{"admin reads", http.MethodGet, "/test-instances", auth.RoleAdmin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if recorder := call(tt.method, tt.path, "", tt.role); recorder.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d %s", tt.name, tt.code, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Invalid credentials are rejected even for routes any role may call
+	r, _ := http.NewRequest(http.MethodGet, "/tests", nil)
+	r.Header.Set("Authorization", "Bearer diago_invalid")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an invalid token to be rejected, got %d", recorder.Code)
+	}
+
+	// The identity is recorded on started instances, and created and updated tests
+	if len(jf.Starters) != 1 || jf.Starters[0] != "runner-user" {
+		t.Errorf("Expected the instance to be started by runner-user, got %v", jf.Starters)
+	}
+
+	if recorder := call(http.MethodPost, "/tests", `{"Name": "Test2", "Jobs": [], "CreatedBy": "someone"}`, auth.RoleAdmin); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected admin to create a test, got %s", recorder.Body.String())
+	}
+	if recorder := call(http.MethodPatch, "/tests/Test2", `{"Jobs": [], "UpdatedBy": "someone"}`, auth.RoleAdmin); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected admin to update a test, got %s", recorder.Body.String())
+	}
+	if test, _ := sto.GetTestByTestId("Test2"); test == nil || test.CreatedBy != "admin-user" || test.UpdatedBy != "admin-user" {
+		t.Errorf("Expected the test to be created and updated by admin-user, got %v", test)
+	}
+}
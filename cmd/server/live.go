@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// liveKeepAlive is the interval of the comments sent to keep idle live streams open through proxies
+const liveKeepAlive = 15 * time.Second
+
+// liveEvent is the data of a metrics event of a live stream
+type liveEvent struct {
+	*metrics.LiveUpdate
+
+	// Dropped is the number of updates skipped since the previous event
+	// because the client did not read them in time
+	Dropped uint64 `json:"dropped"`
+}
+
+// handleTestInstanceLiveBuilder streams the results of a running TestInstance every second
+// as Server-Sent Events, until the instance finishes or the client disconnects
+func handleTestInstanceLiveBuilder(
+	server *APIServer,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		instanceid := vars["instanceid"]
+
+		instance, err := sto.GetTestInstance(m.TestInstanceID(instanceid))
+		if err != nil {
+			w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+			return
+		} else if instance == nil {
+			w.Write(buildFailure(
+				fmt.Sprintf("Cannot find TestInstance<%s>", instanceid),
+				http.StatusNotFound,
+				w,
+			))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.Write(buildFailure("Streaming is not supported", http.StatusInternalServerError, w))
+			return
+		}
+
+		sub := server.jf.Subscribe(instance.ID)
+		if sub == nil {
+			w.Write(buildFailure(
+				fmt.Sprintf("TestInstance<%s> is not running", instanceid),
+				http.StatusConflict,
+				w,
+			))
+			return
+		}
+		defer sub.Cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(liveKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case update, ok := <-sub.Updates():
+				if !ok {
+					fmt.Fprint(w, "event: end\ndata: {}\n\n")
+					flusher.Flush()
+					return
+				}
+				data, err := json.Marshal(liveEvent{update, sub.Dropped()})
+				if err != nil {
+					log.WithError(err).WithField("instanceid", instanceid).Error("Failed to encode live update")
+					return
+				}
+				fmt.Fprintf(w, "event: metrics\ndata: %s\n\n", data)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestHandleTestInstanceLive(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	sto.AddTestInstance(&m.TestInstance{ID: "Test1-1", TestID: "Test1", Status: "running"})
+	sto.AddTestInstance(&m.TestInstance{ID: "Test1-0", TestID: "Test1", Status: "done"})
+
+	feed := metrics.NewLiveFeed([]string{"job-1"})
+	jf := &mgr.TestingJobFunnel{Feeds: map[m.TestInstanceID]*metrics.LiveFeed{"Test1-1": feed}}
+
+	router := mux.NewRouter()
+	(&APIServer{jf, &mgr.TestingScheduleManager{}, nil}).Start(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for path, code := range map[string]int{
+		"/test-instances/Missing/live": http.StatusNotFound,
+		"/test-instances/Test1-0/live": http.StatusConflict,
+	} {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != code {
+			t.Errorf("Expected %d from %s, got %d", code, path, response.StatusCode)
+		}
+	}
+
+	response, err := http.Get(server.URL + "/test-instances/Test1-1/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	// The stream is flushed before the first update, so the client is subscribed once the headers arrive
+	feed.Add("job-1", &scheduler.Metrics{Code: 200, Timestamp: time.Now(), Latency: time.Millisecond})
+	feed.Publish(time.Now(), map[string]int{"job-1": 2})
+	feed.Close()
+
+	events := map[string]string{}
+	order := []string{}
+	lines := bufio.NewScanner(response.Body)
+	var event string
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+			order = append(order, event)
+		case strings.HasPrefix(line, "data: "):
+			events[event] = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if len(order) != 2 || order[0] != "metrics" || order[1] != "end" {
+		t.Fatalf("Expected a metrics event followed by the end, got %v", order)
+	}
+
+	var update struct {
+		Jobs    map[string]*metrics.LiveJob
+		Dropped uint64
+	}
+	if err := json.Unmarshal([]byte(events["metrics"]), &update); err != nil {
+		t.Fatal(err)
+	}
+	if job := update.Jobs["job-1"]; job == nil || job.Requests != 1 || job.Workers != 2 || update.Dropped != 0 {
+		t.Errorf("Unexpected live update %s", events["metrics"])
+	}
+}
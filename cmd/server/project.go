@@ -0,0 +1,251 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/gorilla/mux"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// Internal function used to write the failure of checking a Test against its Project,
+// returning whether the Test fits its Project
+func checkProject(w http.ResponseWriter, test *m.Test) bool {
+	_, err := mgr.CheckProject(test)
+	if _, ok := err.(*mgr.ProjectError); ok {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return false
+	} else if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return false
+	}
+	return true
+}
+
+// Internal function used to read a Project from the body of r
+func readProject(r *http.Request) (*m.Project, error) {
+	bodyContent, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Validate(reflect.TypeOf(m.Project{}), bodyContent); err != nil {
+		return nil, err
+	}
+
+	var project m.Project
+	if err := json.Unmarshal(bodyContent, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func handleProjectCreate(w http.ResponseWriter, r *http.Request) {
+	project, err := readProject(r)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	existing, err := sto.GetProject(project.Name)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if existing != nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Project<%s> already exists", project.Name),
+			http.StatusConflict,
+			w,
+		))
+		return
+	}
+
+	if err := sto.SaveProject(project); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"project": project.Name}, w))
+}
+
+func handleProjectReadAll(w http.ResponseWriter, r *http.Request) {
+	projects, err := sto.GetAllProjects()
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	result := []*m.Project{}
+	for _, project := range projects {
+		if visible(r, project.Name) {
+			result = append(result, project)
+		}
+	}
+	w.Write(buildSuccess(result, w))
+}
+
+func handleProjectRead(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["project"]
+
+	project, err := sto.GetProject(name)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if project == nil {
+		// The default project exists without quotas until it is created
+		if m.NormalizeProject(name) != "" {
+			w.Write(buildFailure(
+				fmt.Sprintf("Cannot find Project<%s>", name),
+				http.StatusNotFound,
+				w,
+			))
+			return
+		}
+		project = &m.Project{Name: m.DefaultProject}
+	}
+
+	w.Write(buildSuccess(project, w))
+}
+
+func handleProjectUpdate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["project"]
+
+	project, err := readProject(r)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	if project.Name != name {
+		w.Write(buildFailure(
+			fmt.Sprintf("Name of Project<%s> cannot change", name),
+			http.StatusBadRequest,
+			w,
+		))
+		return
+	}
+
+	current, err := sto.GetProject(name)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if current == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find Project<%s>", name),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Quotas apply to Tests started from now on, existing Tests are not checked against them
+	if err := sto.SaveProject(project); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"project": name}, w))
+}
+
+func handleProjectDelete(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["project"]
+
+	project, err := sto.GetProject(name)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if project == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find Project<%s>", name),
+			http.StatusNotFound,
+			w,
+		))
+		return
+	}
+
+	// Tests of a deleted project could not be started anymore
+	tests, err := sto.GetTestsByProject(name)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if len(tests) > 0 && m.NormalizeProject(name) != "" {
+		w.Write(buildFailure(
+			fmt.Sprintf("Project<%s> still has %d Tests", name, len(tests)),
+			http.StatusConflict,
+			w,
+		))
+		return
+	}
+
+	if err := sto.DeleteProject(name); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"project": name}, w))
+}
+
+func handleProjectTestReadAll(w http.ResponseWriter, r *http.Request) {
+	tests, err := sto.GetTestsByProject(mux.Vars(r)["project"])
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+	w.Write(buildSuccess(tests, w))
+}
+
+func handleProjectTestInstanceReadAll(w http.ResponseWriter, r *http.Request) {
+	instances, err := sto.GetTestInstancesByProject(mux.Vars(r)["project"])
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+	w.Write(buildSuccess(instances, w))
+}
+
+func handleProjectTestScheduleReadAll(w http.ResponseWriter, r *http.Request) {
+	schedules, err := sto.GetTestSchedulesByProject(mux.Vars(r)["project"])
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+	w.Write(buildSuccess(schedules, w))
+}
+
+// Internal function used to keep the Tests whose project the caller of r may see
+func visibleTests(r *http.Request, tests []*m.Test) []*m.Test {
+	result := make([]*m.Test, 0, len(tests))
+	for _, test := range tests {
+		if visible(r, test.Project) {
+			result = append(result, test)
+		}
+	}
+	return result
+}
+
+// Internal function used to keep the TestInstances whose project the caller of r may see
+func visibleTestInstances(r *http.Request, instances []*m.TestInstance) []*m.TestInstance {
+	result := make([]*m.TestInstance, 0, len(instances))
+	for _, instance := range instances {
+		if visible(r, instance.Project) {
+			result = append(result, instance)
+		}
+	}
+	return result
+}
+
+// Internal function used to keep the TestSchedules whose project the caller of r may see
+func visibleTestSchedules(r *http.Request, schedules []*m.TestSchedule) []*m.TestSchedule {
+	result := make([]*m.TestSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		if visible(r, schedule.Project) {
+			result = append(result, schedule)
+		}
+	}
+	return result
+}
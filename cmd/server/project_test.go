@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/t-bfame/diago/pkg/auth"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestHandleProjects(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	admin, _ := auth.GenerateToken()
+	member, _ := auth.GenerateToken()
+	entries := []auth.Token{
+		{Name: "admin", Role: auth.RoleAdmin, Hash: auth.HashToken(admin)},
+		{Name: "member", Role: auth.RoleAdmin, Hash: auth.HashToken(member), Projects: []string{"team-a"}},
+	}
+
+	router := mux.NewRouter()
+	router.Use(Authenticate(&auth.Chain{Authenticators: []auth.Authenticator{auth.NewTokenAuthenticator(entries)}}))
+	(&APIServer{&mgr.TestingJobFunnel{}, &mgr.TestingScheduleManager{}, nil}).Start(router)
+
+	call := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	test := `{"Name": "checkout", "Project": "team-a", "Jobs": [{"Name": "home", "Group": "web", "Frequency": 30, "Duration": 10}]}`
+
+	// Tests of a project can only be created once it exists
+	if recorder := call(http.MethodPost, "/tests", test, admin); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a test of a missing project to be rejected, got %d", recorder.Code)
+	}
+
+	// Only callers of every project manage projects
+	project := `{"Name": "team-a", "MaxFrequency": 50, "Groups": ["web"]}`
+	if recorder := call(http.MethodPost, "/projects", project, member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected a restricted caller not to create projects, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodPost, "/projects", project, admin); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the project to be created, got %s", recorder.Body.String())
+	}
+	if recorder := call(http.MethodPost, "/projects", project, admin); recorder.Code != http.StatusConflict {
+		t.Errorf("Expected an existing project to conflict, got %d", recorder.Code)
+	}
+
+	if recorder := call(http.MethodPost, "/tests", test, member); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the test to be created, got %s", recorder.Body.String())
+	}
+	if created, _ := sto.GetTestByTestId("team-a:checkout"); created == nil || created.Project != "team-a" {
+		t.Errorf("Expected the test to be scoped to its project, got %v", created)
+	}
+
+	// Quotas of the project apply to its tests
+	over := `{"Name": "search", "Project": "team-a", "Jobs": [{"Name": "home", "Group": "batch", "Frequency": 30, "Duration": 10}]}`
+	if recorder := call(http.MethodPost, "/tests", over, member); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a test on a group outside the project to be rejected, got %d", recorder.Code)
+	}
+
+	// Restricted callers neither see nor change other projects
+	sto.AddTest(&m.Test{ID: "Test1", Name: "Test1", Jobs: []m.Job{}})
+	if recorder := call(http.MethodGet, "/tests/Test1", "", member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected the default project to be forbidden, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodPost, "/tests", `{"Name": "Test2", "Jobs": []}`, member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected creating a test in the default project to be forbidden, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodGet, "/projects/default/tests", "", member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected the tests of the default project to be forbidden, got %d", recorder.Code)
+	}
+
+	var tests struct{ Payload []*m.Test }
+	recorder := call(http.MethodGet, "/tests", "", member)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &tests); err != nil {
+		t.Fatal(err)
+	}
+	if len(tests.Payload) != 1 || tests.Payload[0].ID != "team-a:checkout" {
+		t.Errorf("Expected only the tests of team-a, got %s", recorder.Body.String())
+	}
+
+	recorder = call(http.MethodGet, "/projects/team-a/tests", "", member)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &tests); err != nil {
+		t.Fatal(err)
+	}
+	if len(tests.Payload) != 1 {
+		t.Errorf("Expected the test of team-a, got %s", recorder.Body.String())
+	}
+	recorder = call(http.MethodGet, "/projects/default/tests", "", admin)
+	if err := json.Unmarshal(recorder.Body.Bytes(), &tests); err != nil {
+		t.Fatal(err)
+	}
+	if len(tests.Payload) != 1 || tests.Payload[0].ID != "Test1" {
+		t.Errorf("Expected the test of the default project, got %s", recorder.Body.String())
+	}
+
+	// Projects with tests cannot be deleted
+	if recorder := call(http.MethodDelete, "/projects/team-a", "", admin); recorder.Code != http.StatusConflict {
+		t.Errorf("Expected deleting a project with tests to conflict, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodPut, "/projects/team-a", `{"Name": "team-b"}`, admin); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected renaming a project to be rejected, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodDelete, "/tests/team-a:checkout", "", member); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the test to be deleted, got %s", recorder.Body.String())
+	}
+	if recorder := call(http.MethodDelete, "/projects/team-a", "", admin); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the project to be deleted, got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// Internal function used to format a revision as an entity tag
+func revisionETag(revision int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(revision))
+}
+
+// Internal function used to read the revision an update expects the object to be at.
+// The If-Match header takes precedence over the revision of the body, and an update
+// which specifies neither is applied regardless of the current revision.
+func expectedRevision(r *http.Request, bodyRevision int) (int, error) {
+	match := r.Header.Get("If-Match")
+	if match == "" || match == "*" {
+		if bodyRevision > 0 {
+			return bodyRevision, nil
+		}
+		return sto.AnyRevision, nil
+	}
+
+	revision, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("Invalid If-Match header %s", match)
+	}
+	return revision, nil
+}
+
+// Internal function used to apply a JSON merge patch (RFC 7396) to the JSON representation of current
+func mergePatchJSON(current interface{}, patch []byte) ([]byte, error) {
+	enc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are kept as they are written so large integers are not rounded
+	var target, changes interface{}
+	if err := decodeNumbers(enc, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeNumbers(patch, &changes); err != nil {
+		return nil, fmt.Errorf("`%s` is invalid json", patch)
+	}
+	object, ok := changes.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Patch must be a json object")
+	}
+
+	// A patch without revision applies to whichever revision is current
+	if object["Revision"] == nil {
+		delete(object, "Revision")
+		delete(target.(map[string]interface{}), "Revision")
+	}
+
+	return json.Marshal(mergePatch(dropNulls(target), changes))
+}
+
+// Internal function used to remove the null fields of objects, which validation rejects,
+// such as the empty slices of a marshalled struct
+func dropNulls(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if field == nil {
+				delete(value, key)
+			} else {
+				value[key] = dropNulls(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = dropNulls(value[i])
+		}
+	}
+	return v
+}
+
+// Internal function used to merge patch into target, fields set to null in patch are removed
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+		} else {
+			object[key] = mergePatch(object[key], value)
+		}
+	}
+	return object
+}
+
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Internal function used to retrieve the revision of the Test an instance ran,
+// or the current Test for instances which ran before tests had revisions
+func testOfInstance(instance *m.TestInstance) (*m.Test, error) {
+	if instance.TestRevision > 0 {
+		test, err := sto.GetTestRevision(instance.TestID, instance.TestRevision)
+		if err != nil || test != nil {
+			return test, err
+		}
+	}
+	return sto.GetTestByTestId(instance.TestID)
+}
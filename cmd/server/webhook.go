@@ -0,0 +1,270 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+	"github.com/t-bfame/diago/pkg/webhook"
+)
+
+const (
+	// defaultDeliveryLimit is the number of deliveries returned unless limit is set
+	defaultDeliveryLimit = 100
+	// maxDeliveryLimit is the largest number of deliveries returned at once
+	maxDeliveryLimit = 1000
+	// webhookTestTimeout is the timeout of the single request sending an example to a Webhook
+	webhookTestTimeout = 10 * time.Second
+)
+
+// Internal function used to read a Webhook from the body of r, assigning its ID
+func readWebhook(r *http.Request) (*m.Webhook, error) {
+	bodyContent, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Validate(reflect.TypeOf(m.Webhook{}), bodyContent); err != nil {
+		return nil, err
+	}
+
+	var hook m.Webhook
+	if err := json.Unmarshal(bodyContent, &hook); err != nil {
+		return nil, err
+	}
+	hook.AssignID()
+	return &hook, nil
+}
+
+// Internal function used to write the failure of checking that a Webhook only watches
+// its own existing project, returning whether it does
+func checkWebhookProject(w http.ResponseWriter, hook *m.Webhook) bool {
+	if hook.TestID != "" && m.ProjectOfID(string(hook.TestID)) != hook.Project {
+		w.Write(buildFailure(
+			fmt.Sprintf("Test<%s> is not in Project<%s>", hook.TestID, m.ProjectName(hook.Project)),
+			http.StatusBadRequest,
+			w,
+		))
+		return false
+	}
+	if hook.Project == "" {
+		return true
+	}
+
+	project, err := sto.GetProject(hook.Project)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return false
+	} else if project == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find Project<%s>", hook.Project),
+			http.StatusBadRequest,
+			w,
+		))
+		return false
+	}
+	return true
+}
+
+// Internal function used to hide the Secret of a Webhook from API responses
+func redactWebhook(hook *m.Webhook) *m.Webhook {
+	redacted := *hook
+	redacted.Secret = ""
+	return &redacted
+}
+
+// Internal function used to retrieve the Webhook named by the path of r,
+// writing the failure and returning nil if there is none
+func webhookOf(w http.ResponseWriter, r *http.Request) *m.Webhook {
+	webhookid := mux.Vars(r)["webhookid"]
+
+	hook, err := sto.GetWebhook(m.WebhookID(webhookid))
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return nil
+	} else if hook == nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Cannot find Webhook<%s>", webhookid),
+			http.StatusNotFound,
+			w,
+		))
+		return nil
+	}
+	return hook
+}
+
+func handleWebhookCreate(w http.ResponseWriter, r *http.Request) {
+	hook, err := readWebhook(r)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+	if !allowProject(w, r, hook.Project) || !checkWebhookProject(w, hook) {
+		return
+	}
+
+	existing, err := sto.GetWebhook(hook.ID)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	} else if existing != nil {
+		w.Write(buildFailure(
+			fmt.Sprintf("Webhook<%s> already exists", hook.ID),
+			http.StatusConflict,
+			w,
+		))
+		return
+	}
+
+	if err := sto.SaveWebhook(hook); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"webhookid": string(hook.ID)}, w))
+}
+
+func handleWebhookReadAll(w http.ResponseWriter, r *http.Request) {
+	hooks, err := sto.GetAllWebhooks()
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	result := []*m.Webhook{}
+	for _, hook := range hooks {
+		if visible(r, hook.Project) {
+			result = append(result, redactWebhook(hook))
+		}
+	}
+	w.Write(buildSuccess(result, w))
+}
+
+func handleWebhookRead(w http.ResponseWriter, r *http.Request) {
+	if hook := webhookOf(w, r); hook != nil {
+		w.Write(buildSuccess(redactWebhook(hook), w))
+	}
+}
+
+func handleWebhookUpdate(w http.ResponseWriter, r *http.Request) {
+	webhookid := mux.Vars(r)["webhookid"]
+
+	hook, err := readWebhook(r)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusBadRequest, w))
+		return
+	}
+
+	if hook.ID != m.WebhookID(webhookid) {
+		w.Write(buildFailure(
+			fmt.Sprintf("Name and Project of Webhook<%s> cannot change", webhookid),
+			http.StatusBadRequest,
+			w,
+		))
+		return
+	}
+	if !checkWebhookProject(w, hook) {
+		return
+	}
+
+	current := webhookOf(w, r)
+	if current == nil {
+		return
+	}
+
+	// Secrets are never returned, so updates without one keep the current secret
+	if hook.Secret == "" {
+		hook.Secret = current.Secret
+	}
+
+	if err := sto.SaveWebhook(hook); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"webhookid": webhookid}, w))
+}
+
+func handleWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	hook := webhookOf(w, r)
+	if hook == nil {
+		return
+	}
+
+	if err := sto.DeleteWebhook(hook.ID); err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+
+	w.Write(buildSuccess(map[string]string{"webhookid": string(hook.ID)}, w))
+}
+
+func handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeliveryLimit
+	if raw := r.FormValue("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxDeliveryLimit {
+			w.Write(buildFailure(
+				fmt.Sprintf("limit must be between 1 and %d, got %s", maxDeliveryLimit, raw),
+				http.StatusBadRequest,
+				w,
+			))
+			return
+		}
+		limit = parsed
+	}
+
+	hook := webhookOf(w, r)
+	if hook == nil {
+		return
+	}
+
+	deliveries, err := sto.GetWebhookDeliveries(hook.ID, limit)
+	if err != nil {
+		w.Write(buildFailure(err.Error(), http.StatusInternalServerError, w))
+		return
+	}
+	w.Write(buildSuccess(deliveries, w))
+}
+
+// handleWebhookTest sends an example InstanceSummary to a Webhook right away, with a single
+// attempt, so that its endpoint and Template can be checked without running a Test
+func handleWebhookTest(w http.ResponseWriter, r *http.Request) {
+	event := m.WebhookDone
+	if raw := r.FormValue("event"); raw != "" {
+		event = m.WebhookEvent(raw)
+	}
+
+	hook := webhookOf(w, r)
+	if hook == nil {
+		return
+	}
+
+	now := time.Now().Unix()
+	testID := hook.TestID
+	if testID == "" {
+		testID = m.TestID(m.ScopedID(hook.Project, "example"))
+	}
+	summary := &m.InstanceSummary{
+		Event:      event,
+		Time:       now,
+		InstanceID: m.TestInstanceID(string(testID) + "-" + strconv.FormatInt(now, 10)),
+		TestID:     testID,
+		TestName:   "example",
+		Project:    hook.Project,
+		Type:       "test",
+		StartedBy:  actor(r),
+		CreatedAt:  now,
+		Status:     string(event),
+	}
+
+	dispatcher := webhook.NewDispatcher(webhook.Options{Timeout: webhookTestTimeout, MaxAttempts: 1})
+	w.Write(buildSuccess(dispatcher.Deliver(hook, summary), w))
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/t-bfame/diago/pkg/auth"
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+	"github.com/t-bfame/diago/pkg/webhook"
+)
+
+func TestHandleWebhooks(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB(t)
+
+	admin, _ := auth.GenerateToken()
+	member, _ := auth.GenerateToken()
+	entries := []auth.Token{
+		{Name: "admin", Role: auth.RoleAdmin, Hash: auth.HashToken(admin)},
+		{Name: "member", Role: auth.RoleAdmin, Hash: auth.HashToken(member), Projects: []string{"team-a"}},
+	}
+
+	router := mux.NewRouter()
+	router.Use(Authenticate(&auth.Chain{Authenticators: []auth.Authenticator{auth.NewTokenAuthenticator(entries)}}))
+	(&APIServer{&mgr.TestingJobFunnel{}, &mgr.TestingScheduleManager{}, nil}).Start(router)
+
+	call := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	var received *http.Request
+	var body string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		received, body = r, string(content)
+	}))
+	defer endpoint.Close()
+
+	hook := `{"Name": "chat", "Project": "team-a", "URL": "` + endpoint.URL + `", "Events": ["done"], "Secret": "secret",
+		"Template": "{\"text\": {{json .TestName}}}"}`
+
+	// Webhooks watch existing projects only
+	if recorder := call(http.MethodPost, "/webhooks", hook, admin); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a webhook of a missing project to be rejected, got %d", recorder.Code)
+	}
+	sto.SaveProject(&m.Project{Name: "team-a"})
+	if recorder := call(http.MethodPost, "/webhooks", hook, member); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the webhook to be created, got %s", recorder.Body.String())
+	}
+	if recorder := call(http.MethodPost, "/webhooks", hook, member); recorder.Code != http.StatusConflict {
+		t.Errorf("Expected an existing webhook to conflict, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodPost, "/webhooks", `{"Name": "ci", "URL": "https://ci.example.com"}`, member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected a webhook of the default project to be forbidden, got %d", recorder.Code)
+	}
+	invalid := `{"Name": "ci", "Project": "team-a", "URL": "https://ci.example.com", "TestID": "checkout"}`
+	if recorder := call(http.MethodPost, "/webhooks", invalid, admin); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a webhook of a test of another project to be rejected, got %d", recorder.Code)
+	}
+
+	// Secrets are never returned
+	recorder := call(http.MethodGet, "/webhooks/team-a:chat", "", member)
+	var read struct{ Payload m.Webhook }
+	json.Unmarshal(recorder.Body.Bytes(), &read)
+	if recorder.Code != http.StatusOK || read.Payload.ID != "team-a:chat" || read.Payload.Secret != "" {
+		t.Errorf("Expected the webhook without its secret, got %s", recorder.Body.String())
+	}
+	if stored, _ := sto.GetWebhook("team-a:chat"); stored == nil || stored.Secret != "secret" {
+		t.Errorf("Expected the secret to be stored, got %v", stored)
+	}
+
+	// Updates without a secret keep the current one
+	update := `{"Name": "chat", "Project": "team-a", "URL": "` + endpoint.URL + `", "Events": ["done", "failed"]}`
+	if recorder := call(http.MethodPut, "/webhooks/team-a:chat", update, member); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the webhook to be updated, got %s", recorder.Body.String())
+	}
+	if stored, _ := sto.GetWebhook("team-a:chat"); len(stored.Events) != 2 || stored.Secret != "secret" || stored.Template != "" {
+		t.Errorf("Unexpected updated webhook %v", stored)
+	}
+	if recorder := call(http.MethodPut, "/webhooks/team-a:chat", `{"Name": "other", "Project": "team-a", "URL": "https://ci.example.com"}`, member); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected renaming the webhook to be rejected, got %d", recorder.Code)
+	}
+
+	// An example is sent right away and recorded
+	recorder = call(http.MethodPost, "/webhooks/team-a:chat/test?event=failed", "", member)
+	var tested struct{ Payload m.WebhookDelivery }
+	json.Unmarshal(recorder.Body.Bytes(), &tested)
+	if recorder.Code != http.StatusOK || !tested.Payload.Delivered || tested.Payload.Event != m.WebhookFailed {
+		t.Fatalf("Expected the example to be delivered, got %s", recorder.Body.String())
+	}
+	if received == nil || received.Header.Get(webhook.EventHeader) != "failed" || !strings.Contains(body, `"TestID":"team-a:example"`) {
+		t.Errorf("Unexpected example %s", body)
+	}
+
+	recorder = call(http.MethodGet, "/webhooks/team-a:chat/deliveries", "", member)
+	var deliveries struct{ Payload []*m.WebhookDelivery }
+	json.Unmarshal(recorder.Body.Bytes(), &deliveries)
+	if len(deliveries.Payload) != 1 || deliveries.Payload[0].ID != tested.Payload.ID {
+		t.Errorf("Expected the delivery of the example, got %s", recorder.Body.String())
+	}
+
+	// Restricted callers only see the webhooks of their projects
+	sto.SaveWebhook(&m.Webhook{ID: "ci", Name: "ci", URL: "https://ci.example.com"})
+	var all struct{ Payload []*m.Webhook }
+	json.Unmarshal(call(http.MethodGet, "/webhooks", "", member).Body.Bytes(), &all)
+	if len(all.Payload) != 1 {
+		t.Errorf("Expected only the webhook of team-a, got %d", len(all.Payload))
+	}
+	if recorder := call(http.MethodDelete, "/webhooks/ci", "", member); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected deleting a webhook of the default project to be forbidden, got %d", recorder.Code)
+	}
+
+	if recorder := call(http.MethodDelete, "/webhooks/team-a:chat", "", member); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the webhook to be deleted, got %d", recorder.Code)
+	}
+	if recorder := call(http.MethodGet, "/webhooks/team-a:chat", "", member); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected the deleted webhook to be missing, got %d", recorder.Code)
+	}
+
+	// The first creation failed before the webhook had an ID
+	if audit, _ := sto.GetAuditEntries(sto.AuditQuery{Action: m.AuditWebhookCreate}); len(audit) != 5 || audit[3].Target != "team-a:chat" {
+		t.Errorf("Expected the creations to be audited, got %d", len(audit))
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/t-bfame/diago/pkg/auth"
+)
+
+func runToken(args []string) error {
+	if len(args) != 2 {
+		return errors.New(commandUsage)
+	}
+	role, err := auth.ParseRole(args[1])
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is stored, the token cannot be shown again
+	fmt.Println(token)
+	fmt.Fprintf(os.Stderr, "Add to DIAGO_AUTH_TOKENS_FILE:\n- Name: %q\n  Role: %s\n  Hash: %s\n", args[0], role, auth.HashToken(token))
+	return nil
+}
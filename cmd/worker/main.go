@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/t-bfame/diago/pkg/worker"
+)
+
+func main() {
+	config, err := worker.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if os.Getenv("DIAGO_DEBUG") == "true" {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	if err := worker.NewWorker(config).Run(ctx); err != nil && err != context.Canceled {
+		log.WithError(err).Fatal("Worker stopped")
+	}
+
+	log.Info("Worker exited")
+}
@@ -17,6 +17,49 @@ type Config struct {
 
 	StoragePath string `envconfig:"DIAGO_STORAGE_PATH" default:"diago.db"`
 
+	// StorageBackend decides how objects are stored at StoragePath, see StorageBolt and StorageSQLite
+	StorageBackend string `envconfig:"DIAGO_STORAGE_BACKEND" default:"bolt"`
+
+	// WorkerBackend decides how workers are provisioned, see BackendKubernetes and BackendLocal
+	WorkerBackend               string `envconfig:"DIAGO_WORKER_BACKEND" default:"kubernetes"`
+	LocalWorkerCommand          string `envconfig:"DIAGO_LOCAL_WORKER_COMMAND" default:"diago-worker"`
+	LocalWorkerInactivityPeriod uint64 `envconfig:"DIAGO_LOCAL_WORKER_INACTIVITY_PERIOD" default:"60"`
+
+	// Preemption allows jobs of higher priority to stop running jobs of lower priority when there is not enough capacity
+	Preemption bool `envconfig:"DIAGO_PREEMPTION" default:"false"`
+
+	// RecoveryGracePeriod is the number of seconds workers of a previous leader have to register again before they are removed
+	RecoveryGracePeriod uint64 `envconfig:"DIAGO_RECOVERY_GRACE_PERIOD" default:"60"`
+
+	// Retention of finished test instances, baselines are always kept. Instances beyond the
+	// RetentionKeepLast most recent of a test or older than RetentionMaxAge hours are deleted,
+	// unless they failed and RetentionKeepFailed is set. Zero keeps all instances.
+	RetentionKeepLast   uint64 `envconfig:"DIAGO_RETENTION_KEEP_LAST" default:"0"`
+	RetentionMaxAge     uint64 `envconfig:"DIAGO_RETENTION_MAX_AGE_HOURS" default:"0"`
+	RetentionKeepFailed bool   `envconfig:"DIAGO_RETENTION_KEEP_FAILED" default:"true"`
+	// RetentionInterval is the number of seconds between clean ups
+	RetentionInterval uint64 `envconfig:"DIAGO_RETENTION_INTERVAL" default:"3600"`
+
+	// Authentication of API calls, which is disabled unless AuthTokensFile or AuthJWKSFile is set.
+	// AuthTokensFile holds the hashes of static API tokens, see "diago token".
+	AuthTokensFile string `envconfig:"DIAGO_AUTH_TOKENS_FILE" default:""`
+	// AuthJWKSFile holds the keys JWT bearer tokens are signed with, such as the JWKS of an OIDC provider
+	AuthJWKSFile     string `envconfig:"DIAGO_AUTH_JWKS_FILE" default:""`
+	AuthJWTIssuer    string `envconfig:"DIAGO_AUTH_JWT_ISSUER" default:""`
+	AuthJWTAudience  string `envconfig:"DIAGO_AUTH_JWT_AUDIENCE" default:""`
+	AuthJWTNameClaim string `envconfig:"DIAGO_AUTH_JWT_NAME_CLAIM" default:"sub"`
+	AuthJWTRoleClaim string `envconfig:"DIAGO_AUTH_JWT_ROLE_CLAIM" default:"role"`
+	// AuthJWTProjectsClaim restricts JWTs which have it to the projects it holds
+	AuthJWTProjectsClaim string `envconfig:"DIAGO_AUTH_JWT_PROJECTS_CLAIM" default:"projects"`
+	// AuthAnonymousRole is given to calls without credentials, which are rejected if it is empty
+	AuthAnonymousRole string `envconfig:"DIAGO_AUTH_ANONYMOUS_ROLE" default:""`
+
+	// Webhooks are sent with WebhookTimeout seconds per request and retried up to WebhookMaxAttempts
+	// requests in total, waiting WebhookBackoff seconds before the first retry and twice as long every time
+	WebhookTimeout     uint64 `envconfig:"DIAGO_WEBHOOK_TIMEOUT" default:"10"`
+	WebhookMaxAttempts uint64 `envconfig:"DIAGO_WEBHOOK_MAX_ATTEMPTS" default:"5"`
+	WebhookBackoff     uint64 `envconfig:"DIAGO_WEBHOOK_BACKOFF" default:"2"`
+
 	Debug bool `envconfig:"DIAGO_DEBUG" default:"false"`
 
 	GrafanaBasePath     string `envconfig:"DIAGO_GRAFANA_BASE_PATH" default:""`
@@ -24,6 +67,20 @@ type Config struct {
 	GrafanaDashboardConfig     string `envconfig:"DIAGO_GRAFANA_DASHBOARD_CONFIG"`
 }
 
+const (
+	// BackendKubernetes runs workers as pods of a WorkerGroup, leader must run in cluster
+	BackendKubernetes = "kubernetes"
+	// BackendLocal runs workers as processes on the same machine as the leader
+	BackendLocal = "local"
+)
+
+const (
+	// StorageBolt stores objects in a boltDB file, which only a single leader can open
+	StorageBolt = "bolt"
+	// StorageSQLite stores objects in a SQLite database, which can be queried with SQL
+	StorageSQLite = "sqlite"
+)
+
 var Diago *Config
 
 // Initializes a new Diago config
@@ -37,6 +94,14 @@ func Init() error {
 		log.Fatal(err.Error())
 	}
 
+	if c.WorkerBackend != BackendKubernetes && c.WorkerBackend != BackendLocal {
+		log.Fatalf("Unknown worker backend %s", c.WorkerBackend)
+	}
+
+	if c.StorageBackend != StorageBolt && c.StorageBackend != StorageSQLite {
+		log.Fatalf("Unknown storage backend %s", c.StorageBackend)
+	}
+
 	Diago = &c
 
 	if Diago.GrafanaDashboardConfig == "" {
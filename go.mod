@@ -29,4 +29,5 @@ require (
 	k8s.io/apimachinery v0.17.3
 	k8s.io/client-go v0.17.3
 	k8s.io/utils v0.0.0-20210111153108-fddb29f9d009 // indirect
+	modernc.org/sqlite v1.10.6
 )
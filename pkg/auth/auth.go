@@ -0,0 +1,164 @@
+// Package auth identifies the callers of the API, with static API tokens or JWTs
+// signed by an identity provider, and the roles which decide what they may do.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/t-bfame/diago/config"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// Role decides which API calls an identity may make, each role may do what the previous ones do
+type Role string
+
+const (
+	// RoleViewer reads tests, schedules, instances and their reports
+	RoleViewer Role = "viewer"
+	// RoleRunner starts and stops tests
+	RoleRunner Role = "runner"
+	// RoleAdmin creates, modifies and deletes tests and schedules, and backs up and restores the storage
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleRunner: 2, RoleAdmin: 3}
+
+// ParseRole returns the Role named by s
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, exists := roleRanks[role]; !exists {
+		return "", fmt.Errorf("unknown role %s", s)
+	}
+	return role, nil
+}
+
+// Includes returns whether the role may do what required may do
+func (r Role) Includes(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Identity is the caller of an API call
+type Identity struct {
+	Name string
+	Role Role
+
+	// Method is how the identity was authenticated, such as token or jwt
+	Method string
+
+	// Projects the identity may access, every project if nil
+	Projects []string
+}
+
+// CanAccess returns whether the identity may access the objects of project
+func (i *Identity) CanAccess(project string) bool {
+	if i.Projects == nil {
+		return true
+	}
+	for _, p := range i.Projects {
+		if m.ProjectName(p) == m.ProjectName(project) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoCredentials is returned by an Authenticator for requests without credentials it handles
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the identity of the caller of r, ErrNoCredentials if r carries
+	// no credentials the Authenticator handles, or an error if the credentials are invalid
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Chain tries each of its Authenticators in turn. Requests without credentials are
+// given the Anonymous role if it is set, and are rejected otherwise.
+type Chain struct {
+	Authenticators []Authenticator
+	Anonymous      Role
+}
+
+func (c *Chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range c.Authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if err != ErrNoCredentials {
+			return identity, err
+		}
+	}
+
+	if bearerToken(r) != "" {
+		return nil, errors.New("invalid credentials")
+	}
+	if c.Anonymous != "" {
+		return &Identity{Name: "anonymous", Role: c.Anonymous, Method: "anonymous"}, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// FromConfig creates the Authenticator configured by c, which is nil if authentication is disabled
+func FromConfig(c *config.Config) (Authenticator, error) {
+	chain := &Chain{}
+
+	if c.AuthTokensFile != "" {
+		tokens, err := LoadTokens(c.AuthTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		chain.Authenticators = append(chain.Authenticators, NewTokenAuthenticator(tokens))
+	}
+
+	if c.AuthJWKSFile != "" {
+		keys, err := LoadJWKS(c.AuthJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		chain.Authenticators = append(chain.Authenticators, &JWTAuthenticator{
+			Keys:          keys,
+			Issuer:        c.AuthJWTIssuer,
+			Audience:      c.AuthJWTAudience,
+			NameClaim:     c.AuthJWTNameClaim,
+			RoleClaim:     c.AuthJWTRoleClaim,
+			ProjectsClaim: c.AuthJWTProjectsClaim,
+		})
+	}
+
+	if len(chain.Authenticators) == 0 {
+		return nil, nil
+	}
+
+	if c.AuthAnonymousRole != "" {
+		role, err := ParseRole(c.AuthAnonymousRole)
+		if err != nil {
+			return nil, err
+		}
+		chain.Anonymous = role
+	}
+	return chain, nil
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of r whose context carries identity
+func WithIdentity(r *http.Request, identity *Identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, identity))
+}
+
+// IdentityOf returns the identity of the caller of r, nil if authentication is disabled
+func IdentityOf(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(contextKey{}).(*Identity)
+	return identity
+}
+
+// Internal function used to read the bearer token of the Authorization header of r
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
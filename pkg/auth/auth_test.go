@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func request(token string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/api/tests", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, RoleAdmin.Includes(RoleRunner))
+	assert.True(t, RoleRunner.Includes(RoleRunner))
+	assert.False(t, RoleViewer.Includes(RoleRunner))
+	assert.False(t, Role("").Includes(RoleViewer))
+
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	file, err := ioutil.TempFile("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	token, _ := GenerateToken()
+	file.WriteString("- Name: ci\n  Role: runner\n  Hash: " + HashToken(token) + "\n")
+	file.Close()
+
+	tokens, err := LoadTokens(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Token{{"ci", RoleRunner, HashToken(token), nil}}, tokens)
+
+	authenticator := NewTokenAuthenticator(tokens)
+	identity, err := authenticator.Authenticate(request(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &Identity{"ci", RoleRunner, "token", nil}, identity)
+
+	if _, err := authenticator.Authenticate(request(token + "x")); err == nil || err == ErrNoCredentials {
+		t.Errorf("Expected an unknown token to be rejected, got %v", err)
+	}
+	if _, err := authenticator.Authenticate(request("")); err != ErrNoCredentials {
+		t.Errorf("Expected a request without token to have no credentials, got %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"unknown role":  "- Name: ci\n  Role: owner\n  Hash: " + HashToken(token) + "\n",
+		"plain token":   "- Name: ci\n  Role: admin\n  Hash: " + token + "\n",
+		"no name":       "- Role: admin\n  Hash: " + HashToken(token) + "\n",
+		"empty project": "- Name: ci\n  Role: admin\n  Hash: " + HashToken(token) + "\n  Projects: ['']\n",
+	} {
+		ioutil.WriteFile(file.Name(), []byte(content), 0600)
+		if _, err := LoadTokens(file.Name()); err == nil {
+			t.Errorf("Expected tokens file with %s to be rejected", name)
+		}
+	}
+
+	// Tokens listing projects may only access them
+	ioutil.WriteFile(file.Name(), []byte("- Name: ci\n  Role: runner\n  Hash: "+HashToken(token)+"\n  Projects: [team-a]\n"), 0600)
+	if tokens, err = LoadTokens(file.Name()); err != nil {
+		t.Fatal(err)
+	}
+	identity, err = NewTokenAuthenticator(tokens).Authenticate(request(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"team-a"}, identity.Projects)
+	assert.True(t, identity.CanAccess("team-a"))
+	assert.False(t, identity.CanAccess(""))
+}
+
+// Internal function used to sign claims with key as a JWT
+func sign(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := signingAlgorithms[alg].hash
+	digest := hash.New()
+	digest.Write([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, hash, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encodeInt(rsaKey.N), "e": encodeInt(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		},
+	})
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(keys))
+
+	now := time.Unix(1618100600, 0)
+	authenticator := &JWTAuthenticator{
+		Keys:     keys,
+		Issuer:   "https://idp.example.com",
+		Audience: "diago",
+		now:      func() time.Time { return now },
+	}
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub":  "alice",
+			"iss":  "https://idp.example.com",
+			"aud":  []string{"diago", "other"},
+			"exp":  now.Add(time.Hour).Unix(),
+			"role": []string{"viewer", "runner"},
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	for _, token := range []string{
+		sign(t, "RS256", "rsa", rsaKey, claims(nil)),
+		sign(t, "ES256", "ec", ecKey, claims(nil)),
+	} {
+		identity, err := authenticator.Authenticate(request(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &Identity{"alice", RoleRunner, "jwt", nil}, identity)
+	}
+
+	identity, err := authenticator.Authenticate(request(sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"projects": "team-a"}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"team-a"}, identity.Projects)
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	invalid := map[string]string{
+		"wrong key":        sign(t, "RS256", "rsa", otherKey, claims(nil)),
+		"unknown kid":      sign(t, "RS256", "other", rsaKey, claims(nil)),
+		"mismatched alg":   sign(t, "ES256", "rsa", ecKey, claims(nil)),
+		"expired":          sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+		"no expiry":        sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": nil})),
+		"not valid yet":    sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+		"wrong issuer":     sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		"wrong audience":   sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"aud": "other"})),
+		"no role":          sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"role": "owner"})),
+		"no subject":       sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"sub": nil})),
+		"invalid projects": sign(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"projects": 1})),
+		"unsigned":         strings.SplitAfter(sign(t, "RS256", "rsa", rsaKey, claims(nil)), ".")[0] + "e30.",
+		"malformed claim":  "e30.e30.e30",
+	}
+	for name, token := range invalid {
+		if _, err := authenticator.Authenticate(request(token)); err == nil || err == ErrNoCredentials {
+			t.Errorf("Expected JWT with %s to be rejected, got %v", name, err)
+		}
+	}
+
+	if _, err := authenticator.Authenticate(request("diago_token")); err != ErrNoCredentials {
+		t.Errorf("Expected API tokens to be left to other authenticators, got %v", err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	token, _ := GenerateToken()
+	chain := &Chain{Authenticators: []Authenticator{
+		NewTokenAuthenticator([]Token{{"ci", RoleAdmin, HashToken(token), nil}}),
+	}}
+
+	if identity, err := chain.Authenticate(request(token)); err != nil || identity.Name != "ci" {
+		t.Errorf("Expected the token to be authenticated, got %v %v", identity, err)
+	}
+	if _, err := chain.Authenticate(request("")); err != ErrNoCredentials {
+		t.Errorf("Expected a request without credentials to be rejected, got %v", err)
+	}
+	// No authenticator handles JWTs
+	if _, err := chain.Authenticate(request("e30.e30.e30")); err == nil || err == ErrNoCredentials {
+		t.Errorf("Expected unhandled credentials to be rejected, got %v", err)
+	}
+
+	chain.Anonymous = RoleViewer
+	if identity, err := chain.Authenticate(request("")); err != nil || identity.Role != RoleViewer {
+		t.Errorf("Expected an anonymous viewer, got %v %v", identity, err)
+	}
+}
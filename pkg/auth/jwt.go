@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // hashes of the supported algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is the leeway given to the expiry and not before times of JWTs
+const clockSkew = time.Minute
+
+// signingAlgorithm is a JWS algorithm accepted for JWTs, symmetric algorithms are not
+type signingAlgorithm struct {
+	hash crypto.Hash
+	// curve of ECDSA algorithms, nil for RSA algorithms
+	curve elliptic.Curve
+}
+
+var signingAlgorithms = map[string]signingAlgorithm{
+	"RS256": {crypto.SHA256, nil},
+	"RS384": {crypto.SHA384, nil},
+	"RS512": {crypto.SHA512, nil},
+	"ES256": {crypto.SHA256, elliptic.P256()},
+	"ES384": {crypto.SHA384, elliptic.P384()},
+	"ES512": {crypto.SHA512, elliptic.P521()},
+}
+
+// JWTAuthenticator identifies callers by a JWT bearer token signed by one of its keys, as issued by an OIDC provider
+type JWTAuthenticator struct {
+	// Keys by their key ID, see LoadJWKS
+	Keys map[string]crypto.PublicKey
+
+	// Issuer and Audience the JWT must have, if set
+	Issuer   string
+	Audience string
+
+	// NameClaim names the identity, sub by default
+	NameClaim string
+	// RoleClaim holds the role of the identity, a role or a list of which the highest is used, role by default
+	RoleClaim string
+	// ProjectsClaim holds the projects the identity may access, a project or a list,
+	// projects by default. Identities without the claim may access every project.
+	ProjectsClaim string
+
+	// now returns the current time, time.Now by default
+	now func() time.Time
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	bearer := bearerToken(r)
+	if !isJWT(bearer) {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(bearer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %s", err)
+	}
+
+	nameClaim := a.NameClaim
+	if nameClaim == "" {
+		nameClaim = "sub"
+	}
+	name, _ := claims[nameClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("invalid JWT: missing %s claim", nameClaim)
+	}
+
+	roleClaim := a.RoleClaim
+	if roleClaim == "" {
+		roleClaim = "role"
+	}
+	role := highestRole(claims[roleClaim])
+	if role == "" {
+		return nil, fmt.Errorf("invalid JWT: %s claim has no known role", roleClaim)
+	}
+
+	projectsClaim := a.ProjectsClaim
+	if projectsClaim == "" {
+		projectsClaim = "projects"
+	}
+	projects, err := claimStrings(claims, projectsClaim)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %s", err)
+	}
+
+	return &Identity{Name: name, Role: role, Method: "jwt", Projects: projects}, nil
+}
+
+// Internal function used to read a claim holding a string or a list of strings, nil if it is missing
+func claimStrings(claims map[string]interface{}, claim string) ([]string, error) {
+	switch value := claims[claim].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s claim must only hold strings", claim)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%s claim must be a string or a list of strings", claim)
+}
+
+// Internal function used to check the signature and registered claims of token, returning its claims
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %s", err)
+	}
+
+	alg, supported := signingAlgorithms[header.Alg]
+	if !supported {
+		return nil, fmt.Errorf("unsupported algorithm %s", header.Alg)
+	}
+
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %s", err)
+	}
+	digest := alg.hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(alg, key, digest.Sum(nil), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %s", err)
+	}
+	return claims, a.checkClaims(claims)
+}
+
+// Internal function used to find the key which signed a JWT, which may omit its key ID if there is only one key
+func (a *JWTAuthenticator) key(kid string) (crypto.PublicKey, error) {
+	if key, exists := a.Keys[kid]; exists {
+		return key, nil
+	}
+	if kid == "" && len(a.Keys) == 1 {
+		for _, key := range a.Keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// Internal function used to check the time, issuer and audience claims of a JWT
+func (a *JWTAuthenticator) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return errors.New("expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return errors.New("not valid yet")
+	}
+
+	if a.Issuer != "" && claims["iss"] != a.Issuer {
+		return fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+
+	if a.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud == a.Audience {
+				return nil
+			}
+		case []interface{}:
+			for _, v := range aud {
+				if v == a.Audience {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	return nil
+}
+
+func verifySignature(alg signingAlgorithm, key crypto.PublicKey, digest []byte, signature []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg.curve != nil {
+			return errors.New("algorithm does not match the RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(key, alg.hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if alg.curve == nil || alg.curve.Params().Name != key.Curve.Params().Name {
+			return errors.New("algorithm does not match the EC key")
+		}
+		// The signature is the concatenation of r and s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+// jsonWebKey holds the parameters of RSA and EC public keys of a JWK set
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads the signature keys of a JWK set (RFC 7517) from the file at path, by their key ID
+func LoadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS from %s: %s", path, err)
+	}
+	return keys, nil
+}
+
+// ParseJWKS decodes the RSA and EC signature keys of a JWK set by their key ID, other keys are left out
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %s", i, err)
+		}
+
+		if _, exists := keys[jwk.Kid]; exists {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, jwk.Kid)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signature keys")
+	}
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk *jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+	}
+
+	x, err := decodeInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Internal function used to tell JWTs, which have three segments, apart from API tokens
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// Internal function used to read a NumericDate claim, seconds since the epoch
+func numericDate(v interface{}) (time.Time, bool) {
+	number, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// Internal function used to read the highest known role of a role claim, a string or a list of strings
+func highestRole(claim interface{}) Role {
+	var names []interface{}
+	switch v := claim.(type) {
+	case string:
+		names = []interface{}{v}
+	case []interface{}:
+		names = v
+	}
+
+	var highest Role
+	for _, name := range names {
+		s, _ := name.(string)
+		if role, err := ParseRole(s); err == nil && role.Includes(highest) {
+			highest = role
+		}
+	}
+	return highest
+}
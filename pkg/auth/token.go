@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// tokenPrefix starts every generated token, which tells them apart from JWTs
+const tokenPrefix = "diago_"
+
+// Token is a static API token, only the hash of the token is stored
+type Token struct {
+	Name string
+	Role Role
+
+	// Hash is the hex encoded SHA-256 of the token, see HashToken
+	Hash string
+
+	// Projects the token may access, every project if empty
+	Projects []string
+}
+
+// GenerateToken returns a new random API token
+func GenerateToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashToken returns the hash of token which is stored instead of the token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadTokens reads a YAML or JSON list of Token from the file at path
+func LoadTokens(path string) ([]Token, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var tokens []Token
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("failed to read tokens from %s: %s", path, err)
+	}
+
+	names := map[string]bool{}
+	for i, token := range tokens {
+		if token.Name == "" || names[token.Name] {
+			return nil, fmt.Errorf("token %d of %s must have a unique Name", i, path)
+		}
+		names[token.Name] = true
+
+		if _, err := ParseRole(string(token.Role)); err != nil {
+			return nil, fmt.Errorf("token %s: %s", token.Name, err)
+		}
+		if hash, err := hex.DecodeString(token.Hash); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("token %s: Hash must be a hex encoded SHA-256", token.Name)
+		}
+		for _, project := range token.Projects {
+			if project == "" {
+				return nil, fmt.Errorf("token %s: Projects cannot be empty strings", token.Name)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// TokenAuthenticator identifies callers by the static API token in their Authorization header
+type TokenAuthenticator struct {
+	tokens map[string]Token
+}
+
+func NewTokenAuthenticator(tokens []Token) *TokenAuthenticator {
+	byHash := make(map[string]Token, len(tokens))
+	for _, token := range tokens {
+		byHash[strings.ToLower(token.Hash)] = token
+	}
+	return &TokenAuthenticator{byHash}
+}
+
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	bearer := bearerToken(r)
+	if bearer == "" || isJWT(bearer) {
+		return nil, ErrNoCredentials
+	}
+
+	// Tokens are looked up by their hash, so comparisons do not depend on the secret
+	token, exists := a.tokens[HashToken(bearer)]
+	if !exists {
+		return nil, fmt.Errorf("unknown API token")
+	}
+	identity := &Identity{Name: token.Name, Role: token.Role, Method: "token"}
+	if len(token.Projects) > 0 {
+		identity.Projects = token.Projects
+	}
+	return identity, nil
+}
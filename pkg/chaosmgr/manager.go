@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	c "github.com/t-bfame/diago/config"
 	m "github.com/t-bfame/diago/pkg/model"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -99,6 +100,11 @@ func (cm *ChaosManager) deletePod(name string, namespace string, timeout uint64,
 // an array of pods being deleted as a part of the chaos
 // OR an error indicating if there was an error starting the simulation
 func (cm *ChaosManager) Simulate(testId m.TestID, instance *m.ChaosInstance, testDuration uint64) (chan error, []string, error) {
+	// Without kubernetes there are no pods to simulate disaster for
+	if cm.clientset == nil {
+		return nil, nil, errors.New("Chaos simulation requires the kubernetes worker backend")
+	}
+
 	// Fetch names of pods that we can simulate disaster for
 	p, err := cm.relevantPodNames(instance)
 
@@ -177,7 +183,12 @@ func (cm *ChaosManager) Simulate(testId m.TestID, instance *m.ChaosInstance, tes
 // For the provided test template ID and chaosID
 // stop the load test simulation by closing the rror channel
 func (cm *ChaosManager) Stop(testId m.TestID, instanceID m.ChaosID) {
-	funnelCh := cm.chMap[fmt.Sprintf("%s-%s", testId, instanceID)]
+	funnelCh, ok := cm.chMap[fmt.Sprintf("%s-%s", testId, instanceID)]
+
+	// simulation was never started
+	if !ok {
+		return
+	}
 
 	// remove the channel from map 
 	delete(cm.chMap, fmt.Sprintf("%s-%s", testId, instanceID))
@@ -187,6 +198,16 @@ func (cm *ChaosManager) Stop(testId m.TestID, instanceID m.ChaosID) {
 
 // NewChaosManager laalala
 func NewChaosManager() *ChaosManager {
+	cm := new(ChaosManager)
+	cm.podMap = make(map[string]bool)
+	cm.chMap = make(map[string]chan error)
+
+	// chaos cannot be simulated without a cluster
+	if c.Diago.WorkerBackend == c.BackendLocal {
+		log.Info("Chaos simulation is disabled for the local worker backend")
+		return cm
+	}
+
 	// creates the in-cluster config
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -199,10 +220,7 @@ func NewChaosManager() *ChaosManager {
 		panic(err.Error())
 	}
 
-	cm := new(ChaosManager)
 	cm.clientset = clientset
-	cm.podMap = make(map[string]bool)
-	cm.chMap = make(map[string]chan error)
 
 	if err != nil {
 		panic(err.Error())
@@ -21,8 +21,10 @@ import (
 type JobFunnel interface {
 	startOp(key string)
 	endOp(key string)
-	BeginTest(testID m.TestID, testType string) error
+	BeginTest(testID m.TestID, testType string, startedBy string) error
 	StopTest(testID m.TestID) error
+	GroupExists(group string) bool
+	Subscribe(instanceID m.TestInstanceID) *metrics.LiveSubscription
 }
 
 type JobFunnelImpl struct {
@@ -31,6 +33,16 @@ type JobFunnelImpl struct {
 	ongoing    map[string]bool
 	scheduler  *s.Scheduler
 	chaosmgr   *cm.ChaosManager
+
+	// projectLock is held while Tests are added to or removed from ongoing,
+	// so a starting Test is checked against the quota of its Project alone
+	projectLock *sync.Mutex
+
+	// live holds the feeds of the results of running TestInstances, see Subscribe
+	live *liveFeeds
+
+	// notifier is told about TestInstances entering a status, nil if nobody listens
+	notifier Notifier
 }
 
 func (jf *JobFunnelImpl) startOp(key string) {
@@ -47,7 +59,7 @@ func (jf *JobFunnelImpl) endOp(key string) {
 	jf.testLocks[key].Unlock()
 }
 
-func (jf *JobFunnelImpl) RunChaosSimulation(testID m.TestID, chaosInstances []m.ChaosInstance, testDuration uint64) map[m.ChaosID]m.ChaosResult {
+func (jf *JobFunnelImpl) RunChaosSimulation(testID m.TestID, chaosInstances []m.ChaosInstance, testDuration uint64, feed *metrics.LiveFeed) map[m.ChaosID]m.ChaosResult {
 	result := make(map[m.ChaosID]m.ChaosResult)
 	chaosGroup := sync.WaitGroup{}
 
@@ -76,6 +88,11 @@ func (jf *JobFunnelImpl) RunChaosSimulation(testID m.TestID, chaosInstances []m.
 					Status: m.ChaosFail,
 					Error:  err.Error(),
 				}
+				feed.AddEvent(m.InstanceEvent{
+					Time:    time.Now().Unix(),
+					Type:    "chaos",
+					Message: fmt.Sprintf("Chaos<%s> failed: %s", id, err),
+				})
 				return
 			}
 
@@ -84,6 +101,11 @@ func (jf *JobFunnelImpl) RunChaosSimulation(testID m.TestID, chaosInstances []m.
 				DeletedPods: deletedPodNames,
 				Error:       "",
 			}
+			feed.AddEvent(m.InstanceEvent{
+				Time:    time.Now().Unix(),
+				Type:    "chaos",
+				Message: fmt.Sprintf("Chaos<%s> deleted pods %v", id, deletedPodNames),
+			})
 		}(c.ID, deletedPodNames)
 	}
 
@@ -91,9 +113,14 @@ func (jf *JobFunnelImpl) RunChaosSimulation(testID m.TestID, chaosInstances []m.
 	return result
 }
 
+// GroupExists checks whether workers of the group can run the jobs of a Test
+func (jf *JobFunnelImpl) GroupExists(group string) bool {
+	return jf.scheduler.GroupExists(group)
+}
+
 // BeginTest creates a TestInstance for the Test with the specified TestID
 // if another instance of the same Test is not already ongoing
-func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
+func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string, startedBy string) error {
 	key := string(testID)
 	jf.startOp(key)
 	defer jf.endOp(key)
@@ -107,15 +134,25 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 		return fmt.Errorf("Test<%s> is already ongoing", testID)
 	}
 
+	jf.projectLock.Lock()
+	defer jf.projectLock.Unlock()
+	if err := jf.checkQuota(test); err != nil {
+		return err
+	}
+
 	// make instance
 	now := time.Now().Unix()
-	instanceid := test.Name + "-" + strconv.FormatInt(now, 10)
+	instanceid := string(test.ID) + "-" + strconv.FormatInt(now, 10)
 	instance := &m.TestInstance{
 		ID:        m.TestInstanceID(instanceid),
 		TestID:    m.TestID(testID),
+		Project:   test.Project,
 		Type:      testType,
 		Status:    "submitted",
 		CreatedAt: now,
+
+		TestRevision: test.Revision,
+		StartedBy:    startedBy,
 	}
 
 	// save instance
@@ -124,9 +161,19 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 		return err
 	}
 
+	jobIDs := make([]string, 0, len(test.Jobs))
+	for _, j := range test.Jobs {
+		jobIDs = append(jobIDs, string(j.ID))
+	}
+	feed := metrics.NewLiveFeed(jobIDs)
+
 	jobGroup := sync.WaitGroup{}
 	jobMAggs := map[string]*metrics.Metrics{}
 	jobGroupStart := sync.WaitGroup{}
+	abortOnce := sync.Once{}
+	partial := newCheckpoint()
+	events := newEventQueue()
+	go jf.runEvents(testID, instance.ID, events)
 	var testDuration uint64 = 0
 
 	for i, v := range test.Jobs {
@@ -139,6 +186,8 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 			instance.Error = err.Error()
 			// save instance
 			sto.AddTestInstance(instance)
+			jf.notify(m.WebhookFailed, test, instance, nil)
+			events.close()
 
 			// cancel previously submitted jobs
 			for idx := 0; idx < i; idx++ {
@@ -152,7 +201,7 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 						Info("Failed to stop job")
 				}
 			}
-			return fmt.Errorf("Job<%s> failed to submit: %s", v.ID, err)
+			return &submitError{v.ID, err}
 		}
 
 		jobGroup.Add(1)
@@ -165,19 +214,65 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 		)
 		jobMAggs[string(v.ID)] = mAgg
 
+		monitor := metrics.NewAbortMonitor(test.AbortConditions, v.Name)
+
 		// listen on each channel for job events
 		go func(j m.Job, mAgg *metrics.Metrics) {
 			defer jobGroup.Done()
+			started := false
+			checkpointed := time.Now()
 			for msg := range ch {
 				switch x := msg.(type) {
 				case s.Metrics:
 					mAgg.Add(&x)
+					feed.Add(string(j.ID), &x)
+					if time.Since(checkpointed) >= checkpointInterval {
+						partial.update(string(j.ID), mAgg.Snapshot())
+						checkpointed = time.Now()
+					}
+					if reason := monitor.Add(&x); reason != "" {
+						abortOnce.Do(func() {
+							go jf.abortTest(testID, fmt.Sprintf("Job<%s>: %s", j.Name, reason))
+						})
+					}
 				case s.Start:
+					// Only the first start of a job is awaited
+					if started {
+						continue
+					}
+					started = true
 					log.WithField("Start event", msg).Info("Starting job")
 					jobGroupStart.Done()
+				case s.Reassign:
+					events.push(m.InstanceEvent{
+						Time:  time.Now().Unix(),
+						Type:  "reassign",
+						JobID: j.ID,
+						Message: fmt.Sprintf("Workload of %d/s lost with worker %s reassigned to %v for the remaining %ds",
+							x.Frequency, x.Lost, x.Instances, x.Remaining),
+					})
+				case s.Preempt:
+					events.push(m.InstanceEvent{
+						Time:    time.Now().Unix(),
+						Type:    "preempted",
+						JobID:   j.ID,
+						Message: fmt.Sprintf("Job preempted by job %s of higher priority", x.By),
+					})
+				case s.Resume:
+					events.push(m.InstanceEvent{
+						Time:  time.Now().Unix(),
+						Type:  "resumed",
+						JobID: j.ID,
+						Message: fmt.Sprintf("Job resumed at %d/s on %v for the remaining %ds",
+							x.Frequency, x.Instances, x.Remaining),
+					})
 				default:
 				}
 			}
+			// Job was stopped while queued
+			if !started {
+				jobGroupStart.Done()
+			}
 			mAgg.Close()
 			log.
 				WithField("TestID", testID).
@@ -187,15 +282,30 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 		}(v, mAgg)
 	}
 
+	// the feed is registered before the jobs can finish and runLive removes it
+	jf.live.add(instance.ID, feed)
+
 	// wait for all jobs to finish or stop
 	go func() {
 		// Wait for jobs to start
 		jobGroupStart.Wait()
 
+		// Persist partial metrics while the jobs are running, so they
+		// survive a restart of the leader
+		finished := make(chan struct{})
+		if running := jf.markRunning(testID, instance.ID); running != nil {
+			jf.notify(m.WebhookRunning, test, running, nil)
+		}
+		go jf.runCheckpoints(testID, instance.ID, partial, finished)
+		go jf.runLive(instance.ID, test.Jobs, feed, finished)
+
 		// Complete Chaos simulation with result
-		chaosResult := jf.RunChaosSimulation(testID, test.Chaos, testDuration)
+		chaosResult := jf.RunChaosSimulation(testID, test.Chaos, testDuration, feed)
 
 		jobGroup.Wait()
+		close(finished)
+		events.close()
+
 		jf.startOp(key)
 		defer jf.endOp(key)
 
@@ -211,10 +321,24 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 			instance.Status = "done"
 			instance.Metrics = jobMAggs
 			instance.ChaosResult = chaosResult
+			instance.Verdict, instance.CriteriaResults = metrics.Evaluate(test, jobMAggs)
+			sto.AddTestInstance(instance)
+		} else if instance.Status == "aborted" {
+			// keep what was measured up to the abort, an aborted
+			// instance cannot pass its criteria
+			instance.Metrics = jobMAggs
+			instance.ChaosResult = chaosResult
+			instance.Verdict, instance.CriteriaResults = metrics.Evaluate(test, jobMAggs)
+			if instance.Verdict != "" {
+				instance.Verdict = m.VerdictFailed
+			}
 			sto.AddTestInstance(instance)
 		}
+		jf.notify(m.WebhookEvent(instance.Status), test, instance, jobMAggs)
 
+		jf.projectLock.Lock()
 		delete(jf.ongoing, key)
+		jf.projectLock.Unlock()
 
 		log.
 			WithField("TestID", testID).
@@ -227,6 +351,7 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 
 	instance.Status = "submitted"
 	sto.AddTestInstance(instance)
+	jf.notify(m.WebhookSubmitted, test, instance, nil)
 
 	log.
 		WithField("TestID", testID).
@@ -235,9 +360,112 @@ func (jf *JobFunnelImpl) BeginTest(testID m.TestID, testType string) error {
 	return nil
 }
 
+// eventQueue holds the events of a TestInstance until they are recorded. Jobs queue their
+// events instead of recording them, since the scheduler may wait for them to receive its
+// next event while stopping the test, which holds the lock recording requires.
+type eventQueue struct {
+	events []m.InstanceEvent
+	closed bool
+	mux    sync.Mutex
+	ready  chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *eventQueue) push(event m.InstanceEvent) {
+	q.mux.Lock()
+	q.events = append(q.events, event)
+	q.mux.Unlock()
+	q.signal()
+}
+
+// close stops the queue once the events queued so far are recorded
+func (q *eventQueue) close() {
+	q.mux.Lock()
+	q.closed = true
+	q.mux.Unlock()
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) take() ([]m.InstanceEvent, bool) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	events := q.events
+	q.events = nil
+	return events, q.closed
+}
+
+// runEvents records the events of a TestInstance in order until its queue is closed
+func (jf *JobFunnelImpl) runEvents(testID m.TestID, instanceID m.TestInstanceID, q *eventQueue) {
+	for range q.ready {
+		events, closed := q.take()
+		for _, event := range events {
+			jf.recordEvent(testID, instanceID, event)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// recordEvent adds an event to the TestInstance with the given ID
+func (jf *JobFunnelImpl) recordEvent(testID m.TestID, instanceID m.TestInstanceID, event m.InstanceEvent) {
+	key := string(testID)
+	jf.startOp(key)
+	defer jf.endOp(key)
+
+	instance, err := sto.GetTestInstance(instanceID)
+	if err != nil || instance == nil {
+		log.WithField("TestInstanceID", instanceID).WithField("Event", event).Error("Unable to record event")
+		return
+	}
+
+	instance.Events = append(instance.Events, event)
+	sto.AddTestInstance(instance)
+
+	if feed := jf.live.get(instanceID); feed != nil {
+		feed.AddEvent(event)
+	}
+
+	log.
+		WithField("TestID", testID).
+		WithField("TestInstanceID", instanceID).
+		WithField("Type", event.Type).
+		Info(event.Message)
+}
+
 // StopTest stops the running TestInstance for the Test corresponding
 // to the given TestID, if it exists
 func (jf *JobFunnelImpl) StopTest(testID m.TestID) error {
+	return jf.stopTest(testID, "stopped", "")
+}
+
+// abortTest stops the running TestInstance for the Test corresponding to
+// the given TestID because one of its abort conditions was breached
+func (jf *JobFunnelImpl) abortTest(testID m.TestID, reason string) {
+	err := jf.stopTest(testID, "aborted", reason)
+	if err != nil {
+		log.WithError(err).WithField("TestID", testID).Error("Failed to abort test")
+		return
+	}
+
+	log.
+		WithField("TestID", testID).
+		WithField("Reason", reason).
+		Info("Test aborted")
+}
+
+func (jf *JobFunnelImpl) stopTest(testID m.TestID, status string, reason string) error {
 	key := string(testID)
 	jf.startOp(key)
 	defer jf.endOp(key)
@@ -263,7 +491,9 @@ func (jf *JobFunnelImpl) StopTest(testID m.TestID) error {
 	}
 
 	// we've stopped the test instance
+	jf.projectLock.Lock()
 	delete(jf.ongoing, key)
+	jf.projectLock.Unlock()
 
 	instances, err := sto.GetTestInstancesByTestID(m.TestID(key))
 	if len(instances) == 0 {
@@ -271,7 +501,8 @@ func (jf *JobFunnelImpl) StopTest(testID m.TestID) error {
 	}
 	for _, instance := range instances {
 		if !instance.IsTerminal() {
-			instance.Status = "stopped"
+			instance.Status = status
+			instance.AbortReason = reason
 			sto.AddTestInstance(instance)
 		}
 	}
@@ -282,14 +513,18 @@ func (jf *JobFunnelImpl) StopTest(testID m.TestID) error {
 	return nil
 }
 
-// NewJobFunnel creates a new JobFunnel
-func NewJobFunnel(scheduler *s.Scheduler, cm *cm.ChaosManager) JobFunnel {
+// NewJobFunnel creates a new JobFunnel, which tells notifier about TestInstances
+// entering a status unless it is nil
+func NewJobFunnel(scheduler *s.Scheduler, cm *cm.ChaosManager, notifier Notifier) JobFunnel {
 	jf := &JobFunnelImpl{
 		&sync.Mutex{},
 		map[string]*sync.Mutex{},
 		map[string]bool{},
 		scheduler,
 		cm,
+		&sync.Mutex{},
+		&liveFeeds{feeds: map[m.TestInstanceID]*metrics.LiveFeed{}},
+		notifier,
 	}
 	return jf
 }
@@ -297,6 +532,15 @@ func NewJobFunnel(scheduler *s.Scheduler, cm *cm.ChaosManager) JobFunnel {
 type TestingJobFunnel struct {
 	Starts []m.TestID
 	Stops  []m.TestID
+
+	// Starters are the identities which started each of Starts
+	Starters []string
+
+	// MissingGroups are the groups GroupExists reports as missing
+	MissingGroups []string
+
+	// Feeds are the live feeds Subscribe subscribes to, keyed by TestInstanceID
+	Feeds map[m.TestInstanceID]*metrics.LiveFeed
 }
 
 func (jf *TestingJobFunnel) startOp(key string) {}
@@ -304,8 +548,10 @@ func (jf *TestingJobFunnel) endOp(key string)   {}
 func (jf *TestingJobFunnel) BeginTest(
 	testID m.TestID,
 	testType string,
+	startedBy string,
 ) error {
 	jf.Starts = append(jf.Starts, testID)
+	jf.Starters = append(jf.Starters, startedBy)
 	return nil
 }
 func (jf *TestingJobFunnel) StopTest(
@@ -314,3 +560,23 @@ func (jf *TestingJobFunnel) StopTest(
 	jf.Stops = append(jf.Stops, testID)
 	return nil
 }
+func (jf *TestingJobFunnel) GroupExists(
+	group string,
+) bool {
+	for _, missing := range jf.MissingGroups {
+		if missing == group {
+			return false
+		}
+	}
+	return true
+}
+
+func (jf *TestingJobFunnel) Subscribe(
+	instanceID m.TestInstanceID,
+) *metrics.LiveSubscription {
+	feed, ok := jf.Feeds[instanceID]
+	if !ok {
+		return nil
+	}
+	return feed.Subscribe()
+}
@@ -0,0 +1,28 @@
+package manager
+
+import (
+	"testing"
+
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+func TestEventQueue(t *testing.T) {
+	q := newEventQueue()
+
+	// Jobs never wait for their events to be recorded
+	for _, eventType := range []string{"preempted", "resumed", "reassign"} {
+		q.push(m.InstanceEvent{Type: eventType})
+	}
+	<-q.ready
+
+	events, closed := q.take()
+	if len(events) != 3 || events[0].Type != "preempted" || events[2].Type != "reassign" || closed {
+		t.Errorf("expected the 3 events in order, got %+v", events)
+	}
+
+	q.close()
+	<-q.ready
+	if events, closed := q.take(); len(events) != 0 || !closed {
+		t.Errorf("expected the queue to be closed without events, got %+v", events)
+	}
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// liveFeeds holds the LiveFeeds of the running TestInstances
+type liveFeeds struct {
+	mux   sync.Mutex
+	feeds map[m.TestInstanceID]*metrics.LiveFeed
+}
+
+func (lf *liveFeeds) add(instanceID m.TestInstanceID, feed *metrics.LiveFeed) {
+	lf.mux.Lock()
+	defer lf.mux.Unlock()
+
+	lf.feeds[instanceID] = feed
+}
+
+func (lf *liveFeeds) remove(instanceID m.TestInstanceID) {
+	lf.mux.Lock()
+	defer lf.mux.Unlock()
+
+	delete(lf.feeds, instanceID)
+}
+
+// get returns the LiveFeed of the TestInstance, nil if it is not running
+func (lf *liveFeeds) get(instanceID m.TestInstanceID) *metrics.LiveFeed {
+	lf.mux.Lock()
+	defer lf.mux.Unlock()
+
+	return lf.feeds[instanceID]
+}
+
+// Subscribe returns a subscription to the live results of the TestInstance
+// with the given ID, or nil if it is not running
+func (jf *JobFunnelImpl) Subscribe(instanceID m.TestInstanceID) *metrics.LiveSubscription {
+	feed := jf.live.get(instanceID)
+	if feed == nil {
+		return nil
+	}
+	return feed.Subscribe()
+}
+
+// runLive publishes the results of the jobs of a running TestInstance every
+// metrics.LiveInterval until finished is closed, then closes its feed
+func (jf *JobFunnelImpl) runLive(instanceID m.TestInstanceID, jobs []m.Job, feed *metrics.LiveFeed, finished chan struct{}) {
+	ticker := time.NewTicker(metrics.LiveInterval)
+	defer ticker.Stop()
+
+	workers := func() map[string]int {
+		counts := make(map[string]int, len(jobs))
+		for _, j := range jobs {
+			counts[string(j.ID)] = jf.scheduler.Workers(j)
+		}
+		return counts
+	}
+
+	for {
+		select {
+		case <-finished:
+			// The results of the last partial interval are published as well
+			feed.Publish(time.Now(), workers())
+			jf.live.remove(instanceID)
+			feed.Close()
+			return
+		case now := <-ticker.C:
+			feed.Publish(now, workers())
+		}
+	}
+}
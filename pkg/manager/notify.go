@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// Notifier is told about TestInstances entering a status, see "webhook/Dispatcher".
+// Notify must not wait for the notifications to be delivered.
+type Notifier interface {
+	Notify(summary *m.InstanceSummary)
+}
+
+// submitError is returned by BeginTest when a job of the TestInstance could not be
+// submitted, after the instance was recorded as failed
+type submitError struct {
+	jobID m.JobID
+	err   error
+}
+
+func (e *submitError) Error() string {
+	return fmt.Sprintf("Job<%s> failed to submit: %s", e.jobID, e.err)
+}
+
+// notify tells the Notifier of the JobFunnel, if any, that instance of test entered event.
+// results are the results of the jobs of a finished instance.
+func (jf *JobFunnelImpl) notify(event m.WebhookEvent, test *m.Test, instance *m.TestInstance, results map[string]*metrics.Metrics) {
+	if jf.notifier == nil {
+		return
+	}
+	jf.notifier.Notify(summarize(event, test, instance, results))
+}
+
+// summarize describes instance of test as it enters event
+func summarize(event m.WebhookEvent, test *m.Test, instance *m.TestInstance, results map[string]*metrics.Metrics) *m.InstanceSummary {
+	summary := &m.InstanceSummary{
+		Event:           event,
+		Time:            time.Now().Unix(),
+		InstanceID:      instance.ID,
+		TestID:          instance.TestID,
+		TestName:        test.Name,
+		Project:         instance.Project,
+		Type:            instance.Type,
+		StartedBy:       instance.StartedBy,
+		CreatedAt:       instance.CreatedAt,
+		Status:          instance.Status,
+		Verdict:         instance.Verdict,
+		CriteriaResults: instance.CriteriaResults,
+		Error:           instance.Error,
+		AbortReason:     instance.AbortReason,
+	}
+	if instance.Type == "scheduled" {
+		summary.ScheduleID = m.TestScheduleID(instance.StartedBy)
+	}
+
+	if len(results) > 0 {
+		summary.Jobs = make(map[string]m.JobSummary, len(results))
+		for jobID, result := range results {
+			summary.Jobs[jobID] = m.JobSummary{
+				Requests: result.Requests,
+				Rate:     result.Rate,
+				Success:  result.Success,
+				Errors:   result.Errors,
+				P50:      result.Latencies.P50,
+				P95:      result.Latencies.P95,
+				P99:      result.Latencies.P99,
+				Max:      result.Latencies.Max,
+			}
+		}
+	}
+	return summary
+}
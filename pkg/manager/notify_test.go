@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// testingNotifier records the summaries it is notified of
+type testingNotifier struct {
+	summaries []*m.InstanceSummary
+}
+
+func (n *testingNotifier) Notify(summary *m.InstanceSummary) {
+	n.summaries = append(n.summaries, summary)
+}
+
+func TestSummarize(t *testing.T) {
+	test := &m.Test{ID: "team-a:checkout", Name: "checkout", Project: "team-a"}
+	instance := &m.TestInstance{
+		ID:        "team-a:checkout-1",
+		TestID:    test.ID,
+		Project:   test.Project,
+		Type:      "scheduled",
+		Status:    "done",
+		StartedBy: "team-a:nightly",
+		Verdict:   m.VerdictFailed,
+	}
+	results := map[string]*metrics.Metrics{
+		"job": {
+			Requests:  100,
+			Success:   0.9,
+			Errors:    []string{"timeout"},
+			Latencies: metrics.LatencyMetrics{P99: time.Second},
+		},
+	}
+
+	summary := summarize(m.WebhookDone, test, instance, results)
+	if summary.ScheduleID != "team-a:nightly" || summary.TestName != "checkout" || summary.Project != "team-a" ||
+		!summary.Failed() {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if job := summary.Jobs["job"]; job.Requests != 100 || job.Success != 0.9 || job.P99 != time.Second || len(job.Errors) != 1 {
+		t.Errorf("Unexpected summary of the job %+v", job)
+	}
+
+	instance.Type, instance.StartedBy = "adhoc", "alice"
+	if summary := summarize(m.WebhookSubmitted, test, instance, nil); summary.ScheduleID != "" || summary.Jobs != nil {
+		t.Errorf("Expected an adhoc instance without results, got %+v", summary)
+	}
+}
+
+func TestScheduleNotifyFailure(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+	defer os.Remove(testDBName)
+
+	sto.AddTest(&m.Test{ID: "checkout", Name: "checkout"})
+	schedule := &m.TestSchedule{ID: "nightly", Name: "nightly", TestID: "checkout"}
+
+	notifier := &testingNotifier{}
+	sm := &ScheduleManagerImpl{notifier: notifier}
+
+	sm.notifyFailure(schedule, fmt.Errorf("Test<checkout> is already ongoing"))
+	if len(notifier.summaries) != 1 {
+		t.Fatalf("Expected the failure to be notified, got %d summaries", len(notifier.summaries))
+	}
+	summary := notifier.summaries[0]
+	if summary.Event != m.WebhookFailed || summary.ScheduleID != "nightly" || summary.TestName != "checkout" ||
+		summary.InstanceID != "" || summary.Error != "Test<checkout> is already ongoing" {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	// The JobFunnel notifies instances whose jobs failed to submit
+	sm.notifyFailure(schedule, &submitError{"job", fmt.Errorf("no capacity")})
+	if len(notifier.summaries) != 1 {
+		t.Errorf("Expected the failed instance not to be notified twice, got %d summaries", len(notifier.summaries))
+	}
+}
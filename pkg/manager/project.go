@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"fmt"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// ProjectError is returned when a Test does not belong to an existing
+// Project, or does not fit the quotas of its Project
+type ProjectError struct {
+	Project string
+	Reason  string
+}
+
+func (e *ProjectError) Error() string {
+	return fmt.Sprintf("Project<%s>: %s", m.ProjectName(e.Project), e.Reason)
+}
+
+// CheckProject checks that the Project of test exists and that test fits its quotas on
+// its own, returning the Project or nil for a default project without quotas.
+// Tests of the default project do not require it to be created.
+func CheckProject(test *m.Test) (*m.Project, error) {
+	project, err := sto.GetProject(test.Project)
+	if err != nil {
+		return nil, err
+	} else if project == nil {
+		if test.Project == "" {
+			return nil, nil
+		}
+		return nil, &ProjectError{test.Project, "does not exist"}
+	}
+
+	if err := project.CheckTest(test); err != nil {
+		return nil, &ProjectError{test.Project, err.Error()}
+	}
+	return project, nil
+}
+
+// Internal function used to check that test can start without the ongoing
+// Tests of its Project exceeding the MaxFrequency of the Project
+func (jf *JobFunnelImpl) checkQuota(test *m.Test) error {
+	project, err := CheckProject(test)
+	if err != nil || project == nil || project.MaxFrequency == 0 {
+		return err
+	}
+
+	frequency := test.PeakFrequency()
+	for key := range jf.ongoing {
+		other, err := sto.GetTestByTestId(m.TestID(key))
+		if err != nil {
+			return err
+		}
+		if other != nil && other.Project == test.Project {
+			frequency += other.PeakFrequency()
+		}
+	}
+
+	if frequency > project.MaxFrequency {
+		return &ProjectError{test.Project, fmt.Sprintf(
+			"starting Test<%s> would run %d/s, above its quota of %d/s", test.ID, frequency, project.MaxFrequency,
+		)}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestCheckProject(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+	defer os.Remove(testDBName)
+
+	test := &m.Test{Name: "checkout", Project: "team-a", Jobs: []m.Job{{Name: "job", Group: "web", Frequency: 30}}}
+	test.AssignIDs()
+
+	if _, err := CheckProject(test); err == nil {
+		t.Error("expected a test of a missing project to be rejected")
+	} else if _, ok := err.(*ProjectError); !ok {
+		t.Errorf("expected a ProjectError, got %v", err)
+	}
+
+	// the default project does not need to be created
+	if project, err := CheckProject(&m.Test{ID: "checkout", Name: "checkout"}); err != nil || project != nil {
+		t.Errorf("expected the default project to have no quotas, got %v %v", project, err)
+	}
+
+	sto.SaveProject(&m.Project{Name: "team-a", MaxFrequency: 50, Groups: []string{"web"}})
+	if _, err := CheckProject(test); err != nil {
+		t.Errorf("expected the test to fit its project, got %s", err)
+	}
+	sto.AddTest(test)
+
+	other := &m.Test{Name: "search", Project: "team-a", Jobs: []m.Job{{Name: "job", Group: "web", Frequency: 30}}}
+	other.AssignIDs()
+
+	// ongoing tests of the project count towards its quota, those of other projects do not
+	jf := &JobFunnelImpl{ongoing: map[string]bool{}}
+	if err := jf.checkQuota(other); err != nil {
+		t.Errorf("expected the test to start, got %s", err)
+	}
+	jf.ongoing[string(test.ID)] = true
+	if err := jf.checkQuota(other); err == nil {
+		t.Error("expected the test to exceed the quota of its project")
+	}
+	if err := jf.checkQuota(&m.Test{ID: "unscoped", Jobs: other.Jobs}); err != nil {
+		t.Errorf("expected a test of the default project to start, got %s", err)
+	}
+}
@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// checkpointInterval is how often partial metrics of a running TestInstance are persisted
+const checkpointInterval = 10 * time.Second
+
+// checkpoint holds the latest snapshot of the metrics of each job of a running TestInstance
+type checkpoint struct {
+	metrics map[string]*metrics.Metrics
+	mux     sync.Mutex
+}
+
+func newCheckpoint() *checkpoint {
+	return &checkpoint{metrics: map[string]*metrics.Metrics{}}
+}
+
+func (c *checkpoint) update(jobID string, snapshot *metrics.Metrics) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.metrics[jobID] = snapshot
+}
+
+func (c *checkpoint) copy() map[string]*metrics.Metrics {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	result := make(map[string]*metrics.Metrics, len(c.metrics))
+	for jobID, snapshot := range c.metrics {
+		result[jobID] = snapshot
+	}
+	return result
+}
+
+// markRunning updates the status of a TestInstance whose jobs have started,
+// returning the instance unless it had already finished
+func (jf *JobFunnelImpl) markRunning(testID m.TestID, instanceID m.TestInstanceID) *m.TestInstance {
+	key := string(testID)
+	jf.startOp(key)
+	defer jf.endOp(key)
+
+	instance, err := sto.GetTestInstance(instanceID)
+	if err != nil || instance == nil || instance.IsTerminal() {
+		return nil
+	}
+
+	instance.Status = "running"
+	sto.AddTestInstance(instance)
+	return instance
+}
+
+// runCheckpoints persists the partial metrics of a running TestInstance
+// every checkpointInterval until finished is closed
+func (jf *JobFunnelImpl) runCheckpoints(testID m.TestID, instanceID m.TestInstanceID, partial *checkpoint, finished chan struct{}) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	key := string(testID)
+	for {
+		select {
+		case <-finished:
+			return
+		case <-ticker.C:
+		}
+
+		jf.startOp(key)
+		instance, err := sto.GetTestInstance(instanceID)
+		if err == nil && instance != nil && !instance.IsTerminal() {
+			instance.Metrics = partial.copy()
+			sto.AddTestInstance(instance)
+		}
+		jf.endOp(key)
+	}
+}
+
+// RecoverInstances marks TestInstances which were still running when the
+// leader stopped as interrupted. Jobs are not resumed since their workers
+// lost their assignments, but the metrics checkpointed until then are kept.
+// Must be called on startup, before any test is started.
+func RecoverInstances() error {
+	instances, err := sto.GetAllTestInstances()
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if instance.IsTerminal() {
+			continue
+		}
+
+		if err := interruptInstance(instance, "Leader restarted before the test instance finished"); err != nil {
+			return err
+		}
+
+		log.
+			WithField("TestID", instance.TestID).
+			WithField("TestInstanceID", instance.ID).
+			Warning("Marked test instance interrupted by leader restart")
+	}
+
+	return nil
+}
+
+// RecoverRestoredInstances marks restored TestInstances which had not finished
+// when their backup was taken as interrupted, since no leader runs them anymore.
+func RecoverRestoredInstances(instanceIDs []m.TestInstanceID) error {
+	instances, err := sto.GetTestInstances(instanceIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if instance == nil || instance.IsTerminal() {
+			continue
+		}
+
+		if err := interruptInstance(instance, "Backup was taken before the test instance finished"); err != nil {
+			return err
+		}
+
+		log.
+			WithField("TestID", instance.TestID).
+			WithField("TestInstanceID", instance.ID).
+			Warning("Marked restored test instance interrupted")
+	}
+
+	return nil
+}
+
+// Internal function used to store an instance which cannot finish as interrupted
+func interruptInstance(instance *m.TestInstance, reason string) error {
+	instance.Status = "interrupted"
+	instance.Error = reason
+	instance.Events = append(instance.Events, m.InstanceEvent{
+		Time:    time.Now().Unix(),
+		Type:    "interrupted",
+		Message: instance.Error,
+	})
+
+	// an interrupted instance cannot pass its criteria
+	test, err := sto.GetTestByTestId(instance.TestID)
+	if instance.TestRevision > 0 && err == nil {
+		test, err = sto.GetTestRevision(instance.TestID, instance.TestRevision)
+	}
+	if partial, ok := instance.Metrics.(map[string]*metrics.Metrics); ok && err == nil && test != nil {
+		instance.Verdict, instance.CriteriaResults = metrics.Evaluate(test, partial)
+		if instance.Verdict != "" {
+			instance.Verdict = m.VerdictFailed
+		}
+	}
+
+	return sto.AddTestInstance(instance)
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+const testDBName = "managerTest.db"
+
+func TestRecoverInstances(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+	defer os.Remove(testDBName)
+
+	test := &m.Test{
+		ID:   "test",
+		Name: "test",
+		Jobs: []m.Job{{ID: "job", Name: "job"}},
+		Criteria: []m.Criterion{
+			{Metric: m.MetricSuccess, Operator: ">=", Threshold: 0},
+		},
+	}
+	sto.AddTest(test)
+
+	running := &m.TestInstance{
+		ID:      "running",
+		TestID:  test.ID,
+		Status:  "running",
+		Metrics: map[string]*metrics.Metrics{"job": {Requests: 10, Success: 1}},
+	}
+	done := &m.TestInstance{ID: "done", TestID: test.ID, Status: "done"}
+	sto.AddTestInstance(running)
+	sto.AddTestInstance(done)
+
+	if err := RecoverInstances(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, _ := sto.GetTestInstance("running")
+	if recovered.Status != "interrupted" || recovered.Error == "" || len(recovered.Events) != 1 {
+		t.Errorf("expected running instance to be interrupted, got %+v", recovered)
+	}
+	if recovered.Verdict != m.VerdictFailed || len(recovered.CriteriaResults) != 1 {
+		t.Errorf("expected interrupted instance to fail its criteria, got %s", recovered.Verdict)
+	}
+	if ms, ok := recovered.Metrics.(map[string]*metrics.Metrics); !ok || ms["job"].Requests != 10 {
+		t.Errorf("expected checkpointed metrics to be kept, got %+v", recovered.Metrics)
+	}
+
+	untouched, _ := sto.GetTestInstance("done")
+	if untouched.Status != "done" || len(untouched.Events) != 0 {
+		t.Errorf("expected finished instance to be untouched, got %+v", untouched)
+	}
+}
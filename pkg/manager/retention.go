@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// RetentionPolicy decides which finished TestInstances are deleted by a Janitor.
+// Baselines and instances which have not finished are always kept.
+type RetentionPolicy struct {
+	// KeepLast is the number of most recent finished instances kept per Test, 0 keeps all of them
+	KeepLast int
+
+	// MaxAge is the age after which finished instances are deleted, 0 keeps them regardless of age
+	MaxAge time.Duration
+
+	// KeepFailed keeps instances which failed, were aborted or interrupted, or did not pass their criteria
+	KeepFailed bool
+}
+
+// Enabled is false if the policy keeps every instance
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAge > 0
+}
+
+// Expired returns the instances of a single Test which the policy deletes at now
+func (p RetentionPolicy) Expired(instances []*m.TestInstance, now time.Time) []*m.TestInstance {
+	finished := make([]*m.TestInstance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.IsTerminal() {
+			finished = append(finished, instance)
+		}
+	}
+
+	// Most recent first
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[i].CreatedAt > finished[j].CreatedAt
+	})
+
+	// Instances which are kept anyway do not count towards KeepLast
+	expired := []*m.TestInstance{}
+	eligible := 0
+	for _, instance := range finished {
+		if instance.Baseline || (p.KeepFailed && failed(instance)) {
+			continue
+		}
+		eligible++
+
+		tooMany := p.KeepLast > 0 && eligible > p.KeepLast
+		tooOld := p.MaxAge > 0 && now.Sub(time.Unix(instance.CreatedAt, 0)) > p.MaxAge
+		if tooMany || tooOld {
+			expired = append(expired, instance)
+		}
+	}
+	return expired
+}
+
+// Internal function used to check whether an instance failed to run or did not pass its criteria
+func failed(instance *m.TestInstance) bool {
+	switch instance.Status {
+	case "failed", "aborted", "interrupted":
+		return true
+	}
+	return instance.Verdict == m.VerdictFailed
+}
+
+// Janitor periodically deletes the TestInstances expired by a RetentionPolicy
+type Janitor struct {
+	policy   RetentionPolicy
+	interval time.Duration
+}
+
+// NewJanitor creates a Janitor enforcing policy every interval
+func NewJanitor(policy RetentionPolicy, interval time.Duration) *Janitor {
+	return &Janitor{policy, interval}
+}
+
+// Run cleans up once right away and then every interval until stop is closed
+func (j *Janitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := j.Clean(time.Now()); err != nil {
+			log.WithError(err).Error("Failed to clean up test instances")
+		} else if deleted > 0 {
+			log.WithField("deleted", deleted).Info("Cleaned up test instances")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Clean deletes the instances expired at now and returns how many were deleted
+func (j *Janitor) Clean(now time.Time) (int, error) {
+	instances, err := sto.GetTestInstanceSummaries()
+	if err != nil {
+		return 0, err
+	}
+
+	byTest := map[m.TestID][]*m.TestInstance{}
+	for _, instance := range instances {
+		byTest[instance.TestID] = append(byTest[instance.TestID], instance)
+	}
+
+	deleted := 0
+	for testID, instances := range byTest {
+		for _, instance := range j.policy.Expired(instances, now) {
+			if err := sto.DeleteTestInstance(instance.ID); err != nil {
+				return deleted, err
+			}
+			log.WithField("testID", testID).WithField("instanceID", instance.ID).Debug("Deleted expired test instance")
+			deleted++
+		}
+	}
+	return deleted, nil
+}
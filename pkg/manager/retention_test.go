@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+func TestRetentionPolicy_Expired(t *testing.T) {
+	now := time.Unix(1618100600, 0)
+	ago := func(d time.Duration) int64 { return now.Add(-d).Unix() }
+
+	instances := []*m.TestInstance{
+		{ID: "running", Status: "running", CreatedAt: ago(72 * time.Hour)},
+		{ID: "new", Status: "done", CreatedAt: ago(time.Hour)},
+		{ID: "recent", Status: "done", CreatedAt: ago(2 * time.Hour)},
+		{ID: "baseline", Status: "done", CreatedAt: ago(3 * time.Hour), Baseline: true},
+		{ID: "failed", Status: "failed", CreatedAt: ago(4 * time.Hour)},
+		{ID: "verdict", Status: "done", Verdict: m.VerdictFailed, CreatedAt: ago(5 * time.Hour)},
+		{ID: "old", Status: "stopped", CreatedAt: ago(48 * time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{
+		{"disabled", RetentionPolicy{}, []string{}},
+		{"keep last", RetentionPolicy{KeepLast: 2}, []string{"failed", "old", "verdict"}},
+		{"keep last and failed", RetentionPolicy{KeepLast: 2, KeepFailed: true}, []string{"old"}},
+		{"keep last without kept instances", RetentionPolicy{KeepLast: 3, KeepFailed: true}, []string{}},
+		{"max age", RetentionPolicy{MaxAge: 24 * time.Hour}, []string{"old"}},
+		{"keep last and max age", RetentionPolicy{KeepLast: 5, MaxAge: 90 * time.Minute, KeepFailed: true}, []string{"old", "recent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, instance := range tt.policy.Expired(instances, now) {
+				got = append(got, string(instance.ID))
+			}
+			sort.Strings(got)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v to expire, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v to expire, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestJanitor_Clean(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+	defer os.Remove(testDBName)
+
+	now := time.Unix(1618100600, 0)
+	for i, testID := range []m.TestID{"a", "a", "a", "b"} {
+		sto.AddTestInstance(&m.TestInstance{
+			ID:        m.TestInstanceID(string(testID) + string(rune('0'+i))),
+			TestID:    testID,
+			Status:    "done",
+			CreatedAt: now.Add(time.Duration(i) * time.Minute).Unix(),
+		})
+	}
+
+	janitor := NewJanitor(RetentionPolicy{KeepLast: 1}, time.Hour)
+	deleted, err := janitor.Clean(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 instances to be deleted, got %d", deleted)
+	}
+
+	remaining, _ := sto.GetTestInstancesByTestID("a")
+	if len(remaining) != 1 || remaining[0].ID != "a2" {
+		t.Errorf("Expected only the latest instance of a to remain, got %v", remaining)
+	}
+	if remaining, _ := sto.GetTestInstancesByTestID("b"); len(remaining) != 1 {
+		t.Errorf("Expected the instance of b to remain, got %v", remaining)
+	}
+
+	// Nothing is left to clean up
+	if deleted, _ := janitor.Clean(now); deleted != 0 {
+		t.Errorf("Expected no instance to be deleted, got %d", deleted)
+	}
+}
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +13,7 @@ import (
 
 type ScheduleManager interface {
 	Add(schedule *m.TestSchedule, store bool) error
+	Update(schedule *m.TestSchedule, expected int) error
 	Remove(id m.TestScheduleID) error
 	ValidateSpec(spec string) error
 }
@@ -21,6 +23,9 @@ type ScheduleManagerImpl struct {
 	entries    map[m.TestScheduleID]cron.EntryID
 	cronRunner *cron.Cron
 	jf         JobFunnel
+
+	// notifier is told about schedules which fail to start their Test, nil if nobody listens
+	notifier Notifier
 }
 
 func (sm *ScheduleManagerImpl) Add(schedule *m.TestSchedule, store bool) error {
@@ -30,18 +35,39 @@ func (sm *ScheduleManagerImpl) Add(schedule *m.TestSchedule, store bool) error {
 		}
 	}
 
+	return sm.start(schedule)
+}
+
+// Update saves schedule if its current revision is expected, see storage.SaveTestSchedule,
+// and runs it instead of its previous revision
+func (sm *ScheduleManagerImpl) Update(schedule *m.TestSchedule, expected int) error {
+	if err := sto.SaveTestSchedule(schedule, expected); err != nil {
+		return err
+	}
+
+	if entryID, exists := sm.entries[schedule.ID]; exists {
+		sm.cronRunner.Remove(entryID)
+		delete(sm.entries, schedule.ID)
+	}
+	return sm.start(schedule)
+}
+
+func (sm *ScheduleManagerImpl) start(schedule *m.TestSchedule) error {
 	entryID, err := sm.cronRunner.AddFunc(schedule.CronSpec, func() {
 		log.WithField("TestScheduleID", schedule.ID).
 			Info("About to start scheduled test")
 		err := sm.jf.BeginTest(
 			schedule.TestID,
 			"scheduled",
+			string(schedule.ID),
 		)
 		if err != nil {
 			log.WithField("TestScheduleID", schedule.ID).
 				WithError(err).
 				Errorf("Scheduled test failed to start")
+			sm.notifyFailure(schedule, err)
 		}
+		auditTrigger(schedule, err)
 	})
 
 	if err != nil {
@@ -55,6 +81,49 @@ func (sm *ScheduleManagerImpl) Add(schedule *m.TestSchedule, store bool) error {
 	return nil
 }
 
+// Internal function used to record in the audit log that schedule tried to start its Test
+func auditTrigger(schedule *m.TestSchedule, err error) {
+	entry := &m.AuditEntry{
+		Time:    time.Now().Unix(),
+		Action:  m.AuditTestScheduleTrigger,
+		Actor:   string(schedule.ID),
+		Target:  string(schedule.TestID),
+		Project: schedule.Project,
+		Outcome: m.AuditSucceeded,
+	}
+	if err != nil {
+		entry.Outcome = m.AuditFailed
+		entry.Error = err.Error()
+	}
+	if err := sto.AddAuditEntry(entry); err != nil {
+		log.WithField("TestScheduleID", schedule.ID).WithError(err).Error("Failed to audit scheduled test")
+	}
+}
+
+// Internal function used to tell the notifier that schedule failed to start its Test.
+// Instances whose jobs failed to submit were already notified by the JobFunnel.
+func (sm *ScheduleManagerImpl) notifyFailure(schedule *m.TestSchedule, err error) {
+	if _, submitted := err.(*submitError); submitted || sm.notifier == nil {
+		return
+	}
+
+	summary := &m.InstanceSummary{
+		Event:      m.WebhookFailed,
+		Time:       time.Now().Unix(),
+		TestID:     schedule.TestID,
+		Project:    schedule.Project,
+		Type:       "scheduled",
+		StartedBy:  string(schedule.ID),
+		ScheduleID: schedule.ID,
+		Status:     "failed",
+		Error:      err.Error(),
+	}
+	if test, _ := sto.GetTestByTestId(schedule.TestID); test != nil {
+		summary.TestName = test.Name
+	}
+	sm.notifier.Notify(summary)
+}
+
 func (sm *ScheduleManagerImpl) Remove(id m.TestScheduleID) error {
 	entryID, exists := sm.entries[id]
 	if !exists {
@@ -91,7 +160,9 @@ func (sm *ScheduleManagerImpl) onStart() {
 	log.Info("ScheduleManager started cron runner")
 }
 
-func NewScheduleManager(jf JobFunnel) ScheduleManager {
+// NewScheduleManager creates a ScheduleManager starting the Tests of the stored TestSchedules
+// through jf, which tells notifier about schedules failing to start their Test unless it is nil
+func NewScheduleManager(jf JobFunnel, notifier Notifier) ScheduleManager {
 	sm := &ScheduleManagerImpl{
 		// standardParser according to https://github.com/robfig/cron/blob/v3.0.1/parser.go#L217
 		cron.NewParser(
@@ -101,6 +172,7 @@ func NewScheduleManager(jf JobFunnel) ScheduleManager {
 		map[m.TestScheduleID]cron.EntryID{},
 		cron.New(),
 		jf,
+		notifier,
 	}
 	sm.onStart()
 	return sm
@@ -108,6 +180,7 @@ func NewScheduleManager(jf JobFunnel) ScheduleManager {
 
 type TestingScheduleManager struct {
 	Added   []m.TestScheduleID
+	Updated []m.TestScheduleID
 	Removed []m.TestScheduleID
 }
 
@@ -118,6 +191,13 @@ func (sm *TestingScheduleManager) Add(
 	sm.Added = append(sm.Added, schedule.ID)
 	return nil
 }
+func (sm *TestingScheduleManager) Update(
+	schedule *m.TestSchedule,
+	expected int,
+) error {
+	sm.Updated = append(sm.Updated, schedule.ID)
+	return sto.SaveTestSchedule(schedule, expected)
+}
 func (sm *TestingScheduleManager) Remove(
 	id m.TestScheduleID,
 ) error {
@@ -0,0 +1,262 @@
+// Package manifest implements declarative definitions of Diago tests, their
+// schedules and the worker groups they run on, which are applied to the storage.
+//
+// A manifest is a stream of YAML or JSON documents of the form
+//
+//	Name: checkout
+//	Project: shop
+//	WorkerGroups:
+//	  - test-worker
+//	Tests:
+//	  - Name: browse
+//	    Jobs:
+//	      - Name: home
+//	        Group: test-worker
+//	        ...
+//	TestSchedules:
+//	  - Name: browse-nightly
+//	    TestID: browse
+//	    CronSpec: "0 3 * * *"
+//
+// where tests and schedules have the same fields as accepted by the API. The tests and schedules
+// are in the project of the manifest, the default project if it has none, and schedules name
+// the tests they run by their name within the project.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Manifest declares Tests, TestSchedules and the worker groups their jobs run on
+type Manifest struct {
+	// Name identifies the objects applied by the manifest, which can only be pruned if it is set
+	Name string
+
+	// Project of the declared objects, the default project if empty
+	Project string
+
+	// WorkerGroups must exist for the manifest to be applied, in addition to the groups of its jobs
+	WorkerGroups []string
+
+	Tests         []*m.Test
+	TestSchedules []*m.TestSchedule
+}
+
+// document is a single YAML or JSON document of a manifest
+type document struct {
+	Name          string
+	Project       string
+	WorkerGroups  []string
+	Tests         []json.RawMessage
+	TestSchedules []json.RawMessage
+}
+
+// Parse reads and validates a manifest of one or more YAML or JSON documents from r
+func Parse(r io.Reader) (*Manifest, error) {
+	mf := &Manifest{WorkerGroups: []string{}, Tests: []*m.Test{}, TestSchedules: []*m.TestSchedule{}}
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+
+	for i := 0; ; i++ {
+		data, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := mf.add(data); err != nil {
+			return nil, fmt.Errorf("document %d: %s", i, err)
+		}
+	}
+
+	return mf, mf.check()
+}
+
+// Internal function used to add the objects of a YAML or JSON document to the manifest
+func (mf *Manifest) add(data []byte) error {
+	enc, err := yaml.ToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	var doc *document
+	decoder := json.NewDecoder(bytes.NewReader(enc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+	// Empty documents, such as after a trailing separator
+	if doc == nil {
+		return nil
+	}
+
+	if doc.Name != "" {
+		if mf.Name != "" && mf.Name != doc.Name {
+			return fmt.Errorf("Name %s differs from %s", doc.Name, mf.Name)
+		}
+		mf.Name = doc.Name
+	}
+	if doc.Project != "" {
+		if mf.Project != "" && mf.Project != doc.Project {
+			return fmt.Errorf("Project %s differs from %s", doc.Project, mf.Project)
+		}
+		mf.Project = doc.Project
+	}
+	mf.WorkerGroups = append(mf.WorkerGroups, doc.WorkerGroups...)
+
+	for i, enc := range doc.Tests {
+		if err := m.Validate(reflect.TypeOf(m.Test{}), enc); err != nil {
+			return fmt.Errorf("Tests[%d]: %s", i, err)
+		}
+
+		var test m.Test
+		if err := json.Unmarshal(enc, &test); err != nil {
+			return fmt.Errorf("Tests[%d]: %s", i, err)
+		}
+		test.Manifest = mf.Name
+		mf.Tests = append(mf.Tests, &test)
+	}
+
+	for i, enc := range doc.TestSchedules {
+		if err := m.Validate(reflect.TypeOf(m.TestSchedule{}), enc); err != nil {
+			return fmt.Errorf("TestSchedules[%d]: %s", i, err)
+		}
+
+		var schedule m.TestSchedule
+		if err := json.Unmarshal(enc, &schedule); err != nil {
+			return fmt.Errorf("TestSchedules[%d]: %s", i, err)
+		}
+		schedule.Manifest = mf.Name
+		mf.TestSchedules = append(mf.TestSchedules, &schedule)
+	}
+
+	return nil
+}
+
+// Internal function used to check the objects of the manifest are unique and in its project, and
+// that the manifest name and project are set on all of them since documents can set them late
+func (mf *Manifest) check() error {
+	if mf.Project = m.NormalizeProject(mf.Project); !validProject(mf.Project) {
+		return fmt.Errorf("invalid Project %s", mf.Project)
+	}
+
+	tests := map[m.TestID]bool{}
+	for _, test := range mf.Tests {
+		if test.Name == "" {
+			return fmt.Errorf("Test without Name")
+		}
+		if err := mf.scope(&test.Project); err != nil {
+			return fmt.Errorf("Test<%s>: %s", test.Name, err)
+		}
+		test.AssignIDs()
+		if tests[test.ID] {
+			return fmt.Errorf("Test<%s> is declared twice", test.ID)
+		}
+		tests[test.ID] = true
+		test.Manifest = mf.Name
+	}
+
+	schedules := map[m.TestScheduleID]bool{}
+	for _, schedule := range mf.TestSchedules {
+		if err := mf.scope(&schedule.Project); err != nil {
+			return fmt.Errorf("TestSchedule<%s>: %s", schedule.Name, err)
+		}
+		schedule.AssignID()
+		schedule.TestID = m.TestID(m.ScopedID(mf.Project, string(schedule.TestID)))
+		if schedules[schedule.ID] {
+			return fmt.Errorf("TestSchedule<%s> is declared twice", schedule.ID)
+		}
+		schedules[schedule.ID] = true
+		schedule.Manifest = mf.Name
+	}
+
+	return nil
+}
+
+// Internal function used to set the project of an object to the project of the manifest,
+// which the object may only repeat
+func (mf *Manifest) scope(project *string) error {
+	if declared := m.NormalizeProject(*project); declared != "" && declared != mf.Project {
+		return fmt.Errorf("Project %s differs from %s", declared, m.ProjectName(mf.Project))
+	}
+	*project = mf.Project
+	return nil
+}
+
+// Internal function used to check the name of the project of the manifest
+func validProject(project string) bool {
+	return m.Validate(reflect.TypeOf(m.Project{}), []byte(fmt.Sprintf(`{"Name": %q}`, m.ProjectName(project)))) == nil
+}
+
+// Groups returns the declared worker groups and the groups of every job, without duplicates
+func (mf *Manifest) Groups() []string {
+	seen := map[string]bool{}
+	groups := []string{}
+	add := func(group string) {
+		if group != "" && !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+
+	for _, group := range mf.WorkerGroups {
+		add(group)
+	}
+	for _, test := range mf.Tests {
+		for _, job := range test.Jobs {
+			add(job.Group)
+		}
+	}
+	return groups
+}
+
+// Check that the worker groups of the manifest exist, that its tests fit the quotas of its project,
+// that its schedules have valid cron specs, and that they run tests of the manifest or tests which are not pruned.
+func (mf *Manifest) Check(groupExists func(group string) bool, validateSpec func(spec string) error, prune bool) error {
+	for _, group := range mf.Groups() {
+		if !groupExists(group) {
+			return fmt.Errorf("WorkerGroup<%s> does not exist", group)
+		}
+	}
+
+	for _, test := range mf.Tests {
+		if _, err := mgr.CheckProject(test); err != nil {
+			return fmt.Errorf("Test<%s>: %s", test.ID, err)
+		}
+	}
+
+	tests := map[m.TestID]bool{}
+	for _, test := range mf.Tests {
+		tests[test.ID] = true
+	}
+
+	for _, schedule := range mf.TestSchedules {
+		if err := validateSpec(schedule.CronSpec); err != nil {
+			return fmt.Errorf("TestSchedule<%s>: %s", schedule.ID, err)
+		}
+		if tests[schedule.TestID] {
+			continue
+		}
+
+		test, err := sto.GetTestByTestId(schedule.TestID)
+		if err != nil {
+			return err
+		}
+		if test == nil || (prune && mf.Name != "" && test.Manifest == mf.Name) {
+			return fmt.Errorf("TestSchedule<%s>: Cannot find Test<%s>", schedule.ID, schedule.TestID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+const testDBName = "manifestTest.db"
+
+const checkout = `
+Name: checkout
+WorkerGroups:
+  - test-worker
+Tests:
+  - Name: browse
+    Jobs:
+      - Name: home
+        Group: test-worker
+        Priority: 0
+        Env: {}
+        Config: []
+        Frequency: 5
+        Duration: 10
+        HTTPMethod: GET
+        HTTPUrl: https://example.com
+---
+TestSchedules:
+  - Name: browse-nightly
+    TestID: browse
+    CronSpec: "0 3 * * *"
+`
+
+func initTestDB(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+}
+
+func removeTestDB() {
+	sto.Close()
+	os.Remove(testDBName)
+}
+
+func parse(t *testing.T, manifest string) *Manifest {
+	mf, err := Parse(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %s", err)
+	}
+	return mf
+}
+
+func TestParse(t *testing.T) {
+	mf := parse(t, checkout)
+
+	assert.Equal(t, "checkout", mf.Name)
+	assert.Equal(t, []string{"test-worker"}, mf.Groups())
+	if assert.Equal(t, 1, len(mf.Tests)) {
+		test := mf.Tests[0]
+		assert.Equal(t, m.TestID("browse"), test.ID)
+		assert.Equal(t, m.JobID("browse-0"), test.Jobs[0].ID)
+		assert.Equal(t, uint64(5), test.Jobs[0].Frequency)
+		assert.Equal(t, "checkout", test.Manifest)
+	}
+	if assert.Equal(t, 1, len(mf.TestSchedules)) {
+		schedule := mf.TestSchedules[0]
+		assert.Equal(t, m.TestScheduleID("browse-nightly"), schedule.ID)
+		assert.Equal(t, "checkout", schedule.Manifest)
+	}
+
+	// JSON documents are accepted as well
+	json, err := Parse(strings.NewReader(`{"Name": "other", "TestSchedules": [{"Name": "s", "TestID": "t", "CronSpec": "@daily"}]}`))
+	if err != nil {
+		t.Fatalf("Failed to parse JSON manifest: %s", err)
+	}
+	assert.Equal(t, "other", json.Name)
+
+	invalid := map[string]string{
+		"unknown field":     "Name: checkout\nSpec: {}\n",
+		"invalid test":      "Tests:\n  - Name: browse\n    Frequency: 5\n",
+		"missing cron spec": "TestSchedules:\n  - Name: s\n    TestID: t\n",
+		"duplicate test":    "Tests:\n  - Name: browse\n---\nTests:\n  - Name: browse\n",
+		"different names":   "Name: a\n---\nName: b\n",
+	}
+	for name, manifest := range invalid {
+		if _, err := Parse(strings.NewReader(manifest)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestParseProject(t *testing.T) {
+	// The project may be set by any document and applies to all of them
+	mf := parse(t, checkout+"---\nProject: team-a\n")
+
+	assert.Equal(t, "team-a", mf.Project)
+	if assert.Equal(t, 1, len(mf.Tests)) {
+		assert.Equal(t, m.TestID("team-a:browse"), mf.Tests[0].ID)
+		assert.Equal(t, "team-a", mf.Tests[0].Project)
+	}
+	if assert.Equal(t, 1, len(mf.TestSchedules)) {
+		schedule := mf.TestSchedules[0]
+		assert.Equal(t, m.TestScheduleID("team-a:browse-nightly"), schedule.ID)
+		assert.Equal(t, m.TestID("team-a:browse"), schedule.TestID)
+	}
+
+	// The default project keeps IDs unscoped
+	mf = parse(t, checkout+"---\nProject: default\n")
+	assert.Equal(t, "", mf.Project)
+	assert.Equal(t, m.TestID("browse"), mf.Tests[0].ID)
+
+	invalid := map[string]string{
+		"different projects": "Project: a\n---\nProject: b\n",
+		"invalid project":    "Project: Team A\n",
+		"test of another":    "Project: a\nTests:\n  - Name: browse\n    Project: b\n",
+	}
+	for name, manifest := range invalid {
+		if _, err := Parse(strings.NewReader(manifest)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	mf := parse(t, checkout)
+	exists := func(groups ...string) func(string) bool {
+		return func(group string) bool {
+			for _, g := range groups {
+				if g == group {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	validSpec := func(spec string) error { return nil }
+
+	assert.Nil(t, mf.Check(exists("test-worker"), validSpec, false))
+	assert.NotNil(t, mf.Check(exists(), validSpec, false))
+	assert.NotNil(t, mf.Check(exists("test-worker"), func(spec string) error {
+		return fmt.Errorf("invalid spec %s", spec)
+	}, false))
+
+	// Schedules can only run tests which exist or are declared
+	mf.Tests = []*m.Test{}
+	assert.NotNil(t, mf.Check(exists("test-worker"), validSpec, false))
+
+	sto.SaveTest(&m.Test{ID: "browse", Name: "browse", Manifest: "checkout"}, 0)
+	assert.Nil(t, mf.Check(exists("test-worker"), validSpec, false))
+	assert.NotNil(t, mf.Check(exists("test-worker"), validSpec, true))
+
+	// Tests must fit the quotas of their project
+	mf = parse(t, checkout+"---\nProject: team-a\n")
+	assert.NotNil(t, mf.Check(exists("test-worker"), validSpec, false))
+	sto.SaveProject(&m.Project{Name: "team-a", Groups: []string{"test-worker"}})
+	assert.Nil(t, mf.Check(exists("test-worker"), validSpec, false))
+	sto.SaveProject(&m.Project{Name: "team-a", MaxFrequency: 1})
+	assert.NotNil(t, mf.Check(exists("test-worker"), validSpec, false))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+	sm := &mgr.TestingScheduleManager{}
+
+	plan, err := NewPlan(parse(t, checkout), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "checkout", plan.Manifest)
+	if assert.Equal(t, 2, len(plan.Changes)) {
+		assert.Equal(t, Create, plan.Changes[0].Action)
+		assert.Equal(t, TestKind, plan.Changes[0].Kind)
+		assert.Contains(t, plan.Changes[0].Fields, FieldChange{"Jobs[0].HTTPUrl", nil, "https://example.com"})
+		assert.Equal(t, Create, plan.Changes[1].Action)
+		assert.Equal(t, TestScheduleKind, plan.Changes[1].Kind)
+	}
+
+	if err := plan.Apply(sm, ""); err != nil {
+		t.Fatal(err)
+	}
+	test, _ := sto.GetTestByTestId("browse")
+	if assert.NotNil(t, test) {
+		assert.Equal(t, 1, test.Revision)
+	}
+	assert.Equal(t, []m.TestScheduleID{"browse-nightly"}, sm.Updated)
+
+	// Applying the manifest again changes nothing
+	plan, err = NewPlan(parse(t, checkout), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range plan.Changes {
+		assert.Equal(t, Unchanged, change.Action, change.Name)
+	}
+
+	// Only the modified fields are reported
+	plan, err = NewPlan(parse(t, strings.Replace(checkout, "Frequency: 5", "Frequency: 20", 1)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Update, plan.Changes[0].Action)
+	assert.Equal(t, 1, plan.Changes[0].Revision)
+	assert.Equal(t, 1, len(plan.Changes[0].Fields))
+	assert.Equal(t, "Jobs[0].Frequency", plan.Changes[0].Fields[0].Path)
+
+	// A plan is not applied over changes made since it was made
+	concurrent := *test
+	sto.SaveTest(&concurrent, sto.AnyRevision)
+	if _, ok := plan.Apply(sm, "").(*sto.RevisionConflictError); !ok {
+		t.Error("Expected a revision conflict")
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+	sm := &mgr.TestingScheduleManager{}
+
+	plan, _ := NewPlan(parse(t, checkout), false)
+	if err := plan.Apply(sm, ""); err != nil {
+		t.Fatal(err)
+	}
+	sto.SaveTest(&m.Test{ID: "unmanaged", Name: "unmanaged"}, 0)
+
+	// The schedule of a pruned test must be pruned as well
+	onlySchedule := "Name: checkout\n"
+	if _, err := NewPlan(parse(t, onlySchedule), false); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := NewPlan(parse(t, onlySchedule), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Equal(t, 2, len(plan.Changes)) {
+		assert.Equal(t, Delete, plan.Changes[0].Action)
+		assert.Equal(t, "browse-nightly", plan.Changes[0].Name)
+		assert.Equal(t, Delete, plan.Changes[1].Action)
+		assert.Equal(t, "browse", plan.Changes[1].Name)
+	}
+
+	if err := plan.Apply(sm, ""); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []m.TestScheduleID{"browse-nightly"}, sm.Removed)
+	if test, _ := sto.GetTestByTestId("browse"); test != nil {
+		t.Error("Expected test browse to be pruned")
+	}
+	if test, _ := sto.GetTestByTestId("unmanaged"); test == nil {
+		t.Error("Expected test unmanaged to be kept")
+	}
+
+	// A schedule outside the manifest keeps its test from being pruned
+	plan, _ = NewPlan(parse(t, checkout), false)
+	plan.Apply(sm, "")
+	sto.SaveTestSchedule(&m.TestSchedule{ID: "manual", Name: "manual", TestID: "browse", CronSpec: "@daily"}, 0)
+	if _, err := NewPlan(parse(t, onlySchedule), true); err == nil {
+		t.Error("Expected pruning a test run by another schedule to fail")
+	}
+
+	if _, err := NewPlan(parse(t, "Tests: []\n"), true); err == nil {
+		t.Error("Expected pruning without a manifest name to fail")
+	}
+}
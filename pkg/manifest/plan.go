@@ -0,0 +1,322 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	mgr "github.com/t-bfame/diago/pkg/manager"
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+// Action is what applying a manifest does to an object
+type Action string
+
+const (
+	Create    Action = "create"
+	Update    Action = "update"
+	Unchanged Action = "unchanged"
+	Delete    Action = "delete"
+)
+
+// Kinds of objects declared by a manifest
+const (
+	TestKind         = "Test"
+	TestScheduleKind = "TestSchedule"
+)
+
+// FieldChange is a field whose value differs between the stored object and the manifest,
+// its path is written as in Jobs[0].Frequency
+type FieldChange struct {
+	Path   string
+	Before interface{}
+	After  interface{}
+}
+
+// Change describes how an object is applied
+type Change struct {
+	Kind   string
+	Name   string
+	Action Action
+
+	// Revision of the stored object the change was planned against, 0 if it does not exist
+	Revision int
+
+	Fields []FieldChange
+
+	test      *m.Test
+	schedule  *m.TestSchedule
+	createdBy string
+}
+
+// Plan lists the changes applying a manifest makes to the storage
+type Plan struct {
+	Manifest string
+	Changes  []*Change
+}
+
+// NewPlan compares the manifest to the storage. With prune, the objects of the project of the manifest
+// previously applied by a manifest of the same name which it no longer declares are deleted.
+func NewPlan(mf *Manifest, prune bool) (*Plan, error) {
+	if prune && mf.Name == "" {
+		return nil, fmt.Errorf("Cannot prune objects of a manifest without Name")
+	}
+
+	plan := &Plan{Manifest: mf.Name, Changes: []*Change{}}
+
+	tests := map[m.TestID]bool{}
+	for _, test := range mf.Tests {
+		tests[test.ID] = true
+
+		current, err := sto.GetTestByTestId(test.ID)
+		if err != nil {
+			return nil, err
+		}
+		change, err := newChange(TestKind, string(test.ID), current, test)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			change.Revision = current.Revision
+		}
+		change.test = test
+		change.createdBy = createdBy(current)
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	schedules := map[m.TestScheduleID]*m.TestSchedule{}
+	for _, schedule := range mf.TestSchedules {
+		schedules[schedule.ID] = schedule
+
+		current, err := sto.GetTestSchedule(schedule.ID)
+		if err != nil {
+			return nil, err
+		}
+		change, err := newChange(TestScheduleKind, string(schedule.ID), current, schedule)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			change.Revision = current.Revision
+		}
+		change.schedule = schedule
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if !prune {
+		return plan, nil
+	}
+
+	// Schedules only run Tests of their own project
+	allSchedules, err := sto.GetTestSchedulesByProject(mf.Project)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range allSchedules {
+		if schedule.Manifest != mf.Name || schedules[schedule.ID] != nil {
+			continue
+		}
+		plan.Changes = append(plan.Changes, &Change{
+			Kind:     TestScheduleKind,
+			Name:     string(schedule.ID),
+			Action:   Delete,
+			Revision: schedule.Revision,
+			Fields:   []FieldChange{},
+			schedule: schedule,
+		})
+		schedules[schedule.ID] = nil
+	}
+
+	allTests, err := sto.GetTestsByProject(mf.Project)
+	if err != nil {
+		return nil, err
+	}
+	for _, test := range allTests {
+		if test.Manifest != mf.Name || tests[test.ID] {
+			continue
+		}
+
+		// Schedules outside of the manifest would be left without their Test
+		for _, schedule := range allSchedules {
+			if _, applied := schedules[schedule.ID]; schedule.TestID == test.ID && !applied {
+				return nil, fmt.Errorf(
+					"Cannot prune Test<%s> which is run by TestSchedule<%s>", test.ID, schedule.ID,
+				)
+			}
+		}
+
+		plan.Changes = append(plan.Changes, &Change{
+			Kind:     TestKind,
+			Name:     string(test.ID),
+			Action:   Delete,
+			Revision: test.Revision,
+			Fields:   []FieldChange{},
+			test:     test,
+		})
+	}
+
+	return plan, nil
+}
+
+// Apply makes the changes of the plan on behalf of the identity by, tests are saved before the schedules
+// which run them and deleted after. Objects modified since the plan was made fail with a storage.RevisionConflictError.
+func (p *Plan) Apply(sm mgr.ScheduleManager, by string) error {
+	for _, change := range p.changes(TestKind, Create, Update) {
+		change.test.CreatedBy = change.createdBy
+		if change.Action == Create {
+			change.test.CreatedBy = by
+		}
+		change.test.UpdatedBy = by
+		if err := sto.SaveTest(change.test, change.Revision); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range p.changes(TestScheduleKind, Create, Update) {
+		if err := sm.Update(change.schedule, change.Revision); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range p.changes(TestScheduleKind, Delete) {
+		// Fails without deleting a schedule which is not running
+		if err := sm.Remove(change.schedule.ID); err != nil {
+			if err := sto.DeleteTestSchedule(change.schedule.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, change := range p.changes(TestKind, Delete) {
+		if err := sto.DeleteTest(change.test.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Internal function used to select the changes of a kind with one of the actions
+func (p *Plan) changes(kind string, actions ...Action) []*Change {
+	changes := []*Change{}
+	for _, change := range p.Changes {
+		for _, action := range actions {
+			if change.Kind == kind && change.Action == action {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// Internal function used to retrieve the identity which created a stored Test, if any
+func createdBy(test *m.Test) string {
+	if test == nil {
+		return ""
+	}
+	return test.CreatedBy
+}
+
+// Internal function used to compare the stored object to the declared one
+func newChange(kind string, name string, current interface{}, declared interface{}) (*Change, error) {
+	change := &Change{Kind: kind, Name: name, Action: Create, Fields: []FieldChange{}}
+
+	// A typed nil pointer is not a nil interface
+	var before interface{}
+	if !reflect.ValueOf(current).IsNil() {
+		before = current
+	}
+
+	fields, err := diff(before, declared)
+	if err != nil {
+		return nil, err
+	}
+	change.Fields = fields
+
+	if before != nil {
+		change.Action = Update
+		if len(fields) == 0 {
+			change.Action = Unchanged
+		}
+	}
+	return change, nil
+}
+
+// Internal function used to list the fields which differ between the JSON representations of before and after
+func diff(before interface{}, after interface{}) ([]FieldChange, error) {
+	beforeFields, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for path := range beforeFields {
+		paths = append(paths, path)
+	}
+	for path := range afterFields {
+		if _, exists := beforeFields[path]; !exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	fields := []FieldChange{}
+	for _, path := range paths {
+		if !reflect.DeepEqual(beforeFields[path], afterFields[path]) {
+			fields = append(fields, FieldChange{path, beforeFields[path], afterFields[path]})
+		}
+	}
+	return fields, nil
+}
+
+// Internal function used to map the path of every value of the JSON representation of v to the value.
+// Empty values are left out, and so are the revision and identities which are assigned when saving.
+func flatten(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(enc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	flattenInto(fields, "", decoded)
+	for _, field := range []string{"Revision", "CreatedBy", "UpdatedBy"} {
+		delete(fields, field)
+	}
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, path string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenInto(fields, key, field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			flattenInto(fields, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case nil:
+	default:
+		fields[path] = value
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/influxdata/tdigest"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+// AbortMonitor checks the abort conditions of a job against its
+// live results, keeping per-second summaries of the most recent ones
+type AbortMonitor struct {
+	conditions  []model.AbortCondition
+	window      int64
+	earliest    int64
+	buckets     []abortBucket
+	consecutive uint64
+}
+
+type abortBucket struct {
+	second    int64
+	requests  uint64
+	errors    uint64
+	latencies *tdigest.TDigest
+}
+
+// NewAbortMonitor creates an AbortMonitor for the conditions which apply to the named job
+func NewAbortMonitor(conditions []model.AbortCondition, job string) *AbortMonitor {
+	var am AbortMonitor
+	for _, c := range conditions {
+		if c.Job != "" && c.Job != job {
+			continue
+		}
+		am.conditions = append(am.conditions, c)
+		if int64(c.Window) > am.window {
+			am.window = int64(c.Window)
+		}
+	}
+	return &am
+}
+
+// Add records r and returns a description of the first condition
+// it breaches, or an empty string if there is none
+func (am *AbortMonitor) Add(r *scheduler.Metrics) string {
+	if len(am.conditions) == 0 {
+		return ""
+	}
+
+	if r.Code >= 500 || r.Code == 0 {
+		am.consecutive++
+	} else {
+		am.consecutive = 0
+	}
+
+	second := r.Timestamp.Unix()
+	if len(am.buckets) == 0 || second < am.earliest {
+		am.earliest = second
+	}
+
+	b := am.bucket(second)
+	b.requests++
+	if r.Code < 200 || r.Code >= 400 {
+		b.errors++
+	}
+	b.latencies.Add(float64(r.Latency), 1)
+
+	for i := range am.conditions {
+		if am.breached(&am.conditions[i]) {
+			return am.conditions[i].String()
+		}
+	}
+	return ""
+}
+
+// bucket returns the bucket of the given second, creating it if needed.
+// Results mostly arrive in order, so buckets are searched from the end.
+func (am *AbortMonitor) bucket(second int64) *abortBucket {
+	i := len(am.buckets)
+	for i > 0 && am.buckets[i-1].second > second {
+		i--
+	}
+	if i > 0 && am.buckets[i-1].second == second {
+		return &am.buckets[i-1]
+	}
+
+	am.buckets = append(am.buckets, abortBucket{})
+	copy(am.buckets[i+1:], am.buckets[i:])
+	am.buckets[i] = abortBucket{second: second, latencies: tdigest.NewWithCompression(100)}
+
+	// drop buckets which are outside of every window
+	latest := am.buckets[len(am.buckets)-1].second
+	drop := 0
+	for drop < len(am.buckets)-1 && am.buckets[drop].second < latest-am.window {
+		drop++
+	}
+	if drop > 0 {
+		am.buckets = append(am.buckets[:0], am.buckets[drop:]...)
+		i -= drop
+	}
+	if i < 0 {
+		// second was already outside of every window, so don't keep it
+		return &abortBucket{latencies: tdigest.NewWithCompression(100)}
+	}
+	return &am.buckets[i]
+}
+
+func (am *AbortMonitor) breached(c *model.AbortCondition) bool {
+	latest := am.buckets[len(am.buckets)-1].second
+	window := int64(c.Window)
+
+	switch c.Type {
+	case model.AbortConsecutive:
+		return float64(am.consecutive) >= c.Threshold
+
+	case model.AbortErrorRate:
+		// only judge a full window, so that a few early errors don't abort
+		if latest-am.earliest < window {
+			return false
+		}
+		var requests, errors uint64
+		for _, b := range am.buckets {
+			if b.second > latest-window {
+				requests += b.requests
+				errors += b.errors
+			}
+		}
+		return requests > 0 && float64(errors)/float64(requests) > c.Threshold
+
+	case model.AbortLatency:
+		// the latest second is still incomplete, so check the ones before it
+		if latest-am.earliest < window {
+			return false
+		}
+		threshold := float64(time.Duration(c.Threshold * float64(time.Millisecond)))
+		above := int64(0)
+		for _, b := range am.buckets {
+			if b.second >= latest-window && b.second < latest {
+				if b.latencies.Quantile(0.99) <= threshold {
+					return false
+				}
+				above++
+			}
+		}
+		return above == window
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+func TestAbortMonitor_ErrorRate(t *testing.T) {
+	t.Parallel()
+
+	am := NewAbortMonitor([]model.AbortCondition{
+		{Type: model.AbortErrorRate, Threshold: 0.5, Window: 5},
+	}, "api")
+
+	// 10 requests per second, failing from the 10th second onwards
+	for i := 0; i < 200; i++ {
+		code := uint32(200)
+		if i >= 100 {
+			code = 503
+		}
+		reason := am.Add(&scheduler.Metrics{
+			Code:      code,
+			Timestamp: time.Unix(int64(i/10), 0),
+			Latency:   time.Millisecond,
+		})
+
+		// the window holds 5 seconds of results, so the rate
+		// exceeds 50% with the first error of the 13th second
+		if i < 120 && reason != "" {
+			t.Fatalf("aborted early after %d requests: %s", i+1, reason)
+		}
+		if i == 120 {
+			if reason != "error rate above 50% over 5s" {
+				t.Fatalf("expected abort after %d requests, got %q", i+1, reason)
+			}
+			return
+		}
+	}
+}
+
+func TestAbortMonitor_Latency(t *testing.T) {
+	t.Parallel()
+
+	am := NewAbortMonitor([]model.AbortCondition{
+		{Type: model.AbortLatency, Threshold: 100, Window: 3},
+	}, "api")
+
+	add := func(second int64, latency time.Duration) string {
+		var reason string
+		for i := 0; i < 10; i++ {
+			if r := am.Add(&scheduler.Metrics{
+				Code:      200,
+				Timestamp: time.Unix(second, 0),
+				Latency:   latency,
+			}); r != "" {
+				reason = r
+			}
+		}
+		return reason
+	}
+
+	// a single slow second is not enough
+	for sec, latency := range []time.Duration{10, 500, 10, 500, 500} {
+		if reason := add(int64(sec), latency*time.Millisecond); reason != "" {
+			t.Fatalf("aborted early in second %d: %s", sec, reason)
+		}
+	}
+	// seconds 3, 4 and 5 are slow, which is known once second 6 starts
+	if reason := add(5, 500*time.Millisecond); reason != "" {
+		t.Fatalf("aborted early in second 5: %s", reason)
+	}
+	if reason := add(6, 10*time.Millisecond); reason != "p99 above 100ms for 3s" {
+		t.Errorf("expected abort in second 6, got %q", reason)
+	}
+}
+
+func TestAbortMonitor_Consecutive(t *testing.T) {
+	t.Parallel()
+
+	conditions := []model.AbortCondition{
+		{Job: "api", Type: model.AbortConsecutive, Threshold: 3},
+	}
+
+	// conditions of other jobs are ignored
+	other := NewAbortMonitor(conditions, "web")
+	am := NewAbortMonitor(conditions, "api")
+
+	codes := []uint32{500, 502, 200, 503, 0, 500}
+	for i, code := range codes {
+		r := &scheduler.Metrics{Code: code, Timestamp: time.Unix(0, 0)}
+		if reason := other.Add(r); reason != "" {
+			t.Fatalf("unexpected abort of other job: %s", reason)
+		}
+		reason := am.Add(r)
+		if i < len(codes)-1 && reason != "" {
+			t.Fatalf("aborted early after %d requests: %s", i+1, reason)
+		}
+		if i == len(codes)-1 && reason != "3 consecutive 5xx responses" {
+			t.Errorf("expected abort after %d requests, got %q", i+1, reason)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"math"
 	"strconv"
 	"time"
 
@@ -58,6 +59,13 @@ type Metrics struct {
 	// Errors is a set of unique errors returned by the targets during the attack.
 	Errors []string `json:"errors"`
 
+	// Steps holds the metrics of each scenario step, keyed by step name.
+	Steps map[string]*Metrics `json:"steps,omitempty"`
+
+	// TimeSeries holds the metrics of each interval of the job. It is
+	// served separately since it grows with the duration of the job.
+	TimeSeries *TimeSeries `json:"-"`
+
 	// Used for fast lookup of errors in Errors
 	errors  map[string]struct{}
 	success uint64
@@ -68,6 +76,29 @@ type Metrics struct {
 // Add implements the Add method of the Report interface by adding the given
 // Result to Metrics.
 func (m *Metrics) Add(r *scheduler.Metrics) {
+	m.add(r)
+
+	if r.Step != "" {
+		if m.Steps == nil {
+			m.Steps = map[string]*Metrics{}
+		}
+		step, ok := m.Steps[r.Step]
+		if !ok {
+			step = &Metrics{}
+			m.Steps[r.Step] = step
+		}
+		step.add(r)
+	}
+
+	if m.TimeSeries == nil {
+		m.TimeSeries = &TimeSeries{Interval: TimeSeriesInterval}
+	}
+	m.TimeSeries.add(r)
+
+	m.collector.update(m)
+}
+
+func (m *Metrics) add(r *scheduler.Metrics) {
 	m.init()
 	m.Requests++
 	m.StatusCodes[strconv.Itoa(int(r.Code))]++
@@ -103,13 +134,23 @@ func (m *Metrics) Add(r *scheduler.Metrics) {
 			m.Histogram.Add(r)
 		}
 	*/
-
-	m.collector.update(m)
 }
 
 // Close implements the Close method of the Report interface by computing
 // derived summary metrics which don't need to be run on every Add call.
 func (m *Metrics) Close() {
+	m.close()
+	for _, step := range m.Steps {
+		step.close()
+	}
+	if m.TimeSeries != nil {
+		m.TimeSeries.close()
+	}
+
+	m.collector.clear()
+}
+
+func (m *Metrics) close() {
 	m.init()
 	if m.Requests == 0 {
 		return
@@ -133,8 +174,95 @@ func (m *Metrics) Close() {
 	m.Latencies.P90 = m.Latencies.Quantile(0.90)
 	m.Latencies.P95 = m.Latencies.Quantile(0.95)
 	m.Latencies.P99 = m.Latencies.Quantile(0.99)
+}
 
-	m.collector.clear()
+// Merge returns the combined metrics of ms, as if all of their results had
+// been added to a single Metrics. The result is closed and is not reported.
+func Merge(ms ...*Metrics) *Metrics {
+	var merged Metrics
+	merged.init()
+
+	for _, o := range ms {
+		if o == nil || o.Requests == 0 {
+			continue
+		}
+
+		merged.Requests += o.Requests
+		// success is not kept when metrics are stored, so derive it
+		merged.success += uint64(math.Round(o.Success * float64(o.Requests)))
+		merged.BytesIn.Total += o.BytesIn.Total
+		merged.BytesOut.Total += o.BytesOut.Total
+		merged.Latencies.merge(&o.Latencies)
+
+		for code, count := range o.StatusCodes {
+			merged.StatusCodes[code] += count
+		}
+
+		for _, e := range o.Errors {
+			if _, ok := merged.errors[e]; !ok {
+				merged.errors[e] = struct{}{}
+				merged.Errors = append(merged.Errors, e)
+			}
+		}
+
+		if merged.Earliest.IsZero() || merged.Earliest.After(o.Earliest) {
+			merged.Earliest = o.Earliest
+		}
+		if o.Latest.After(merged.Latest) {
+			merged.Latest = o.Latest
+		}
+		if o.End.After(merged.End) {
+			merged.End = o.End
+		}
+	}
+
+	merged.close()
+	return &merged
+}
+
+// Snapshot returns a closed copy of the metrics added so far, e.g. to persist
+// partial results of a running test. m itself is not closed and keeps
+// accumulating. It must not be called concurrently with Add.
+func (m *Metrics) Snapshot() *Metrics {
+	snapshot := m.snapshot()
+
+	if m.Steps != nil {
+		snapshot.Steps = make(map[string]*Metrics, len(m.Steps))
+		for name, step := range m.Steps {
+			snapshot.Steps[name] = step.snapshot()
+		}
+	}
+
+	if m.TimeSeries != nil {
+		snapshot.TimeSeries = m.TimeSeries.snapshot()
+	}
+
+	return snapshot
+}
+
+func (m *Metrics) snapshot() *Metrics {
+	var s Metrics
+	s.init()
+
+	s.Requests = m.Requests
+	s.success = m.success
+	s.BytesIn.Total = m.BytesIn.Total
+	s.BytesOut.Total = m.BytesOut.Total
+	s.Latencies.merge(&m.Latencies)
+	s.Earliest = m.Earliest
+	s.Latest = m.Latest
+	s.End = m.End
+
+	for code, count := range m.StatusCodes {
+		s.StatusCodes[code] = count
+	}
+	for _, e := range m.Errors {
+		s.errors[e] = struct{}{}
+		s.Errors = append(s.Errors, e)
+	}
+
+	s.close()
+	return &s
 }
 
 func (m *Metrics) init() {
@@ -185,6 +313,21 @@ func (l *LatencyMetrics) Add(latency time.Duration) {
 	l.estimator.Add(float64(latency))
 }
 
+func (l *LatencyMetrics) merge(o *LatencyMetrics) {
+	l.init()
+	l.Total += o.Total
+	if o.Max > l.Max {
+		l.Max = o.Max
+	}
+	if o.Min != 0 && (o.Min < l.Min || l.Min == 0) {
+		l.Min = o.Min
+	}
+
+	if e, ok := o.estimator.(*tdigestEstimator); ok {
+		l.estimator.(*tdigestEstimator).AddCentroidList(e.Centroids())
+	}
+}
+
 // Quantile returns the nth quantile from the latency summary.
 func (l LatencyMetrics) Quantile(nth float64) time.Duration {
 	l.init()
@@ -64,6 +64,8 @@ func TestMetrics_Add(t *testing.T) {
 		StatusCodes: map[string]int{"500": 3333, "200": 3334, "302": 3333},
 		Errors:      []string{"Internal server error"},
 
+		TimeSeries: got.TimeSeries,
+
 		errors:    got.errors,
 		success:   got.success,
 		collector: got.collector,
@@ -72,6 +74,78 @@ func TestMetrics_Add(t *testing.T) {
 	if !reflect.DeepEqual(*got, want) {
 		t.Errorf("\ngot:  %+v\nwant: %+v", got, want)
 	}
+	if got.TimeSeries == nil || len(got.TimeSeries.Buckets) != 10000 {
+		t.Errorf("expected a bucket for every second of results")
+	}
+}
+
+func TestMetrics_Steps(t *testing.T) {
+	t.Parallel()
+
+	got := NewMetricAggregator("testid", "instanceid", "stepjobid")
+
+	for i := 1; i <= 100; i++ {
+		step := "login"
+		if i%4 == 0 {
+			step = "list"
+		}
+		got.Add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(int64(i-1), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+			Step:      step,
+		})
+	}
+	got.Close()
+
+	if got.Requests != 100 {
+		t.Errorf("got %d requests, want 100", got.Requests)
+	}
+	if len(got.Steps) != 2 {
+		t.Fatalf("got %d steps, want 2", len(got.Steps))
+	}
+	if got.Steps["login"].Requests != 75 || got.Steps["list"].Requests != 25 {
+		t.Errorf("got %d login and %d list requests, want 75 and 25",
+			got.Steps["login"].Requests, got.Steps["list"].Requests)
+	}
+	if got.Steps["list"].Latencies.Min != 4*time.Millisecond || got.Steps["list"].Latencies.Max != 100*time.Millisecond {
+		t.Errorf("unexpected latencies for step list: %+v", got.Steps["list"].Latencies)
+	}
+	if got.Steps["login"].Success != 1 {
+		t.Errorf("got success %f for step login, want 1", got.Steps["login"].Success)
+	}
+}
+
+func TestMetrics_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetricAggregator("testid", "instanceid", "snapshotjobid")
+	for i := 1; i <= 50; i++ {
+		m.Add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+			Step:      "login",
+		})
+	}
+
+	got := m.Snapshot()
+
+	// the aggregator keeps accumulating after the snapshot
+	m.Add(&scheduler.Metrics{Code: 500, Timestamp: time.Unix(51, 0), Error: "Internal server error"})
+
+	if got.Requests != 50 || got.Success != 1 || len(got.Errors) != 0 {
+		t.Errorf("got %d requests, success %f and errors %v, want 50, 1 and none", got.Requests, got.Success, got.Errors)
+	}
+	if got.Latencies.Max != 50*time.Millisecond || got.Latencies.P50 == 0 {
+		t.Errorf("unexpected latencies %+v", got.Latencies)
+	}
+	if got.Steps["login"] == nil || got.Steps["login"].Requests != 50 {
+		t.Errorf("expected snapshot of step login, got %+v", got.Steps)
+	}
+	if m.Requests != 51 || m.Success != 0 {
+		t.Errorf("expected aggregator to stay open, got %d requests and success %f", m.Requests, m.Success)
+	}
 }
 
 // TODO: uncomment these later once we find a way to mock
@@ -0,0 +1,329 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+)
+
+// minSamples is the number of time series buckets each side needs
+// for a difference in latency or throughput to be tested
+const minSamples = 5
+
+// Tolerances decide how much worse a candidate may be than its baseline
+// before the difference is considered a regression
+type Tolerances struct {
+	// Latency is the allowed relative increase of latencies, e.g. 0.1 for 10%
+	Latency float64 `json:"latency"`
+
+	// Throughput is the allowed relative decrease of throughput
+	Throughput float64 `json:"throughput"`
+
+	// Success is the allowed absolute decrease of the success ratio
+	Success float64 `json:"success"`
+
+	// StatusCodes is the allowed absolute increase of the share of each error status code
+	StatusCodes float64 `json:"status_codes"`
+
+	// Confidence is the level at which differences are significant, e.g. 0.95
+	Confidence float64 `json:"confidence"`
+}
+
+// DefaultTolerances are used for tolerances which are not specified
+var DefaultTolerances = Tolerances{
+	Latency:     0.1,
+	Throughput:  0.1,
+	Success:     0.01,
+	StatusCodes: 0.01,
+	Confidence:  0.95,
+}
+
+// Diff is the difference of a single metric between a baseline and a candidate
+type Diff struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+
+	// Change is relative to the baseline for latencies and throughput,
+	// absolute for ratios like success and status code shares
+	Change float64 `json:"change"`
+
+	// Tested is set if there was enough data to test the significance of the change
+	Tested      bool `json:"tested"`
+	Significant bool `json:"significant"`
+
+	// Regression is set if the candidate is worse than the tolerance allows,
+	// and the change is significant where it could be tested
+	Regression bool `json:"regression"`
+}
+
+// JobComparison holds the differences of the metrics of a job
+type JobComparison struct {
+	JobID string `json:"job_id"`
+
+	// Missing names the side that has no metrics for the job
+	Missing string `json:"missing,omitempty"`
+
+	Diffs       []Diff `json:"diffs"`
+	StatusCodes []Diff `json:"status_codes"`
+	Regression  bool   `json:"regression"`
+}
+
+// Comparison holds the differences of all jobs of two test instances
+type Comparison struct {
+	Baseline   model.TestInstanceID `json:"baseline"`
+	Candidate  model.TestInstanceID `json:"candidate"`
+	Tolerances Tolerances           `json:"tolerances"`
+	Jobs       []JobComparison      `json:"jobs"`
+	Regression bool                 `json:"regression"`
+}
+
+// Compare diffs the metrics of a candidate instance against a baseline instance job by job.
+// Jobs are matched by JobID, so both instances should be of the same Test.
+func Compare(baseline *model.TestInstance, candidate *model.TestInstance, tolerances Tolerances) Comparison {
+	before, _ := baseline.Metrics.(map[string]*Metrics)
+	after, _ := candidate.Metrics.(map[string]*Metrics)
+
+	comparison := Comparison{
+		Baseline:   baseline.ID,
+		Candidate:  candidate.ID,
+		Tolerances: tolerances,
+		Jobs:       []JobComparison{},
+	}
+
+	jobIDs := map[string]bool{}
+	for jobID := range before {
+		jobIDs[jobID] = true
+	}
+	for jobID := range after {
+		jobIDs[jobID] = true
+	}
+
+	sorted := make([]string, 0, len(jobIDs))
+	for jobID := range jobIDs {
+		sorted = append(sorted, jobID)
+	}
+	sort.Strings(sorted)
+
+	for _, jobID := range sorted {
+		job := compareJob(jobID, before[jobID], after[jobID], tolerances)
+		comparison.Regression = comparison.Regression || job.Regression
+		comparison.Jobs = append(comparison.Jobs, job)
+	}
+
+	return comparison
+}
+
+func compareJob(jobID string, before *Metrics, after *Metrics, tolerances Tolerances) JobComparison {
+	job := JobComparison{JobID: jobID, Diffs: []Diff{}, StatusCodes: []Diff{}}
+
+	switch {
+	case before == nil || before.Requests == 0:
+		job.Missing = "baseline"
+		return job
+	case after == nil || after.Requests == 0:
+		// A job which did not run at all is a regression
+		job.Missing = "candidate"
+		job.Regression = true
+		return job
+	}
+
+	critical := criticalValue(tolerances.Confidence)
+
+	latencies := []struct {
+		metric model.CriterionMetric
+		value  func(l *LatencyMetrics) time.Duration
+	}{
+		{model.MetricP50, func(l *LatencyMetrics) time.Duration { return l.P50 }},
+		{model.MetricP90, func(l *LatencyMetrics) time.Duration { return l.P90 }},
+		{model.MetricP95, func(l *LatencyMetrics) time.Duration { return l.P95 }},
+		{model.MetricP99, func(l *LatencyMetrics) time.Duration { return l.P99 }},
+		{model.MetricMean, func(l *LatencyMetrics) time.Duration { return l.Mean }},
+		{model.MetricMax, func(l *LatencyMetrics) time.Duration { return l.Max }},
+	}
+
+	// Latencies and throughput are tested on the values of each interval
+	for _, l := range latencies {
+		d := Diff{
+			Metric:    string(l.metric),
+			Baseline:  milliseconds(l.value(&before.Latencies)),
+			Candidate: milliseconds(l.value(&after.Latencies)),
+		}
+		d.Change = relativeChange(d.Baseline, d.Candidate)
+		d.Tested, d.Significant = mannWhitney(
+			bucketValues(before.TimeSeries, func(b *Bucket) float64 { return milliseconds(l.value(&b.Latencies)) }),
+			bucketValues(after.TimeSeries, func(b *Bucket) float64 { return milliseconds(l.value(&b.Latencies)) }),
+			critical,
+		)
+		d.Regression = d.Change > tolerances.Latency && (d.Significant || !d.Tested)
+		job.Diffs = append(job.Diffs, d)
+	}
+
+	throughput := Diff{
+		Metric:    string(model.MetricThroughput),
+		Baseline:  before.Throughput,
+		Candidate: after.Throughput,
+	}
+	throughput.Change = relativeChange(throughput.Baseline, throughput.Candidate)
+	throughput.Tested, throughput.Significant = mannWhitney(
+		bucketValues(before.TimeSeries, func(b *Bucket) float64 { return float64(b.Successes) }),
+		bucketValues(after.TimeSeries, func(b *Bucket) float64 { return float64(b.Successes) }),
+		critical,
+	)
+	throughput.Regression = -throughput.Change > tolerances.Throughput && (throughput.Significant || !throughput.Tested)
+	job.Diffs = append(job.Diffs, throughput)
+
+	// Ratios are tested on the counts of all requests
+	success := Diff{
+		Metric:    string(model.MetricSuccess),
+		Baseline:  before.Success,
+		Candidate: after.Success,
+		Change:    after.Success - before.Success,
+		Tested:    true,
+	}
+	success.Significant = proportionsDiffer(before.Success, before.Requests, after.Success, after.Requests, critical)
+	success.Regression = -success.Change > tolerances.Success && success.Significant
+	job.Diffs = append(job.Diffs, success)
+
+	codes := map[string]bool{}
+	for code := range before.StatusCodes {
+		codes[code] = true
+	}
+	for code := range after.StatusCodes {
+		codes[code] = true
+	}
+	sortedCodes := make([]string, 0, len(codes))
+	for code := range codes {
+		sortedCodes = append(sortedCodes, code)
+	}
+	sort.Strings(sortedCodes)
+
+	for _, code := range sortedCodes {
+		d := Diff{
+			Metric:    code,
+			Baseline:  float64(before.StatusCodes[code]) / float64(before.Requests),
+			Candidate: float64(after.StatusCodes[code]) / float64(after.Requests),
+			Tested:    true,
+		}
+		d.Change = d.Candidate - d.Baseline
+		d.Significant = proportionsDiffer(d.Baseline, before.Requests, d.Candidate, after.Requests, critical)
+
+		// Only a growing share of failed requests is a regression
+		d.Regression = isErrorCode(code) && d.Change > tolerances.StatusCodes && d.Significant
+		job.StatusCodes = append(job.StatusCodes, d)
+	}
+
+	for _, d := range append(job.Diffs, job.StatusCodes...) {
+		job.Regression = job.Regression || d.Regression
+	}
+
+	return job
+}
+
+// Internal function used to get the value of each bucket of a time series
+func bucketValues(ts *TimeSeries, value func(b *Bucket) float64) []float64 {
+	if ts == nil {
+		return nil
+	}
+
+	values := make([]float64, 0, len(ts.Buckets))
+	for _, b := range ts.Buckets {
+		if b.Requests > 0 {
+			values = append(values, value(b))
+		}
+	}
+	return values
+}
+
+// Internal function used to test whether two samples come from different distributions
+// with the Mann-Whitney U test, using the normal approximation with a correction for ties
+func mannWhitney(a []float64, b []float64, critical float64) (tested bool, significant bool) {
+	if len(a) < minSamples || len(b) < minSamples {
+		return false, false
+	}
+
+	type sample struct {
+		value float64
+		first bool
+	}
+	samples := make([]sample, 0, len(a)+len(b))
+	for _, v := range a {
+		samples = append(samples, sample{v, true})
+	}
+	for _, v := range b {
+		samples = append(samples, sample{v, false})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].value < samples[j].value })
+
+	// Tied values get the average of their ranks
+	var rankSum, ties float64
+	for i := 0; i < len(samples); {
+		j := i
+		for j < len(samples) && samples[j].value == samples[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if samples[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	n := n1 + n2
+	u := rankSum - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+
+	// All values are equal
+	if variance <= 0 {
+		return true, false
+	}
+
+	z := (u - mean) / math.Sqrt(variance)
+	return true, math.Abs(z) > critical
+}
+
+// Internal function used to test whether two ratios of n1 and n2 trials differ with a two proportion z-test
+func proportionsDiffer(p1 float64, n1 uint64, p2 float64, n2 uint64, critical float64) bool {
+	pooled := (p1*float64(n1) + p2*float64(n2)) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return p1 != p2
+	}
+
+	return math.Abs(p1-p2)/se > critical
+}
+
+// Internal function used to get the two sided critical value of the standard normal distribution
+func criticalValue(confidence float64) float64 {
+	if confidence <= 0 || confidence >= 1 {
+		confidence = DefaultTolerances.Confidence
+	}
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// Internal function used to get the change relative to before, any
+// increase from 0 counts as 100% as infinity cannot be encoded in json
+func relativeChange(before float64, after float64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (after - before) / before
+}
+
+// Internal function used to check whether a status code denotes a failed request
+func isErrorCode(code string) bool {
+	c, err := strconv.Atoi(code)
+	return err != nil || c < 200 || c >= 400
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+// Internal function used to create the metrics of a job which ran for 30s at 10/s
+func runJob(latency func(i int) time.Duration, code func(i int) uint32) *Metrics {
+	m := &Metrics{TimeSeries: &TimeSeries{Interval: TimeSeriesInterval}}
+	for i := 0; i < 300; i++ {
+		r := &scheduler.Metrics{
+			Code:      code(i),
+			Timestamp: time.Unix(1000, 0).Add(time.Duration(i) * 100 * time.Millisecond),
+			Latency:   latency(i),
+		}
+		m.add(r)
+		m.TimeSeries.add(r)
+	}
+	m.close()
+	m.TimeSeries.close()
+	return m
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	ok := func(i int) uint32 { return 200 }
+	baseline := &model.TestInstance{
+		ID: "baseline",
+		Metrics: map[string]*Metrics{
+			"fast":    runJob(func(i int) time.Duration { return time.Duration(10+i%5) * time.Millisecond }, ok),
+			"flaky":   runJob(func(i int) time.Duration { return 10 * time.Millisecond }, ok),
+			"removed": runJob(func(i int) time.Duration { return 10 * time.Millisecond }, ok),
+		},
+	}
+	candidate := &model.TestInstance{
+		ID: "candidate",
+		Metrics: map[string]*Metrics{
+			// within the tolerance of 10%
+			"fast": runJob(func(i int) time.Duration { return time.Duration(10+i%6) * time.Millisecond }, ok),
+			// every 10th request fails
+			"flaky": runJob(func(i int) time.Duration { return 10 * time.Millisecond }, func(i int) uint32 {
+				if i%10 == 0 {
+					return 503
+				}
+				return 200
+			}),
+			"added": runJob(func(i int) time.Duration { return 10 * time.Millisecond }, ok),
+		},
+	}
+
+	got := Compare(baseline, candidate, DefaultTolerances)
+	if !got.Regression || len(got.Jobs) != 4 {
+		t.Fatalf("expected a regression in 4 jobs, got %+v", got)
+	}
+
+	jobs := map[string]JobComparison{}
+	for _, job := range got.Jobs {
+		jobs[job.JobID] = job
+	}
+
+	if jobs["fast"].Regression {
+		t.Errorf("expected no regression for job within tolerances, got %+v", jobs["fast"])
+	}
+	if jobs["added"].Missing != "baseline" || jobs["added"].Regression {
+		t.Errorf("expected job without baseline not to regress, got %+v", jobs["added"])
+	}
+	if jobs["removed"].Missing != "candidate" || !jobs["removed"].Regression {
+		t.Errorf("expected job which did not run to regress, got %+v", jobs["removed"])
+	}
+
+	flaky := jobs["flaky"]
+	if !flaky.Regression {
+		t.Fatalf("expected failing requests to regress, got %+v", flaky)
+	}
+	for _, d := range flaky.StatusCodes {
+		if d.Metric == "503" && (!d.Regression || d.Candidate != 0.1) {
+			t.Errorf("expected share of 503 to regress, got %+v", d)
+		}
+		if d.Metric == "200" && d.Regression {
+			t.Errorf("expected fewer successful responses not to count twice, got %+v", d)
+		}
+	}
+}
+
+func TestCompare_Latency(t *testing.T) {
+	t.Parallel()
+
+	ok := func(i int) uint32 { return 200 }
+	baseline := &model.TestInstance{ID: "baseline", Metrics: map[string]*Metrics{
+		"job": runJob(func(i int) time.Duration { return time.Duration(10+i%5) * time.Millisecond }, ok),
+	}}
+	slower := &model.TestInstance{ID: "slower", Metrics: map[string]*Metrics{
+		"job": runJob(func(i int) time.Duration { return time.Duration(20+i%5) * time.Millisecond }, ok),
+	}}
+
+	got := Compare(baseline, slower, DefaultTolerances)
+	if !got.Regression {
+		t.Fatalf("expected slower job to regress, got %+v", got)
+	}
+	for _, d := range got.Jobs[0].Diffs {
+		if d.Metric == string(model.MetricP50) && (!d.Tested || !d.Significant || !d.Regression || d.Change < 0.5) {
+			t.Errorf("expected significant regression of p50, got %+v", d)
+		}
+	}
+
+	// a generous tolerance accepts the slower job
+	tolerances := DefaultTolerances
+	tolerances.Latency = 2
+	if got := Compare(baseline, slower, tolerances); got.Regression {
+		t.Errorf("expected no regression within tolerance, got %+v", got)
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	t.Parallel()
+
+	critical := criticalValue(0.95)
+	if tested, _ := mannWhitney([]float64{1, 2}, []float64{1, 2, 3, 4, 5}, critical); tested {
+		t.Errorf("expected too few samples not to be tested")
+	}
+	if _, significant := mannWhitney([]float64{1, 2, 3, 4, 5, 6}, []float64{2, 3, 1, 5, 4, 6}, critical); significant {
+		t.Errorf("expected equal samples not to differ")
+	}
+	if _, significant := mannWhitney([]float64{1, 2, 3, 4, 5, 6}, []float64{11, 12, 13, 14, 15, 16}, critical); !significant {
+		t.Errorf("expected shifted samples to differ")
+	}
+	if _, significant := mannWhitney([]float64{5, 5, 5, 5, 5}, []float64{5, 5, 5, 5, 5}, critical); significant {
+		t.Errorf("expected identical samples not to differ")
+	}
+}
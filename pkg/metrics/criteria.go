@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+)
+
+// Evaluate checks the criteria of test against the metrics of its jobs,
+// keyed by JobID. Criteria without a job are checked against the
+// metrics of all jobs combined. The verdict is empty if test has no criteria.
+func Evaluate(test *model.Test, jobs map[string]*Metrics) (model.Verdict, []model.CriterionResult) {
+	if len(test.Criteria) == 0 {
+		return "", nil
+	}
+
+	var combined *Metrics
+	verdict := model.VerdictPassed
+	results := make([]model.CriterionResult, 0, len(test.Criteria))
+
+	for _, c := range test.Criteria {
+		var target *Metrics
+		if c.Job == "" {
+			if combined == nil {
+				all := make([]*Metrics, 0, len(jobs))
+				for _, mAgg := range jobs {
+					all = append(all, mAgg)
+				}
+				combined = Merge(all...)
+			}
+			target = combined
+		} else {
+			for _, j := range test.Jobs {
+				if j.Name == c.Job {
+					target = jobs[string(j.ID)]
+					break
+				}
+			}
+		}
+
+		result := model.CriterionResult{Criterion: c}
+		if value, err := criterionValue(c.Metric, target); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Value = value
+			result.Passed = c.Holds(value)
+		}
+
+		if !result.Passed {
+			verdict = model.VerdictFailed
+		}
+		results = append(results, result)
+	}
+
+	return verdict, results
+}
+
+func criterionValue(metric model.CriterionMetric, m *Metrics) (float64, error) {
+	if m == nil || m.Requests == 0 {
+		return 0, fmt.Errorf("no requests were recorded")
+	}
+
+	switch metric {
+	case model.MetricP50:
+		return milliseconds(m.Latencies.P50), nil
+	case model.MetricP90:
+		return milliseconds(m.Latencies.P90), nil
+	case model.MetricP95:
+		return milliseconds(m.Latencies.P95), nil
+	case model.MetricP99:
+		return milliseconds(m.Latencies.P99), nil
+	case model.MetricMean:
+		return milliseconds(m.Latencies.Mean), nil
+	case model.MetricMax:
+		return milliseconds(m.Latencies.Max), nil
+	case model.MetricSuccess:
+		return m.Success, nil
+	case model.MetricErrors:
+		return float64(m.Requests) - math.Round(m.Success*float64(m.Requests)), nil
+	case model.MetricRequests:
+		return float64(m.Requests), nil
+	case model.MetricRate:
+		return m.Rate, nil
+	case model.MetricThroughput:
+		return m.Throughput, nil
+	}
+	return 0, fmt.Errorf("unknown metric `%s`", metric)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	a, b := &Metrics{}, &Metrics{}
+	for i := 1; i <= 100; i++ {
+		a.add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+		})
+		b.add(&scheduler.Metrics{
+			Code:      500,
+			Timestamp: time.Unix(int64(i+50), 0),
+			Latency:   time.Duration(i+100) * time.Millisecond,
+			Error:     "Internal server error",
+		})
+	}
+	a.close()
+	b.close()
+
+	got := Merge(a, b, nil)
+	if got.Requests != 200 {
+		t.Errorf("got %d requests, want 200", got.Requests)
+	}
+	if got.Success != 0.5 {
+		t.Errorf("got success %v, want 0.5", got.Success)
+	}
+	if got.StatusCodes["200"] != 100 || got.StatusCodes["500"] != 100 {
+		t.Errorf("unexpected status codes %v", got.StatusCodes)
+	}
+	if got.Latencies.Min != time.Millisecond || got.Latencies.Max != 200*time.Millisecond {
+		t.Errorf("unexpected latencies %+v", got.Latencies)
+	}
+	if p50 := got.Latencies.P50; p50 < 95*time.Millisecond || p50 > 105*time.Millisecond {
+		t.Errorf("got p50 %s, want about 100ms", p50)
+	}
+	if !got.Earliest.Equal(time.Unix(1, 0)) || !got.Latest.Equal(time.Unix(150, 0)) {
+		t.Errorf("got span %s - %s, want 1 - 150", got.Earliest, got.Latest)
+	}
+	if len(got.Errors) != 1 {
+		t.Errorf("got errors %v, want 1", got.Errors)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	fast, slow := &Metrics{}, &Metrics{}
+	for i := 1; i <= 100; i++ {
+		fast.add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   10 * time.Millisecond,
+		})
+		code := uint32(200)
+		if i%10 == 0 {
+			code = 503
+		}
+		slow.add(&scheduler.Metrics{
+			Code:      code,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   500 * time.Millisecond,
+		})
+	}
+	fast.close()
+	slow.close()
+
+	test := &model.Test{
+		Jobs: []model.Job{
+			{ID: "fast-id", Name: "fast"},
+			{ID: "slow-id", Name: "slow"},
+			{ID: "idle-id", Name: "idle"},
+		},
+	}
+	jobs := map[string]*Metrics{"fast-id": fast, "slow-id": slow}
+
+	verdict, results := Evaluate(test, jobs)
+	if verdict != "" || results != nil {
+		t.Errorf("expected no verdict without criteria, got %q %v", verdict, results)
+	}
+
+	test.Criteria = []model.Criterion{
+		{Job: "fast", Metric: model.MetricP99, Operator: "<", Threshold: 300},
+		{Job: "fast", Metric: model.MetricErrors, Operator: "<=", Threshold: 0},
+		{Metric: model.MetricRequests, Operator: ">=", Threshold: 200},
+		{Metric: model.MetricSuccess, Operator: ">", Threshold: 0.99},
+	}
+	verdict, results = Evaluate(test, jobs)
+	if verdict != model.VerdictFailed {
+		t.Errorf("got verdict %q, want %q", verdict, model.VerdictFailed)
+	}
+	wantPassed := []bool{true, true, true, false}
+	wantValues := []float64{10, 0, 200, 0.95}
+	for i, r := range results {
+		if r.Passed != wantPassed[i] || r.Value != wantValues[i] {
+			t.Errorf("criterion %s: got %v with value %v, want %v with value %v",
+				&r.Criterion, r.Passed, r.Value, wantPassed[i], wantValues[i])
+		}
+	}
+
+	test.Criteria = test.Criteria[:3]
+	if verdict, _ = Evaluate(test, jobs); verdict != model.VerdictPassed {
+		t.Errorf("got verdict %q, want %q", verdict, model.VerdictPassed)
+	}
+
+	// jobs without results fail their criteria
+	test.Criteria = []model.Criterion{{Job: "idle", Metric: model.MetricMean, Operator: "<", Threshold: 100}}
+	verdict, results = Evaluate(test, jobs)
+	if verdict != model.VerdictFailed || results[0].Error != "no requests were recorded" {
+		t.Errorf("got verdict %q and %+v, want failure without requests", verdict, results[0])
+	}
+}
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+// LiveInterval is the interval the results of a running TestInstance are published in
+const LiveInterval = time.Second
+
+// liveBuffer is the number of updates a subscriber can fall behind by before its oldest
+// updates are dropped, so that slow subscribers never block the results of the jobs
+const liveBuffer = 16
+
+// LiveUpdate holds the results of one interval of a running TestInstance
+type LiveUpdate struct {
+	// Time is the end of the interval.
+	Time time.Time `json:"time"`
+
+	// Jobs holds the results of each job, keyed by job ID.
+	Jobs map[string]*LiveJob `json:"jobs"`
+
+	// Events which occurred within the interval, such as chaos simulations
+	// ending or the workload of a failed worker being reassigned.
+	Events []model.InstanceEvent `json:"events,omitempty"`
+}
+
+// LiveJob holds the results of one job within the interval of a LiveUpdate
+type LiveJob struct {
+	*Bucket
+
+	// Rate is the number of requests per second sent within the interval.
+	Rate float64 `json:"rate"`
+
+	// Workers is the number of workers the job is currently assigned to.
+	Workers int `json:"workers"`
+}
+
+// LiveFeed aggregates the results of the jobs of a running TestInstance and
+// publishes them every LiveInterval to any number of subscribers
+type LiveFeed struct {
+	jobs map[string]*liveJob
+
+	mux         sync.Mutex
+	start       time.Time
+	events      []model.InstanceEvent
+	subscribers map[*LiveSubscription]struct{}
+	closed      bool
+}
+
+// liveJob holds the results of a job within the current interval. Each job has its
+// own lock, so the jobs of a TestInstance only contend with publishing.
+type liveJob struct {
+	mux    sync.Mutex
+	bucket *Bucket
+}
+
+// LiveSubscription receives the updates of a LiveFeed until it is cancelled or the feed is closed
+type LiveSubscription struct {
+	feed    *LiveFeed
+	updates chan *LiveUpdate
+	dropped uint64
+}
+
+// NewLiveFeed creates a LiveFeed for the jobs with the given IDs
+func NewLiveFeed(jobIDs []string) *LiveFeed {
+	f := &LiveFeed{
+		jobs:        make(map[string]*liveJob, len(jobIDs)),
+		start:       time.Now(),
+		subscribers: map[*LiveSubscription]struct{}{},
+	}
+	for _, id := range jobIDs {
+		f.jobs[id] = &liveJob{bucket: newBucket(f.start)}
+	}
+	return f
+}
+
+// Add records a result of the job with the given ID in the current interval
+func (f *LiveFeed) Add(jobID string, r *scheduler.Metrics) {
+	job, ok := f.jobs[jobID]
+	if !ok {
+		return
+	}
+
+	job.mux.Lock()
+	job.bucket.add(r)
+	job.mux.Unlock()
+}
+
+// AddEvent records an event in the current interval
+func (f *LiveFeed) AddEvent(event model.InstanceEvent) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.events = append(f.events, event)
+}
+
+// Publish sends the results since the previous update to every subscriber and starts a
+// new interval. workers is the number of workers of each job, keyed by job ID.
+// Subscribers whose buffer is full lose their oldest update instead of blocking.
+func (f *LiveFeed) Publish(now time.Time, workers map[string]int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	update := &LiveUpdate{
+		Time:   now,
+		Jobs:   make(map[string]*LiveJob, len(f.jobs)),
+		Events: f.events,
+	}
+	seconds := now.Sub(f.start).Seconds()
+	for id, job := range f.jobs {
+		job.mux.Lock()
+		bucket := job.bucket
+		job.bucket = newBucket(now)
+		job.mux.Unlock()
+
+		bucket.close()
+		live := &LiveJob{Bucket: bucket, Workers: workers[id]}
+		if seconds > 0 {
+			live.Rate = float64(bucket.Requests) / seconds
+		}
+		update.Jobs[id] = live
+	}
+	f.start = now
+	f.events = nil
+
+	for sub := range f.subscribers {
+		sub.send(update)
+	}
+}
+
+// Subscribe returns a subscription to the updates published from now on.
+// The subscription of a closed feed receives no updates.
+func (f *LiveFeed) Subscribe() *LiveSubscription {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	sub := &LiveSubscription{feed: f, updates: make(chan *LiveUpdate, liveBuffer)}
+	if f.closed {
+		close(sub.updates)
+		return sub
+	}
+	f.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close ends the subscriptions of the feed, after the TestInstance has finished
+func (f *LiveFeed) Close() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+	for sub := range f.subscribers {
+		close(sub.updates)
+	}
+	f.subscribers = nil
+}
+
+// Updates returns the channel the updates are received on, which is closed
+// once the subscription is cancelled or the feed is closed
+func (s *LiveSubscription) Updates() <-chan *LiveUpdate {
+	return s.updates
+}
+
+// Dropped returns the number of updates dropped since the previous call
+// because the subscriber did not receive them in time
+func (s *LiveSubscription) Dropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
+}
+
+// Cancel ends the subscription
+func (s *LiveSubscription) Cancel() {
+	s.feed.mux.Lock()
+	defer s.feed.mux.Unlock()
+
+	if _, ok := s.feed.subscribers[s]; ok {
+		delete(s.feed.subscribers, s)
+		close(s.updates)
+	}
+}
+
+// Internal function used to send an update without blocking, dropping the oldest
+// update of a full buffer. Only called while the feed is locked.
+func (s *LiveSubscription) send(update *LiveUpdate) {
+	select {
+	case s.updates <- update:
+		return
+	default:
+	}
+
+	select {
+	case <-s.updates:
+		atomic.AddUint64(&s.dropped, 1)
+	default:
+	}
+	select {
+	case s.updates <- update:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
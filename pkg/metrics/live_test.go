@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+func TestLiveFeed_Publish(t *testing.T) {
+	t.Parallel()
+
+	feed := NewLiveFeed([]string{"job-1", "job-2"})
+	sub := feed.Subscribe()
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		feed.Add("job-1", &scheduler.Metrics{Code: 200, Timestamp: now, Latency: 10 * time.Millisecond})
+	}
+	feed.Add("job-2", &scheduler.Metrics{Code: 500, Timestamp: now, Latency: time.Second, Error: "failed"})
+	feed.Add("unknown", &scheduler.Metrics{Code: 200, Timestamp: now})
+	feed.AddEvent(model.InstanceEvent{Time: now.Unix(), Type: "chaos"})
+
+	feed.Publish(feed.start.Add(2*time.Second), map[string]int{"job-1": 3})
+
+	update := <-sub.Updates()
+	first, second := update.Jobs["job-1"], update.Jobs["job-2"]
+	if len(update.Jobs) != 2 || first.Requests != 10 || first.Rate != 5 || first.Workers != 3 {
+		t.Errorf("unexpected results of job-1 %+v", first)
+	}
+	if second.Requests != 1 || second.Errors != 1 || second.Latencies.P50 != time.Second || second.Workers != 0 {
+		t.Errorf("unexpected results of job-2 %+v", second)
+	}
+	if len(update.Events) != 1 || update.Events[0].Type != "chaos" {
+		t.Errorf("got events %v, want the chaos event", update.Events)
+	}
+
+	// Every interval starts empty
+	feed.Publish(update.Time.Add(time.Second), nil)
+	update = <-sub.Updates()
+	if update.Jobs["job-1"].Requests != 0 || update.Jobs["job-1"].Rate != 0 || len(update.Events) != 0 {
+		t.Errorf("expected an empty interval, got %+v", update.Jobs["job-1"])
+	}
+}
+
+func TestLiveFeed_SlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	feed := NewLiveFeed([]string{"job-1"})
+	slow, fast := feed.Subscribe(), feed.Subscribe()
+
+	start := time.Now()
+	for i := 0; i < liveBuffer+5; i++ {
+		feed.Publish(start.Add(time.Duration(i+1)*time.Second), nil)
+		<-fast.Updates()
+	}
+
+	if dropped := slow.Dropped(); dropped != 5 {
+		t.Errorf("got %d dropped updates, want 5", dropped)
+	}
+	if dropped := slow.Dropped(); dropped != 0 {
+		t.Errorf("expected dropped updates to be counted once, got %d", dropped)
+	}
+
+	// The oldest updates are dropped, so the slow subscriber catches up on the latest
+	if update := <-slow.Updates(); !update.Time.Equal(start.Add(6 * time.Second)) {
+		t.Errorf("got update of %s, want the sixth", update.Time)
+	}
+	if fast.Dropped() != 0 {
+		t.Error("expected no dropped update of the fast subscriber")
+	}
+}
+
+func TestLiveFeed_Close(t *testing.T) {
+	t.Parallel()
+
+	feed := NewLiveFeed([]string{"job-1"})
+	sub, cancelled := feed.Subscribe(), feed.Subscribe()
+
+	cancelled.Cancel()
+	cancelled.Cancel()
+	if _, ok := <-cancelled.Updates(); ok {
+		t.Error("expected no update after the subscription was cancelled")
+	}
+
+	feed.Publish(time.Now(), nil)
+	feed.Close()
+	feed.Publish(time.Now(), nil)
+
+	if _, ok := <-sub.Updates(); !ok {
+		t.Error("expected the update published before closing")
+	}
+	if _, ok := <-sub.Updates(); ok {
+		t.Error("expected no update after closing")
+	}
+
+	late := feed.Subscribe()
+	if _, ok := <-late.Updates(); ok {
+		t.Error("expected subscriptions of a closed feed to be closed")
+	}
+	late.Cancel()
+}
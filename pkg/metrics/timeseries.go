@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/tdigest"
+
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+// TimeSeriesInterval is the width of the buckets results are recorded in.
+// Coarser intervals are derived from them with Resample.
+const TimeSeriesInterval = time.Second
+
+// Compression of the latency digest of a single bucket, lower than the one of
+// a whole job since a bucket holds few results and is stored many times over
+const bucketCompression = 20
+
+// TimeSeries holds the metrics of consecutive time intervals of a job
+type TimeSeries struct {
+	// Interval is the width of each bucket.
+	Interval time.Duration `json:"interval"`
+
+	// Buckets ordered by their start, intervals without results are left out.
+	Buckets []*Bucket `json:"buckets"`
+}
+
+// Bucket holds the metrics of the results sent within one interval
+type Bucket struct {
+	// Start of the interval.
+	Start time.Time `json:"start"`
+
+	// Requests is the number of requests sent within the interval.
+	Requests uint64 `json:"requests"`
+
+	// Successes is the number of non-error responses.
+	Successes uint64 `json:"successes"`
+
+	// Success is the percentage of non-error responses.
+	Success float64 `json:"success"`
+
+	// Errors is the number of requests which returned an error.
+	Errors uint64 `json:"errors"`
+
+	// Latencies holds computed request latency metrics.
+	Latencies LatencyMetrics `json:"latencies"`
+
+	// BytesIn holds computed incoming byte metrics.
+	BytesIn ByteMetrics `json:"bytes_in"`
+
+	// BytesOut holds computed outgoing byte metrics.
+	BytesOut ByteMetrics `json:"bytes_out"`
+
+	// StatusCodes is a histogram of the responses' status codes.
+	StatusCodes map[string]int `json:"status_codes"`
+
+	// Centroids of the latency digest, kept so that buckets can still
+	// be merged after they were stored
+	Centroids tdigest.CentroidList `json:"-"`
+}
+
+// Internal function used to add a result to the bucket its timestamp falls in
+func (ts *TimeSeries) add(r *scheduler.Metrics) {
+	if ts.Interval == 0 {
+		ts.Interval = TimeSeriesInterval
+	}
+
+	ts.bucket(r.Timestamp.Truncate(ts.Interval)).add(r)
+}
+
+// Internal function used to find or create the bucket starting at start
+func (ts *TimeSeries) bucket(start time.Time) *Bucket {
+	// Results mostly arrive in order, so search from the end
+	i := len(ts.Buckets)
+	for i > 0 && ts.Buckets[i-1].Start.After(start) {
+		i--
+	}
+	if i > 0 && ts.Buckets[i-1].Start.Equal(start) {
+		return ts.Buckets[i-1]
+	}
+
+	b := newBucket(start)
+	ts.Buckets = append(ts.Buckets, nil)
+	copy(ts.Buckets[i+1:], ts.Buckets[i:])
+	ts.Buckets[i] = b
+
+	return b
+}
+
+// Internal function used to compute the derived metrics of every bucket
+func (ts *TimeSeries) close() {
+	for _, b := range ts.Buckets {
+		b.close()
+	}
+}
+
+// Internal function used to create a closed copy of the time series
+func (ts *TimeSeries) snapshot() *TimeSeries {
+	return Resample(ts.Interval, ts)
+}
+
+// Resample combines the buckets of the given time series into buckets of the
+// given interval, e.g. to chart a long test in 10s steps or the load of all
+// jobs of a test together. Interval should be a multiple of the interval of
+// each time series.
+func Resample(interval time.Duration, series ...*TimeSeries) *TimeSeries {
+	result := &TimeSeries{Interval: interval, Buckets: []*Bucket{}}
+	buckets := map[time.Time]*Bucket{}
+
+	for _, ts := range series {
+		if ts == nil {
+			continue
+		}
+
+		for _, b := range ts.Buckets {
+			start := b.Start.Truncate(interval)
+			merged, ok := buckets[start]
+			if !ok {
+				merged = newBucket(start)
+				buckets[start] = merged
+				result.Buckets = append(result.Buckets, merged)
+			}
+			merged.merge(b)
+		}
+	}
+
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Start.Before(result.Buckets[j].Start)
+	})
+	result.close()
+
+	return result
+}
+
+func newBucket(start time.Time) *Bucket {
+	b := &Bucket{Start: start, StatusCodes: map[string]int{}}
+	b.Latencies.estimator = newTdigestEstimator(bucketCompression)
+	return b
+}
+
+func (b *Bucket) add(r *scheduler.Metrics) {
+	b.Requests++
+	b.StatusCodes[strconv.Itoa(int(r.Code))]++
+	b.BytesIn.Total += r.BytesIn
+	b.BytesOut.Total += r.BytesOut
+	b.Latencies.Add(r.Latency)
+
+	if r.Code >= 200 && r.Code < 400 {
+		b.Successes++
+	}
+	if r.Error != "" {
+		b.Errors++
+	}
+}
+
+func (b *Bucket) merge(o *Bucket) {
+	b.Requests += o.Requests
+	b.Successes += o.Successes
+	b.Errors += o.Errors
+	b.BytesIn.Total += o.BytesIn.Total
+	b.BytesOut.Total += o.BytesOut.Total
+
+	for code, count := range o.StatusCodes {
+		b.StatusCodes[code] += count
+	}
+
+	// Stored buckets only have their centroids left
+	latencies := o.Latencies
+	if latencies.estimator == nil {
+		e := newTdigestEstimator(bucketCompression)
+		e.AddCentroidList(o.Centroids)
+		latencies.estimator = e
+	}
+	b.Latencies.merge(&latencies)
+}
+
+func (b *Bucket) close() {
+	if b.Requests == 0 {
+		return
+	}
+
+	b.Success = float64(b.Successes) / float64(b.Requests)
+	b.BytesIn.Mean = float64(b.BytesIn.Total) / float64(b.Requests)
+	b.BytesOut.Mean = float64(b.BytesOut.Total) / float64(b.Requests)
+	b.Latencies.Mean = time.Duration(float64(b.Latencies.Total) / float64(b.Requests))
+	b.Latencies.P50 = b.Latencies.Quantile(0.50)
+	b.Latencies.P90 = b.Latencies.Quantile(0.90)
+	b.Latencies.P95 = b.Latencies.Quantile(0.95)
+	b.Latencies.P99 = b.Latencies.Quantile(0.99)
+
+	if e, ok := b.Latencies.estimator.(*tdigestEstimator); ok {
+		b.Centroids = e.Centroids()
+	}
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/scheduler"
+	"github.com/t-bfame/diago/pkg/tools"
+)
+
+func TestTimeSeries_Add(t *testing.T) {
+	t.Parallel()
+
+	var ts TimeSeries
+	start := time.Unix(1000, 0)
+
+	// 10 results per second for 4 seconds, which get slow after 2 seconds
+	for i := 0; i < 40; i++ {
+		latency := 10 * time.Millisecond
+		code := uint32(200)
+		if i >= 20 {
+			latency = time.Second
+			code = 500
+		}
+		ts.add(&scheduler.Metrics{
+			Code:      code,
+			Timestamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+			Latency:   latency,
+			BytesIn:   10,
+		})
+	}
+
+	// a late result of the first second
+	ts.add(&scheduler.Metrics{Code: 200, Timestamp: start.Add(500 * time.Millisecond), Latency: 10 * time.Millisecond})
+	ts.close()
+
+	if ts.Interval != TimeSeriesInterval || len(ts.Buckets) != 4 {
+		t.Fatalf("got %d buckets of %s, want 4 of %s", len(ts.Buckets), ts.Interval, TimeSeriesInterval)
+	}
+
+	first, last := ts.Buckets[0], ts.Buckets[3]
+	if !first.Start.Equal(start) || first.Requests != 11 || first.Success != 1 || first.BytesIn.Total != 100 {
+		t.Errorf("unexpected first bucket %+v", first)
+	}
+	if !last.Start.Equal(start.Add(3*time.Second)) || last.Success != 0 || last.StatusCodes["500"] != 10 {
+		t.Errorf("unexpected last bucket %+v", last)
+	}
+	if first.Latencies.P99 != 10*time.Millisecond || last.Latencies.P50 != time.Second {
+		t.Errorf("got p99 %s and p50 %s, want 10ms and 1s", first.Latencies.P99, last.Latencies.P50)
+	}
+}
+
+func TestResample(t *testing.T) {
+	t.Parallel()
+
+	a, b := &TimeSeries{}, &TimeSeries{}
+	start := time.Unix(1000, 0)
+	for i := 0; i < 20; i++ {
+		timestamp := start.Add(time.Duration(i) * time.Second)
+		a.add(&scheduler.Metrics{Code: 200, Timestamp: timestamp, Latency: time.Duration(i+1) * time.Millisecond})
+		b.add(&scheduler.Metrics{Code: 500, Timestamp: timestamp, Latency: time.Second, Error: "Internal server error"})
+	}
+	a.close()
+	b.close()
+
+	// buckets are merged from their centroids once they were stored
+	encoded, err := tools.GobEncode(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored *TimeSeries
+	if err := tools.GobDecode(&stored, encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Resample(10*time.Second, stored)
+	if len(got.Buckets) != 2 || got.Buckets[0].Requests != 10 {
+		t.Fatalf("got %d buckets, want 2 of 10 requests", len(got.Buckets))
+	}
+	if p50 := got.Buckets[1].Latencies.P50; p50 < 14*time.Millisecond || p50 > 17*time.Millisecond {
+		t.Errorf("got p50 %s, want about 15ms", p50)
+	}
+	if got.Buckets[1].Latencies.Min != 11*time.Millisecond || got.Buckets[1].Latencies.Max != 20*time.Millisecond {
+		t.Errorf("unexpected latencies %+v", got.Buckets[1].Latencies)
+	}
+
+	total := Resample(10*time.Second, stored, b, nil)
+	if len(total.Buckets) != 2 || total.Buckets[0].Requests != 20 || total.Buckets[0].Success != 0.5 || total.Buckets[0].Errors != 10 {
+		t.Errorf("unexpected merged bucket %+v", total.Buckets[0])
+	}
+}
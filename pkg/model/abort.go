@@ -0,0 +1,79 @@
+package model
+
+import "fmt"
+
+// AbortConditionType identifies what an AbortCondition watches
+type AbortConditionType string
+
+const (
+	// AbortErrorRate aborts when the ratio of unsuccessful requests over
+	// the last Window seconds exceeds Threshold, between 0 and 1
+	AbortErrorRate AbortConditionType = "error-rate"
+	// AbortLatency aborts when the p99 latency of every second in the
+	// last Window seconds exceeds Threshold milliseconds
+	AbortLatency AbortConditionType = "p99"
+	// AbortConsecutive aborts after Threshold consecutive requests which
+	// failed with a 5xx response or without any response
+	AbortConsecutive AbortConditionType = "consecutive-5xx"
+)
+
+// AbortCondition stops a running TestInstance early when the live
+// metrics of one of its jobs show that the target is failing
+type AbortCondition struct {
+	Job       string             // Name of the job, applies to each job when empty
+	Type      AbortConditionType `validation:"required"`
+	Threshold float64            `validation:"required"`
+	Window    uint64             // seconds
+}
+
+func (c *AbortCondition) String() string {
+	switch c.Type {
+	case AbortErrorRate:
+		return fmt.Sprintf("error rate above %g%% over %ds", c.Threshold*100, c.Window)
+	case AbortLatency:
+		return fmt.Sprintf("p99 above %gms for %ds", c.Threshold, c.Window)
+	case AbortConsecutive:
+		return fmt.Sprintf("%g consecutive 5xx responses", c.Threshold)
+	}
+	return string(c.Type)
+}
+
+func (c *AbortCondition) check(jobs []Job, trace *ErrorTrace) bool {
+	switch c.Type {
+	case AbortErrorRate:
+		if c.Threshold <= 0 || c.Threshold > 1 {
+			trace.reason = fmt.Sprintf("expected value between 0 and 1, got `%g`", c.Threshold)
+			trace.attach(".Threshold")
+			return false
+		}
+		fallthrough
+	case AbortLatency:
+		if c.Window == 0 {
+			trace.reason = fmt.Sprintf("%s condition requires a Window", c.Type)
+			trace.attach(".Window")
+			return false
+		}
+	case AbortConsecutive:
+		if c.Threshold < 1 {
+			trace.reason = fmt.Sprintf("expected value >= 1, got `%g`", c.Threshold)
+			trace.attach(".Threshold")
+			return false
+		}
+	default:
+		trace.reason = fmt.Sprintf("unknown abort condition type `%s`", c.Type)
+		trace.attach(".Type")
+		return false
+	}
+
+	if c.Job == "" {
+		return true
+	}
+	for _, j := range jobs {
+		if j.Name == c.Job {
+			return true
+		}
+	}
+	trace.reason = fmt.Sprintf("test has no job named `%s`", c.Job)
+	trace.attach(".Job")
+	return false
+}
@@ -0,0 +1,71 @@
+package model
+
+// AuditID identifies an AuditEntry, entries recorded later have greater IDs
+type AuditID uint64
+
+// AuditAction is a kind of mutating operation recorded in the audit log
+type AuditAction string
+
+const (
+	AuditTestCreate           AuditAction = "test.create"
+	AuditTestUpdate           AuditAction = "test.update"
+	AuditTestDelete           AuditAction = "test.delete"
+	AuditTestStart            AuditAction = "test.start"
+	AuditTestStop             AuditAction = "test.stop"
+	AuditTestInstanceDelete   AuditAction = "test-instance.delete"
+	AuditTestInstanceBaseline AuditAction = "test-instance.baseline"
+	AuditTestScheduleCreate   AuditAction = "test-schedule.create"
+	AuditTestScheduleUpdate   AuditAction = "test-schedule.update"
+	AuditTestScheduleDelete   AuditAction = "test-schedule.delete"
+	// AuditTestScheduleTrigger is recorded when a TestSchedule starts its Test
+	AuditTestScheduleTrigger AuditAction = "test-schedule.trigger"
+	AuditProjectCreate       AuditAction = "project.create"
+	AuditProjectUpdate       AuditAction = "project.update"
+	AuditProjectDelete       AuditAction = "project.delete"
+	AuditRestore             AuditAction = "restore"
+	AuditApply               AuditAction = "apply"
+	AuditWebhookCreate       AuditAction = "webhook.create"
+	AuditWebhookUpdate       AuditAction = "webhook.update"
+	AuditWebhookDelete       AuditAction = "webhook.delete"
+	// AuditWebhookTest is recorded when an example is sent to a Webhook
+	AuditWebhookTest AuditAction = "webhook.test"
+)
+
+// AuditOutcome tells whether an audited operation took place
+type AuditOutcome string
+
+const (
+	AuditSucceeded AuditOutcome = "succeeded"
+	AuditFailed    AuditOutcome = "failed"
+	// AuditDenied operations were rejected because the caller lacked the role or project
+	AuditDenied AuditOutcome = "denied"
+)
+
+// AuditEntry records a mutating operation requested through the API, or a TestSchedule
+// starting its Test. Entries are never modified once recorded.
+type AuditEntry struct {
+	ID     AuditID
+	Time   int64
+	Action AuditAction
+
+	// Actor is the identity which called the API, or the ID of the TestSchedule which triggered
+	Actor string
+	// SourceIP is the address the call came from, empty for scheduled triggers
+	SourceIP string
+	// Path is the path and query of the call
+	Path string
+
+	// Target is the ID of the object operated on, empty when the call creates it
+	Target string
+	// Project of the object operated on
+	Project string
+
+	// PayloadHash is the hex SHA-256 of the body of the call, empty without body
+	PayloadHash string
+
+	Outcome AuditOutcome
+	// Status is the HTTP status of the response to the call
+	Status int
+	// Error explains why the operation failed or was denied
+	Error string
+}
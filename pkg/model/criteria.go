@@ -0,0 +1,105 @@
+package model
+
+import "fmt"
+
+// CriterionMetric names the aggregated metric a Criterion is checked against
+type CriterionMetric string
+
+const (
+	// Latencies, in milliseconds
+	MetricP50  CriterionMetric = "p50"
+	MetricP90  CriterionMetric = "p90"
+	MetricP95  CriterionMetric = "p95"
+	MetricP99  CriterionMetric = "p99"
+	MetricMean CriterionMetric = "mean"
+	MetricMax  CriterionMetric = "max"
+
+	// MetricSuccess is the ratio of successful requests, between 0 and 1
+	MetricSuccess CriterionMetric = "success"
+	// MetricErrors is the number of unsuccessful requests
+	MetricErrors CriterionMetric = "errors"
+	// MetricRequests is the total number of requests
+	MetricRequests CriterionMetric = "requests"
+	// MetricRate and MetricThroughput are in requests / second
+	MetricRate       CriterionMetric = "rate"
+	MetricThroughput CriterionMetric = "throughput"
+)
+
+// Criterion is a pass/fail assertion on the results of a Test,
+// for example `p95 < 300` or `success >= 0.995`
+type Criterion struct {
+	Job       string          // Name of the job, applies to all jobs combined when empty
+	Metric    CriterionMetric `validation:"required"`
+	Operator  string          `validation:"required"` // One of <, <=, > or >=
+	Threshold float64         `validation:"required"`
+}
+
+// CriterionResult holds the outcome of evaluating a Criterion
+type CriterionResult struct {
+	Criterion Criterion
+	Value     float64
+	Passed    bool
+	Error     string
+}
+
+// Verdict is the overall outcome of evaluating all criteria of a Test
+type Verdict string
+
+const (
+	VerdictPassed Verdict = "passed"
+	VerdictFailed Verdict = "failed"
+)
+
+// Holds returns whether value satisfies the criterion
+func (c *Criterion) Holds(value float64) bool {
+	switch c.Operator {
+	case "<":
+		return value < c.Threshold
+	case "<=":
+		return value <= c.Threshold
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	}
+	return false
+}
+
+func (c *Criterion) String() string {
+	scope := "test"
+	if c.Job != "" {
+		scope = c.Job
+	}
+	return fmt.Sprintf("%s: %s %s %g", scope, c.Metric, c.Operator, c.Threshold)
+}
+
+func (c *Criterion) check(jobs []Job, trace *ErrorTrace) bool {
+	switch c.Metric {
+	case MetricP50, MetricP90, MetricP95, MetricP99, MetricMean, MetricMax,
+		MetricSuccess, MetricErrors, MetricRequests, MetricRate, MetricThroughput:
+	default:
+		trace.reason = fmt.Sprintf("unknown metric `%s`", c.Metric)
+		trace.attach(".Metric")
+		return false
+	}
+
+	switch c.Operator {
+	case "<", "<=", ">", ">=":
+	default:
+		trace.reason = fmt.Sprintf("unknown operator `%s`", c.Operator)
+		trace.attach(".Operator")
+		return false
+	}
+
+	if c.Job == "" {
+		return true
+	}
+	for _, j := range jobs {
+		if j.Name == c.Job {
+			return true
+		}
+	}
+	trace.reason = fmt.Sprintf("test has no job named `%s`", c.Job)
+	trace.attach(".Job")
+	return false
+}
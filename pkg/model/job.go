@@ -1,5 +1,12 @@
 package model
 
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
 type JobID string
 
 type Job struct {
@@ -13,4 +20,89 @@ type Job struct {
 	Duration   uint64
 	HTTPMethod string
 	HTTPUrl    string
+
+	HTTPHeaders    map[string]string
+	HTTPQuery      map[string]string // Added to the query string of HTTPUrl
+	HTTPBody       string
+	HTTPBodyBase64 bool   // HTTPBody holds base64 encoded binary content
+	HTTPTimeout    uint64 // milliseconds, 0 uses the worker default
+	HTTPRedirects  int    // 0 uses the worker default, -1 does not follow redirects
+
+	// Scenario replaces the single request above with an ordered list of
+	// steps, which are run through once per request at Frequency
+	Scenario []Step
+
+	// LoadProfile varies the rate over Duration, by default the
+	// rate is held at Frequency
+	LoadProfile LoadProfile
+}
+
+// RequestBody returns the decoded body to be sent with each request
+func (j *Job) RequestBody() ([]byte, error) {
+	return decodeBody(j.HTTPBody, j.HTTPBodyBase64)
+}
+
+func (j *Job) check(trace *ErrorTrace) bool {
+	if !checkMethod(j.HTTPMethod, trace) ||
+		!checkURL(j.HTTPUrl, trace) ||
+		!checkBody(j.HTTPBody, j.HTTPBodyBase64, trace) {
+		return false
+	}
+
+	if j.HTTPRedirects < -1 {
+		trace.reason = fmt.Sprintf("expected value >= -1, got `%d`", j.HTTPRedirects)
+		trace.attach(".HTTPRedirects")
+		return false
+	}
+
+	if !checkScenario(j.Scenario, trace) {
+		trace.attach(".Scenario")
+		return false
+	}
+
+	if !j.LoadProfile.check(trace) {
+		trace.attach(".LoadProfile")
+		return false
+	}
+
+	return true
+}
+
+func decodeBody(body string, isBase64 bool) ([]byte, error) {
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+func checkMethod(method string, trace *ErrorTrace) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	trace.reason = fmt.Sprintf("unsupported HTTP method `%s`", method)
+	trace.attach(".HTTPMethod")
+	return false
+}
+
+func checkURL(rawurl string, trace *ErrorTrace) bool {
+	if rawurl == "" {
+		return true
+	}
+	if u, err := url.ParseRequestURI(rawurl); err != nil || u.Host == "" {
+		trace.reason = fmt.Sprintf("`%s` is not an absolute url", rawurl)
+		trace.attach(".HTTPUrl")
+		return false
+	}
+	return true
+}
+
+func checkBody(body string, isBase64 bool, trace *ErrorTrace) bool {
+	if _, err := decodeBody(body, isBase64); err != nil {
+		trace.reason = fmt.Sprintf("body is not valid base64: %s", err)
+		trace.attach(".HTTPBody")
+		return false
+	}
+	return true
 }
@@ -0,0 +1,207 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// LoadProfileType identifies how the rate of a Job changes over time
+type LoadProfileType string
+
+const (
+	// ProfileConstant runs the Job at Frequency for its whole Duration
+	ProfileConstant LoadProfileType = ""
+	// ProfileRamp changes the rate linearly from StartFrequency to Frequency
+	// over RampDuration and holds Frequency afterwards
+	ProfileRamp LoadProfileType = "ramp"
+	// ProfileStep runs through Stages in order, holding the rate of the last one
+	ProfileStep LoadProfileType = "step"
+	// ProfileSpike runs at Frequency, except for SpikeDuration seconds
+	// starting at SpikeStart during which it runs at SpikeFrequency
+	ProfileSpike LoadProfileType = "spike"
+	// ProfileSine oscillates around Frequency by Amplitude every Period seconds
+	ProfileSine LoadProfileType = "sine"
+	// ProfileCustom interpolates linearly between Points
+	ProfileCustom LoadProfileType = "custom"
+)
+
+// LoadProfile describes how the request rate of a Job changes over its Duration.
+// All durations and times are in seconds, relative to the start of the Job.
+type LoadProfile struct {
+	Type LoadProfileType
+
+	StartFrequency uint64
+	RampDuration   uint64
+
+	Stages []LoadStage
+
+	SpikeStart     uint64
+	SpikeDuration  uint64
+	SpikeFrequency uint64
+
+	Amplitude uint64
+	Period    uint64
+
+	Points []LoadPoint
+}
+
+// LoadStage holds a rate for a number of seconds
+type LoadStage struct {
+	Duration  uint64
+	Frequency uint64
+}
+
+// LoadPoint sets the rate at a point in time
+type LoadPoint struct {
+	Time      uint64
+	Frequency uint64
+}
+
+// FrequencyAt returns the rate at which the job runs after elapsed seconds
+func (j *Job) FrequencyAt(elapsed uint64) uint64 {
+	p := &j.LoadProfile
+
+	switch p.Type {
+	case ProfileRamp:
+		if elapsed >= p.RampDuration {
+			return j.Frequency
+		}
+		progress := float64(elapsed) / float64(p.RampDuration)
+		return uint64(math.Round(float64(p.StartFrequency) + progress*(float64(j.Frequency)-float64(p.StartFrequency))))
+
+	case ProfileStep:
+		var end uint64
+		for _, s := range p.Stages {
+			end += s.Duration
+			if elapsed < end {
+				return s.Frequency
+			}
+		}
+		if len(p.Stages) > 0 {
+			return p.Stages[len(p.Stages)-1].Frequency
+		}
+
+	case ProfileSpike:
+		if elapsed >= p.SpikeStart && elapsed < p.SpikeStart+p.SpikeDuration {
+			return p.SpikeFrequency
+		}
+
+	case ProfileSine:
+		offset := float64(p.Amplitude) * math.Sin(2*math.Pi*float64(elapsed)/float64(p.Period))
+		return uint64(math.Max(0, math.Round(float64(j.Frequency)+offset)))
+
+	case ProfileCustom:
+		if len(p.Points) == 0 {
+			break
+		}
+		if elapsed <= p.Points[0].Time {
+			return p.Points[0].Frequency
+		}
+		for i := 1; i < len(p.Points); i++ {
+			prev, next := p.Points[i-1], p.Points[i]
+			if elapsed <= next.Time {
+				progress := float64(elapsed-prev.Time) / float64(next.Time-prev.Time)
+				return uint64(math.Round(float64(prev.Frequency) + progress*(float64(next.Frequency)-float64(prev.Frequency))))
+			}
+		}
+		return p.Points[len(p.Points)-1].Frequency
+	}
+
+	return j.Frequency
+}
+
+// PeakFrequency returns the highest rate the job reaches during its Duration
+func (j *Job) PeakFrequency() uint64 {
+	p := &j.LoadProfile
+
+	switch p.Type {
+	case ProfileRamp:
+		return maxFrequency(j.Frequency, p.StartFrequency)
+	case ProfileStep:
+		// Frequency is the rate of the job until its first rate change is applied
+		peak := j.Frequency
+		for _, s := range p.Stages {
+			peak = maxFrequency(peak, s.Frequency)
+		}
+		return peak
+	case ProfileSpike:
+		return maxFrequency(j.Frequency, p.SpikeFrequency)
+	case ProfileSine:
+		return j.Frequency + p.Amplitude
+	case ProfileCustom:
+		var peak uint64
+		for _, pt := range p.Points {
+			peak = maxFrequency(peak, pt.Frequency)
+		}
+		return peak
+	}
+
+	return j.Frequency
+}
+
+func maxFrequency(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (p *LoadProfile) check(trace *ErrorTrace) bool {
+	switch p.Type {
+	case ProfileConstant:
+	case ProfileRamp:
+		if p.RampDuration == 0 {
+			trace.reason = "ramp profile requires a RampDuration"
+			trace.attach(".RampDuration")
+			return false
+		}
+	case ProfileStep:
+		if len(p.Stages) == 0 {
+			trace.reason = "step profile requires at least one stage"
+			trace.attach(".Stages")
+			return false
+		}
+		for i, s := range p.Stages {
+			if s.Duration == 0 {
+				trace.reason = "stages require a Duration"
+				trace.attach(".Duration")
+				trace.attach(fmt.Sprintf("[%d]", i))
+				trace.attach(".Stages")
+				return false
+			}
+		}
+	case ProfileSpike:
+		if p.SpikeDuration == 0 {
+			trace.reason = "spike profile requires a SpikeDuration"
+			trace.attach(".SpikeDuration")
+			return false
+		}
+	case ProfileSine:
+		if p.Period == 0 {
+			trace.reason = "sine profile requires a Period"
+			trace.attach(".Period")
+			return false
+		}
+	case ProfileCustom:
+		if len(p.Points) == 0 {
+			trace.reason = "custom profile requires at least one point"
+			trace.attach(".Points")
+			return false
+		}
+		for i := 1; i < len(p.Points); i++ {
+			if p.Points[i].Time <= p.Points[i-1].Time {
+				trace.reason = "point times must be strictly increasing"
+				trace.attach(".Time")
+				trace.attach(fmt.Sprintf("[%d]", i))
+				trace.attach(".Points")
+				return false
+			}
+		}
+	default:
+		trace.reason = fmt.Sprintf("unknown load profile type `%s`", p.Type)
+		trace.attach(".Type")
+		return false
+	}
+
+	return true
+}
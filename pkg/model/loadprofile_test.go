@@ -0,0 +1,105 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoadProfile_FrequencyAt(t *testing.T) {
+	cases := []struct {
+		name    string
+		job     Job
+		elapsed []uint64
+		want    []uint64
+		peak    uint64
+	}{
+		{
+			"constant",
+			Job{Frequency: 50, Duration: 60},
+			[]uint64{0, 30, 59},
+			[]uint64{50, 50, 50},
+			50,
+		},
+		{
+			"ramp",
+			Job{Frequency: 100, LoadProfile: LoadProfile{Type: ProfileRamp, StartFrequency: 10, RampDuration: 10}},
+			[]uint64{0, 5, 10, 20},
+			[]uint64{10, 55, 100, 100},
+			100,
+		},
+		{
+			"step",
+			Job{LoadProfile: LoadProfile{Type: ProfileStep, Stages: []LoadStage{{10, 20}, {10, 80}, {5, 40}}}},
+			[]uint64{0, 9, 10, 20, 30},
+			[]uint64{20, 20, 80, 40, 40},
+			80,
+		},
+		{
+			"step below frequency",
+			Job{Frequency: 100, LoadProfile: LoadProfile{Type: ProfileStep, Stages: []LoadStage{{10, 20}, {10, 80}}}},
+			[]uint64{0, 10, 20},
+			[]uint64{20, 80, 80},
+			100,
+		},
+		{
+			"spike",
+			Job{Frequency: 10, LoadProfile: LoadProfile{Type: ProfileSpike, SpikeStart: 30, SpikeDuration: 5, SpikeFrequency: 500}},
+			[]uint64{29, 30, 34, 35},
+			[]uint64{10, 500, 500, 10},
+			500,
+		},
+		{
+			"sine",
+			Job{Frequency: 100, LoadProfile: LoadProfile{Type: ProfileSine, Amplitude: 50, Period: 40}},
+			[]uint64{0, 10, 20, 30},
+			[]uint64{100, 150, 100, 50},
+			150,
+		},
+		{
+			"custom",
+			Job{LoadProfile: LoadProfile{Type: ProfileCustom, Points: []LoadPoint{{10, 0}, {20, 100}, {40, 50}}}},
+			[]uint64{0, 15, 20, 30, 50},
+			[]uint64{0, 50, 100, 75, 50},
+			100,
+		},
+	}
+
+	for _, c := range cases {
+		var got []uint64
+		for _, e := range c.elapsed {
+			got = append(got, c.job.FrequencyAt(e))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got rates %v, want %v", c.name, got, c.want)
+		}
+		if peak := c.job.PeakFrequency(); peak != c.peak {
+			t.Errorf("%s: got peak %d, want %d", c.name, peak, c.peak)
+		}
+	}
+}
+
+func TestValidation_LoadProfile(t *testing.T) {
+	raw := []byte(`{"Jobs": [{"LoadProfile": {"Type": "ramp", "StartFrequency": 1, "RampDuration": 30}}]}`)
+	et := Validate(reflect.TypeOf(Test{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	raw = []byte(`{"Jobs": [{"LoadProfile": {"Type": "square"}}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].LoadProfile.Type: unknown load profile type `square`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	raw = []byte(`{"Jobs": [{"LoadProfile": {"Type": "step", "Stages": [{"Duration": 10, "Frequency": 1}, {"Frequency": 2}]}}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].LoadProfile.Stages[1].Duration: stages require a Duration" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	raw = []byte(`{"Jobs": [{"LoadProfile": {"Type": "custom", "Points": [{"Time": 10, "Frequency": 1}, {"Time": 5, "Frequency": 2}]}}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].LoadProfile.Points[1].Time: point times must be strictly increasing" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+}
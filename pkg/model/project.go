@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultProject is the project of Tests, TestSchedules and TestInstances which do not name one.
+// Objects of the default project store an empty Project.
+const DefaultProject = "default"
+
+// projectSeparator separates the project from the name in the ID of an object outside the default project
+const projectSeparator = ":"
+
+// Project names are DNS labels, so they cannot contain projectSeparator
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Project scopes Tests, TestSchedules and TestInstances of a team, and limits what its Tests run
+type Project struct {
+	Name string `validation:"required"`
+
+	// MaxFrequency caps the total peak frequency of the jobs of the running Tests of the project, 0 is unlimited
+	MaxFrequency uint64
+
+	// Groups are the worker groups the jobs of the project may run on, any group if empty
+	Groups []string
+}
+
+// ProjectName returns the name of project, DefaultProject if it is empty
+func ProjectName(project string) string {
+	if project == "" {
+		return DefaultProject
+	}
+	return project
+}
+
+// NormalizeProject returns project as stored on objects, empty for DefaultProject
+func NormalizeProject(project string) string {
+	if project == DefaultProject {
+		return ""
+	}
+	return project
+}
+
+// ScopedID returns the ID of an object named name in project,
+// which is its name in the default project and project:name otherwise
+func ScopedID(project string, name string) string {
+	if project = NormalizeProject(project); project == "" {
+		return name
+	}
+	return project + projectSeparator + name
+}
+
+// ProjectOfID returns the project of the object with the given ID, see ScopedID.
+// IDs of TestInstances start with the ID of their Test, so they have its project.
+func ProjectOfID(id string) string {
+	if i := strings.Index(id, projectSeparator); i >= 0 {
+		return id[:i]
+	}
+	return ""
+}
+
+// AllowsGroup checks whether the jobs of the project may run on workers of group
+func (p *Project) AllowsGroup(group string) bool {
+	if len(p.Groups) == 0 {
+		return true
+	}
+	for _, g := range p.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTest checks that the jobs of test only run on the groups the project allows,
+// and that they do not exceed MaxFrequency on their own
+func (p *Project) CheckTest(test *Test) error {
+	for _, job := range test.Jobs {
+		if !p.AllowsGroup(job.Group) {
+			return fmt.Errorf("Job<%s> runs on group %s, which Project<%s> does not allow", job.Name, job.Group, p.Name)
+		}
+	}
+
+	if frequency := test.PeakFrequency(); p.MaxFrequency > 0 && frequency > p.MaxFrequency {
+		return fmt.Errorf("Test<%s> peaks at %d/s, above the %d/s of Project<%s>", test.ID, frequency, p.MaxFrequency, p.Name)
+	}
+	return nil
+}
+
+func (p *Project) check(trace *ErrorTrace) bool {
+	if p.Name == "" {
+		trace.reason = "expected a project name, got an empty string"
+		trace.attach(".Name")
+		return false
+	}
+	if !checkProject(p.Name, trace) {
+		trace.attach(".Name")
+		return false
+	}
+
+	for i, group := range p.Groups {
+		if group == "" {
+			trace.reason = "expected a worker group, got an empty string"
+			trace.attach(fmt.Sprintf("[%d]", i))
+			trace.attach(".Groups")
+			return false
+		}
+	}
+	return true
+}
+
+// Internal function used to check the name of a project, which may be empty for the default project
+func checkProject(project string, trace *ErrorTrace) bool {
+	if project != "" && !projectNamePattern.MatchString(project) {
+		trace.reason = fmt.Sprintf("expected lowercase letters, digits and dashes, got `%s`", project)
+		return false
+	}
+	return true
+}
+
+// Internal function used to check that name does not contain the separator of scoped IDs
+func checkScopedName(name string, trace *ErrorTrace) bool {
+	if strings.Contains(name, projectSeparator) {
+		trace.reason = fmt.Sprintf("`%s` cannot contain `%s`", name, projectSeparator)
+		return false
+	}
+	return true
+}
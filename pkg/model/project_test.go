@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestScopedID(t *testing.T) {
+	tests := []struct {
+		project string
+		name    string
+		id      string
+	}{
+		{"", "checkout", "checkout"},
+		{DefaultProject, "checkout", "checkout"},
+		{"team-a", "checkout", "team-a:checkout"},
+	}
+	for _, tt := range tests {
+		id := ScopedID(tt.project, tt.name)
+		if id != tt.id {
+			t.Errorf("expected ScopedID(%q, %q) to be %q, got %q", tt.project, tt.name, tt.id, id)
+		}
+		if project := ProjectOfID(id); project != NormalizeProject(tt.project) {
+			t.Errorf("expected ProjectOfID(%q) to be %q, got %q", id, NormalizeProject(tt.project), project)
+		}
+	}
+
+	// instances have the project of their test
+	if project := ProjectOfID("team-a:checkout-1600000000"); project != "team-a" {
+		t.Errorf("expected the instance to be in team-a, got %q", project)
+	}
+
+	test := &Test{Name: "checkout", Project: DefaultProject}
+	test.AssignIDs()
+	if test.ID != "checkout" || test.Project != "" {
+		t.Errorf("expected the test to be stored in the default project, got %v", test)
+	}
+}
+
+func TestProjectCheckTest(t *testing.T) {
+	test := &Test{
+		ID: "team-a:checkout",
+		Jobs: []Job{
+			{Name: "browse", Group: "web", Frequency: 30},
+			{Name: "pay", Group: "web", Frequency: 20},
+		},
+	}
+
+	project := &Project{Name: "team-a"}
+	if err := project.CheckTest(test); err != nil {
+		t.Errorf("expected a project without quotas to allow the test, got %s", err)
+	}
+
+	project = &Project{Name: "team-a", MaxFrequency: 50, Groups: []string{"web"}}
+	if err := project.CheckTest(test); err != nil {
+		t.Errorf("expected the test to fit the quotas, got %s", err)
+	}
+
+	project.MaxFrequency = 40
+	if err := project.CheckTest(test); err == nil {
+		t.Error("expected the test to exceed the frequency of the project")
+	}
+
+	project = &Project{Name: "team-a", Groups: []string{"batch"}}
+	if err := project.CheckTest(test); err == nil || err.Error() != "Job<browse> runs on group web, which Project<team-a> does not allow" {
+		t.Errorf("expected the group of the test to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Step is a single request within the scenario of a Job
+type Step struct {
+	Name           string `validation:"required"`
+	HTTPMethod     string
+	HTTPUrl        string `validation:"required"`
+	HTTPHeaders    map[string]string
+	HTTPQuery      map[string]string
+	HTTPBody       string
+	HTTPBodyBase64 bool
+	HTTPTimeout    uint64 // milliseconds, 0 uses the worker default
+
+	Extract   []Extraction
+	ThinkTime uint64 // milliseconds to wait before running the next step
+}
+
+// ExtractionSource indicates which part of a response a value is extracted from
+type ExtractionSource string
+
+const (
+	// ExtractJSON evaluates Expression as a path like `$.items[0].id` on the json body
+	ExtractJSON ExtractionSource = "json"
+	// ExtractRegex matches Expression against the body, using the first
+	// capture group if there is one and the whole match otherwise
+	ExtractRegex ExtractionSource = "regex"
+	// ExtractHeader uses the value of the response header named by Expression
+	ExtractHeader ExtractionSource = "header"
+)
+
+// Extraction captures a value from the response of a Step into a variable,
+// which subsequent steps can reference as ${Name} in their url, headers,
+// query parameters and body
+type Extraction struct {
+	Name       string           `validation:"required"`
+	Source     ExtractionSource `validation:"required"`
+	Expression string           `validation:"required"`
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// RequestBody returns the decoded body to be sent with the step
+func (s *Step) RequestBody() ([]byte, error) {
+	return decodeBody(s.HTTPBody, s.HTTPBodyBase64)
+}
+
+// ExpandVariables replaces all ${name} references in s with their values
+// in vars. References to unknown variables are replaced by an empty string.
+func ExpandVariables(s string, vars map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		return vars[variablePattern.FindStringSubmatch(ref)[1]]
+	})
+}
+
+// ParseJSONPath splits an expression like `$.items[0].id` into the
+// object keys and array indices it is made of
+func ParseJSONPath(expr string) ([]string, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	if path == "" {
+		return nil, fmt.Errorf("`%s` does not select any value", expr)
+	}
+
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indices []string
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+			for rest := part[i:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("`%s` has unbalanced brackets", expr)
+				}
+				if _, err := strconv.Atoi(rest[1:end]); err != nil {
+					return nil, fmt.Errorf("`%s` has a non-numeric index", expr)
+				}
+				indices = append(indices, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+		if key == "" && len(indices) == 0 {
+			return nil, fmt.Errorf("`%s` has an empty key", expr)
+		}
+		if key != "" {
+			segments = append(segments, key)
+		}
+		segments = append(segments, indices...)
+	}
+	return segments, nil
+}
+
+func checkScenario(steps []Step, trace *ErrorTrace) bool {
+	names := map[string]bool{}
+	defined := map[string]bool{}
+
+	for i := range steps {
+		if !steps[i].check(defined, trace) {
+			trace.attach(fmt.Sprintf("[%d]", i))
+			return false
+		}
+		if names[steps[i].Name] {
+			trace.reason = fmt.Sprintf("step name `%s` is not unique", steps[i].Name)
+			trace.attach(".Name")
+			trace.attach(fmt.Sprintf("[%d]", i))
+			return false
+		}
+		names[steps[i].Name] = true
+
+		for _, e := range steps[i].Extract {
+			defined[e.Name] = true
+		}
+	}
+	return true
+}
+
+func (s *Step) check(defined map[string]bool, trace *ErrorTrace) bool {
+	if !checkMethod(s.HTTPMethod, trace) || !checkBody(s.HTTPBody, s.HTTPBodyBase64, trace) {
+		return false
+	}
+
+	// Variables are only known at runtime, so check the url with placeholders
+	if u, err := url.ParseRequestURI(variablePattern.ReplaceAllString(s.HTTPUrl, "x")); err != nil || u.Host == "" {
+		trace.reason = fmt.Sprintf("`%s` is not an absolute url", s.HTTPUrl)
+		trace.attach(".HTTPUrl")
+		return false
+	}
+
+	// All referenced variables must be extracted by a previous step
+	checkRefs := func(ref string, fields ...string) bool {
+		for _, match := range variablePattern.FindAllStringSubmatch(ref, -1) {
+			if !defined[match[1]] {
+				trace.reason = fmt.Sprintf("variable `%s` is not extracted by a previous step", match[1])
+				for _, field := range fields {
+					trace.attach(field)
+				}
+				return false
+			}
+		}
+		return true
+	}
+	if !checkRefs(s.HTTPUrl, ".HTTPUrl") {
+		return false
+	}
+	for k, v := range s.HTTPHeaders {
+		if !checkRefs(v, fmt.Sprintf("[%s]", k), ".HTTPHeaders") {
+			return false
+		}
+	}
+	for k, v := range s.HTTPQuery {
+		if !checkRefs(v, fmt.Sprintf("[%s]", k), ".HTTPQuery") {
+			return false
+		}
+	}
+	if !s.HTTPBodyBase64 && !checkRefs(s.HTTPBody, ".HTTPBody") {
+		return false
+	}
+
+	for i := range s.Extract {
+		if !s.Extract[i].check(trace) {
+			trace.attach(fmt.Sprintf("[%d]", i))
+			trace.attach(".Extract")
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *Extraction) check(trace *ErrorTrace) bool {
+	var err error
+	switch e.Source {
+	case ExtractJSON:
+		_, err = ParseJSONPath(e.Expression)
+	case ExtractRegex:
+		_, err = regexp.Compile(e.Expression)
+	case ExtractHeader:
+	default:
+		trace.reason = fmt.Sprintf("unknown source `%s`", e.Source)
+		trace.attach(".Source")
+		return false
+	}
+
+	if err != nil {
+		trace.reason = fmt.Sprintf("invalid expression: %s", err)
+		trace.attach(".Expression")
+		return false
+	}
+	return true
+}
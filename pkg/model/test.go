@@ -1,10 +1,92 @@
 package model
 
+import "fmt"
+
 type TestID string
 
 type Test struct {
 	ID   TestID
 	Name string
+
+	// Project scopes the test, see DefaultProject
+	Project string
+
 	Jobs []Job
 	Chaos []ChaosInstance
+
+	// Criteria decide whether an instance of the test passed
+	Criteria []Criterion
+
+	// AbortConditions stop an instance of the test early
+	AbortConditions []AbortCondition
+
+	// Revision is incremented every time the test is saved, each revision is kept.
+	// In requests it is only the revision the update expects.
+	Revision int
+
+	// Manifest is the name of the manifest which applied the test, if any, requests cannot change it
+	Manifest string
+
+	// CreatedBy and UpdatedBy are the identities which created the test and saved its revision, set by the server
+	CreatedBy string
+	UpdatedBy string
+}
+
+// AssignIDs derives the ID of the test, its jobs and chaos from its project and name
+func (t *Test) AssignIDs() {
+	t.Project = NormalizeProject(t.Project)
+	t.ID = TestID(ScopedID(t.Project, t.Name))
+
+	for i := range t.Jobs {
+		t.Jobs[i].ID = JobID(fmt.Sprintf("%s-%d", t.ID, i))
+	}
+
+	for i := range t.Chaos {
+		t.Chaos[i].ID = ChaosID(fmt.Sprintf("%s-%d", t.ID, i))
+	}
+}
+
+// PeakFrequency returns the total peak frequency of the jobs of the test
+func (t *Test) PeakFrequency() uint64 {
+	var frequency uint64
+	for i := range t.Jobs {
+		frequency += t.Jobs[i].PeakFrequency()
+	}
+	return frequency
+}
+
+func (t *Test) check(trace *ErrorTrace) bool {
+	if !checkScopedName(t.Name, trace) {
+		trace.attach(".Name")
+		return false
+	}
+	if !checkProject(t.Project, trace) {
+		trace.attach(".Project")
+		return false
+	}
+
+	for i := range t.Jobs {
+		if !t.Jobs[i].check(trace) {
+			trace.attach(fmt.Sprintf("[%d]", i))
+			trace.attach(".Jobs")
+			return false
+		}
+	}
+
+	for i := range t.Criteria {
+		if !t.Criteria[i].check(t.Jobs, trace) {
+			trace.attach(fmt.Sprintf("[%d]", i))
+			trace.attach(".Criteria")
+			return false
+		}
+	}
+
+	for i := range t.AbortConditions {
+		if !t.AbortConditions[i].check(t.Jobs, trace) {
+			trace.attach(fmt.Sprintf("[%d]", i))
+			trace.attach(".AbortConditions")
+			return false
+		}
+	}
+	return true
 }
@@ -5,14 +5,42 @@ type TestInstanceID string
 type TestInstance struct {
 	ID        TestInstanceID
 	TestID    TestID
+	Project   string
 	Type      string
 	Status    string
 	CreatedAt int64
 	Metrics   interface{} // TODO: decide how to store metrics long-term
 	ChaosResult map[ChaosID]ChaosResult
 	Error string
+
+	Verdict         Verdict
+	CriteriaResults []CriterionResult
+
+	// AbortReason describes the condition which aborted the instance
+	AbortReason string
+
+	// Events which occurred while the instance was running
+	Events []InstanceEvent
+
+	// Baseline is set on the instance other instances of the same Test are compared to by default
+	Baseline bool
+
+	// TestRevision is the revision of the Test the instance ran, 0 if it ran before tests had revisions
+	TestRevision int
+
+	// StartedBy is the identity which started an adhoc instance, or the TestSchedule which started a scheduled one
+	StartedBy string
+}
+
+// InstanceEvent is a notable occurrence during a TestInstance, such as
+// the workload of a failed worker being reassigned
+type InstanceEvent struct {
+	Time    int64
+	Type    string
+	JobID   JobID
+	Message string
 }
 
 func (instance *TestInstance) IsTerminal() bool {
-	return instance.Status == "failed" || instance.Status == "done" || instance.Status == "stopped"
+	return instance.Status == "failed" || instance.Status == "done" || instance.Status == "stopped" || instance.Status == "aborted" || instance.Status == "interrupted"
 }
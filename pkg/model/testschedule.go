@@ -7,4 +7,31 @@ type TestSchedule struct {
 	Name     string `validation:"required"`
 	TestID   TestID `validation:"required"`
 	CronSpec string `validation:"required"`
+
+	// Project scopes the schedule, which runs a Test of the same project
+	Project string
+
+	// Revision is incremented every time the schedule is saved
+	Revision int
+
+	// Manifest is the name of the manifest which applied the schedule, if any
+	Manifest string
+}
+
+// AssignID derives the ID of the schedule from its project and name
+func (s *TestSchedule) AssignID() {
+	s.Project = NormalizeProject(s.Project)
+	s.ID = TestScheduleID(ScopedID(s.Project, s.Name))
+}
+
+func (s *TestSchedule) check(trace *ErrorTrace) bool {
+	if !checkScopedName(s.Name, trace) {
+		trace.attach(".Name")
+		return false
+	}
+	if !checkProject(s.Project, trace) {
+		trace.attach(".Project")
+		return false
+	}
+	return true
 }
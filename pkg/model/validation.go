@@ -35,6 +35,12 @@ func (et *ErrorTrace) attach(msg string) {
 	et.context = append(et.context, msg)
 }
 
+// checker is implemented by types which need semantic checks
+// on top of the structural validation
+type checker interface {
+	check(trace *ErrorTrace) bool
+}
+
 // Validate checks if blob can be unmarshalled into valid struct of type t
 func Validate(t reflect.Type, blob []byte) error {
 	// confirm is valid json
@@ -53,6 +59,18 @@ func Validate(t reflect.Type, blob []byte) error {
 		return trace
 	}
 
+	// blob is structurally valid, so it is safe to unmarshal
+	// and run the semantic checks of the type
+	if c, ok := reflect.New(t).Interface().(checker); ok {
+		if err := json.Unmarshal(blob, c); err != nil {
+			return &ErrorTrace{reason: err.Error()}
+		}
+		if !c.check(trace) {
+			trace.attach(t.Name())
+			return trace
+		}
+	}
+
 	return nil
 }
 
@@ -2,6 +2,7 @@ package model
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -159,3 +160,218 @@ func TestValidation_Nesting(t *testing.T) {
 		t.Errorf("expected validation of %s to fail", raw)
 	}
 }
+
+func TestValidation_Job(t *testing.T) {
+	// valid request specification
+	raw := []byte(`{"Jobs": [{"HTTPMethod": "POST", "HTTPUrl": "http://localhost:8080/items",
+		"HTTPHeaders": {"Content-Type": "application/json"}, "HTTPQuery": {"page": "1"},
+		"HTTPBody": "eyJuYW1lIjogImRpYWdvIn0=", "HTTPBodyBase64": true, "HTTPTimeout": 500, "HTTPRedirects": -1}]}`)
+	et := Validate(reflect.TypeOf(Test{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	// unsupported method
+	raw = []byte(`{"Jobs": [{"HTTPMethod": "FETCH"}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].HTTPMethod: unsupported HTTP method `FETCH`" {
+		t.Errorf("expected validation of %s to fail", raw)
+	}
+
+	// relative url
+	raw = []byte(`{"Jobs": [{"HTTPUrl": "/items"}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].HTTPUrl: `/items` is not an absolute url" {
+		t.Errorf("expected validation of %s to fail", raw)
+	}
+
+	// invalid base64 body
+	raw = []byte(`{"Jobs": [{}, {"HTTPBody": "not base64!", "HTTPBodyBase64": true}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || !strings.HasPrefix(et.Error(), "validation failed at Test.Jobs[1].HTTPBody: body is not valid base64") {
+		t.Errorf("expected validation of %s to fail", raw)
+	}
+
+	// invalid redirect policy
+	raw = []byte(`{"Jobs": [{"HTTPRedirects": -2}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].HTTPRedirects: expected value >= -1, got `-2`" {
+		t.Errorf("expected validation of %s to fail", raw)
+	}
+}
+
+func TestValidation_Scenario(t *testing.T) {
+	// valid scenario
+	raw := []byte(`{"Jobs": [{"Scenario": [
+		{"Name": "login", "HTTPMethod": "POST", "HTTPUrl": "http://localhost/login",
+			"Extract": [{"Name": "token", "Source": "json", "Expression": "$.auth.token"}]},
+		{"Name": "list", "HTTPUrl": "http://localhost/items", "HTTPHeaders": {"Authorization": "Bearer ${token}"},
+			"Extract": [{"Name": "item", "Source": "regex", "Expression": "item-([0-9]+)"}], "ThinkTime": 500},
+		{"Name": "open", "HTTPUrl": "http://localhost/items/${item}"}
+	]}]}`)
+	et := Validate(reflect.TypeOf(Test{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	// undefined variable
+	raw = []byte(`{"Jobs": [{"Scenario": [{"Name": "open", "HTTPUrl": "http://localhost/items/${item}"}]}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].Scenario[0].HTTPUrl: variable `item` is not extracted by a previous step" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	raw = []byte(`{"Jobs": [{"Scenario": [{"Name": "open", "HTTPUrl": "http://localhost", "HTTPHeaders": {"Authorization": "Bearer ${token}"}}]}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].Scenario[0].HTTPHeaders[Authorization]: variable `token` is not extracted by a previous step" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// duplicate step names
+	raw = []byte(`{"Jobs": [{"Scenario": [{"Name": "a", "HTTPUrl": "http://localhost"}, {"Name": "a", "HTTPUrl": "http://localhost"}]}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].Scenario[1].Name: step name `a` is not unique" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// unknown extraction source
+	raw = []byte(`{"Jobs": [{"Scenario": [{"Name": "a", "HTTPUrl": "http://localhost",
+		"Extract": [{"Name": "x", "Source": "cookie", "Expression": "session"}]}]}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].Scenario[0].Extract[0].Source: unknown source `cookie`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// invalid json path
+	raw = []byte(`{"Jobs": [{"Scenario": [{"Name": "a", "HTTPUrl": "http://localhost",
+		"Extract": [{"Name": "x", "Source": "json", "Expression": "$.items[first]"}]}]}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Jobs[0].Scenario[0].Extract[0].Expression: invalid expression: `$.items[first]` has a non-numeric index" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	segments, err := ParseJSONPath("$.data.items[0][1].id")
+	if err != nil || !reflect.DeepEqual(segments, []string{"data", "items", "0", "1", "id"}) {
+		t.Errorf("unexpected segments %v, error %v", segments, err)
+	}
+
+	segments, err = ParseJSONPath("token")
+	if err != nil || !reflect.DeepEqual(segments, []string{"token"}) {
+		t.Errorf("unexpected segments %v, error %v", segments, err)
+	}
+
+	if _, err := ParseJSONPath("$"); err == nil {
+		t.Error("expected root only path to fail")
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	got := ExpandVariables("http://localhost/${a}/${b}/${missing}", map[string]string{"a": "1", "b": "two"})
+	if got != "http://localhost/1/two/" {
+		t.Errorf("unexpected expansion `%s`", got)
+	}
+}
+
+func TestValidation_Criteria(t *testing.T) {
+	// valid criteria
+	raw := []byte(`{"Jobs": [{"Name": "api"}], "Criteria": [
+		{"Metric": "p95", "Operator": "<", "Threshold": 300},
+		{"Job": "api", "Metric": "success", "Operator": ">=", "Threshold": 0.995}
+	]}`)
+	et := Validate(reflect.TypeOf(Test{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	// missing threshold
+	raw = []byte(`{"Criteria": [{"Metric": "p95", "Operator": "<"}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Criteria[0]: field Threshold is required, but not specified" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// unknown metric
+	raw = []byte(`{"Criteria": [{"Metric": "p42", "Operator": "<", "Threshold": 1}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Criteria[0].Metric: unknown metric `p42`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// unknown operator
+	raw = []byte(`{"Criteria": [{"Metric": "p99", "Operator": "==", "Threshold": 1}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Criteria[0].Operator: unknown operator `==`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// unknown job
+	raw = []byte(`{"Jobs": [{"Name": "api"}], "Criteria": [{"Job": "web", "Metric": "errors", "Operator": "<", "Threshold": 10}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Criteria[0].Job: test has no job named `web`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+}
+
+func TestValidation_AbortConditions(t *testing.T) {
+	// valid conditions
+	raw := []byte(`{"Jobs": [{"Name": "api"}], "AbortConditions": [
+		{"Type": "error-rate", "Threshold": 0.5, "Window": 10},
+		{"Job": "api", "Type": "p99", "Threshold": 2000, "Window": 5},
+		{"Type": "consecutive-5xx", "Threshold": 20}
+	]}`)
+	et := Validate(reflect.TypeOf(Test{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	// missing window
+	raw = []byte(`{"AbortConditions": [{"Type": "p99", "Threshold": 2000}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.AbortConditions[0].Window: p99 condition requires a Window" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// error rate out of range
+	raw = []byte(`{"AbortConditions": [{"Type": "error-rate", "Threshold": 50, "Window": 10}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.AbortConditions[0].Threshold: expected value between 0 and 1, got `50`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+
+	// unknown type
+	raw = []byte(`{"AbortConditions": [{"Type": "p50", "Threshold": 1}]}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.AbortConditions[0].Type: unknown abort condition type `p50`" {
+		t.Errorf("expected validation of %s to fail, got %v", raw, et)
+	}
+}
+
+func TestValidation_Project(t *testing.T) {
+	raw := []byte(`{"Name": "team-a", "MaxFrequency": 100, "Groups": ["group"]}`)
+	et := Validate(reflect.TypeOf(Project{}), raw)
+	if et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	// project names are DNS labels
+	raw = []byte(`{"Name": "Team:A"}`)
+	et = Validate(reflect.TypeOf(Project{}), raw)
+	if et == nil || et.Error() != "validation failed at Project.Name: expected lowercase letters, digits and dashes, got `Team:A`" {
+		t.Errorf("expected validation of %s to fail, got %s", raw, et)
+	}
+
+	// names of tests cannot look scoped to a project
+	raw = []byte(`{"Name": "team-a:checkout", "Jobs": []}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || et.Error() != "validation failed at Test.Name: `team-a:checkout` cannot contain `:`" {
+		t.Errorf("expected validation of %s to fail, got %s", raw, et)
+	}
+
+	raw = []byte(`{"Name": "checkout", "Project": "Team A", "Jobs": []}`)
+	et = Validate(reflect.TypeOf(Test{}), raw)
+	if et == nil || !strings.HasPrefix(et.Error(), "validation failed at Test.Project") {
+		t.Errorf("expected validation of %s to fail, got %s", raw, et)
+	}
+}
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// WebhookID identifies a Webhook, see ScopedID
+type WebhookID string
+
+// WebhookEvent is a status a TestInstance enters, which fires the Webhooks subscribed to it
+type WebhookEvent string
+
+const (
+	WebhookSubmitted WebhookEvent = "submitted"
+	WebhookRunning   WebhookEvent = "running"
+	WebhookDone      WebhookEvent = "done"
+	// WebhookFailed fires when the jobs of an instance could not be submitted, or a
+	// TestSchedule could not start its Test at all
+	WebhookFailed  WebhookEvent = "failed"
+	WebhookStopped WebhookEvent = "stopped"
+	WebhookAborted WebhookEvent = "aborted"
+)
+
+// WebhookEvents are the events Webhooks may subscribe to
+var WebhookEvents = []WebhookEvent{
+	WebhookSubmitted,
+	WebhookRunning,
+	WebhookDone,
+	WebhookFailed,
+	WebhookStopped,
+	WebhookAborted,
+}
+
+// Webhook is an HTTP endpoint notified with an InstanceSummary whenever a TestInstance
+// of its project enters one of its Events
+type Webhook struct {
+	ID   WebhookID
+	Name string `validation:"required"`
+	URL  string `validation:"required"`
+
+	// Project scopes the webhook, which only fires for instances of the same project
+	Project string
+
+	// Events the webhook fires on, every event if empty
+	Events []WebhookEvent
+
+	// TestID restricts the webhook to the instances of a single Test
+	TestID TestID
+
+	// ScheduledOnly restricts the webhook to instances started by a TestSchedule
+	ScheduledOnly bool
+
+	// FailuresOnly restricts the webhook to instances which failed, were aborted
+	// or did not pass their criteria
+	FailuresOnly bool
+
+	// Template is a Go text/template of the body, executed with the InstanceSummary.
+	// The body is the InstanceSummary as JSON if empty.
+	Template string
+
+	// ContentType of the body, application/json by default
+	ContentType string
+
+	// Headers are added to every request, such as the credentials of the endpoint
+	Headers map[string]string
+
+	// Secret signs every request with HMAC-SHA256, see the X-Diago-Signature header.
+	// It is never returned by the API once set.
+	Secret string
+}
+
+// AssignID derives the ID of the webhook from its project and name
+func (w *Webhook) AssignID() {
+	w.Project = NormalizeProject(w.Project)
+	w.ID = WebhookID(ScopedID(w.Project, w.Name))
+}
+
+// ParseTemplate parses the Template of the webhook, nil if it has none. Besides the
+// builtin functions, templates may call json to quote a value as JSON, such as
+// {{json .TestName}}, so that bodies of chat tools stay valid JSON.
+func (w *Webhook) ParseTemplate() (*template.Template, error) {
+	if w.Template == "" {
+		return nil, nil
+	}
+	return template.New(string(w.ID)).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			enc, err := json.Marshal(v)
+			return string(enc), err
+		},
+	}).Parse(w.Template)
+}
+
+// Matches checks whether the webhook fires for summary
+func (w *Webhook) Matches(summary *InstanceSummary) bool {
+	if NormalizeProject(w.Project) != NormalizeProject(summary.Project) ||
+		(w.TestID != "" && w.TestID != summary.TestID) ||
+		(w.ScheduledOnly && summary.ScheduleID == "") ||
+		(w.FailuresOnly && !summary.Failed()) {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, event := range w.Events {
+		if event == summary.Event {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Webhook) check(trace *ErrorTrace) bool {
+	if !checkScopedName(w.Name, trace) {
+		trace.attach(".Name")
+		return false
+	}
+	if !checkProject(w.Project, trace) {
+		trace.attach(".Project")
+		return false
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		trace.reason = fmt.Sprintf("expected an http or https URL, got `%s`", w.URL)
+		trace.attach(".URL")
+		return false
+	}
+	for i, event := range w.Events {
+		if !validWebhookEvent(event) {
+			trace.reason = fmt.Sprintf("expected one of %v, got `%s`", WebhookEvents, event)
+			trace.attach(fmt.Sprintf(".Events[%d]", i))
+			return false
+		}
+	}
+	if _, err := w.ParseTemplate(); err != nil {
+		trace.reason = err.Error()
+		trace.attach(".Template")
+		return false
+	}
+	return true
+}
+
+// Internal function used to check whether event is one of WebhookEvents
+func validWebhookEvent(event WebhookEvent) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// InstanceSummary describes a TestInstance as it enters a status, it is the payload of Webhooks
+type InstanceSummary struct {
+	Event WebhookEvent
+	Time  int64
+
+	// InstanceID is empty when a TestSchedule failed to start its Test
+	InstanceID TestInstanceID
+	TestID     TestID
+	TestName   string
+	Project    string
+	Type       string
+	StartedBy  string
+	// ScheduleID is the TestSchedule which started the instance, if any
+	ScheduleID TestScheduleID
+	CreatedAt  int64
+
+	Status          string
+	Verdict         Verdict
+	CriteriaResults []CriterionResult
+	Error           string
+	AbortReason     string
+
+	// Jobs summarizes the results of each job, keyed by JobID, once the instance has finished
+	Jobs map[string]JobSummary
+}
+
+// JobSummary holds the main results of a job of a TestInstance
+type JobSummary struct {
+	Requests uint64
+	Rate     float64
+	// Success is the ratio of non-error responses
+	Success float64
+	// Errors are the unique errors returned by the targets
+	Errors []string
+
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Failed checks whether the instance failed, was aborted or did not pass its criteria
+func (s *InstanceSummary) Failed() bool {
+	return s.Event == WebhookFailed || s.Event == WebhookAborted || s.Verdict == VerdictFailed
+}
+
+// WebhookDeliveryID identifies a WebhookDelivery, deliveries recorded later have greater IDs
+type WebhookDeliveryID uint64
+
+// WebhookDelivery records the delivery of an InstanceSummary to a Webhook
+type WebhookDelivery struct {
+	ID         WebhookDeliveryID
+	WebhookID  WebhookID
+	Event      WebhookEvent
+	InstanceID TestInstanceID
+	TestID     TestID
+	Time       int64
+
+	// Attempts is the number of requests sent, retries included
+	Attempts int
+	// Status is the HTTP status of the last response, 0 if there was none
+	Status int
+	// Delivered is set once the endpoint accepted the payload with a 2xx response
+	Delivered bool
+	// Error explains why the last attempt failed
+	Error string
+}
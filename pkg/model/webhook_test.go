@@ -0,0 +1,84 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidation_Webhook(t *testing.T) {
+	raw := []byte(`{"Name": "chat", "URL": "https://chat.example.com/hooks/1", "Events": ["done", "failed"],
+		"Headers": {"Authorization": "Bearer token"}, "FailuresOnly": true, "Template": "{\"text\": {{json .TestName}}}"}`)
+	if et := Validate(reflect.TypeOf(Webhook{}), raw); et != nil {
+		t.Errorf("expected validation of %s to pass, got %s", raw, et)
+	}
+
+	tests := []struct {
+		raw    string
+		reason string
+	}{
+		{`{"Name": "chat", "URL": "chat.example.com"}`, "validation failed at Webhook.URL: expected an http or https URL, got `chat.example.com`"},
+		{`{"Name": "chat", "URL": "ftp://chat.example.com"}`, "validation failed at Webhook.URL: expected an http or https URL"},
+		{`{"Name": "chat", "URL": "https://chat.example.com", "Events": ["done", "finished"]}`, "validation failed at Webhook.Events[1]"},
+		{`{"Name": "chat", "URL": "https://chat.example.com", "Template": "{{.TestName"}`, "validation failed at Webhook.Template"},
+		{`{"Name": "team-a:chat", "URL": "https://chat.example.com"}`, "validation failed at Webhook.Name"},
+		{`{"Name": "chat"}`, "validation failed at Webhook: field URL is required"},
+	}
+	for _, tt := range tests {
+		et := Validate(reflect.TypeOf(Webhook{}), []byte(tt.raw))
+		if et == nil || !strings.HasPrefix(et.Error(), tt.reason) {
+			t.Errorf("expected validation of %s to fail with %q, got %v", tt.raw, tt.reason, et)
+		}
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	done := &InstanceSummary{Event: WebhookDone, TestID: "team-a:checkout", Project: "team-a", Verdict: VerdictPassed}
+	breached := &InstanceSummary{Event: WebhookDone, TestID: "team-a:checkout", Project: "team-a", Verdict: VerdictFailed}
+	scheduled := &InstanceSummary{Event: WebhookFailed, TestID: "team-a:search", Project: "team-a", ScheduleID: "team-a:nightly"}
+
+	tests := []struct {
+		name    string
+		webhook Webhook
+		matches []*InstanceSummary
+	}{
+		{"every event of the project", Webhook{Project: "team-a"}, []*InstanceSummary{done, breached, scheduled}},
+		{"other project", Webhook{}, []*InstanceSummary{}},
+		{"events", Webhook{Project: "team-a", Events: []WebhookEvent{WebhookFailed}}, []*InstanceSummary{scheduled}},
+		{"test", Webhook{Project: "team-a", TestID: "team-a:checkout"}, []*InstanceSummary{done, breached}},
+		{"scheduled", Webhook{Project: "team-a", ScheduledOnly: true}, []*InstanceSummary{scheduled}},
+		{"failures", Webhook{Project: "team-a", FailuresOnly: true}, []*InstanceSummary{breached, scheduled}},
+	}
+	for _, tt := range tests {
+		matches := []*InstanceSummary{}
+		for _, summary := range []*InstanceSummary{done, breached, scheduled} {
+			if tt.webhook.Matches(summary) {
+				matches = append(matches, summary)
+			}
+		}
+		if !reflect.DeepEqual(matches, tt.matches) {
+			t.Errorf("%s: expected %d matching summaries, got %d", tt.name, len(tt.matches), len(matches))
+		}
+	}
+}
+
+func TestWebhookParseTemplate(t *testing.T) {
+	webhook := &Webhook{Template: `{"text": {{json (printf "%s %s" .TestName .Event)}}}`}
+	tmpl, err := webhook.ParseTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, &InstanceSummary{TestName: `say "hi"`, Event: WebhookDone}); err != nil {
+		t.Fatal(err)
+	}
+	if body.String() != `{"text": "say \"hi\" done"}` {
+		t.Errorf("expected the text to be quoted as JSON, got %s", body.String())
+	}
+
+	if tmpl, err := (&Webhook{}).ParseTemplate(); tmpl != nil || err != nil {
+		t.Errorf("expected no template, got %v %v", tmpl, err)
+	}
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+var csvHeader = []string{"type", "job", "step", "name", "value", "passed", "detail"}
+
+// Internal function used to render the report as csv. Every row is a single value,
+// its type tells whether it is a metric, status code count, criterion or chaos result.
+func (r *Report) renderCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write(csvHeader)
+
+	for _, j := range r.Jobs {
+		if j.Metrics != nil {
+			writeMetrics(out, j.Name, "", j.Metrics)
+
+			steps := make([]string, 0, len(j.Metrics.Steps))
+			for step := range j.Metrics.Steps {
+				steps = append(steps, step)
+			}
+			sort.Strings(steps)
+			for _, step := range steps {
+				writeMetrics(out, j.Name, step, j.Metrics.Steps[step])
+			}
+		}
+	}
+
+	for _, result := range r.Instance.CriteriaResults {
+		c := result.Criterion
+		out.Write([]string{
+			"criterion", c.Job, "", c.String(),
+			formatFloat(result.Value), strconv.FormatBool(result.Passed), result.Error,
+		})
+	}
+
+	for _, id := range r.chaosIDs() {
+		result := r.Instance.ChaosResult[id]
+		out.Write([]string{
+			"chaos", "", "", string(id),
+			strconv.Itoa(len(result.DeletedPods)), strconv.FormatBool(result.Status == m.ChaosSuccess), result.Error,
+		})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// Internal function used to write the metrics of a job or step as rows
+func writeMetrics(out *csv.Writer, job string, step string, ms *metrics.Metrics) {
+	values := []struct {
+		name  string
+		value float64
+	}{
+		{"requests", float64(ms.Requests)},
+		{"rate", ms.Rate},
+		{"throughput", ms.Throughput},
+		{"success", ms.Success},
+		{"latency_mean_ms", milliseconds(ms.Latencies.Mean)},
+		{"latency_p50_ms", milliseconds(ms.Latencies.P50)},
+		{"latency_p90_ms", milliseconds(ms.Latencies.P90)},
+		{"latency_p95_ms", milliseconds(ms.Latencies.P95)},
+		{"latency_p99_ms", milliseconds(ms.Latencies.P99)},
+		{"latency_min_ms", milliseconds(ms.Latencies.Min)},
+		{"latency_max_ms", milliseconds(ms.Latencies.Max)},
+		{"bytes_in", float64(ms.BytesIn.Total)},
+		{"bytes_out", float64(ms.BytesOut.Total)},
+		{"duration_s", ms.Duration.Seconds()},
+	}
+
+	for _, v := range values {
+		out.Write([]string{"metric", job, step, v.name, formatFloat(v.value), "", ""})
+	}
+
+	codes := make([]string, 0, len(ms.StatusCodes))
+	for code := range ms.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		out.Write([]string{"status_code", job, step, code, strconv.Itoa(ms.StatusCodes[code]), "", ""})
+	}
+
+	for _, e := range ms.Errors {
+		out.Write([]string{"error", job, step, "", "", "", e})
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
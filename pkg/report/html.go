@@ -0,0 +1,267 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// Charts are resampled so they have at most this many points
+const maxChartPoints = 300
+
+const (
+	chartWidth  = 640
+	chartHeight = 160
+)
+
+type chart struct {
+	Title  string
+	Unit   string
+	Max    float64
+	Lines  []chartLine
+	Width  int
+	Height int
+}
+
+type chartLine struct {
+	Label  string
+	Color  string
+	Points string
+}
+
+type htmlJob struct {
+	Job
+	Charts []chart
+}
+
+type htmlEvent struct {
+	Time    string
+	Type    string
+	JobID   string
+	Message string
+}
+
+type htmlChaos struct {
+	ID      string
+	Status  string
+	Deleted int
+	Error   string
+}
+
+var htmlFuncs = template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.2f", milliseconds(d))
+	},
+	"percent": func(v float64) string {
+		return fmt.Sprintf("%.2f%%", v*100)
+	},
+	"float": func(v float64) string {
+		return fmt.Sprintf("%.2f", v)
+	},
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - {{.Instance.ID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.7em; text-align: left; }
+th { background: #f3f3f3; }
+.pass { color: #1a7f37; }
+.fail { color: #cf222e; }
+.muted { color: #777; }
+.chart { display: inline-block; margin: 0 1em 1em 0; }
+.chart svg { border: 1px solid #ddd; background: #fcfcfc; }
+.legend span { margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p class="muted">Instance {{.Instance.ID}}, started {{.Created}}</p>
+
+<table>
+<tr><th>Status</th><td>{{.Instance.Status}}</td></tr>
+{{if .Instance.Verdict}}<tr><th>Verdict</th><td class="{{if eq (print .Instance.Verdict) "passed"}}pass{{else}}fail{{end}}">{{.Instance.Verdict}}</td></tr>{{end}}
+{{if .Instance.AbortReason}}<tr><th>Abort reason</th><td>{{.Instance.AbortReason}}</td></tr>{{end}}
+{{if .Instance.Error}}<tr><th>Error</th><td>{{.Instance.Error}}</td></tr>{{end}}
+</table>
+
+<h2>Jobs</h2>
+<table>
+<tr><th>Job</th><th>Requests</th><th>Rate</th><th>Throughput</th><th>Success</th><th>p50 (ms)</th><th>p90 (ms)</th><th>p99 (ms)</th><th>Max (ms)</th><th>Status codes</th></tr>
+{{range .Jobs}}<tr>
+<td>{{.Name}}</td>
+{{with .Metrics}}<td>{{.Requests}}</td><td>{{float .Rate}}/s</td><td>{{float .Throughput}}/s</td><td>{{percent .Success}}</td>
+<td>{{ms .Latencies.P50}}</td><td>{{ms .Latencies.P90}}</td><td>{{ms .Latencies.P99}}</td><td>{{ms .Latencies.Max}}</td>
+<td>{{range $code, $count := .StatusCodes}}{{$code}}: {{$count}} {{end}}</td>
+{{else}}<td colspan="9" class="muted">No metrics recorded</td>{{end}}
+</tr>{{end}}
+</table>
+
+{{range .Jobs}}{{if .Charts}}
+<h3>{{.Name}}</h3>
+{{range .Charts}}<div class="chart">
+<div>{{.Title}} <span class="muted">(max {{float .Max}} {{.Unit}})</span></div>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+{{range .Lines}}<polyline fill="none" stroke="{{.Color}}" stroke-width="1.5" points="{{.Points}}"/>
+{{end}}</svg>
+<div class="legend">{{range .Lines}}<span style="color: {{.Color}}">&#9632; {{.Label}}</span>{{end}}</div>
+</div>
+{{end}}{{end}}{{end}}
+
+{{if .Criteria}}
+<h2>Criteria</h2>
+<table>
+<tr><th>Criterion</th><th>Value</th><th>Result</th><th>Error</th></tr>
+{{range .Criteria}}<tr>
+<td>{{.Criterion.String}}</td><td>{{float .Value}}</td>
+<td class="{{if .Passed}}pass{{else}}fail{{end}}">{{if .Passed}}passed{{else}}failed{{end}}</td><td>{{.Error}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+
+{{if .Chaos}}
+<h2>Chaos</h2>
+<table>
+<tr><th>Chaos</th><th>Status</th><th>Deleted pods</th><th>Error</th></tr>
+{{range .Chaos}}<tr>
+<td>{{.ID}}</td><td class="{{if eq .Status "success"}}pass{{else}}fail{{end}}">{{.Status}}</td><td>{{.Deleted}}</td><td>{{.Error}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+
+{{if .Events}}
+<h2>Events</h2>
+<table>
+<tr><th>Time</th><th>Type</th><th>Job</th><th>Message</th></tr>
+{{range .Events}}<tr><td>{{.Time}}</td><td>{{.Type}}</td><td>{{.JobID}}</td><td>{{.Message}}</td></tr>{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// Internal function used to render the report as a single html page, charts are
+// drawn as inline svg so the page can be viewed without network access
+func (r *Report) renderHTML(w io.Writer) error {
+	data := struct {
+		*Report
+		Created  string
+		Jobs     []htmlJob
+		Criteria []m.CriterionResult
+		Chaos    []htmlChaos
+		Events   []htmlEvent
+	}{Report: r, Created: r.createdAt().Format(time.RFC3339)}
+
+	for _, j := range r.Jobs {
+		hj := htmlJob{Job: j}
+		if j.Metrics != nil {
+			hj.Charts = charts(j.Metrics.TimeSeries)
+		}
+		data.Jobs = append(data.Jobs, hj)
+	}
+
+	// Job criteria are listed before the ones of the whole test
+	var criteria []m.CriterionResult
+	for _, j := range r.Jobs {
+		criteria = append(criteria, j.Criteria...)
+	}
+	data.Criteria = append(criteria, r.Criteria...)
+
+	for _, id := range r.chaosIDs() {
+		result := r.Instance.ChaosResult[id]
+		data.Chaos = append(data.Chaos, htmlChaos{string(id), string(result.Status), len(result.DeletedPods), result.Error})
+	}
+
+	for _, e := range r.Instance.Events {
+		data.Events = append(data.Events, htmlEvent{
+			Time:    time.Unix(e.Time, 0).UTC().Format(time.RFC3339),
+			Type:    e.Type,
+			JobID:   string(e.JobID),
+			Message: e.Message,
+		})
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// Internal function used to chart the latencies and request rate of a time series
+func charts(ts *metrics.TimeSeries) []chart {
+	if ts == nil || len(ts.Buckets) < 2 {
+		return nil
+	}
+
+	// Long tests are charted in coarser intervals
+	first, last := ts.Buckets[0].Start, ts.Buckets[len(ts.Buckets)-1].Start
+	if span := last.Sub(first); span > ts.Interval*maxChartPoints {
+		interval := (span/maxChartPoints + ts.Interval - 1) / ts.Interval * ts.Interval
+		ts = metrics.Resample(interval, ts)
+	}
+
+	latency := func(f func(b *metrics.Bucket) time.Duration) func(b *metrics.Bucket) float64 {
+		return func(b *metrics.Bucket) float64 { return milliseconds(f(b)) }
+	}
+	perSecond := ts.Interval.Seconds()
+
+	return []chart{
+		newChart("Latency", "ms", ts, []series{
+			{"p50", "#0969da", latency(func(b *metrics.Bucket) time.Duration { return b.Latencies.P50 })},
+			{"p99", "#cf222e", latency(func(b *metrics.Bucket) time.Duration { return b.Latencies.P99 })},
+		}),
+		newChart("Requests", "req/s", ts, []series{
+			{"requests", "#0969da", func(b *metrics.Bucket) float64 { return float64(b.Requests) / perSecond }},
+			{"successes", "#1a7f37", func(b *metrics.Bucket) float64 { return float64(b.Successes) / perSecond }},
+		}),
+	}
+}
+
+type series struct {
+	label string
+	color string
+	value func(b *metrics.Bucket) float64
+}
+
+// Internal function used to scale the values of each series into svg coordinates.
+// The x axis is time since the first bucket, the y axis starts at 0.
+func newChart(title string, unit string, ts *metrics.TimeSeries, lines []series) chart {
+	c := chart{Title: title, Unit: unit, Width: chartWidth, Height: chartHeight}
+
+	for _, l := range lines {
+		for _, b := range ts.Buckets {
+			if v := l.value(b); v > c.Max {
+				c.Max = v
+			}
+		}
+	}
+
+	first := ts.Buckets[0].Start
+	span := ts.Buckets[len(ts.Buckets)-1].Start.Sub(first).Seconds()
+	if span == 0 {
+		span = 1
+	}
+	max := c.Max
+	if max == 0 {
+		max = 1
+	}
+
+	for _, l := range lines {
+		points := make([]string, 0, len(ts.Buckets))
+		for _, b := range ts.Buckets {
+			x := b.Start.Sub(first).Seconds() / span * chartWidth
+			y := chartHeight - l.value(b)/max*(chartHeight-10)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		c.Lines = append(c.Lines, chartLine{Label: l.label, Color: l.color, Points: strings.Join(points, " ")})
+	}
+
+	return c
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// Internal function used to render the report as JUnit XML, with one testcase per job.
+// A job fails if any of its criteria fail, criteria of the whole test are a testcase of their own.
+func (r *Report) renderJUnit(w io.Writer) error {
+	suite := junitSuite{
+		Name:      r.Name,
+		Timestamp: r.createdAt().Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{"instance", string(r.Instance.ID)},
+			{"status", r.Instance.Status},
+			{"verdict", string(r.Instance.Verdict)},
+		},
+	}
+
+	for _, j := range r.Jobs {
+		c := junitCase{Name: j.Name, Classname: r.Name}
+
+		if j.Metrics == nil || j.Metrics.Requests == 0 {
+			c.Skipped = &junitMessage{Message: "No requests were recorded"}
+		} else {
+			ms := j.Metrics
+			c.Time = (ms.Duration + ms.Wait).Seconds()
+			c.SystemOut = fmt.Sprintf(
+				"requests=%d rate=%.2f/s success=%.4f p50=%.2fms p99=%.2fms max=%.2fms",
+				ms.Requests, ms.Rate, ms.Success,
+				milliseconds(ms.Latencies.P50), milliseconds(ms.Latencies.P99), milliseconds(ms.Latencies.Max),
+			)
+			c.Failure = criteriaFailure(j.Criteria)
+		}
+
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if len(r.Criteria) > 0 {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "criteria",
+			Classname: r.Name,
+			Failure:   criteriaFailure(r.Criteria),
+		})
+	}
+
+	// An instance which did not finish fails as a whole
+	switch r.Instance.Status {
+	case "failed", "aborted", "interrupted":
+		message := r.Instance.Error
+		if r.Instance.AbortReason != "" {
+			message = r.Instance.AbortReason
+		}
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "instance",
+			Classname: r.Name,
+			Error:     &junitMessage{Message: fmt.Sprintf("Test instance %s", r.Instance.Status), Body: message},
+		})
+	}
+
+	var chaos []string
+	for _, id := range r.chaosIDs() {
+		result := r.Instance.ChaosResult[id]
+		suite.Properties = append(suite.Properties, junitProperty{"chaos." + string(id), string(result.Status)})
+		if result.Status != m.ChaosSuccess {
+			chaos = append(chaos, fmt.Sprintf("chaos %s failed: %s", id, result.Error))
+		}
+	}
+	suite.SystemErr = strings.Join(chaos, "\n")
+
+	for _, c := range suite.Cases {
+		suite.Tests++
+		suite.Time += c.Time
+		switch {
+		case c.Failure != nil:
+			suite.Failures++
+		case c.Error != nil:
+			suite.Errors++
+		case c.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Internal function used to describe the criteria that failed, nil if all passed
+func criteriaFailure(results []m.CriterionResult) *junitMessage {
+	var failed []string
+	for _, result := range results {
+		if result.Passed {
+			continue
+		}
+		if result.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", result.Criterion.String(), result.Error))
+		} else {
+			failed = append(failed, fmt.Sprintf("%s, was %g", result.Criterion.String(), result.Value))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return &junitMessage{
+		Message: fmt.Sprintf("%d of %d criteria failed", len(failed), len(results)),
+		Body:    strings.Join(failed, "\n"),
+	}
+}
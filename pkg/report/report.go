@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// Format of a rendered report
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+	FormatJUnit Format = "junit"
+	FormatHTML  Format = "html"
+)
+
+var contentTypes = map[Format]string{
+	FormatJSON:  "application/json",
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJUnit: "application/xml; charset=utf-8",
+	FormatHTML:  "text/html; charset=utf-8",
+}
+
+var extensions = map[Format]string{
+	FormatJSON:  "json",
+	FormatCSV:   "csv",
+	FormatJUnit: "xml",
+	FormatHTML:  "html",
+}
+
+// Report holds the results of a TestInstance in the shape they are rendered in
+type Report struct {
+	// Name of the test, the TestID if the test no longer exists
+	Name     string
+	Instance *m.TestInstance
+	Jobs     []Job
+
+	// Criteria which apply to all jobs combined
+	Criteria []m.CriterionResult
+}
+
+// Job holds the results of a single job of a TestInstance
+type Job struct {
+	ID       string
+	Name     string
+	Metrics  *metrics.Metrics
+	Criteria []m.CriterionResult
+}
+
+// New creates the report of an instance of test. Test may be nil if it was deleted,
+// jobs are then named by their JobID.
+func New(test *m.Test, instance *m.TestInstance) *Report {
+	r := &Report{Name: string(instance.TestID), Instance: instance}
+	jobMetrics, _ := instance.Metrics.(map[string]*metrics.Metrics)
+
+	seen := map[string]bool{}
+	if test != nil {
+		r.Name = test.Name
+		for _, j := range test.Jobs {
+			r.Jobs = append(r.Jobs, Job{ID: string(j.ID), Name: j.Name, Metrics: jobMetrics[string(j.ID)]})
+			seen[string(j.ID)] = true
+		}
+	}
+
+	// Jobs which were removed from the test since the instance ran
+	var removed []string
+	for jobID := range jobMetrics {
+		if !seen[jobID] {
+			removed = append(removed, jobID)
+		}
+	}
+	sort.Strings(removed)
+	for _, jobID := range removed {
+		r.Jobs = append(r.Jobs, Job{ID: jobID, Name: jobID, Metrics: jobMetrics[jobID]})
+	}
+
+	for _, result := range instance.CriteriaResults {
+		if result.Criterion.Job == "" {
+			r.Criteria = append(r.Criteria, result)
+			continue
+		}
+		for i := range r.Jobs {
+			if r.Jobs[i].Name == result.Criterion.Job {
+				r.Jobs[i].Criteria = append(r.Jobs[i].Criteria, result)
+			}
+		}
+	}
+
+	return r
+}
+
+// ParseFormat checks that format is one a report can be rendered in
+func ParseFormat(format string) (Format, error) {
+	if _, ok := contentTypes[Format(format)]; !ok {
+		return "", fmt.Errorf("Unknown report format `%s`", format)
+	}
+	return Format(format), nil
+}
+
+// ContentType returns the media type of a report in the given format
+func ContentType(format Format) string {
+	return contentTypes[format]
+}
+
+// Filename returns the name a report in the given format is downloaded as
+func (r *Report) Filename(format Format) string {
+	return fmt.Sprintf("%s.%s", r.Instance.ID, extensions[format])
+}
+
+// Render writes the report in the given format to w
+func (r *Report) Render(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return r.renderJSON(w)
+	case FormatCSV:
+		return r.renderCSV(w)
+	case FormatJUnit:
+		return r.renderJUnit(w)
+	case FormatHTML:
+		return r.renderHTML(w)
+	}
+	return fmt.Errorf("Unknown report format `%s`", format)
+}
+
+// Internal function used to render the report as json, including the time series of each job
+func (r *Report) renderJSON(w io.Writer) error {
+	type job struct {
+		ID         string              `json:"id"`
+		Name       string              `json:"name"`
+		Metrics    *metrics.Metrics    `json:"metrics"`
+		TimeSeries *metrics.TimeSeries `json:"timeseries"`
+		Criteria   []m.CriterionResult `json:"criteria"`
+	}
+
+	jobs := make([]job, 0, len(r.Jobs))
+	for _, j := range r.Jobs {
+		var ts *metrics.TimeSeries
+		if j.Metrics != nil {
+			ts = j.Metrics.TimeSeries
+		}
+		jobs = append(jobs, job{j.ID, j.Name, j.Metrics, ts, j.Criteria})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"test":     r.Name,
+		"instance": r.Instance,
+		"jobs":     jobs,
+		"criteria": r.Criteria,
+	})
+}
+
+// Internal function used to get when the instance was created
+func (r *Report) createdAt() time.Time {
+	return time.Unix(r.Instance.CreatedAt, 0).UTC()
+}
+
+// Internal function used to sort chaos results by their ChaosID
+func (r *Report) chaosIDs() []m.ChaosID {
+	ids := make([]m.ChaosID, 0, len(r.Instance.ChaosResult))
+	for id := range r.Instance.ChaosResult {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	m "github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+)
+
+// Internal function used to create a report of a test with a job which ran
+// for 5s, a job which ran without any request succeeding and a removed job
+func testReport() *Report {
+	run := func(jobID string, code uint32) *metrics.Metrics {
+		jm := metrics.NewMetricAggregator("test", "test-1", jobID)
+		for i := 0; i < 50; i++ {
+			jm.Add(&scheduler.Metrics{
+				Code:      code,
+				Timestamp: time.Unix(1618100600, 0).Add(time.Duration(i) * 100 * time.Millisecond),
+				Latency:   time.Duration(10+i%5) * time.Millisecond,
+			})
+		}
+		jm.Close()
+		return jm
+	}
+
+	test := &m.Test{
+		ID:   "test",
+		Name: "checkout",
+		Jobs: []m.Job{{ID: "test-a", Name: "browse"}, {ID: "test-b", Name: "pay"}, {ID: "test-c", Name: "idle"}},
+	}
+	instance := &m.TestInstance{
+		ID:        "test-1",
+		TestID:    "test",
+		Status:    "done",
+		CreatedAt: 1618100600,
+		Verdict:   m.VerdictFailed,
+		Metrics: map[string]*metrics.Metrics{
+			"test-a":   run("test-a", 200),
+			"test-b":   run("test-b", 503),
+			"test-old": run("test-old", 200),
+		},
+		CriteriaResults: []m.CriterionResult{
+			{Criterion: m.Criterion{Job: "browse", Metric: m.MetricP99, Operator: "<", Threshold: 100}, Value: 14, Passed: true},
+			{Criterion: m.Criterion{Job: "pay", Metric: m.MetricSuccess, Operator: ">=", Threshold: 0.99}, Value: 0},
+			{Criterion: m.Criterion{Metric: m.MetricSuccess, Operator: ">=", Threshold: 0.5}, Value: 0.66, Passed: true},
+		},
+		ChaosResult: map[m.ChaosID]m.ChaosResult{
+			"chaos-1": {Status: m.ChaosSuccess, DeletedPods: []string{"pod-1"}},
+		},
+	}
+
+	return New(test, instance)
+}
+
+func TestNew(t *testing.T) {
+	r := testReport()
+
+	var names []string
+	for _, j := range r.Jobs {
+		names = append(names, j.Name)
+	}
+	if got := strings.Join(names, ","); got != "browse,pay,idle,test-old" {
+		t.Errorf("expected jobs in test order followed by removed jobs, got %s", got)
+	}
+
+	if len(r.Jobs[0].Criteria) != 1 || len(r.Jobs[1].Criteria) != 1 || len(r.Criteria) != 1 {
+		t.Errorf("expected criteria grouped by job, got %+v", r)
+	}
+
+	if r.Jobs[2].Metrics != nil {
+		t.Errorf("expected no metrics for a job which did not run")
+	}
+
+	if r.Filename(FormatJUnit) != "test-1.xml" {
+		t.Errorf("unexpected filename %s", r.Filename(FormatJUnit))
+	}
+
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Errorf("expected pdf to be an unknown format")
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Render(&buf, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(kind string, job string, name string) []string {
+		for _, row := range rows {
+			if row[0] == kind && row[1] == job && row[3] == name {
+				return row
+			}
+		}
+		return nil
+	}
+
+	if row := find("metric", "browse", "requests"); row == nil || row[4] != "50" {
+		t.Errorf("expected 50 requests of browse, got %v", row)
+	}
+	if row := find("status_code", "pay", "503"); row == nil || row[4] != "50" {
+		t.Errorf("expected 50 responses with 503 of pay, got %v", row)
+	}
+	if row := find("criterion", "pay", "pay: success >= 0.99"); row == nil || row[5] != "false" {
+		t.Errorf("expected failed criterion of pay, got %v", row)
+	}
+	if row := find("chaos", "", "chaos-1"); row == nil || row[4] != "1" || row[5] != "true" {
+		t.Errorf("expected successful chaos, got %v", row)
+	}
+}
+
+func TestRenderJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Render(&buf, FormatJUnit); err != nil {
+		t.Fatal(err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("expected a single testsuite, got %d", len(suites.Suites))
+	}
+
+	suite := suites.Suites[0]
+	if suite.Name != "checkout" || suite.Tests != 5 || suite.Failures != 1 || suite.Skipped != 1 {
+		t.Errorf("expected 5 testcases with 1 failure and 1 skipped, got %+v", suite)
+	}
+
+	cases := map[string]junitCase{}
+	for _, c := range suite.Cases {
+		cases[c.Name] = c
+	}
+	if cases["pay"].Failure == nil || cases["browse"].Failure != nil {
+		t.Errorf("expected only pay to fail, got %+v", suite.Cases)
+	}
+	if cases["idle"].Skipped == nil {
+		t.Errorf("expected idle to be skipped")
+	}
+	if cases["browse"].Time < 4.9 {
+		t.Errorf("expected browse to take at least 4.9s, got %v", cases["browse"].Time)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	r := testReport()
+	r.Instance.Events = []m.InstanceEvent{{Time: 1618100602, Type: "reassigned", JobID: "test-a", Message: "<worker>"}}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, FormatHTML); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{"<h1>checkout</h1>", "<polyline", "pay: success &gt;= 0.99", "chaos-1", "&lt;worker&gt;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected html report to contain %s", want)
+		}
+	}
+
+	// The report must not load anything over the network
+	for _, external := range []string{"<script src", "<link", "http://", "https://"} {
+		if strings.Contains(strings.Replace(page, "http://www.w3.org/2000/svg", "", -1), external) {
+			t.Errorf("expected html report to be self-contained, found %s", external)
+		}
+	}
+}
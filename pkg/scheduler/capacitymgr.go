@@ -19,8 +19,8 @@ type CapacityManager struct {
 	podMetrics           map[InstanceID]*PodMetrics
 	capacity             uint64
 
-	group string
-	model *SchedulerModel
+	group       string
+	provisioner Provisioner
 }
 
 /**
@@ -58,7 +58,7 @@ func (cm *CapacityManager) calculateInstanceCount(frequency uint64) (int, error)
 
 /**
 * Assign a new job to a specific instance in the manager. Try to assign to the instance as much job as possible without
-* exceeding its max capacity.
+* exceeding its max capacity. Capacity assigned to a job the instance already runs is added to its workload.
 *
 * @return  amount of job that was successfully assigned
 * @return  amount of job that was left unassigned
@@ -86,7 +86,7 @@ func (cm *CapacityManager) assignCapacity(instance InstanceID, jobID m.JobID, re
 		cm.currentCapacities[instance] = 0
 	}
 
-	workDis[jobID] = workload
+	workDis[jobID] += workload
 	cm.podMetrics[instance].updateCurrentCapacity(cm.currentCapacities[instance])
 	return workload, required, nil
 }
@@ -112,6 +112,23 @@ func (cm *CapacityManager) reclaimCapacity(instance InstanceID, jobID m.JobID) e
 	return nil
 }
 
+/**
+* Check whether capacity of an instance is assigned to a job.
+*
+* @return  whether the instance holds a workload of the job
+ */
+func (cm *CapacityManager) holdsWorkload(instance InstanceID, jobID m.JobID) bool {
+	cm.capmux.Lock()
+	defer cm.capmux.Unlock()
+
+	dis, ok := cm.workloadDistribution[instance]
+	if !ok {
+		return false
+	}
+	_, ok = (*dis)[jobID]
+	return ok
+}
+
 /**
 * Non-blocking version
 * @return  the total available capacity of all instances in the manager
@@ -136,6 +153,16 @@ func (cm *CapacityManager) currentCapacity() uint64 {
 	return cm.nonBlockingCurrentCapacity()
 }
 
+/**
+* @return  the capacity of all instances in the manager which is assigned to jobs
+ */
+func (cm *CapacityManager) reservedCapacity() uint64 {
+	cm.capmux.Lock()
+	defer cm.capmux.Unlock()
+
+	return cm.cumulativeMaxCap - cm.nonBlockingCurrentCapacity()
+}
+
 /**
 * Remove an instance from the manager and adjust the capacity counters
  */
@@ -194,11 +221,51 @@ func (cm *CapacityManager) getPodAssignment(jobID m.JobID) *[]InstanceID {
 	return &arr
 }
 
+/**
+* Get the workloads assigned to an instance.
+*
+* @return  the capacity assigned to each job on the instance
+ */
+func (cm *CapacityManager) getWorkloads(instance InstanceID) map[m.JobID]uint64 {
+	cm.capmux.Lock()
+	defer cm.capmux.Unlock()
+
+	workloads := make(map[m.JobID]uint64)
+
+	if dis, ok := cm.workloadDistribution[instance]; ok {
+		for jobID, workload := range *dis {
+			workloads[jobID] = workload
+		}
+	}
+
+	return workloads
+}
+
+/**
+* Get the workloads of a job.
+*
+* @return  the capacity assigned to the job on each instance
+ */
+func (cm *CapacityManager) getJobWorkloads(jobID m.JobID) map[InstanceID]uint64 {
+	cm.capmux.Lock()
+	defer cm.capmux.Unlock()
+
+	workloads := make(map[InstanceID]uint64)
+
+	for ins, dis := range cm.workloadDistribution {
+		if workload, ok := (*dis)[jobID]; ok {
+			workloads[ins] = workload
+		}
+	}
+
+	return workloads
+}
+
 // NewCapacityManager returns a new capacity manager
-func NewCapacityManager(group string, model *SchedulerModel) *CapacityManager {
+func NewCapacityManager(group string, provisioner Provisioner) *CapacityManager {
 	var capmgr CapacityManager
 
-	capmgr.model = model
+	capmgr.provisioner = provisioner
 	capmgr.group = group
 
 	capmgr.maxCapacities = make(map[InstanceID]uint64)
@@ -206,8 +273,8 @@ func NewCapacityManager(group string, model *SchedulerModel) *CapacityManager {
 	capmgr.workloadDistribution = make(map[InstanceID]*map[m.JobID]uint64)
 	capmgr.podMetrics = make(map[InstanceID]*PodMetrics)
 
-	// Assign capacity based on the given scheduler model and group
-	capmgr.capacity, _ = model.getCapacity(group)
+	// Assign capacity based on the given provisioner and group
+	capmgr.capacity, _ = provisioner.Capacity(group)
 
 	return &capmgr
 }
@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	c "github.com/t-bfame/diago/config"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// KubernetesProvisioner runs workers as pods, configured by the WorkerGroup resource of their group
+type KubernetesProvisioner struct {
+	clientset *kubernetes.Clientset
+	model     *SchedulerModel
+}
+
+// Provision creates the pod of a worker
+func (kp *KubernetesProvisioner) Provision(group string, instance InstanceID) error {
+	// Assumption: Pod configuration is correct and it will always come up
+	// TODO: Add listener that listens whether pod was initialized
+	pod, err := kp.model.createPodConfig(group, instance)
+	if err != nil {
+		return err
+	}
+
+	result, err := kp.clientset.CoreV1().Pods(c.Diago.DefaultNamespace).Create(pod)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("pod", result.GetObjectMeta().GetName()).WithField("podGroup", group).Info("Created pod")
+	return nil
+}
+
+// Deprovision deletes the pod of a worker
+func (kp *KubernetesProvisioner) Deprovision(group string, instance InstanceID) error {
+	name := group + "-" + string(instance)
+	deletePolicy := metav1.DeletePropagationForeground
+
+	// Add listeners for detecting deletion
+	if err := kp.clientset.CoreV1().Pods(c.Diago.DefaultNamespace).Delete(name, &metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	}); err != nil {
+		return err
+	}
+
+	log.WithField("podName", name).WithField("podGroup", group).Info("Removed pod")
+	return nil
+}
+
+// Capacity returns the capacity of the WorkerGroup
+func (kp *KubernetesProvisioner) Capacity(group string) (uint64, error) {
+	return kp.model.getCapacity(group)
+}
+
+// Exists checks whether the WorkerGroup exists
+func (kp *KubernetesProvisioner) Exists(group string) bool {
+	return kp.model.checkExists(group)
+}
+
+// Workers lists the worker pods in the namespace by their group and instance labels
+func (kp *KubernetesProvisioner) Workers() (map[string][]InstanceID, error) {
+	pods, err := kp.clientset.CoreV1().Pods(c.Diago.DefaultNamespace).List(metav1.ListOptions{
+		LabelSelector: "group,instance",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workers := make(map[string][]InstanceID)
+	for _, pod := range pods.Items {
+		group := pod.Labels["group"]
+		workers[group] = append(workers[group], InstanceID(pod.Labels["instance"]))
+	}
+
+	return workers, nil
+}
+
+// NewKubernetesProvisioner creates a KubernetesProvisioner using in cluster config.
+func NewKubernetesProvisioner() (*KubernetesProvisioner, error) {
+	// creates the in-cluster config
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// creates the clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := NewSchedulerModel(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubernetesProvisioner{clientset, model}, nil
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+/**
+* Divide a rate between the instances of a job, proportionally to the share of the job's peak frequency
+* each instance was assigned. Remainders are handed out by largest fraction so the parts add up.
+*
+* @param  rate         the rate the job should currently run at
+* @param  peak         the peak frequency of the job
+* @param  assignments  the capacity assigned to each instance
+* @return  the rate for each instance
+ */
+func splitFrequency(rate uint64, peak uint64, assignments map[InstanceID]uint64) map[InstanceID]uint64 {
+	rates := make(map[InstanceID]uint64, len(assignments))
+	if peak == 0 {
+		for instance := range assignments {
+			rates[instance] = 0
+		}
+		return rates
+	}
+
+	instances := make([]InstanceID, 0, len(assignments))
+	var assigned uint64
+	for instance, workload := range assignments {
+		instances = append(instances, instance)
+		assigned += workload
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i] < instances[j] })
+
+	target := uint64(math.Round(float64(rate) * float64(assigned) / float64(peak)))
+	fractions := make(map[InstanceID]float64, len(assignments))
+	var total uint64
+
+	for _, instance := range instances {
+		exact := float64(rate) * float64(assignments[instance]) / float64(peak)
+		rates[instance] = uint64(exact)
+		fractions[instance] = exact - math.Floor(exact)
+		total += rates[instance]
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return fractions[instances[i]] > fractions[instances[j]]
+	})
+	for i := 0; total < target && i < len(instances); i++ {
+		rates[instances[i]]++
+		total++
+	}
+
+	return rates
+}
+
+/**
+* Adjust the rate of each workload of a job every second to follow its load profile.
+* Runs until the job's duration has elapsed or the stop channel is closed.
+*
+* @param  j            the job following a load profile
+* @param  stop         closed when the job finishes or is stopped
+ */
+func (pg *PodGroup) runLoadProfile(j m.Job, stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	peak := j.PeakFrequency()
+	current := splitFrequency(j.FrequencyAt(0), peak, pg.capmgr.getJobWorkloads(j.ID))
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Time the job was preempted for does not count
+			pg.qmux.Lock()
+			active, ok := pg.active[j.ID]
+			pg.qmux.Unlock()
+
+			elapsed := active.elapsed()
+			if !ok || (j.Duration > 0 && elapsed >= j.Duration) {
+				return
+			}
+
+			// Assignments change when workloads are reassigned
+			rates := splitFrequency(j.FrequencyAt(elapsed), peak, pg.capmgr.getJobWorkloads(j.ID))
+			for instance, rate := range rates {
+				if rate == current[instance] {
+					continue
+				}
+
+				pg.qmux.Lock()
+				out, ok := pg.scheduledPods[instance]
+				pg.qmux.Unlock()
+
+				if !ok {
+					continue
+				}
+				out <- Rate{ID: j.ID, Frequency: rate}
+			}
+
+			log.WithField("jobID", j.ID).WithField("elapsed", elapsed).Debug("Adjusted rate for load profile")
+			current = rates
+		}
+	}
+}
+
+/**
+* Stop adjusting the rate of a job, if it follows a load profile
+*
+* @param  id       the given job id
+ */
+func (pg *PodGroup) stopLoadProfile(id m.JobID) {
+	pg.profmux.Lock()
+	defer pg.profmux.Unlock()
+
+	if stop, ok := pg.profileStops[id]; ok {
+		close(stop)
+		delete(pg.profileStops, id)
+	}
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFrequency(t *testing.T) {
+	assignments := map[InstanceID]uint64{"a": 50, "b": 30, "c": 20}
+
+	// At peak every instance runs at its assigned capacity
+	got := splitFrequency(100, 100, assignments)
+	if !reflect.DeepEqual(got, assignments) {
+		t.Errorf("got %v, want %v", got, assignments)
+	}
+
+	// Parts always add up to the requested rate
+	got = splitFrequency(33, 100, assignments)
+	var total uint64
+	for _, rate := range got {
+		total += rate
+	}
+	if total != 33 {
+		t.Errorf("got total %d from %v, want 33", total, got)
+	}
+	if got["a"] < got["b"] || got["b"] < got["c"] {
+		t.Errorf("expected rates to follow assignments, got %v", got)
+	}
+
+	// Partially assigned jobs only run their assigned share
+	got = splitFrequency(100, 200, map[InstanceID]uint64{"a": 50})
+	if got["a"] != 25 {
+		t.Errorf("got %d, want 25", got["a"])
+	}
+
+	got = splitFrequency(0, 100, assignments)
+	if got["a"] != 0 || got["b"] != 0 || got["c"] != 0 {
+		t.Errorf("expected all rates to be 0, got %v", got)
+	}
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	c "github.com/t-bfame/diago/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LocalProvisioner runs workers as processes on the machine of the leader,
+// so that Diago can be used without Kubernetes. Every group exists and
+// all workers have the default group capacity.
+type LocalProvisioner struct {
+	command    []string
+	host       string
+	port       uint64
+	inactivity uint64
+	capacity   uint64
+
+	processes map[InstanceID]*exec.Cmd
+	groups    map[InstanceID]string
+	procmux   sync.Mutex
+}
+
+// Provision starts the worker command with the env variables of the worker
+func (lp *LocalProvisioner) Provision(group string, instance InstanceID) error {
+	if len(lp.command) == 0 {
+		return errors.New("No local worker command is configured")
+	}
+
+	cmd := exec.Command(lp.command[0], lp.command[1:]...)
+	cmd.Env = os.Environ()
+	for name, value := range workerEnv(group, instance, lp.host, lp.port, lp.inactivity, lp.capacity) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	output := log.WithField("group", group).WithField("instance", instance).Writer()
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	lp.procmux.Lock()
+	defer lp.procmux.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		output.Close()
+		return err
+	}
+	lp.processes[instance] = cmd
+	lp.groups[instance] = group
+
+	go func() {
+		err := cmd.Wait()
+		output.Close()
+
+		lp.procmux.Lock()
+		if lp.processes[instance] == cmd {
+			delete(lp.processes, instance)
+			delete(lp.groups, instance)
+		}
+		lp.procmux.Unlock()
+
+		log.WithError(err).WithField("group", group).WithField("instance", instance).Info("Worker process exited")
+	}()
+
+	log.WithField("pid", cmd.Process.Pid).WithField("podGroup", group).WithField("instance", instance).Info("Started worker process")
+	return nil
+}
+
+// Deprovision kills the worker process if it is still running
+func (lp *LocalProvisioner) Deprovision(group string, instance InstanceID) error {
+	lp.procmux.Lock()
+	cmd, ok := lp.processes[instance]
+	delete(lp.processes, instance)
+	delete(lp.groups, instance)
+	lp.procmux.Unlock()
+
+	// Workers exit by themselves once they are inactive
+	if !ok {
+		return nil
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return err
+	}
+
+	log.WithField("pid", cmd.Process.Pid).WithField("podGroup", group).WithField("instance", instance).Info("Killed worker process")
+	return nil
+}
+
+// Capacity returns the default group capacity
+func (lp *LocalProvisioner) Capacity(group string) (uint64, error) {
+	return lp.capacity, nil
+}
+
+// Exists always returns true, local workers need no configuration per group
+func (lp *LocalProvisioner) Exists(group string) bool {
+	return true
+}
+
+// Workers returns the worker processes which are still running. Processes of a
+// previous leader are not known, they exit by themselves once they are inactive.
+func (lp *LocalProvisioner) Workers() (map[string][]InstanceID, error) {
+	lp.procmux.Lock()
+	defer lp.procmux.Unlock()
+
+	workers := make(map[string][]InstanceID)
+	for instance, group := range lp.groups {
+		workers[group] = append(workers[group], instance)
+	}
+
+	return workers, nil
+}
+
+// NewLocalProvisioner creates a LocalProvisioner from the local worker settings of config
+func NewLocalProvisioner(config *c.Config) *LocalProvisioner {
+	lp := new(LocalProvisioner)
+
+	lp.command = strings.Fields(config.LocalWorkerCommand)
+	lp.host = config.Host
+	lp.port = config.GRPCPort
+	lp.inactivity = config.LocalWorkerInactivityPeriod
+	lp.capacity = config.DefaultGroupCapacity
+	lp.processes = make(map[InstanceID]*exec.Cmd)
+	lp.groups = make(map[InstanceID]string)
+
+	return lp
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	c "github.com/t-bfame/diago/config"
+)
+
+func TestLocalProvisioner(t *testing.T) {
+	lp := NewLocalProvisioner(&c.Config{
+		Host:                 "localhost",
+		GRPCPort:             5000,
+		DefaultGroupCapacity: 50,
+		LocalWorkerCommand:   "sleep 30",
+	})
+
+	if !lp.Exists("any-group") {
+		t.Errorf("expected every group to exist")
+	}
+	if capacity, _ := lp.Capacity("any-group"); capacity != 50 {
+		t.Errorf("got capacity %d, want 50", capacity)
+	}
+
+	if err := lp.Provision("any-group", "abc123"); err != nil {
+		t.Fatalf("failed to provision worker: %s", err)
+	}
+	lp.procmux.Lock()
+	cmd, ok := lp.processes["abc123"]
+	lp.procmux.Unlock()
+	if !ok || cmd.Process == nil {
+		t.Fatalf("expected worker process to be tracked")
+	}
+
+	if err := lp.Deprovision("any-group", "abc123"); err != nil {
+		t.Fatalf("failed to deprovision worker: %s", err)
+	}
+
+	// the process is reaped once it was killed
+	deadline := time.Now().Add(5 * time.Second)
+	for cmd.Process.Signal(syscall.Signal(0)) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected worker process to be killed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// removing an exited worker is a no-op
+	if err := lp.Deprovision("any-group", "abc123"); err != nil {
+		t.Errorf("expected no error for exited worker, got %s", err)
+	}
+}
+
+func TestLocalProvisioner_NoCommand(t *testing.T) {
+	lp := NewLocalProvisioner(&c.Config{})
+
+	if err := lp.Provision("any-group", "abc123"); err == nil {
+		t.Errorf("expected provisioning without a command to fail")
+	}
+}
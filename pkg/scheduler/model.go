@@ -2,7 +2,6 @@ package scheduler
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/t-bfame/diago/api/v1alpha1"
 	c "github.com/t-bfame/diago/config"
@@ -47,16 +46,8 @@ func (sm SchedulerModel) getEnvs(group string, instance InstanceID) map[string]s
 		return nil
 	}
 
-	envs := map[string]string{
-		"DIAGO_WORKER_GROUP":                   group,
-		"DIAGO_WORKER_GROUP_INSTANCE":          string(instance),
-		"DIAGO_LEADER_HOST":                    c.Diago.Host,
-		"DIAGO_LEADER_PORT":                    fmt.Sprintf("%d", c.Diago.GRPCPort),
-		"ALLOWED_INACTIVITY_PERIOD_SECONDS":    fmt.Sprintf("%d", workerConfig.Spec.AllowedInactivityPeriod),
-		"DIAGO_WORKER_GROUP_INSTANCE_CAPACITY": fmt.Sprintf("%d", workerConfig.Spec.Capacity),
-	}
-
-	return envs
+	return workerEnv(group, instance, c.Diago.Host, c.Diago.GRPCPort,
+		uint64(workerConfig.Spec.AllowedInactivityPeriod), uint64(workerConfig.Spec.Capacity))
 }
 
 // Internal function used to retrieves labels for a specified group and instance.
@@ -2,37 +2,46 @@ package scheduler
 
 import (
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
-	c "github.com/t-bfame/diago/config"
 	m "github.com/t-bfame/diago/pkg/model"
 	"github.com/t-bfame/diago/pkg/utils"
 
 	log "github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 const hashSize = 6
 
 // PodGroup indicates kind of pod
 type PodGroup struct {
-	group     string
-	clientset *kubernetes.Clientset
-	model     *SchedulerModel
+	group       string
+	provisioner Provisioner
 
-	scheduledPods map[InstanceID]chan Outgoing
-	podmux        sync.Mutex
+	// podmux serializes adding and removing instances
+	podmux sync.Mutex
 
+	// scheduledPods, like the job state below, is guarded by qmux
+	scheduledPods  map[InstanceID]chan Outgoing
 	outputChannels map[m.JobID]chan Event
 	workloadCount  map[m.JobID]uint32
 
 	qmux     sync.Mutex
-	jobQueue *[]m.Job
+	jobQueue jobQueue
+	active   map[m.JobID]activeJob
+
+	// whether running jobs can be preempted by jobs of higher priority
+	preemption    bool
+	preemptingFor m.JobID
 
 	capmgr *CapacityManager
 
+	profileStops map[m.JobID]chan struct{}
+	profmux      sync.Mutex
+
 	cleanupChannel chan struct{}
+	cleanupOnce    sync.Once
 }
 
 /**
@@ -53,7 +62,7 @@ func (pg *PodGroup) addInstances(frequency uint64) (err error) {
 	}
 
 	var wg sync.WaitGroup
-	wgErr := make(chan error)
+	wgErr := make(chan error, count)
 	wg.Add(count)
 
 	for i := 0; i < count; i++ {
@@ -62,30 +71,18 @@ func (pg *PodGroup) addInstances(frequency uint64) (err error) {
 
 			id := InstanceID(utils.RandHash(hashSize))
 
-			// Assumption: Pod configuration is correct and it will always come up
-			// TODO: Add listener that listens whether pod was initialized
-			pod, err := pg.model.createPodConfig(pg.group, id)
-			if err != nil {
+			if err := pg.provisioner.Provision(pg.group, id); err != nil {
 				log.WithField("group", pg.group).WithError(err).Error("Unable to add instances for pod group")
 				wgErr <- err
 				return
 			}
-
-			result, err := pg.clientset.CoreV1().Pods(c.Diago.DefaultNamespace).Create(pod)
-			if err != nil {
-				log.WithField("group", pg.group).WithError(err).Error("Unable to add instances for pod group")
-				wgErr <- err
-				return
-			}
-
-			log.WithField("pod", result.GetObjectMeta().GetName()).WithField("podGroup", pg.group).Info("Created pod")
 		}()
 	}
 
 	wg.Wait()
 
 	select {
-	case <-wgErr:
+	case err = <-wgErr:
 		return err
 	default:
 		return nil
@@ -101,29 +98,23 @@ func (pg *PodGroup) removeInstance(instance InstanceID) (err error) {
 	pg.podmux.Lock()
 	defer pg.podmux.Unlock()
 
-	name := pg.group + "-" + string(instance)
-
+	pg.qmux.Lock()
 	delete(pg.scheduledPods, instance)
 	pg.capmgr.removeInstance(instance)
+	empty := len(pg.scheduledPods) == 0 && len(pg.workloadCount) == 0
+	pg.qmux.Unlock()
 
-	deletePolicy := metav1.DeletePropagationForeground
-
-	// Since there are no more workers remaining we can
-	// cleanup the pg instance from the scheduler
-	if len(pg.scheduledPods) == 0 {
-		close(pg.cleanupChannel)
+	// Since there are no more workers or workloads remaining we
+	// can cleanup the pg instance from the scheduler
+	if empty {
+		pg.cleanup()
 	}
 
-	// Add listeners for detecting deletion
-	if err := pg.clientset.CoreV1().Pods(c.Diago.DefaultNamespace).Delete(name, &metav1.DeleteOptions{
-		PropagationPolicy: &deletePolicy,
-	}); err != nil {
-		log.WithError(err).WithField("podName", name).WithField("podGroup", pg.group).Error("Encountered error while pod deletion")
+	if err := pg.provisioner.Deprovision(pg.group, instance); err != nil {
+		log.WithError(err).WithField("instance", instance).WithField("podGroup", pg.group).Error("Encountered error while removing worker")
 		return err
 	}
 
-	log.WithField("podName", name).WithField("podGroup", pg.group).Info("Removed pod")
-
 	return nil
 }
 
@@ -139,9 +130,9 @@ func (pg *PodGroup) addJob(j m.Job, events chan Event) (err error) {
 
 	pg.outputChannels[j.ID] = events
 
-	// Queue job
-	*pg.jobQueue = append(*pg.jobQueue, j)
-	go pg.addInstances(j.Frequency)
+	// Queue job, workers are sized for the peak of its load profile
+	pg.jobQueue.push(queuedJob{job: j, frequency: j.PeakFrequency()})
+	go pg.addInstances(j.PeakFrequency())
 
 	pg.distribute()
 	return nil
@@ -153,11 +144,42 @@ func (pg *PodGroup) addJob(j m.Job, events chan Event) (err error) {
 * @param  id       the given job id
  */
 func (pg *PodGroup) removeJob(id m.JobID) (err error) {
+	pg.stopLoadProfile(id)
+
+	pg.qmux.Lock()
+
+	// Lost shares of the job must not be reassigned anymore
+	delete(pg.active, id)
+	if pg.preemptingFor == id {
+		pg.preemptingFor = ""
+	}
+
+	// Drop the job from the queue, if it has not started yet it is done
+	for i := len(pg.jobQueue) - 1; i >= 0; i-- {
+		if pg.jobQueue[i].job.ID != id {
+			continue
+		}
+		if q := pg.jobQueue.remove(i); q.lost != "" || q.resume {
+			pg.finishWorkload(id)
+		} else if output, ok := pg.outputChannels[id]; ok && pg.workloadCount[id] == 0 {
+			delete(pg.outputChannels, id)
+			close(output)
+		}
+	}
 
 	// Locate all workers that handle the specified job
+	var workers []chan Outgoing
 	for _, instance := range *(pg.capmgr.getPodAssignment(id)) {
-		// Send stop message
-		pg.scheduledPods[instance] <- Stop{id}
+		if worker, ok := pg.scheduledPods[instance]; ok {
+			workers = append(workers, worker)
+		}
+	}
+
+	pg.qmux.Unlock()
+
+	// Send stop message
+	for _, worker := range workers {
+		worker <- Stop{id}
 	}
 
 	return nil
@@ -183,69 +205,136 @@ func (pg *PodGroup) registerPod(group string, instance InstanceID, frequency uin
 	// Mux events from pod to correct job channels
 	go func() {
 		for msg := range leader {
+			pg.handle(instance, msg)
+		}
 
-			jobID := msg.getJobID()
+		// If channel is closed then communication with pod has stopped,
+		// workloads it did not finish are given to other instances
+		lost := pg.capmgr.getWorkloads(instance)
+		pg.removeInstance(instance)
+		pg.reassign(instance, lost)
+	}()
 
-			// Locate the output channel for this job
-			output, ok := pg.outputChannels[jobID]
-			if !ok {
-				log.WithField("jobID", jobID).Error("Could not find registered channel for job, discarding event")
-				continue
-			}
+	pg.distribute()
+	return leader, worker, nil
+}
 
-			switch msg.(type) {
-			// If get a message of job finished, then clean up the worker. If all workloads are finished, then close channel.
-			case Finish:
-				pg.workloadCount[jobID]--
+/**
+* Handle a message of an instance. Events are sent to the output channel of their job while holding the lock,
+* so that the channel cannot be closed meanwhile.
+*
+* @param  instance  the instance which sent the message
+* @param  msg       the message
+ */
+func (pg *PodGroup) handle(instance InstanceID, msg Incoming) {
+	pg.qmux.Lock()
+	defer pg.qmux.Unlock()
 
-				pg.capmgr.reclaimCapacity(instance, jobID)
-				pg.distribute()
+	jobID := msg.getJobID()
 
-				// Since no more remaining workloads, output channel can be closed
-				if pg.workloadCount[jobID] == 0 {
-					delete(pg.workloadCount, jobID)
-					delete(pg.outputChannels, jobID)
-					close(output)
-				}
+	// A worker which registered again may still report on workloads of its previous
+	// registration, which were reassigned when it was lost
+	if !pg.capmgr.holdsWorkload(instance, jobID) {
+		log.WithField("jobID", jobID).WithField("instance", instance).Warning("Worker does not hold a workload of job, discarding event")
+		return
+	}
 
-			default:
-				// Send event to respective output channel
-				output <- msg
-			}
-		}
+	// Locate the output channel for this job
+	output, ok := pg.outputChannels[jobID]
+	if !ok {
+		log.WithField("jobID", jobID).Error("Could not find registered channel for job, discarding event")
+		return
+	}
 
-		// If channel is closed then communication with pod has stopped
-		pg.removeInstance(instance)
-	}()
+	switch msg.(type) {
+	// If get a message of job finished, then clean up the worker. If all workloads are finished, then close channel.
+	case Finish:
+		if err := pg.capmgr.reclaimCapacity(instance, jobID); err == nil {
+			pg.finishWorkload(jobID)
+			pg.distribute()
+		}
 
-	pg.distribute()
-	return leader, worker, nil
+	default:
+		// Send event to respective output channel
+		output <- msg
+	}
 }
 
 /**
-* Distribute the next queued job. Loop through workers and assign job.
-* TODO: for every jobID, keep track of which pod has been assigned what frequency
-*		and cleanup this information when finish event from that pod is received
+* Distribute queued jobs. The job first in the queue is started if there is enough capacity, otherwise running jobs of
+* lower priority are preempted for it if enabled. If neither is possible, later jobs which fit the remaining capacity are
+* started instead. Shares of jobs lost with a worker, and preempted jobs, are started for the remaining duration of the job.
  */
 func (pg *PodGroup) distribute() {
-	if len(*pg.jobQueue) == 0 {
-		return
+	for len(pg.jobQueue) > 0 {
+		capacity := pg.capmgr.currentCapacity()
+
+		// Wait for the capacity freed by preemption
+		if pg.jobQueue[0].frequency > capacity && pg.preempt(pg.jobQueue[0], capacity) {
+			return
+		}
+
+		// Backfill with the first job that fits
+		next := -1
+		for i, q := range pg.jobQueue {
+			if q.frequency <= capacity {
+				next = i
+				break
+			}
+		}
+
+		// Cannot start since there is not enough capacity
+		if next < 0 {
+			return
+		}
+
+		pg.startJob(pg.jobQueue.remove(next))
 	}
+}
 
-	j := (*pg.jobQueue)[0]
-	frequency := j.Frequency
+/**
+* Assign a queued job to workers with available capacity.
+*
+* @param  q  the queued job
+ */
+func (pg *PodGroup) startJob(q queuedJob) {
+	j := q.job
+	peak := j.PeakFrequency()
+	frequency := q.frequency
 	var workload uint64
 	var err error
 
-	// Cannot start since there is not enough capacity
-	if frequency > pg.capmgr.currentCapacity() {
-		return
+	var elapsed, remaining uint64
+	if q.lost != "" || q.resume {
+		active, ok := pg.active[j.ID]
+		if ok && q.resume {
+			active.paused += time.Since(active.pausedAt)
+			active.pausedAt = time.Time{}
+			pg.active[j.ID] = active
+		}
+		elapsed = active.elapsed()
+
+		// The job was stopped or has ended while it was queued
+		if !ok || (j.Duration > 0 && elapsed >= j.Duration) {
+			pg.finishWorkload(j.ID)
+			return
+		}
 	}
+	if j.Duration > 0 {
+		remaining = j.Duration - elapsed
+	}
+
+	// Instances may already run a share of the job when a lost share is reassigned
+	held := pg.capmgr.getJobWorkloads(j.ID)
 
-	// Remove next job from queue
-	(*pg.jobQueue) = (*pg.jobQueue)[1:]
+	// Capacity is reserved for the peak of the load profile
+	assignments := make(map[InstanceID]uint64)
+	for instance := range pg.scheduledPods {
 
-	for instance, out := range pg.scheduledPods {
+		// Workloads of a preempted job may not have stopped yet
+		if _, ok := held[instance]; ok && q.resume {
+			continue
+		}
 
 		workload, frequency, err = pg.capmgr.assignCapacity(instance, j.ID, frequency)
 
@@ -258,61 +347,249 @@ func (pg *PodGroup) distribute() {
 			continue
 		}
 
-		// Increment the worload count
-		pg.workloadCount[j.ID]++
-		out <- Start{
-			ID:         j.ID,
-			Frequency:  workload,
-			Duration:   j.Duration,
-			HTTPMethod: j.HTTPMethod,
-			HTTPUrl:    j.HTTPUrl,
-		}
+		assignments[instance] = workload
 
 		if frequency == 0 {
 			break
 		}
 	}
 
+	totals := make(map[InstanceID]uint64, len(assignments))
+	for instance, workload := range assignments {
+		totals[instance] = held[instance] + workload
+	}
+
+	// Workloads start at their share of the current rate, instances already running
+	// the job take on the share by running their workload faster instead
+	for instance, rate := range splitFrequency(j.FrequencyAt(elapsed), peak, totals) {
+		if _, ok := held[instance]; ok {
+			pg.scheduledPods[instance] <- Rate{ID: j.ID, Frequency: rate}
+			continue
+		}
+
+		start := newStart(j, rate)
+		start.Duration = remaining
+
+		// Increment the worload count
+		pg.workloadCount[j.ID]++
+		pg.scheduledPods[instance] <- start
+	}
+
 	if frequency > 0 {
 		log.WithField("jobID", j.ID).Warning("Assigned partial workload, continuing test")
 	}
 
-	// Send start event on events channel
 	output, ok := pg.outputChannels[j.ID]
 	if !ok {
 		log.WithField("jobID", j.ID).Error("Could not find registered channel for job, discarding start event")
 		return
 	}
 
-	output <- Start{
-		ID:         j.ID,
-		Frequency:  j.Frequency - frequency,
-		Duration:   j.Duration,
-		HTTPMethod: j.HTTPMethod,
-		HTTPUrl:    j.HTTPUrl,
+	if q.lost != "" || q.resume {
+		// The queued job was counted as a workload of its own
+		pg.finishWorkload(j.ID)
+
+		instances := make([]InstanceID, 0, len(assignments))
+		for instance := range assignments {
+			instances = append(instances, instance)
+		}
+
+		if q.resume {
+			log.WithField("jobID", j.ID).WithField("instances", instances).Info("Resumed preempted job")
+			output <- Resume{ID: j.ID, Instances: instances, Frequency: q.frequency - frequency, Remaining: remaining}
+			return
+		}
+
+		log.WithField("jobID", j.ID).WithField("lost", q.lost).WithField("instances", instances).Info("Reassigned workload of stopped worker")
+		output <- Reassign{ID: j.ID, Lost: q.lost, Instances: instances, Frequency: q.frequency - frequency, Remaining: remaining}
+		return
+	}
+
+	pg.active[j.ID] = activeJob{job: j, started: time.Now()}
+	if pg.preemptingFor == j.ID {
+		pg.preemptingFor = ""
+	}
+
+	if j.LoadProfile.Type != m.ProfileConstant {
+		stop := make(chan struct{})
+		pg.profmux.Lock()
+		pg.profileStops[j.ID] = stop
+		pg.profmux.Unlock()
+
+		go pg.runLoadProfile(j, stop)
+	}
+
+	// Send start event on events channel
+	output <- newStart(j, peak-frequency)
+}
+
+/**
+* Stop running jobs of lower priority than a queued job, until enough capacity would be freed for it.
+* Jobs with the lowest priority, and of those the most recently started ones, are preempted first.
+* Preempted jobs are queued again and resume for their remaining duration once there is capacity.
+*
+* @param  q         the queued job that cannot start
+* @param  capacity  the currently available capacity
+* @return  whether enough capacity is being freed for the job
+ */
+func (pg *PodGroup) preempt(q queuedJob, capacity uint64) bool {
+	if !pg.preemption || q.lost != "" {
+		return false
+	}
+
+	// Already waiting for preempted jobs to stop
+	if pg.preemptingFor == q.job.ID {
+		return true
+	}
+
+	var candidates []activeJob
+	for _, active := range pg.active {
+		if active.job.Priority < q.job.Priority && active.pausedAt.IsZero() {
+			candidates = append(candidates, active)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].job.Priority != candidates[j].job.Priority {
+			return candidates[i].job.Priority < candidates[j].job.Priority
+		}
+		return candidates[i].started.After(candidates[j].started)
+	})
+
+	var victims []activeJob
+	for _, active := range candidates {
+		if capacity >= q.frequency {
+			break
+		}
+		for _, workload := range pg.capmgr.getJobWorkloads(active.job.ID) {
+			capacity += workload
+		}
+		victims = append(victims, active)
+	}
+
+	if capacity < q.frequency {
+		return false
+	}
+
+	for _, active := range victims {
+		pg.suspend(active, q.job.ID)
 	}
+	pg.preemptingFor = q.job.ID
+
+	return true
+}
+
+/**
+* Stop the workloads of a running job and queue it to resume later.
+*
+* @param  active  the running job
+* @param  by      the job the running job is preempted for
+ */
+func (pg *PodGroup) suspend(active activeJob, by m.JobID) {
+	j := active.job
+
+	var reserved uint64
+	for instance, workload := range pg.capmgr.getJobWorkloads(j.ID) {
+		reserved += workload
+		pg.scheduledPods[instance] <- Stop{j.ID}
+	}
+
+	active.pausedAt = time.Now()
+	pg.active[j.ID] = active
+
+	// The queued job is counted as a workload, so the job is not finished by the stopped workers
+	pg.workloadCount[j.ID]++
+	pg.jobQueue.push(queuedJob{job: j, frequency: reserved, resume: true})
+
+	log.WithField("jobID", j.ID).WithField("by", by).Warning("Preempted job for a job of higher priority")
+
+	if output, ok := pg.outputChannels[j.ID]; ok {
+		output <- Preempt{ID: j.ID, By: by}
+	}
+}
+
+/**
+* Requeue the workloads of an instance which stopped before finishing them, so that
+* they are distributed to the remaining or new instances for the rest of their job's duration.
+*
+* @param  instance  the instance which stopped
+* @param  lost      the capacity assigned to each job on the instance
+ */
+func (pg *PodGroup) reassign(instance InstanceID, lost map[m.JobID]uint64) {
+	pg.qmux.Lock()
+	defer pg.qmux.Unlock()
+
+	var required uint64
+	for jobID, workload := range lost {
+		active, ok := pg.active[jobID]
+		if !ok {
+			// Job was stopped, the worker will not report its finish anymore
+			pg.finishWorkload(jobID)
+			continue
+		}
+
+		log.WithField("jobID", jobID).WithField("instance", instance).WithField("frequency", workload).Warning("Worker stopped before finishing its workload, requeuing")
+
+		// Lost shares are reassigned before new jobs are started
+		pg.jobQueue.push(queuedJob{job: active.job, frequency: workload, lost: instance})
+		required += workload
+	}
+
+	// Add workers for the lost shares on top of the capacity already in use
+	if required > 0 {
+		go pg.addInstances(pg.capmgr.reservedCapacity() + required)
+	}
+
+	pg.distribute()
+}
+
+/**
+* Account for a finished workload of a job. Once no workloads remain, the output channel of the job is closed.
+*
+* @param  jobID  the job of the workload
+ */
+func (pg *PodGroup) finishWorkload(jobID m.JobID) {
+	pg.workloadCount[jobID]--
+
+	// Since no more remaining workloads, output channel can be closed
+	if pg.workloadCount[jobID] == 0 {
+		pg.stopLoadProfile(jobID)
+		delete(pg.workloadCount, jobID)
+		delete(pg.active, jobID)
+
+		if output, ok := pg.outputChannels[jobID]; ok {
+			delete(pg.outputChannels, jobID)
+			close(output)
+		}
+	}
+}
+
+// Internal function used to remove the group from the scheduler, at most once
+func (pg *PodGroup) cleanup() {
+	pg.cleanupOnce.Do(func() {
+		close(pg.cleanupChannel)
+	})
 }
 
 // NewPodGroup Allocates a new podGroup
-func NewPodGroup(group string, clientset *kubernetes.Clientset, model *SchedulerModel, cleanup chan struct{}, failNonExistentGroup bool) (pg *PodGroup, err error) {
+func NewPodGroup(group string, provisioner Provisioner, cleanup chan struct{}, failNonExistentGroup bool) (pg *PodGroup, err error) {
 
 	// If we want to fail when WorkerGroup doesnt exist in K8s
-	if failNonExistentGroup && !model.checkExists(group) {
+	if failNonExistentGroup && !provisioner.Exists(group) {
 		return nil, errors.New("WorkerGroup does not exist")
 	}
 
 	pg = new(PodGroup)
 
-	pg.clientset = clientset
-	pg.model = model
+	pg.provisioner = provisioner
 	pg.group = group
 
 	pg.scheduledPods = make(map[InstanceID]chan Outgoing)
 	pg.outputChannels = make(map[m.JobID]chan Event)
 	pg.workloadCount = make(map[m.JobID]uint32)
 
-	pg.jobQueue = new([]m.Job)
-	pg.capmgr = NewCapacityManager(group, model)
+	pg.active = make(map[m.JobID]activeJob)
+	pg.capmgr = NewCapacityManager(group, provisioner)
+	pg.profileStops = make(map[m.JobID]chan struct{})
 
 	pg.cleanupChannel = cleanup
 
@@ -0,0 +1,354 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// fakeProvisioner records provisioned workers instead of starting them
+type fakeProvisioner struct {
+	capacity    uint64
+	provisioned chan InstanceID
+
+	workers      map[string][]InstanceID
+	deprovisions []InstanceID
+	mux          sync.Mutex
+}
+
+func (fp *fakeProvisioner) Provision(group string, instance InstanceID) error {
+	fp.provisioned <- instance
+	return nil
+}
+
+func (fp *fakeProvisioner) Deprovision(group string, instance InstanceID) error {
+	fp.mux.Lock()
+	defer fp.mux.Unlock()
+
+	fp.deprovisions = append(fp.deprovisions, instance)
+	return nil
+}
+
+func (fp *fakeProvisioner) Capacity(group string) (uint64, error) {
+	return fp.capacity, nil
+}
+
+func (fp *fakeProvisioner) Exists(group string) bool {
+	return true
+}
+
+func (fp *fakeProvisioner) Workers() (map[string][]InstanceID, error) {
+	return fp.workers, nil
+}
+
+func (fp *fakeProvisioner) deprovisioned() []InstanceID {
+	fp.mux.Lock()
+	defer fp.mux.Unlock()
+
+	return append([]InstanceID(nil), fp.deprovisions...)
+}
+
+func receive(t *testing.T, ch chan Outgoing) Outgoing {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message to worker")
+		return nil
+	}
+}
+
+func TestPodGroup_Reassign(t *testing.T) {
+	group := "reassign-group"
+	fp := &fakeProvisioner{capacity: 10, provisioned: make(chan InstanceID, 10)}
+	pg, err := NewPodGroup(group, fp, make(chan struct{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderA, workerA, _ := pg.registerPod(group, "reassign-a", 10)
+	leaderB, workerB, _ := pg.registerPod(group, "reassign-b", 10)
+
+	events := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "job", Frequency: 20, Duration: 60}, events)
+
+	for _, ch := range []chan Outgoing{workerA, workerB} {
+		if start, ok := receive(t, ch).(Start); !ok || start.Frequency != 10 || start.Duration != 60 {
+			t.Fatalf("expected start at 10/s for 60s, got %+v", start)
+		}
+	}
+	if _, ok := (<-events).(Start); !ok {
+		t.Fatal("expected start event for job")
+	}
+
+	// worker A dies, its share is given to a new worker
+	close(leaderA)
+
+	var replacement InstanceID
+	select {
+	case replacement = <-fp.provisioned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a new worker to be provisioned")
+	}
+
+	leaderC, workerC, _ := pg.registerPod(group, replacement, 10)
+	start, ok := receive(t, workerC).(Start)
+	if !ok || start.Frequency != 10 || start.Duration == 0 || start.Duration > 60 {
+		t.Fatalf("expected start at 10/s for the remaining duration, got %+v", start)
+	}
+
+	reassign, ok := (<-events).(Reassign)
+	if !ok || reassign.Lost != "reassign-a" || len(reassign.Instances) != 1 || reassign.Instances[0] != replacement || reassign.Frequency != 10 {
+		t.Fatalf("unexpected reassign event %+v", reassign)
+	}
+
+	// job ends once the remaining workers have finished
+	leaderB <- Finish{ID: "job"}
+	leaderC <- Finish{ID: "job"}
+
+	select {
+	case _, open := <-events:
+		if open {
+			t.Error("expected events of job to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected events of job to be closed")
+	}
+
+	close(leaderB)
+	close(leaderC)
+}
+
+func TestPodGroup_ReassignToWorkerOfJob(t *testing.T) {
+	group := "reassign-merge-group"
+	fp := &fakeProvisioner{capacity: 10, provisioned: make(chan InstanceID, 10)}
+	pg, err := NewPodGroup(group, fp, make(chan struct{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderA, workerA, _ := pg.registerPod(group, "merge-a", 20)
+
+	events := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "job", Frequency: 10, Duration: 60}, events)
+
+	if start, ok := receive(t, workerA).(Start); !ok || start.Frequency != 10 {
+		t.Fatalf("expected start at 10/s, got %+v", start)
+	}
+	if _, ok := (<-events).(Start); !ok {
+		t.Fatal("expected start event for job")
+	}
+
+	// worker B runs another share of the job, then dies
+	leaderB, _, _ := pg.registerPod(group, "merge-b", 10)
+	pg.qmux.Lock()
+	pg.capmgr.assignCapacity("merge-b", "job", 10)
+	pg.workloadCount["job"]++
+	pg.qmux.Unlock()
+
+	close(leaderB)
+
+	// worker A has spare capacity and takes on the share of B without a second start
+	rate, ok := receive(t, workerA).(Rate)
+	if !ok || rate.ID != "job" || rate.Frequency != 20 {
+		t.Fatalf("expected rate of 20/s, got %+v", rate)
+	}
+
+	reassign, ok := (<-events).(Reassign)
+	if !ok || reassign.Lost != "merge-b" || len(reassign.Instances) != 1 || reassign.Instances[0] != "merge-a" || reassign.Frequency != 10 {
+		t.Fatalf("unexpected reassign event %+v", reassign)
+	}
+
+	if workloads := pg.capmgr.getJobWorkloads("job"); workloads["merge-a"] != 20 {
+		t.Errorf("expected workload of 20 on worker A, got %v", workloads)
+	}
+
+	// the single workload of worker A finishes the job and frees all of its capacity
+	leaderA <- Finish{ID: "job"}
+
+	select {
+	case _, open := <-events:
+		if open {
+			t.Error("expected events of job to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected events of job to be closed")
+	}
+
+	if capacity := pg.capmgr.currentCapacity(); capacity != 20 {
+		t.Errorf("expected capacity of 20 to be reclaimed, got %d", capacity)
+	}
+
+	close(leaderA)
+}
+
+func TestPodGroup_ReregisteredWorker(t *testing.T) {
+	group := "reregister-group"
+	fp := &fakeProvisioner{capacity: 10, provisioned: make(chan InstanceID, 10)}
+	pg, err := NewPodGroup(group, fp, make(chan struct{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderA, workerA, _ := pg.registerPod(group, "reregister-a", 10)
+	leaderB, workerB, _ := pg.registerPod(group, "reregister-b", 10)
+
+	events := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "job", Frequency: 20, Duration: 60}, events)
+	receive(t, workerA)
+	receive(t, workerB)
+	if _, ok := (<-events).(Start); !ok {
+		t.Fatal("expected start event for job")
+	}
+
+	// worker A is lost and registers again, too small to take on its lost share
+	close(leaderA)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		pg.qmux.Lock()
+		requeued := len(pg.jobQueue) == 1
+		pg.qmux.Unlock()
+
+		if requeued {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("expected the lost share to be requeued")
+		}
+	}
+	leaderA, _, _ = pg.registerPod(group, "reregister-a", 5)
+
+	// reports on the workload of its previous registration are discarded
+	leaderA <- Metrics{ID: "job"}
+	leaderA <- Finish{ID: "job"}
+
+	select {
+	case e, open := <-events:
+		t.Errorf("expected no event of the previous registration, got %+v (open: %v)", e, open)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the workload of worker B and the lost share remain
+	pg.qmux.Lock()
+	count := pg.workloadCount["job"]
+	pg.qmux.Unlock()
+	if count != 2 {
+		t.Errorf("expected 2 remaining workloads, got %d", count)
+	}
+
+	close(leaderA)
+	close(leaderB)
+}
+
+func TestJobQueue_Order(t *testing.T) {
+	now := time.Now()
+	var jq jobQueue
+
+	jq.push(queuedJob{job: m.Job{ID: "low", Priority: 0}, enqueued: now})
+	jq.push(queuedJob{job: m.Job{ID: "high", Priority: 2}, enqueued: now})
+	jq.push(queuedJob{job: m.Job{ID: "old", Priority: 0}, enqueued: now.Add(-3 * priorityAging)})
+	jq.push(queuedJob{job: m.Job{ID: "lost", Priority: 0}, lost: "worker", enqueued: now})
+	jq.push(queuedJob{job: m.Job{ID: "same", Priority: 2}, enqueued: now})
+
+	expected := []m.JobID{"lost", "old", "high", "same", "low"}
+	for i, id := range expected {
+		if jq[i].job.ID != id {
+			t.Fatalf("expected %s at position %d, got %s", id, i, jq[i].job.ID)
+		}
+	}
+
+	if q := jq.remove(1); q.job.ID != "old" || len(jq) != 4 || jq[1].job.ID != "high" {
+		t.Errorf("unexpected queue after removal: %+v", jq)
+	}
+}
+
+func TestPodGroup_Backfill(t *testing.T) {
+	group := "backfill-group"
+	fp := &fakeProvisioner{capacity: 10, provisioned: make(chan InstanceID, 10)}
+	pg, err := NewPodGroup(group, fp, make(chan struct{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leader, worker, _ := pg.registerPod(group, "backfill-a", 10)
+
+	running := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "running", Frequency: 6, Duration: 60}, running)
+	receive(t, worker)
+
+	// the big job has to wait, the small one fits next to the running job
+	big := make(chan Event, 10)
+	small := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "big", Frequency: 10, Duration: 60, Priority: 1}, big)
+	pg.addJob(m.Job{ID: "small", Frequency: 4, Duration: 60}, small)
+
+	if start, ok := receive(t, worker).(Start); !ok || start.ID != "small" {
+		t.Fatalf("expected small job to be backfilled, got %+v", start)
+	}
+	if len(big) > 0 {
+		t.Fatalf("expected big job to wait, got %+v", <-big)
+	}
+
+	leader <- Finish{ID: "running"}
+	leader <- Finish{ID: "small"}
+
+	if start, ok := receive(t, worker).(Start); !ok || start.ID != "big" || start.Frequency != 10 {
+		t.Fatalf("expected big job to start once capacity is free, got %+v", start)
+	}
+
+	close(leader)
+}
+
+func TestPodGroup_Preempt(t *testing.T) {
+	group := "preempt-group"
+	fp := &fakeProvisioner{capacity: 10, provisioned: make(chan InstanceID, 10)}
+	pg, err := NewPodGroup(group, fp, make(chan struct{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg.preemption = true
+
+	leader, worker, _ := pg.registerPod(group, "preempt-a", 10)
+
+	low := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "low", Frequency: 10, Duration: 60}, low)
+	receive(t, worker)
+	<-low
+
+	high := make(chan Event, 10)
+	pg.addJob(m.Job{ID: "high", Frequency: 10, Duration: 60, Priority: 1}, high)
+
+	if stop, ok := receive(t, worker).(Stop); !ok || stop.ID != "low" {
+		t.Fatalf("expected low priority job to be stopped, got %+v", stop)
+	}
+	if preempt, ok := (<-low).(Preempt); !ok || preempt.By != "high" {
+		t.Fatalf("expected preempt event, got %+v", preempt)
+	}
+
+	// the stopped worker reports its finish, which frees the capacity
+	leader <- Finish{ID: "low"}
+	if start, ok := receive(t, worker).(Start); !ok || start.ID != "high" {
+		t.Fatalf("expected high priority job to start, got %+v", start)
+	}
+
+	leader <- Finish{ID: "high"}
+	start, ok := receive(t, worker).(Start)
+	if !ok || start.ID != "low" || start.Frequency != 10 || start.Duration == 0 || start.Duration > 60 {
+		t.Fatalf("expected low priority job to resume for its remaining duration, got %+v", start)
+	}
+	if resume, ok := (<-low).(Resume); !ok || resume.Frequency != 10 {
+		t.Fatalf("expected resume event, got %+v", resume)
+	}
+
+	leader <- Finish{ID: "low"}
+	select {
+	case _, open := <-low:
+		if open {
+			t.Error("expected events of low priority job to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected events of low priority job to be closed")
+	}
+
+	close(leader)
+}
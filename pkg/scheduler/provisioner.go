@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"fmt"
+
+	c "github.com/t-bfame/diago/config"
+)
+
+// Provisioner creates and removes the workers of a PodGroup. Provisioned
+// workers connect back to the leader over the Coordinate stream and
+// register with the group and instance they were provisioned for.
+type Provisioner interface {
+	// Provision starts a new worker for the given group and instance
+	Provision(group string, instance InstanceID) error
+
+	// Deprovision removes the worker of the given group and instance
+	Deprovision(group string, instance InstanceID) error
+
+	// Capacity returns the frequency a single worker of the group can handle
+	Capacity(group string) (uint64, error)
+
+	// Exists checks whether workers can be provisioned for the group
+	Exists(group string) bool
+
+	// Workers returns the instances of every group that are currently provisioned,
+	// including workers a previous leader provisioned where the backend can tell
+	Workers() (map[string][]InstanceID, error)
+}
+
+// Internal function used to create the provisioner selected by the config.
+func newProvisioner() (Provisioner, error) {
+	switch c.Diago.WorkerBackend {
+	case c.BackendLocal:
+		return NewLocalProvisioner(c.Diago), nil
+	case c.BackendKubernetes:
+		return NewKubernetesProvisioner()
+	}
+
+	return nil, fmt.Errorf("Unknown worker backend %s", c.Diago.WorkerBackend)
+}
+
+// Internal function used to build the env variables a worker is configured with.
+func workerEnv(group string, instance InstanceID, host string, port uint64, inactivity uint64, capacity uint64) map[string]string {
+	return map[string]string{
+		"DIAGO_WORKER_GROUP":                   group,
+		"DIAGO_WORKER_GROUP_INSTANCE":          string(instance),
+		"DIAGO_LEADER_HOST":                    host,
+		"DIAGO_LEADER_PORT":                    fmt.Sprintf("%d", port),
+		"ALLOWED_INACTIVITY_PERIOD_SECONDS":    fmt.Sprintf("%d", inactivity),
+		"DIAGO_WORKER_GROUP_INSTANCE_CAPACITY": fmt.Sprintf("%d", capacity),
+	}
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+)
+
+// priorityAging is how long a job waits in the queue to be ordered
+// like a job of the next higher priority, so that no job waits forever
+const priorityAging = time.Minute
+
+// queuedJob is a job waiting for capacity, the share of a running job
+// which was lost with its worker, or a job which was preempted
+type queuedJob struct {
+	job       m.Job
+	frequency uint64     // the capacity that needs to be reserved
+	lost      InstanceID // the worker the share was lost with, empty otherwise
+	resume    bool       // whether the job was preempted and resumes
+	enqueued  time.Time
+}
+
+// activeJob is a job which was distributed to workers
+type activeJob struct {
+	job      m.Job
+	started  time.Time
+	paused   time.Duration // time spent preempted
+	pausedAt time.Time     // set while preempted
+}
+
+// jobQueue holds queued jobs, in the order they should be started
+type jobQueue []queuedJob
+
+/**
+* Whether a queued job should be started before another one. Lost shares of running jobs come first,
+* then jobs by priority, where waiting for priorityAging counts as much as one level of priority.
+ */
+func (q queuedJob) before(other queuedJob) bool {
+	if (q.lost != "") != (other.lost != "") {
+		return q.lost != ""
+	}
+
+	return q.score() > other.score()
+}
+
+func (q queuedJob) score() float64 {
+	return float64(q.job.Priority) - float64(q.enqueued.UnixNano())/float64(priorityAging)
+}
+
+/**
+* Add a job to the queue, keeping the queue ordered
+*
+* @param  q  the queued job
+ */
+func (jq *jobQueue) push(q queuedJob) {
+	if q.enqueued.IsZero() {
+		q.enqueued = time.Now()
+	}
+
+	*jq = append(*jq, q)
+	sort.SliceStable(*jq, func(i, j int) bool {
+		return (*jq)[i].before((*jq)[j])
+	})
+}
+
+/**
+* Remove the job at the given position from the queue
+*
+* @param  i  the position of the job
+* @return  the removed job
+ */
+func (jq *jobQueue) remove(i int) queuedJob {
+	q := (*jq)[i]
+	*jq = append((*jq)[:i], (*jq)[i+1:]...)
+	return q
+}
+
+// elapsed returns the number of seconds the job has been running, not counting time it was preempted
+func (a activeJob) elapsed() uint64 {
+	running := time.Since(a.started) - a.paused
+	if !a.pausedAt.IsZero() {
+		running -= time.Since(a.pausedAt)
+	}
+
+	return uint64(running / time.Second)
+}
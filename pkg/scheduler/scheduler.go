@@ -3,19 +3,18 @@ package scheduler
 import (
 	"errors"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	c "github.com/t-bfame/diago/config"
 	m "github.com/t-bfame/diago/pkg/model"
-
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 )
 
 // Scheduler stores data belonging to a scheduler.
 type Scheduler struct {
-	clientset *kubernetes.Clientset
-	model     *SchedulerModel
-	podGroups map[string]*PodGroup
+	provisioner Provisioner
+	podGroups   map[string]*PodGroup
+	preemption  bool
 
 	pgmux sync.Mutex
 }
@@ -33,12 +32,14 @@ func (s *Scheduler) createPodGroup(groupName string, failNonExistentGroup bool)
 	defer s.pgmux.Unlock()
 
 	cleanupChannel := make(chan struct{})
-	pgroup, err := NewPodGroup(groupName, s.clientset, s.model, cleanupChannel, failNonExistentGroup)
+	pgroup, err := NewPodGroup(groupName, s.provisioner, cleanupChannel, failNonExistentGroup)
 
 	if failNonExistentGroup && err != nil {
 		return nil, err
 	}
 
+	pgroup.preemption = s.preemption
+
 	log.WithField("group", groupName).Debug("Group doesnt exist, creating a new group")
 	s.podGroups[groupName] = pgroup
 
@@ -76,6 +77,11 @@ func (s *Scheduler) Submit(j m.Job) (events chan Event, err error) {
 }
 
 // Stop stops a job in a Scheduler
+// GroupExists checks whether workers can be provisioned for the group
+func (s *Scheduler) GroupExists(group string) bool {
+	return s.provisioner.Exists(group)
+}
+
 func (s *Scheduler) Stop(j m.Job) (err error) {
 	groupName := j.Group
 	pg, ok := s.podGroups[groupName]
@@ -89,6 +95,18 @@ func (s *Scheduler) Stop(j m.Job) (err error) {
 	return nil
 }
 
+// Workers returns the number of workers the job is currently assigned to
+func (s *Scheduler) Workers(j m.Job) int {
+	s.pgmux.Lock()
+	pg, ok := s.podGroups[j.Group]
+	s.pgmux.Unlock()
+
+	if !ok {
+		return 0
+	}
+	return len(pg.capmgr.getJobWorkloads(j.ID))
+}
+
 // Register registers a WorkerGroup as a PopGroup to the Scheduler.
 func (s *Scheduler) Register(group string, instance InstanceID, frequency uint64) (chan Incoming, chan Outgoing, error) {
 	// If WorkerGroup does not exist while registration
@@ -100,29 +118,68 @@ func (s *Scheduler) Register(group string, instance InstanceID, frequency uint64
 	return pg.registerPod(group, instance, frequency)
 }
 
-// NewScheduler creates a new scheduler using in cluster config.
-func NewScheduler() *Scheduler {
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
+// ReconcileWorkers removes workers which were already provisioned when it was called, e.g. by a previous
+// leader, and have not registered with the Scheduler once the grace period has passed. Workers that
+// re-register within the grace period are adopted by their group and can be used for new jobs.
+func (s *Scheduler) ReconcileWorkers(grace time.Duration) error {
+	workers, err := s.provisioner.Workers()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
-	// creates the clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err.Error())
+	time.Sleep(grace)
+
+	for group, instances := range workers {
+		for _, instance := range instances {
+			if s.isRegistered(group, instance) {
+				continue
+			}
+
+			log.WithField("group", group).WithField("instance", instance).Warning("Removing worker which did not register with the leader")
+			if err := s.provisioner.Deprovision(group, instance); err != nil {
+				log.WithError(err).WithField("group", group).WithField("instance", instance).Error("Unable to remove orphaned worker")
+			}
+		}
 	}
 
-	s := new(Scheduler)
-	s.clientset = clientset
-	s.podGroups = make(map[string]*PodGroup)
+	return nil
+}
 
-	s.model, err = NewSchedulerModel(config)
+// Internal function used to check whether a worker is registered with its group
+func (s *Scheduler) isRegistered(group string, instance InstanceID) bool {
+	s.pgmux.Lock()
+	pg, ok := s.podGroups[group]
+	s.pgmux.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	pg.qmux.Lock()
+	defer pg.qmux.Unlock()
+
+	_, ok = pg.scheduledPods[instance]
+	return ok
+}
 
+// NewScheduler creates a new scheduler using the worker backend selected by the config.
+func NewScheduler() *Scheduler {
+	provisioner, err := newProvisioner()
 	if err != nil {
 		panic(err.Error())
 	}
 
+	s := NewSchedulerWithProvisioner(provisioner)
+	s.preemption = c.Diago.Preemption
+
+	return s
+}
+
+// NewSchedulerWithProvisioner creates a new scheduler which provisions workers using the given provisioner.
+func NewSchedulerWithProvisioner(provisioner Provisioner) *Scheduler {
+	s := new(Scheduler)
+	s.provisioner = provisioner
+	s.podGroups = make(map[string]*PodGroup)
+
 	return s
 }
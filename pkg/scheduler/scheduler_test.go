@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduler_ReconcileWorkers(t *testing.T) {
+	fp := &fakeProvisioner{
+		capacity:    10,
+		provisioned: make(chan InstanceID, 10),
+		workers: map[string][]InstanceID{
+			"reconcile-group":  {"reconcile-adopted", "reconcile-orphan"},
+			"reconcile-unused": {"reconcile-unowned"},
+		},
+	}
+	s := NewSchedulerWithProvisioner(fp)
+
+	done := make(chan error)
+	go func() {
+		done <- s.ReconcileWorkers(100 * time.Millisecond)
+	}()
+
+	// a worker of the previous leader registers again within the grace period
+	leader, _, err := s.Register("reconcile-group", "reconcile-adopted", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(leader)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	removed := map[InstanceID]bool{}
+	for _, instance := range fp.deprovisioned() {
+		removed[instance] = true
+	}
+
+	if removed["reconcile-adopted"] {
+		t.Errorf("expected re-registered worker to be adopted")
+	}
+	if !removed["reconcile-orphan"] || !removed["reconcile-unowned"] || len(removed) != 2 {
+		t.Errorf("expected unregistered workers to be removed, got %v", removed)
+	}
+}
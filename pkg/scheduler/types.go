@@ -37,6 +37,7 @@ type Metrics struct {
 	Latency   time.Duration
 	Error     string
 	Timestamp time.Time
+	Step      string
 }
 
 func (m Finish) getJobID() m.JobID {
@@ -66,6 +67,7 @@ func ProtoToIncoming(msg *worker.Message) (Incoming, error) {
 			Latency:   time.Duration(metrics.GetLatency()),
 			Error:     metrics.GetError(),
 			Timestamp: timestamp,
+			Step:      metrics.GetStep(),
 		}
 
 	case *worker.Message_Finish:
@@ -89,11 +91,39 @@ type Outgoing interface {
 
 // Start message
 type Start struct {
-	ID         m.JobID
-	Frequency  uint64
-	Duration   uint64
-	HTTPMethod string
-	HTTPUrl    string
+	ID            m.JobID
+	Frequency     uint64
+	Duration      uint64
+	HTTPMethod    string
+	HTTPUrl       string
+	HTTPHeaders   map[string]string
+	HTTPQuery     map[string]string
+	HTTPBody      []byte
+	HTTPTimeout   uint64
+	HTTPRedirects int
+	Scenario      []m.Step
+}
+
+// newStart creates a Start message for running the given job at the specified frequency
+func newStart(j m.Job, frequency uint64) Start {
+	body, err := j.RequestBody()
+	if err != nil {
+		log.Printf("Unable to decode body for job %s: %s", j.ID, err)
+	}
+
+	return Start{
+		ID:            j.ID,
+		Frequency:     frequency,
+		Duration:      j.Duration,
+		HTTPMethod:    j.HTTPMethod,
+		HTTPUrl:       j.HTTPUrl,
+		HTTPHeaders:   j.HTTPHeaders,
+		HTTPQuery:     j.HTTPQuery,
+		HTTPBody:      body,
+		HTTPTimeout:   j.HTTPTimeout,
+		HTTPRedirects: j.HTTPRedirects,
+		Scenario:      j.Scenario,
+	}
 }
 
 // Stop message
@@ -101,6 +131,47 @@ type Stop struct {
 	ID m.JobID
 }
 
+// Reassign event, the share of a job which was lost with a worker was assigned to other workers
+type Reassign struct {
+	ID        m.JobID
+	Lost      InstanceID
+	Instances []InstanceID
+	Frequency uint64
+	Remaining uint64
+}
+
+func (m Reassign) getJobID() m.JobID {
+	return m.ID
+}
+
+// Preempt event, a job was stopped to make room for a job of higher priority
+type Preempt struct {
+	ID m.JobID
+	By m.JobID
+}
+
+func (m Preempt) getJobID() m.JobID {
+	return m.ID
+}
+
+// Resume event, a preempted job was assigned to workers again
+type Resume struct {
+	ID        m.JobID
+	Instances []InstanceID
+	Frequency uint64
+	Remaining uint64
+}
+
+func (m Resume) getJobID() m.JobID {
+	return m.ID
+}
+
+// Rate message
+type Rate struct {
+	ID        m.JobID
+	Frequency uint64
+}
+
 func (m Start) getJobID() m.JobID {
 	return m.ID
 }
@@ -114,14 +185,57 @@ func (m Start) ToProto() *worker.Message {
 				Frequency: m.Frequency,
 				Duration:  m.Duration,
 				Request: &worker.HTTPRequest{
-					Method: m.HTTPMethod,
-					Url:    m.HTTPUrl,
+					Method:    m.HTTPMethod,
+					Url:       m.HTTPUrl,
+					Headers:   m.HTTPHeaders,
+					Query:     m.HTTPQuery,
+					Body:      m.HTTPBody,
+					Timeout:   m.HTTPTimeout,
+					Redirects: int32(m.HTTPRedirects),
 				},
+				Scenario: scenarioToProto(m.Scenario),
 			},
 		},
 	}
 }
 
+// Internal function used to convert the steps of a scenario to protobuf messages
+func scenarioToProto(steps []m.Step) []*worker.Step {
+	var scenario []*worker.Step
+
+	for _, s := range steps {
+		body, err := s.RequestBody()
+		if err != nil {
+			log.Printf("Unable to decode body for step %s: %s", s.Name, err)
+		}
+
+		var extract []*worker.Extraction
+		for _, e := range s.Extract {
+			extract = append(extract, &worker.Extraction{
+				Name:       e.Name,
+				Source:     string(e.Source),
+				Expression: e.Expression,
+			})
+		}
+
+		scenario = append(scenario, &worker.Step{
+			Name: s.Name,
+			Request: &worker.HTTPRequest{
+				Method:  s.HTTPMethod,
+				Url:     s.HTTPUrl,
+				Headers: s.HTTPHeaders,
+				Query:   s.HTTPQuery,
+				Body:    body,
+				Timeout: s.HTTPTimeout,
+			},
+			Extract:   extract,
+			ThinkTime: s.ThinkTime,
+		})
+	}
+
+	return scenario
+}
+
 func (m Stop) getJobID() m.JobID {
 	return m.ID
 }
@@ -136,3 +250,19 @@ func (m Stop) ToProto() *worker.Message {
 		},
 	}
 }
+
+func (m Rate) getJobID() m.JobID {
+	return m.ID
+}
+
+// ToProto convert Outgoing to protobuf messages
+func (m Rate) ToProto() *worker.Message {
+	return &worker.Message{
+		Payload: &worker.Message_Rate{
+			Rate: &worker.Rate{
+				JobId:     string(m.getJobID()),
+				Frequency: m.Frequency,
+			},
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/boltdb/bolt"
+	log "github.com/sirupsen/logrus"
+)
+
+// This is the boltDB bucket name for storing "model/AuditEntry", keyed by their ID in recording order.
+const AuditBucketName = "Audit"
+
+// AuditQuery selects the "model/AuditEntry" returned by GetAuditEntries.
+// Empty fields match every entry.
+type AuditQuery struct {
+	// Before only matches entries recorded before the entry with this ID
+	Before model.AuditID
+	// Limit is the maximum number of entries returned
+	Limit int
+
+	Actor  string
+	Action model.AuditAction
+	Target string
+	// Projects matches entries of any of these projects, see "model/ProjectName"
+	Projects []string
+
+	// Since and Until bound the Time of the entries, Until excluded
+	Since int64
+	Until int64
+}
+
+// Internal function used to check whether entry is selected by q, ignoring Before and Limit
+func (q *AuditQuery) matches(entry *model.AuditEntry) bool {
+	if (q.Actor != "" && entry.Actor != q.Actor) ||
+		(q.Action != "" && entry.Action != q.Action) ||
+		(q.Target != "" && entry.Target != q.Target) ||
+		(q.Since != 0 && entry.Time < q.Since) ||
+		(q.Until != 0 && entry.Time >= q.Until) {
+		return false
+	}
+	if q.Projects == nil {
+		return true
+	}
+	for _, project := range q.Projects {
+		if model.ProjectName(project) == model.ProjectName(entry.Project) {
+			return true
+		}
+	}
+	return false
+}
+
+// Initializes boltDB for "model/AuditEntry" storage.
+func initStorageAudit(db *bolt.DB) error {
+	return db.Update(createInitBucketFunc(AuditBucketName))
+}
+
+// Append a "model/AuditEntry" to the storage, assigning its ID.
+func (s *boltStore) AddAuditEntry(entry *model.AuditEntry) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AuditBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", AuditBucketName)
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = model.AuditID(id)
+		enc, err := encode(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode AuditEntry due to: %s", err)
+		}
+		return b.Put(auditKey(entry.ID), enc)
+	}); err != nil {
+		log.WithError(err).WithField("entry", entry).Error("Failed to add AuditEntry")
+		return err
+	}
+	return nil
+}
+
+// Retrieve the "model/AuditEntry" selected by query from the storage, the latest first.
+func (s *boltStore) GetAuditEntries(query AuditQuery) ([]*model.AuditEntry, error) {
+	var entries = make([]*model.AuditEntry, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(AuditBucketName)).Cursor()
+
+		// Seek finds the first entry at or after Before, if any
+		var k, v []byte
+		if query.Before == 0 {
+			k, v = c.Last()
+		} else if next, _ := c.Seek(auditKey(query.Before)); next == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && (query.Limit == 0 || len(entries) < query.Limit); k, v = c.Prev() {
+			var entry *model.AuditEntry
+			if err := decode(&entry, v); err != nil {
+				return fmt.Errorf("failed to decode AuditEntry due to: %s", err)
+			}
+			if query.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("query", query).Error("Failed to GetAuditEntries")
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Internal function used to generate the key of an entry, which sorts the entries by ID
+func auditKey(id model.AuditID) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAndGetAuditEntries(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	entries := []*model.AuditEntry{
+		{Time: 100, Action: model.AuditTestCreate, Actor: "alice", Target: string(testId1), Outcome: model.AuditSucceeded},
+		{Time: 200, Action: model.AuditTestStart, Actor: "alice", Target: string(testId1), Outcome: model.AuditSucceeded},
+		{Time: 300, Action: model.AuditTestStart, Actor: "bob", Target: "team-a:checkout", Project: "team-a", Outcome: model.AuditDenied},
+		{Time: 400, Action: model.AuditTestScheduleTrigger, Actor: string(testScheduleId1), Target: string(testId1), Outcome: model.AuditFailed},
+	}
+	for i, entry := range entries {
+		if err := AddAuditEntry(entry); err != nil {
+			t.Fatalf("Failed to add audit entry %d", i)
+		}
+		assert.Equal(t, model.AuditID(i+1), entry.ID)
+	}
+
+	all, err := GetAuditEntries(AuditQuery{})
+	if err != nil {
+		t.Fatal("Failed to get audit entries")
+	}
+	assert.Equal(t, []*model.AuditEntry{entries[3], entries[2], entries[1], entries[0]}, all)
+
+	tests := []struct {
+		name  string
+		query AuditQuery
+		ids   []model.AuditID
+	}{
+		{"page", AuditQuery{Limit: 2}, []model.AuditID{4, 3}},
+		{"next page", AuditQuery{Before: 3, Limit: 2}, []model.AuditID{2, 1}},
+		{"before the latest", AuditQuery{Before: 100}, []model.AuditID{4, 3, 2, 1}},
+		{"actor", AuditQuery{Actor: "alice"}, []model.AuditID{2, 1}},
+		{"action", AuditQuery{Action: model.AuditTestStart}, []model.AuditID{3, 2}},
+		{"target", AuditQuery{Target: "team-a:checkout"}, []model.AuditID{3}},
+		{"time", AuditQuery{Since: 200, Until: 400}, []model.AuditID{3, 2}},
+		{"default project", AuditQuery{Projects: []string{model.DefaultProject}}, []model.AuditID{4, 2, 1}},
+		{"no project", AuditQuery{Projects: []string{}}, []model.AuditID{}},
+		{"filtered page", AuditQuery{Actor: "alice", Limit: 1}, []model.AuditID{2}},
+	}
+	for _, tt := range tests {
+		entries, err := GetAuditEntries(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		ids := []model.AuditID{}
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		assert.Equal(t, tt.ids, ids, tt.name)
+	}
+}
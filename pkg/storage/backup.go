@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+)
+
+// BundleVersion is the version of the Bundle format written by Export,
+// version 2 added Projects.
+const BundleVersion = 2
+
+// Bundle is a portable copy of the Projects, Tests, their revisions, TestSchedules
+// and TestInstances of a storage, independent of its backend.
+type Bundle struct {
+	Version       int
+	CreatedAt     int64
+	Projects      []*model.Project
+	Tests         []*model.Test
+	TestRevisions []*model.Test
+	TestSchedules []*model.TestSchedule
+	TestInstances []*model.TestInstance
+}
+
+// bundleRecord is the JSON form of a Bundle, whose TestInstances are encoded
+// the way they are stored so their time series survive the round trip
+type bundleRecord struct {
+	Version       int
+	CreatedAt     int64
+	Projects      []*model.Project
+	Tests         []*model.Test
+	TestRevisions []*model.Test
+	TestSchedules []*model.TestSchedule
+	TestInstances []json.RawMessage
+}
+
+// MarshalJSON encodes the bundle including the time series of its TestInstances
+func (b *Bundle) MarshalJSON() ([]byte, error) {
+	record := bundleRecord{
+		Version:       b.Version,
+		CreatedAt:     b.CreatedAt,
+		Projects:      b.Projects,
+		Tests:         b.Tests,
+		TestRevisions: b.TestRevisions,
+		TestSchedules: b.TestSchedules,
+		TestInstances: make([]json.RawMessage, 0, len(b.TestInstances)),
+	}
+	for _, testInstance := range b.TestInstances {
+		enc, err := encodeTestInstance(testInstance)
+		if err != nil {
+			return nil, err
+		}
+		record.TestInstances = append(record.TestInstances, enc)
+	}
+	return json.Marshal(record)
+}
+
+// UnmarshalJSON decodes a bundle written by MarshalJSON
+func (b *Bundle) UnmarshalJSON(data []byte) error {
+	var record bundleRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	if record.Version > BundleVersion {
+		return fmt.Errorf("bundle version %d is newer than supported version %d", record.Version, BundleVersion)
+	}
+
+	b.Version = record.Version
+	b.CreatedAt = record.CreatedAt
+	b.Projects = record.Projects
+	b.Tests = record.Tests
+	b.TestRevisions = record.TestRevisions
+	b.TestSchedules = record.TestSchedules
+	b.TestInstances = make([]*model.TestInstance, 0, len(record.TestInstances))
+	for _, data := range record.TestInstances {
+		testInstance, err := decodeTestInstance(data)
+		if err != nil {
+			return err
+		}
+		b.TestInstances = append(b.TestInstances, testInstance)
+	}
+	return nil
+}
+
+// Write a consistent snapshot of the storage file to w, which can be
+// opened by the same backend or restored with ReadBundle and Import.
+func Snapshot(w io.Writer) error {
+	return store.Snapshot(w)
+}
+
+// Export the Projects, Tests, their revisions, TestSchedules and TestInstances of the storage as a Bundle.
+func Export() (*Bundle, error) {
+	return exportStore(store)
+}
+
+// Internal function used to copy the objects of a Store into a Bundle
+func exportStore(s Store) (*Bundle, error) {
+	projects, err := s.GetAllProjects()
+	if err != nil {
+		return nil, err
+	}
+	tests, err := s.GetAllTests()
+	if err != nil {
+		return nil, err
+	}
+	testRevisions, err := s.GetAllTestRevisions()
+	if err != nil {
+		return nil, err
+	}
+	testSchedules, err := s.GetAllTestSchedules()
+	if err != nil {
+		return nil, err
+	}
+	testInstances, err := s.GetAllTestInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bundle{
+		Version:       BundleVersion,
+		CreatedAt:     time.Now().Unix(),
+		Projects:      projects,
+		Tests:         tests,
+		TestRevisions: testRevisions,
+		TestSchedules: testSchedules,
+		TestInstances: testInstances,
+	}, nil
+}
+
+var (
+	// Every SQLite database file starts with this header
+	sqliteHeader = []byte("SQLite format 3\x00")
+	// Magic number of the meta page starting a boltDB file, following its 16 byte page header
+	boltMagic       = []byte{0xed, 0xda, 0x0c, 0xed}
+	boltMagicOffset = 16
+)
+
+// ReadBundle reads a boltDB or SQLite snapshot, or a JSON encoded Bundle, from r.
+// Snapshots written by an older version of Diago are migrated while they are read.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+
+	// A short header just fails to match the snapshots
+	header, _ := br.Peek(boltMagicOffset + len(boltMagic))
+
+	switch {
+	case bytes.HasPrefix(header, sqliteHeader):
+		return readSnapshot(br, NewSQLiteStore)
+	case len(header) > boltMagicOffset && bytes.HasPrefix(header[boltMagicOffset:], boltMagic):
+		return readSnapshot(br, NewBoltStore)
+	}
+
+	var bundle Bundle
+	if err := json.NewDecoder(br).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("expected a boltDB or SQLite snapshot, or a JSON bundle: %s", err)
+	}
+	return &bundle, nil
+}
+
+// Internal function used to export the objects of a snapshot, opened with open from a temporary copy
+func readSnapshot(r io.Reader, open func(path string) (Store, error)) (*Bundle, error) {
+	dir, err := ioutil.TempDir("", "diago-restore")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy snapshot due to: %s", err)
+	}
+
+	s, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot due to: %s", err)
+	}
+	defer s.Close()
+
+	return exportStore(s)
+}
+
+// ConflictPolicy decides what Import does with objects whose ID already exists in the storage.
+type ConflictPolicy string
+
+const (
+	// ConflictFail aborts the import before anything is written
+	ConflictFail ConflictPolicy = "fail"
+	// ConflictSkip keeps the existing objects
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing objects, except TestInstances which have not finished
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ParseConflictPolicy parses the name of a ConflictPolicy, ConflictFail if empty
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %s", name)
+}
+
+// ConflictError is returned by Import with ConflictFail when objects of the bundle already exist
+type ConflictError struct {
+	IDs []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("objects already exist: %s", strings.Join(e.IDs, ", "))
+}
+
+// ImportedIDs lists the IDs of the objects of a kind by what Import did with them
+type ImportedIDs struct {
+	Added    []string
+	Replaced []string
+	Skipped  []string
+}
+
+// Internal function used to record what was done with id
+func (ids *ImportedIDs) record(id string, exists bool, replace bool) {
+	switch {
+	case !exists:
+		ids.Added = append(ids.Added, id)
+	case replace:
+		ids.Replaced = append(ids.Replaced, id)
+	default:
+		ids.Skipped = append(ids.Skipped, id)
+	}
+}
+
+// ImportResult describes what Import did with the objects of a Bundle
+type ImportResult struct {
+	Projects      ImportedIDs
+	Tests         ImportedIDs
+	TestRevisions ImportedIDs
+	TestSchedules ImportedIDs
+	TestInstances ImportedIDs
+}
+
+// Import the objects of bundle into the storage, resolving existing IDs with policy.
+// A baseline of the bundle only replaces the baseline of its Test with ConflictOverwrite.
+// Revisions of Tests are never modified, existing revisions are skipped without conflict.
+// The import is not atomic, but with ConflictFail nothing is written if any ID exists.
+func Import(bundle *Bundle, policy ConflictPolicy) (*ImportResult, error) {
+	result := &ImportResult{
+		Projects:      ImportedIDs{[]string{}, []string{}, []string{}},
+		Tests:         ImportedIDs{[]string{}, []string{}, []string{}},
+		TestRevisions: ImportedIDs{[]string{}, []string{}, []string{}},
+		TestSchedules: ImportedIDs{[]string{}, []string{}, []string{}},
+		TestInstances: ImportedIDs{[]string{}, []string{}, []string{}},
+	}
+	conflicts := []string{}
+
+	projectExists := make([]bool, len(bundle.Projects))
+	for i, project := range bundle.Projects {
+		existing, err := GetProject(project.Name)
+		if err != nil {
+			return nil, err
+		}
+		if projectExists[i] = existing != nil; projectExists[i] {
+			conflicts = append(conflicts, fmt.Sprintf("Project<%s>", project.Name))
+		}
+	}
+
+	testExists := make([]bool, len(bundle.Tests))
+	for i, test := range bundle.Tests {
+		existing, err := GetTestByTestId(test.ID)
+		if err != nil {
+			return nil, err
+		}
+		if testExists[i] = existing != nil; testExists[i] {
+			conflicts = append(conflicts, fmt.Sprintf("Test<%s>", test.ID))
+		}
+	}
+
+	scheduleExists := make([]bool, len(bundle.TestSchedules))
+	for i, testSchedule := range bundle.TestSchedules {
+		existing, err := GetTestSchedule(testSchedule.ID)
+		if err != nil {
+			return nil, err
+		}
+		if scheduleExists[i] = existing != nil; scheduleExists[i] {
+			conflicts = append(conflicts, fmt.Sprintf("TestSchedule<%s>", testSchedule.ID))
+		}
+	}
+
+	// Instances which are still running are never replaced
+	instanceExists := make([]bool, len(bundle.TestInstances))
+	instanceReplaceable := make([]bool, len(bundle.TestInstances))
+	for i, testInstance := range bundle.TestInstances {
+		existing, err := GetTestInstance(testInstance.ID)
+		if err != nil {
+			return nil, err
+		}
+		if instanceExists[i] = existing != nil; instanceExists[i] {
+			instanceReplaceable[i] = existing.IsTerminal()
+			conflicts = append(conflicts, fmt.Sprintf("TestInstance<%s>", testInstance.ID))
+		}
+	}
+
+	if policy == ConflictFail && len(conflicts) > 0 {
+		return nil, &ConflictError{conflicts}
+	}
+	overwrite := policy == ConflictOverwrite
+
+	for i, project := range bundle.Projects {
+		if !projectExists[i] || overwrite {
+			if err := SaveProject(project); err != nil {
+				return result, err
+			}
+		}
+		result.Projects.record(project.Name, projectExists[i], overwrite)
+	}
+
+	for i, test := range bundle.Tests {
+		if !testExists[i] || overwrite {
+			if err := AddTest(test); err != nil {
+				return result, err
+			}
+		}
+		result.Tests.record(string(test.ID), testExists[i], overwrite)
+	}
+
+	for _, test := range bundle.TestRevisions {
+		existing, err := GetTestRevision(test.ID, test.Revision)
+		if err != nil {
+			return result, err
+		}
+		if existing == nil {
+			if err := AddTestRevision(test); err != nil {
+				return result, err
+			}
+		}
+		result.TestRevisions.record(fmt.Sprintf("%s@%d", test.ID, test.Revision), existing != nil, false)
+	}
+
+	for i, testSchedule := range bundle.TestSchedules {
+		if !scheduleExists[i] || overwrite {
+			if err := AddTestSchedule(testSchedule); err != nil {
+				return result, err
+			}
+		}
+		result.TestSchedules.record(string(testSchedule.ID), scheduleExists[i], overwrite)
+	}
+
+	baselines := []model.TestInstanceID{}
+	for i, testInstance := range bundle.TestInstances {
+		replace := overwrite && instanceReplaceable[i]
+		result.TestInstances.record(string(testInstance.ID), instanceExists[i], replace)
+		if instanceExists[i] && !replace {
+			continue
+		}
+
+		if testInstance.Baseline {
+			current, err := GetBaseline(testInstance.TestID)
+			if err != nil {
+				return result, err
+			}
+			if current != nil && current.ID != testInstance.ID {
+				if overwrite {
+					baselines = append(baselines, testInstance.ID)
+				}
+				copied := *testInstance
+				copied.Baseline = false
+				testInstance = &copied
+			}
+		}
+
+		if err := AddTestInstance(testInstance); err != nil {
+			return result, err
+		}
+	}
+
+	for _, testInstanceID := range baselines {
+		if err := SetBaseline(testInstanceID); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Internal function used to fill the test database with a test, its schedule and a finished instance with metrics
+func addBackupFixtures(t *testing.T) *model.TestInstance {
+	instance := &model.TestInstance{ID: testInstanceId1, TestID: testId1, Status: "done", Metrics: testMetrics(), Baseline: true}
+	if err := AddTest(test1); err != nil {
+		t.Fatal("Failed to add test 1")
+	}
+	if err := AddTestSchedule(testSchedule1); err != nil {
+		t.Fatal("Failed to add test schedule 1")
+	}
+	if err := AddTestInstance(instance); err != nil {
+		t.Fatal("Failed to add test instance 1")
+	}
+	return instance
+}
+
+// Internal function used to check a bundle holds the objects added by addBackupFixtures
+func assertBackupFixtures(t *testing.T, bundle *Bundle) {
+	assert.Equal(t, []*model.Test{test1}, bundle.Tests)
+	assert.Equal(t, []*model.TestSchedule{testSchedule1}, bundle.TestSchedules)
+	if assert.Equal(t, 1, len(bundle.TestInstances)) {
+		got := bundle.TestInstances[0].Metrics.(map[string]*metrics.Metrics)["job"]
+		assert.Equal(t, uint64(50), got.Requests)
+		assert.NotEmpty(t, got.TimeSeries.Buckets)
+		assert.True(t, bundle.TestInstances[0].Baseline)
+	}
+}
+
+func TestSnapshotAndReadBundle(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	addBackupFixtures(t)
+
+	var buf bytes.Buffer
+	if err := Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBackupFixtures(t, bundle)
+}
+
+func TestExportAndReadBundle(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	addBackupFixtures(t)
+
+	exported, err := Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ReadBundle(bytes.NewReader(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, BundleVersion, bundle.Version)
+	assertBackupFixtures(t, bundle)
+
+	if _, err := ReadBundle(strings.NewReader("not a backup")); err == nil {
+		t.Error("Expected reading an unknown format to fail")
+	}
+	if _, err := ReadBundle(strings.NewReader(`{"Version": 1000}`)); err == nil {
+		t.Error("Expected reading a newer bundle to fail")
+	}
+}
+
+func TestImport(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	instance := addBackupFixtures(t)
+	running := &model.TestInstance{ID: testInstanceId2, TestID: testId1, Status: "running"}
+	if err := AddTestInstance(running); err != nil {
+		t.Fatal("Failed to add test instance 2")
+	}
+
+	renamed := *test1
+	renamed.Name = "renamed"
+	finished := *running
+	finished.Status = "done"
+	bundle := &Bundle{
+		Tests:         []*model.Test{&renamed, test2},
+		TestSchedules: []*model.TestSchedule{testSchedule1},
+		TestInstances: []*model.TestInstance{instance, &finished},
+	}
+
+	// Nothing is written if any object exists
+	if _, err := Import(bundle, ConflictFail); err == nil {
+		t.Fatal("Expected import to fail on existing objects")
+	} else if conflict, ok := err.(*ConflictError); !ok || len(conflict.IDs) != 4 {
+		t.Errorf("Expected 4 conflicts, got %v", err)
+	}
+	if retrieved, _ := GetTestByTestId(testId2); retrieved != nil {
+		t.Error("Expected test 2 not to be imported")
+	}
+
+	result, err := Import(bundle, ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{string(testId2)}, result.Tests.Added)
+	assert.Equal(t, []string{string(testId1)}, result.Tests.Skipped)
+	assert.Equal(t, []string{string(testScheduleId1)}, result.TestSchedules.Skipped)
+	if retrieved, _ := GetTestByTestId(testId1); retrieved.Name != test1.Name {
+		t.Error("Expected test 1 to be kept")
+	}
+
+	result, err = Import(bundle, ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{string(testId1), string(testId2)}, result.Tests.Replaced)
+	assert.Equal(t, []string{string(testInstanceId1)}, result.TestInstances.Replaced)
+	assert.Equal(t, []string{string(testInstanceId2)}, result.TestInstances.Skipped)
+	if retrieved, _ := GetTestByTestId(testId1); retrieved.Name != renamed.Name {
+		t.Error("Expected test 1 to be replaced")
+	}
+	if retrieved, _ := GetTestInstance(testInstanceId2); retrieved.Status != "running" {
+		t.Error("Expected the running test instance to be kept")
+	}
+	if baseline, _ := GetBaseline(testId1); baseline == nil || baseline.ID != testInstanceId1 {
+		t.Errorf("Expected test instance 1 to stay the baseline, got %v", baseline)
+	}
+}
+
+func TestImportBaseline(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	current := &model.TestInstance{ID: testInstanceId2, TestID: testId1, Status: "done", Baseline: true}
+	if err := AddTestInstance(current); err != nil {
+		t.Fatal("Failed to add test instance 2")
+	}
+
+	imported := &model.TestInstance{ID: testInstanceId1, TestID: testId1, Status: "done", Baseline: true}
+	bundle := &Bundle{TestInstances: []*model.TestInstance{imported}}
+
+	// Merging keeps the current baseline
+	if _, err := Import(bundle, ConflictSkip); err != nil {
+		t.Fatal(err)
+	}
+	if baseline, _ := GetBaseline(testId1); baseline == nil || baseline.ID != testInstanceId2 {
+		t.Errorf("Expected test instance 2 to stay the baseline, got %v", baseline)
+	}
+
+	if _, err := Import(bundle, ConflictOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	if baseline, _ := GetBaseline(testId1); baseline == nil || baseline.ID != testInstanceId1 {
+		t.Errorf("Expected test instance 1 to become the baseline, got %v", baseline)
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/influxdata/tdigest"
+
+	"github.com/t-bfame/diago/pkg/metrics"
+	"github.com/t-bfame/diago/pkg/model"
+)
+
+// Objects are stored as JSON, which unlike gob keeps decoding records
+// whose fields were added, removed or reordered since they were written.
+
+// Internal function used to encode an object for storage
+func encode(obj interface{}) ([]byte, error) {
+	if testInstance, ok := obj.(*model.TestInstance); ok {
+		return encodeTestInstance(testInstance)
+	}
+	return json.Marshal(obj)
+}
+
+// Internal function used to decode a stored object into the pointer e
+func decode(e interface{}, data []byte) error {
+	if testInstance, ok := e.(**model.TestInstance); ok {
+		value, err := decodeTestInstance(data)
+		if err != nil {
+			return err
+		}
+		*testInstance = value
+		return nil
+	}
+	return json.Unmarshal(data, e)
+}
+
+// testInstanceRecord is the stored form of a "model/TestInstance", whose Metrics are an interface
+type testInstanceRecord struct {
+	model.TestInstance
+	Metrics map[string]*metricsRecord `json:"Metrics"`
+}
+
+// metricsRecord is the stored form of "metrics/Metrics", including the time series
+// which are not part of its JSON representation served by the API
+type metricsRecord struct {
+	*metrics.Metrics
+	Steps      map[string]*metricsRecord `json:"steps,omitempty"`
+	TimeSeries *timeSeriesRecord         `json:"timeseries,omitempty"`
+}
+
+type timeSeriesRecord struct {
+	*metrics.TimeSeries
+	Buckets []*bucketRecord `json:"buckets"`
+}
+
+// bucketRecord keeps the centroids of a bucket so it can still be merged once loaded
+type bucketRecord struct {
+	*metrics.Bucket
+	Centroids tdigest.CentroidList `json:"centroids"`
+}
+
+// Internal function used to encode a "model/TestInstance" for storage
+func encodeTestInstance(testInstance *model.TestInstance) ([]byte, error) {
+	record := testInstanceRecord{TestInstance: *testInstance}
+
+	switch ms := testInstance.Metrics.(type) {
+	case nil:
+	case map[string]*metrics.Metrics:
+		record.Metrics = make(map[string]*metricsRecord, len(ms))
+		for jobID, m := range ms {
+			record.Metrics[jobID] = toMetricsRecord(m)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported metrics of type %T", testInstance.Metrics)
+	}
+
+	return json.Marshal(record)
+}
+
+// Internal function used to decode a stored "model/TestInstance"
+func decodeTestInstance(data []byte) (*model.TestInstance, error) {
+	var record testInstanceRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	instance := record.TestInstance
+	if record.Metrics != nil {
+		ms := make(map[string]*metrics.Metrics, len(record.Metrics))
+		for jobID, m := range record.Metrics {
+			ms[jobID] = fromMetricsRecord(m)
+		}
+		instance.Metrics = ms
+	}
+	return &instance, nil
+}
+
+func toMetricsRecord(m *metrics.Metrics) *metricsRecord {
+	if m == nil {
+		return nil
+	}
+
+	record := &metricsRecord{Metrics: m}
+	if m.Steps != nil {
+		record.Steps = make(map[string]*metricsRecord, len(m.Steps))
+		for step, sm := range m.Steps {
+			record.Steps[step] = toMetricsRecord(sm)
+		}
+	}
+	if m.TimeSeries != nil {
+		record.TimeSeries = &timeSeriesRecord{TimeSeries: m.TimeSeries}
+		for _, b := range m.TimeSeries.Buckets {
+			record.TimeSeries.Buckets = append(record.TimeSeries.Buckets, &bucketRecord{b, b.Centroids})
+		}
+	}
+	return record
+}
+
+func fromMetricsRecord(record *metricsRecord) *metrics.Metrics {
+	if record == nil {
+		return nil
+	}
+
+	m := record.Metrics
+	if m == nil {
+		m = &metrics.Metrics{}
+	}
+	if record.Steps != nil {
+		m.Steps = make(map[string]*metrics.Metrics, len(record.Steps))
+		for step, sm := range record.Steps {
+			m.Steps[step] = fromMetricsRecord(sm)
+		}
+	}
+	if record.TimeSeries != nil {
+		ts := record.TimeSeries.TimeSeries
+		if ts == nil {
+			ts = &metrics.TimeSeries{}
+		}
+		ts.Buckets = make([]*metrics.Bucket, 0, len(record.TimeSeries.Buckets))
+		for _, br := range record.TimeSeries.Buckets {
+			b := br.Bucket
+			if b == nil {
+				b = &metrics.Bucket{}
+			}
+			b.Centroids = br.Centroids
+			ts.Buckets = append(ts.Buckets, b)
+		}
+		m.TimeSeries = ts
+	}
+	return m
+}
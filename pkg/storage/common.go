@@ -1,39 +1,117 @@
 // Package storage implements a storage API used to store Diago objects.
-// It uses boltDB as the underlying storage management.
+// Objects are stored by a Store, either boltDB or SQLite, selected with Init.
 package storage
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
+	"os"
+
+	"github.com/t-bfame/diago/config"
+	"github.com/t-bfame/diago/pkg/metrics"
+	"github.com/t-bfame/diago/pkg/model"
 
 	"github.com/boltdb/bolt"
 )
 
-var (
+// boltStore stores Diago objects JSON encoded in boltDB buckets, files of older schema versions are upgraded by boltMigrations
+type boltStore struct {
 	db *bolt.DB
-)
+}
+
+// Gob encoded records of storage written before schema version 1 hold these types as interfaces
+func init() {
+	gob.Register(map[string]*metrics.Metrics{})
+	gob.Register(map[model.ChaosID]model.ChaosResult{})
+}
 
-// Initializes storage file
+// Initializes storage file with the boltDB backend
 func InitDatabase(dbName string) error {
-	value, err := bolt.Open(dbName, 0600, nil)
+	return Init(config.StorageBolt, dbName)
+}
+
+// Initializes storage at path with the given backend, see config.StorageBolt and config.StorageSQLite.
+// The previously initialized storage is closed.
+func Init(backend string, path string) error {
+	// The previous storage may hold a lock on the same file
+	if store != nil {
+		store.Close()
+		store = nil
+	}
+
+	var (
+		value Store
+		err   error
+	)
+
+	switch backend {
+	case config.StorageBolt:
+		value, err = NewBoltStore(path)
+	case config.StorageSQLite:
+		value, err = NewSQLiteStore(path)
+	default:
+		err = fmt.Errorf("unknown storage backend %s", backend)
+	}
 	if err != nil {
 		return err
 	}
 
-	db = value
+	store = value
+	return nil
+}
 
-	if err := initStorageJob(db); err != nil {
-		return err
+// NewBoltStore opens the boltDB file at path, creating its buckets if they do not exist,
+// and migrates its records to the latest schema version.
+func NewBoltStore(path string) (Store, error) {
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
 	}
-	if err := initStorageTest(db); err != nil {
-		return err
+
+	for _, initStorage := range []func(db *bolt.DB) error{
+		initStorageMeta,
+		initStorageJob,
+		initStorageTest,
+		initStorageTestRevision,
+		initStorageTestInstance,
+		initStorageTestSchedule,
+		initStorageProject,
+		initStorageAudit,
+		initStorageWebhook,
+	} {
+		if err := initStorage(db); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
-	if err := initStorageTestInstance(db); err != nil {
-		return err
+
+	if err := migrateBolt(db, path, created); err != nil {
+		db.Close()
+		return nil, err
 	}
-	if err := initStorageTestSchedule(db); err != nil {
+	return &boltStore{db}, nil
+}
+
+// Initializes boltDB for storage metadata.
+func initStorageMeta(db *bolt.DB) error {
+	return db.Update(createInitBucketFunc(MetaBucketName))
+}
+
+// Write the boltDB file as seen by a read transaction to w
+func (s *boltStore) Snapshot(w io.Writer) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
 		return err
-	}
-	return nil
+	})
+}
+
+// Close the boltDB file
+func (s *boltStore) Close() error {
+	return s.db.Close()
 }
 
 // Internal helper function used to generate a function creating a bucket with given name
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/t-bfame/diago/pkg/model"
-	"github.com/t-bfame/diago/pkg/tools"
 
 	"github.com/boltdb/bolt"
 	log "github.com/sirupsen/logrus"
@@ -22,13 +21,13 @@ func initStorageJob(db *bolt.DB) error {
 }
 
 // Add a "model/Job" to the storage.
-func AddJob(job *model.Job) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) AddJob(job *model.Job) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(JobBucketName))
 		if b == nil {
 			return fmt.Errorf("missing bucket '%s'", JobBucketName)
 		}
-		enc, err := tools.GobEncode(job)
+		enc, err := encode(job)
 		if err != nil {
 			return fmt.Errorf("failed to encode Job due to: %s", err)
 		}
@@ -44,8 +43,8 @@ func AddJob(job *model.Job) error {
 }
 
 // Delete a "model/Job" with the specified JobID from the storage.
-func DeleteJob(jobID model.JobID) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteJob(jobID model.JobID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(JobBucketName))
 		if b == nil {
 			return fmt.Errorf("missing bucket '%s'", JobBucketName)
@@ -62,16 +61,16 @@ func DeleteJob(jobID model.JobID) error {
 }
 
 // Retrieve a "model/Job" with the specified JobID from the storage.
-func GetJobByJobId(jobId model.JobID) (*model.Job, error) {
+func (s *boltStore) GetJobByJobId(jobId model.JobID) (*model.Job, error) {
 	var result *model.Job
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		var err error
 		b := tx.Bucket([]byte(JobBucketName))
 		data := b.Get([]byte(jobId))
 		if data == nil {
 			return nil
 		}
-		if err = tools.GobDecode(&result, data); err != nil {
+		if err = decode(&result, data); err != nil {
 			return fmt.Errorf("failed to decode Job due to: %s", err)
 		}
 		return nil
@@ -82,15 +81,15 @@ func GetJobByJobId(jobId model.JobID) (*model.Job, error) {
 }
 
 // Retrieve all "model/Job" stored in the storage.
-func GetAllJobs() ([]*model.Job, error) {
+func (s *boltStore) GetAllJobs() ([]*model.Job, error) {
 	var jobs = make([]*model.Job, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(JobBucketName))
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var job *model.Job
-			if err := tools.GobDecode(&job, v); err != nil {
+			if err := decode(&job, v); err != nil {
 				return fmt.Errorf("failed to decode Job due to: %s", err)
 			}
 			jobs = append(jobs, job)
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/tools"
+
+	"github.com/boltdb/bolt"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// This is the boltDB bucket name for storing metadata of the storage, such as its schema version.
+	MetaBucketName = "Meta"
+	// This is the key of the schema version in the Meta bucket.
+	SchemaVersionKey = "SchemaVersion"
+)
+
+// boltMigrations upgrade the records of a boltDB file, migration i upgrades schema version i to i+1.
+// Files written before the schema was versioned are version 0, and have gob encoded records.
+// Applied migrations must never change, append a new migration instead.
+var boltMigrations = []func(tx *bolt.Tx) error{
+	migrateBoltGobToJSON,
+	migrateBoltProjectIndexes,
+}
+
+// Internal function used to apply the migrations the boltDB file at path has not seen yet,
+// the file is backed up next to path first unless it was just created.
+func migrateBolt(db *bolt.DB, path string, created bool) error {
+	var version int
+	if err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = doGetSchemaVersion(tx)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if version > len(boltMigrations) {
+		return fmt.Errorf("schema version %d is newer than supported version %d", version, len(boltMigrations))
+	}
+	if version == len(boltMigrations) {
+		return nil
+	}
+
+	if !created {
+		backup := backupPath(path, version)
+		if err := db.View(func(tx *bolt.Tx) error {
+			return tx.CopyFile(backup, 0600)
+		}); err != nil {
+			return fmt.Errorf("failed to back up storage due to: %s", err)
+		}
+		log.WithField("backup", backup).Info("Backed up storage before migrating it")
+	}
+
+	for ; version < len(boltMigrations); version++ {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			if err := boltMigrations[version](tx); err != nil {
+				return err
+			}
+			return doSetSchemaVersion(tx, version+1)
+		}); err != nil {
+			return fmt.Errorf("failed to migrate storage to schema version %d due to: %s", version+1, err)
+		}
+		log.WithField("version", version+1).Info("Migrated storage schema")
+	}
+	return nil
+}
+
+// Internal function used to retrieve the schema version using the provided boltDB transaction.
+func doGetSchemaVersion(tx *bolt.Tx) (int, error) {
+	b := tx.Bucket([]byte(MetaBucketName))
+	if b == nil {
+		return 0, fmt.Errorf("missing bucket '%s'", MetaBucketName)
+	}
+
+	data := b.Get([]byte(SchemaVersionKey))
+	if data == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(data))
+}
+
+// Internal function used to record the schema version using the provided boltDB transaction.
+func doSetSchemaVersion(tx *bolt.Tx, version int) error {
+	b := tx.Bucket([]byte(MetaBucketName))
+	if b == nil {
+		return fmt.Errorf("missing bucket '%s'", MetaBucketName)
+	}
+	return b.Put([]byte(SchemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+// Internal function used to re-encode the gob encoded records of every bucket.
+func migrateBoltGobToJSON(tx *bolt.Tx) error {
+	for _, bucketName := range []string{
+		JobBucketName,
+		TestBucketName,
+		TestInstanceBucketName,
+		IdxTestID2TestInstanceIDBucketName,
+		TestScheduleBucketName,
+		IdxTestID2TestScheduleIDBucketName,
+	} {
+		b := tx.Bucket([]byte(bucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", bucketName)
+		}
+
+		// Buckets must not be modified while iterating over them
+		records := map[string][]byte{}
+		if err := b.ForEach(func(k, v []byte) error {
+			enc, err := reencodeGob(bucketName, v)
+			if err != nil {
+				return fmt.Errorf("failed to migrate %s<%s> due to: %s", bucketName, k, err)
+			}
+			records[string(k)] = enc
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for k, enc := range records {
+			if err := b.Put([]byte(k), enc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Internal function used to index the Tests, TestInstances and TestSchedules by project,
+// which are all in the default project unless they were restored from a newer storage.
+func migrateBoltProjectIndexes(tx *bolt.Tx) error {
+	for _, bucket := range []struct {
+		name  string
+		index string
+	}{
+		{TestBucketName, IdxProject2TestIDBucketName},
+		{TestInstanceBucketName, IdxProject2TestInstanceIDBucketName},
+		{TestScheduleBucketName, IdxProject2TestScheduleIDBucketName},
+	} {
+		b := tx.Bucket([]byte(bucket.name))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", bucket.name)
+		}
+
+		// Only the project of each record is decoded
+		projects := map[string]string{}
+		if err := b.ForEach(func(k, v []byte) error {
+			var record struct{ Project string }
+			if err := decode(&record, v); err != nil {
+				return fmt.Errorf("failed to migrate %s<%s> due to: %s", bucket.name, k, err)
+			}
+			projects[string(k)] = record.Project
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for id, project := range projects {
+			if err := doAddProjectIndex(tx, bucket.index, project, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Types of the gob encoded records of each bucket
+var gobRecordTypes = map[string]reflect.Type{
+	JobBucketName:                      reflect.TypeOf(&model.Job{}),
+	TestBucketName:                     reflect.TypeOf(&model.Test{}),
+	TestInstanceBucketName:             reflect.TypeOf(&model.TestInstance{}),
+	IdxTestID2TestInstanceIDBucketName: reflect.TypeOf(&IdxTestID2TestInstanceID{}),
+	TestScheduleBucketName:             reflect.TypeOf(&model.TestSchedule{}),
+	IdxTestID2TestScheduleIDBucketName: reflect.TypeOf(&IdxTestID2TestScheduleID{}),
+}
+
+// Internal function used to decode a gob encoded record of the given bucket and encode it again.
+func reencodeGob(bucketName string, data []byte) ([]byte, error) {
+	t, ok := gobRecordTypes[bucketName]
+	if !ok {
+		return nil, fmt.Errorf("unknown bucket '%s'", bucketName)
+	}
+
+	value := reflect.New(t)
+	if err := tools.GobDecode(value.Interface(), data); err != nil {
+		return nil, err
+	}
+	return encode(value.Elem().Interface())
+}
+
+// Internal function used to name the backup of the storage at path before migrating it from version
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().UTC().Format("20060102T150405"))
+}
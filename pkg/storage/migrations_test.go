@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/t-bfame/diago/config"
+	"github.com/t-bfame/diago/pkg/metrics"
+	"github.com/t-bfame/diago/pkg/model"
+	"github.com/t-bfame/diago/pkg/scheduler"
+	"github.com/t-bfame/diago/pkg/tools"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+// Internal function used to create metrics of a job including its time series
+func testMetrics() map[string]*metrics.Metrics {
+	jm := metrics.NewMetricAggregator("test", "instance", "job")
+	for i := 0; i < 50; i++ {
+		jm.Add(&scheduler.Metrics{
+			Code:      200,
+			Timestamp: time.Unix(1618100600, 0).Add(time.Duration(i) * 100 * time.Millisecond),
+			Latency:   time.Duration(10+i%5) * time.Millisecond,
+		})
+	}
+	jm.Close()
+	return map[string]*metrics.Metrics{"job": jm}
+}
+
+// Internal function used to create the path of a database in a temporary directory, so that
+// the backups made while migrating it are removed with the directory
+func tempDBPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), testDBName)
+}
+
+// Internal function used to remove the backups made while migrating the database at path
+func removeBackups(t *testing.T, path string) []string {
+	backups, _ := filepath.Glob(path + ".v*.bak")
+	for _, backup := range backups {
+		if err := os.Remove(backup); err != nil {
+			t.Error(err)
+		}
+	}
+	return backups
+}
+
+func TestMetricsRoundTrip(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	instance := &model.TestInstance{ID: testInstanceId1, TestID: testId1, Metrics: testMetrics()}
+	if err := AddTestInstance(instance); err != nil {
+		t.Fatal("Failed to add test instance")
+	}
+
+	retrieved, err := GetTestInstance(testInstanceId1)
+	if err != nil {
+		t.Fatal("Failed to get test instance")
+	}
+
+	want := instance.Metrics.(map[string]*metrics.Metrics)["job"]
+	got, ok := retrieved.Metrics.(map[string]*metrics.Metrics)
+	if !ok {
+		t.Fatalf("Expected metrics of each job, got %T", retrieved.Metrics)
+	}
+	assert.Equal(t, want.Requests, got["job"].Requests)
+	assert.Equal(t, want.Latencies.P99, got["job"].Latencies.P99)
+	assert.Equal(t, len(want.TimeSeries.Buckets), len(got["job"].TimeSeries.Buckets))
+
+	// Stored buckets can still be merged
+	resampled := metrics.Resample(10*time.Second, got["job"].TimeSeries)
+	assert.Equal(t, 1, len(resampled.Buckets))
+	assert.Equal(t, uint64(50), resampled.Buckets[0].Requests)
+	assert.NotZero(t, resampled.Buckets[0].Latencies.P99)
+}
+
+func TestMigrateBoltGobToJSON(t *testing.T) {
+	path := tempDBPath(t)
+	defer Close()
+
+	// Storage written before the schema was versioned
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &model.TestInstance{ID: testInstanceId1, TestID: testId1, Status: "done", Metrics: testMetrics()}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for bucketName, records := range map[string]map[string]interface{}{
+			TestBucketName:         {string(testId1): test1},
+			TestInstanceBucketName: {string(testInstanceId1): legacy},
+			IdxTestID2TestInstanceIDBucketName: {string(testId1): &IdxTestID2TestInstanceID{
+				TestId:          testId1,
+				TestInstanceIds: map[model.TestInstanceID]bool{testInstanceId1: true},
+			}},
+		} {
+			b, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			if err != nil {
+				return err
+			}
+			for k, v := range records {
+				enc, err := tools.GobEncode(v)
+				if err != nil {
+					return err
+				}
+				if err := b.Put([]byte(k), enc); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := Init(testBackend, path); err != nil {
+		t.Fatal("Failed to init database")
+	}
+
+	retrievedTest, err := GetTestByTestId(testId1)
+	if err != nil {
+		t.Fatal("Failed to get migrated test")
+	}
+	assert.Equal(t, test1, retrievedTest)
+
+	instances, err := GetTestInstancesByTestID(testId1)
+	if err != nil || len(instances) != 1 {
+		t.Fatal("Failed to get migrated test instance")
+	}
+	got := instances[0].Metrics.(map[string]*metrics.Metrics)["job"]
+	assert.Equal(t, uint64(50), got.Requests)
+	assert.NotEmpty(t, got.TimeSeries.Buckets)
+
+	// Objects stored before projects are indexed in the default project
+	if tests, _ := GetTestsByProject(""); len(tests) != 1 {
+		t.Errorf("Expected test 1 in the default project, got %v", tests)
+	}
+	if instances, _ := GetTestInstancesByProject(""); len(instances) != 1 {
+		t.Errorf("Expected test instance 1 in the default project, got %v", instances)
+	}
+
+	if err := store.(*boltStore).db.View(func(tx *bolt.Tx) error {
+		version, err := doGetSchemaVersion(tx)
+		assert.Equal(t, len(boltMigrations), version)
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if backups := removeBackups(t, path); len(backups) != 1 {
+		t.Errorf("Expected a single backup, got %v", backups)
+	}
+
+	// Storage at the latest version is neither migrated nor backed up again
+	if err := Init(testBackend, path); err != nil {
+		t.Fatal("Failed to init database")
+	}
+	if backups := removeBackups(t, path); len(backups) != 0 {
+		t.Errorf("Expected no backup, got %v", backups)
+	}
+}
+
+func TestMigrateSQLiteGobToJSON(t *testing.T) {
+	testBackend = config.StorageSQLite
+	defer func() { testBackend = config.StorageBolt }()
+	path := tempDBPath(t)
+	defer Close()
+
+	// Database written by schema version 1, which stored objects gob encoded
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tools.GobEncode(testSchedule1)
+	if err := inTx(db, func(tx *sql.Tx) error {
+		if err := sqliteMigrations[0](tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO test_schedules (id, test_id, name, cron_spec, data) VALUES (?, ?, ?, ?, ?)`,
+			string(testScheduleId1), string(testId1), testSchedule1.Name, testSchedule1.CronSpec, enc,
+		); err != nil {
+			return err
+		}
+		return execAll(
+			`CREATE TABLE schema_version (version INTEGER NOT NULL)`,
+			`INSERT INTO schema_version (version) VALUES (1)`,
+		)(tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := Init(testBackend, path); err != nil {
+		t.Fatal("Failed to init database")
+	}
+
+	retrieved, err := GetTestSchedule(testScheduleId1)
+	if err != nil {
+		t.Fatal("Failed to get migrated test schedule")
+	}
+	assert.Equal(t, testSchedule1, retrieved)
+
+	if backups := removeBackups(t, path); len(backups) != 1 {
+		t.Errorf("Expected a single backup, got %v", backups)
+	}
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/boltdb/bolt"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// This is the boltDB bucket name for storing "model/Project".
+	ProjectBucketName = "Project"
+	// These are the boltDB bucket names for storing "IdxProject2ID" of each kind of object.
+	IdxProject2TestIDBucketName         = "TestProjectIdx"
+	IdxProject2TestInstanceIDBucketName = "TestInstanceProjectIdx"
+	IdxProject2TestScheduleIDBucketName = "TestScheduleProjectIdx"
+)
+
+// IdxProject2ID stores mapping from one project to the IDs of its objects of one kind.
+// Indexes are keyed by the name of the project, see "model/ProjectName".
+type IdxProject2ID struct {
+	Project string
+	IDs     map[string]bool
+}
+
+// Initializes boltDB for "model/Project" storage and the project indexes.
+func initStorageProject(db *bolt.DB) error {
+	for _, bucketName := range []string{
+		ProjectBucketName,
+		IdxProject2TestIDBucketName,
+		IdxProject2TestInstanceIDBucketName,
+		IdxProject2TestScheduleIDBucketName,
+	} {
+		if err := db.Update(createInitBucketFunc(bucketName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add or replace a "model/Project" in the storage.
+func (s *boltStore) SaveProject(project *model.Project) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ProjectBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", ProjectBucketName)
+		}
+		enc, err := encode(project)
+		if err != nil {
+			return fmt.Errorf("failed to encode Project due to: %s", err)
+		}
+		return b.Put([]byte(project.Name), enc)
+	}); err != nil {
+		log.WithError(err).WithField("project", project).Error("Failed to save Project")
+		return err
+	}
+	return nil
+}
+
+// Delete a "model/Project" with the specified name from the storage.
+func (s *boltStore) DeleteProject(name string) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ProjectBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", ProjectBucketName)
+		}
+		return b.Delete([]byte(model.ProjectName(name)))
+	}); err != nil {
+		log.WithError(err).WithField("project", name).Error("Failed to delete Project")
+		return err
+	}
+	return nil
+}
+
+// Retrieve a "model/Project" with the specified name from the storage.
+func (s *boltStore) GetProject(name string) (*model.Project, error) {
+	var result *model.Project
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(ProjectBucketName)).Get([]byte(model.ProjectName(name)))
+		if data == nil {
+			return nil
+		}
+		if err := decode(&result, data); err != nil {
+			return fmt.Errorf("failed to decode Project due to: %s", err)
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("project", name).Error("Failed to GetProject")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Retrieve all "model/Project" stored in the storage.
+func (s *boltStore) GetAllProjects() ([]*model.Project, error) {
+	var projects = make([]*model.Project, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(ProjectBucketName)).ForEach(func(k, v []byte) error {
+			var project *model.Project
+			if err := decode(&project, v); err != nil {
+				return fmt.Errorf("failed to decode Project due to: %s", err)
+			}
+			projects = append(projects, project)
+			return nil
+		})
+	}); err != nil {
+		log.WithError(err).Error("Failed to GetAllProjects")
+		return nil, err
+	}
+	return projects, nil
+}
+
+// Retrieve all "model/Test" of the specified project from the storage.
+func (s *boltStore) GetTestsByProject(project string) ([]*model.Test, error) {
+	var result = make([]*model.Test, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		index, err := doGetProjectIndex(tx, IdxProject2TestIDBucketName, project)
+		if err != nil || index == nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(TestBucketName))
+		for id := range index.IDs {
+			var test *model.Test
+			data := b.Get([]byte(id))
+			if data == nil {
+				continue
+			}
+			if err := decode(&test, data); err != nil {
+				return fmt.Errorf("failed to decode Test due to: %s", err)
+			}
+			result = append(result, test)
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("project", project).Error("Failed to GetTestsByProject")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Retrieve all "model/TestInstance" of the specified project from the storage.
+func (s *boltStore) GetTestInstancesByProject(project string) ([]*model.TestInstance, error) {
+	var result = make([]*model.TestInstance, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		index, err := doGetProjectIndex(tx, IdxProject2TestInstanceIDBucketName, project)
+		if err != nil || index == nil {
+			return err
+		}
+
+		ids := make(map[model.TestInstanceID]bool, len(index.IDs))
+		for id := range index.IDs {
+			ids[model.TestInstanceID(id)] = true
+		}
+		result, err = doGetTestInstancesByIDMap(tx, ids)
+		return err
+	}); err != nil {
+		log.WithError(err).WithField("project", project).Error("Failed to GetTestInstancesByProject")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Retrieve all "model/TestSchedule" of the specified project from the storage.
+func (s *boltStore) GetTestSchedulesByProject(project string) ([]*model.TestSchedule, error) {
+	var result = make([]*model.TestSchedule, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		index, err := doGetProjectIndex(tx, IdxProject2TestScheduleIDBucketName, project)
+		if err != nil || index == nil {
+			return err
+		}
+
+		ids := make(map[model.TestScheduleID]bool, len(index.IDs))
+		for id := range index.IDs {
+			ids[model.TestScheduleID(id)] = true
+		}
+		result, err = doGetTestSchedulesByIDMap(tx, ids)
+		return err
+	}); err != nil {
+		log.WithError(err).WithField("project", project).Error("Failed to GetTestSchedulesByProject")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Internal function used to retrieve the index of a project from the given bucket, nil if there is none.
+func doGetProjectIndex(tx *bolt.Tx, bucketName string, project string) (*IdxProject2ID, error) {
+	b := tx.Bucket([]byte(bucketName))
+	if b == nil {
+		return nil, fmt.Errorf("missing bucket '%s'", bucketName)
+	}
+
+	data := b.Get([]byte(model.ProjectName(project)))
+	if data == nil {
+		return nil, nil
+	}
+
+	var index *IdxProject2ID
+	if err := decode(&index, data); err != nil {
+		return nil, fmt.Errorf("failed to decode IdxProject2ID due to: %s", err)
+	}
+	return index, nil
+}
+
+// Internal function used to add id to the index of a project in the given bucket.
+func doAddProjectIndex(tx *bolt.Tx, bucketName string, project string, id string) error {
+	index, err := doGetProjectIndex(tx, bucketName, project)
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		index = &IdxProject2ID{
+			Project: model.ProjectName(project),
+			IDs:     make(map[string]bool),
+		}
+	}
+
+	index.IDs[id] = true
+	return doPutProjectIndex(tx, bucketName, index)
+}
+
+// Internal function used to remove id from the index of a project in the given bucket.
+func doRemoveProjectIndex(tx *bolt.Tx, bucketName string, project string, id string) error {
+	index, err := doGetProjectIndex(tx, bucketName, project)
+	if err != nil || index == nil {
+		return err
+	}
+
+	delete(index.IDs, id)
+	return doPutProjectIndex(tx, bucketName, index)
+}
+
+func doPutProjectIndex(tx *bolt.Tx, bucketName string, index *IdxProject2ID) error {
+	enc, err := encode(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode IdxProject2ID due to: %s", err)
+	}
+	return tx.Bucket([]byte(bucketName)).Put([]byte(index.Project), enc)
+}
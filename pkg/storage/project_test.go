@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var project1 = &model.Project{
+	Name:         "team-a",
+	MaxFrequency: 100,
+	Groups:       []string{"group"},
+}
+
+func TestSaveAndGetProject(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	if err := SaveProject(project1); err != nil {
+		t.Fatal("Failed to save project 1")
+	}
+
+	retrieved, err := GetProject(project1.Name)
+	if err != nil {
+		t.Fatal("Failed to get project 1")
+	}
+	assert.Equal(t, project1, retrieved)
+
+	projects, err := GetAllProjects()
+	if err != nil {
+		t.Fatal("Failed to get all projects")
+	}
+	assert.Equal(t, []*model.Project{project1}, projects)
+
+	if err := DeleteProject(project1.Name); err != nil {
+		t.Fatal("Failed to delete project 1")
+	}
+	if retrieved, err := GetProject(project1.Name); err != nil || retrieved != nil {
+		t.Errorf("Expected project 1 to be deleted, got %v", retrieved)
+	}
+}
+
+func TestGetByProject(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	scoped := &model.Test{Name: "checkout", Project: project1.Name, Jobs: []model.Job{*job1}}
+	scoped.AssignIDs()
+	instance := &model.TestInstance{ID: model.TestInstanceID(string(scoped.ID) + "-1"), TestID: scoped.ID, Project: scoped.Project}
+	schedule := &model.TestSchedule{Name: "nightly", Project: scoped.Project, TestID: scoped.ID, CronSpec: "random-spec"}
+	schedule.AssignID()
+
+	for _, err := range []error{
+		AddTest(test1),
+		AddTest(scoped),
+		AddTestInstance(testInstance1),
+		AddTestInstance(instance),
+		AddTestSchedule(testSchedule1),
+		AddTestSchedule(schedule),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests, err := GetTestsByProject(project1.Name)
+	if err != nil {
+		t.Fatal("Failed to get tests of project 1")
+	}
+	assert.Equal(t, []*model.Test{scoped}, tests)
+
+	// Objects without project are in the default project, whichever way it is named
+	for _, name := range []string{"", model.DefaultProject} {
+		if tests, _ := GetTestsByProject(name); len(tests) != 1 || tests[0].ID != testId1 {
+			t.Errorf("Expected test 1 in project %q, got %v", name, tests)
+		}
+		if instances, _ := GetTestInstancesByProject(name); len(instances) != 1 || instances[0].ID != testInstanceId1 {
+			t.Errorf("Expected test instance 1 in project %q, got %v", name, instances)
+		}
+		if schedules, _ := GetTestSchedulesByProject(name); len(schedules) != 1 || schedules[0].ID != testScheduleId1 {
+			t.Errorf("Expected test schedule 1 in project %q, got %v", name, schedules)
+		}
+	}
+
+	instances, err := GetTestInstancesByProject(project1.Name)
+	if err != nil {
+		t.Fatal("Failed to get test instances of project 1")
+	}
+	assert.Equal(t, []*model.TestInstance{instance}, instances)
+
+	schedules, err := GetTestSchedulesByProject(project1.Name)
+	if err != nil {
+		t.Fatal("Failed to get test schedules of project 1")
+	}
+	assert.Equal(t, []*model.TestSchedule{schedule}, schedules)
+
+	// Deleted objects leave their project
+	for _, err := range []error{
+		DeleteTest(scoped.ID),
+		DeleteTestInstance(instance.ID),
+		DeleteTestSchedule(schedule.ID),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tests, _ := GetTestsByProject(project1.Name); len(tests) != 0 {
+		t.Errorf("Expected no test in project 1, got %v", tests)
+	}
+	if instances, _ := GetTestInstancesByProject(project1.Name); len(instances) != 0 {
+		t.Errorf("Expected no test instance in project 1, got %v", instances)
+	}
+	if schedules, _ := GetTestSchedulesByProject(project1.Name); len(schedules) != 0 {
+		t.Errorf("Expected no test schedule in project 1, got %v", schedules)
+	}
+}
+
+func TestImportProjects(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	if err := SaveProject(project1); err != nil {
+		t.Fatal("Failed to save project 1")
+	}
+
+	raised := *project1
+	raised.MaxFrequency = 1000
+	other := &model.Project{Name: "team-b"}
+	bundle := &Bundle{Projects: []*model.Project{&raised, other}}
+
+	if _, err := Import(bundle, ConflictFail); err == nil {
+		t.Fatal("Expected import to fail on an existing project")
+	}
+
+	result, err := Import(bundle, ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{other.Name}, result.Projects.Added)
+	assert.Equal(t, []string{project1.Name}, result.Projects.Replaced)
+	if retrieved, _ := GetProject(project1.Name); retrieved.MaxFrequency != raised.MaxFrequency {
+		t.Error("Expected project 1 to be replaced")
+	}
+
+	exported, err := Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(exported.Projects))
+}
@@ -0,0 +1,986 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	log "github.com/sirupsen/logrus"
+	// registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations create and upgrade the SQLite schema, migration i upgrades schema version i to i+1.
+// Each migration is applied once in order and recorded in the schema_version table, so applied
+// migrations must never change. Objects are stored encoded in the data column, the other columns
+// can be queried.
+var sqliteMigrations = []func(tx *sql.Tx) error{
+	execAll(
+		`CREATE TABLE jobs (
+			id   TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			data BLOB NOT NULL
+		)`,
+		`CREATE TABLE tests (
+			id   TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			data BLOB NOT NULL
+		)`,
+		`CREATE TABLE test_instances (
+			id         TEXT PRIMARY KEY,
+			test_id    TEXT NOT NULL,
+			type       TEXT NOT NULL,
+			status     TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			verdict    TEXT NOT NULL,
+			baseline   INTEGER NOT NULL,
+			data       BLOB NOT NULL
+		)`,
+		`CREATE INDEX test_instances_test_id ON test_instances (test_id, created_at)`,
+		`CREATE TABLE test_schedules (
+			id        TEXT PRIMARY KEY,
+			test_id   TEXT NOT NULL,
+			name      TEXT NOT NULL,
+			cron_spec TEXT NOT NULL,
+			data      BLOB NOT NULL
+		)`,
+		`CREATE INDEX test_schedules_test_id ON test_schedules (test_id)`,
+	),
+	migrateSQLiteGobToJSON,
+	execAll(
+		`CREATE TABLE test_revisions (
+			test_id  TEXT NOT NULL,
+			revision INTEGER NOT NULL,
+			data     BLOB NOT NULL,
+			PRIMARY KEY (test_id, revision)
+		)`,
+	),
+	// Objects written before projects are in the default project, stored as an empty project
+	execAll(
+		`CREATE TABLE projects (
+			name TEXT PRIMARY KEY,
+			data BLOB NOT NULL
+		)`,
+		`ALTER TABLE tests ADD COLUMN project TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX tests_project ON tests (project)`,
+		`ALTER TABLE test_instances ADD COLUMN project TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX test_instances_project ON test_instances (project, created_at)`,
+		`ALTER TABLE test_schedules ADD COLUMN project TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX test_schedules_project ON test_schedules (project)`,
+	),
+	execAll(
+		`CREATE TABLE audit_log (
+			id      INTEGER PRIMARY KEY,
+			time    INTEGER NOT NULL,
+			actor   TEXT NOT NULL,
+			action  TEXT NOT NULL,
+			target  TEXT NOT NULL,
+			project TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			data    BLOB NOT NULL
+		)`,
+		`CREATE INDEX audit_log_time ON audit_log (time)`,
+	),
+	execAll(
+		`CREATE TABLE webhooks (
+			id      TEXT PRIMARY KEY,
+			project TEXT NOT NULL,
+			data    BLOB NOT NULL
+		)`,
+		`CREATE TABLE webhook_deliveries (
+			id          INTEGER PRIMARY KEY,
+			webhook_id  TEXT NOT NULL,
+			time        INTEGER NOT NULL,
+			instance_id TEXT NOT NULL,
+			delivered   INTEGER NOT NULL,
+			data        BLOB NOT NULL
+		)`,
+		`CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id)`,
+	),
+}
+
+// sqliteStore stores Diago objects in a SQLite database
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the SQLite database at path and migrates its schema to the latest version.
+// Databases which already hold a schema are backed up next to path before they are migrated.
+func NewSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, a single connection avoids busy errors within the leader
+	// while the timeout lets other processes reading the database wait for it
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure SQLite due to: %s", err)
+		}
+	}
+
+	if err := migrateSQLite(db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db}, nil
+}
+
+// Internal function used to apply the migrations the database has not seen yet
+func migrateSQLite(db *sql.DB, path string) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("SQLite schema version %d is newer than supported version %d", version, len(sqliteMigrations))
+	}
+
+	if version > 0 && version < len(sqliteMigrations) {
+		backup := backupPath(path, version)
+		if _, err := db.Exec(`VACUUM INTO ?`, backup); err != nil {
+			return fmt.Errorf("failed to back up SQLite database due to: %s", err)
+		}
+		log.WithField("backup", backup).Info("Backed up SQLite database before migrating it")
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		if err := inTx(db, func(tx *sql.Tx) error {
+			if err := sqliteMigrations[version](tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version+1)
+			return err
+		}); err != nil {
+			return fmt.Errorf("failed to migrate SQLite schema to version %d due to: %s", version+1, err)
+		}
+		log.WithField("version", version+1).Info("Migrated SQLite schema")
+	}
+	return nil
+}
+
+// Internal function used to create a migration executing the given statements
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Internal function used to re-encode the gob encoded objects of schema version 1
+func migrateSQLiteGobToJSON(tx *sql.Tx) error {
+	for _, table := range []struct {
+		name   string
+		bucket string
+	}{
+		{"jobs", JobBucketName},
+		{"tests", TestBucketName},
+		{"test_instances", TestInstanceBucketName},
+		{"test_schedules", TestScheduleBucketName},
+	} {
+		records := map[string][]byte{}
+		rows, err := tx.Query(fmt.Sprintf(`SELECT id, data FROM %s`, table.name))
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var (
+				id   string
+				data []byte
+			)
+			if err := rows.Scan(&id, &data); err != nil {
+				rows.Close()
+				return err
+			}
+			records[id] = data
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, data := range records {
+			enc, err := reencodeGob(table.bucket, data)
+			if err != nil {
+				return fmt.Errorf("failed to migrate %s<%s> due to: %s", table.bucket, id, err)
+			}
+			if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET data = ? WHERE id = ?`, table.name), enc, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Write a copy of the SQLite database to w, made with VACUUM INTO in a single transaction
+func (s *sqliteStore) Snapshot(w io.Writer) error {
+	dir, err := ioutil.TempDir("", "diago-snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return logged(err, log.Fields{}, "Snapshot")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Close the SQLite database
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Add a "model/Job" to the storage.
+func (s *sqliteStore) AddJob(job *model.Job) error {
+	enc, err := encode(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode Job due to: %s", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO jobs (id, name, data) VALUES (?, ?, ?)`,
+		string(job.ID), job.Name, enc,
+	)
+	return logged(err, log.Fields{"job": job}, "add Job")
+}
+
+// Delete a "model/Job" with the specified JobID from the storage.
+func (s *sqliteStore) DeleteJob(jobID model.JobID) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = ?`, string(jobID))
+	return logged(err, log.Fields{"jobID": jobID}, "delete Job")
+}
+
+// Retrieve a "model/Job" with the specified JobID from the storage.
+func (s *sqliteStore) GetJobByJobId(jobId model.JobID) (*model.Job, error) {
+	jobs, err := s.queryJobs(`SELECT data FROM jobs WHERE id = ?`, string(jobId))
+	if err := logged(err, log.Fields{"jobId": jobId}, "GetJobById"); err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// Retrieve all "model/Job" stored in the storage.
+func (s *sqliteStore) GetAllJobs() ([]*model.Job, error) {
+	jobs, err := s.queryJobs(`SELECT data FROM jobs ORDER BY id`)
+	return jobs, logged(err, nil, "GetAllJobs")
+}
+
+// Add a "model/Test" to the storage.
+func (s *sqliteStore) AddTest(test *model.Test) error {
+	enc, err := encode(test)
+	if err != nil {
+		return fmt.Errorf("failed to encode Test due to: %s", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO tests (id, name, project, data) VALUES (?, ?, ?, ?)`,
+		string(test.ID), test.Name, test.Project, enc,
+	)
+	return logged(err, log.Fields{"test": test}, "add Test")
+}
+
+// Delete a "model/Test" with the specified TestID from the storage.
+func (s *sqliteStore) DeleteTest(testID model.TestID) error {
+	_, err := s.db.Exec(`DELETE FROM tests WHERE id = ?`, string(testID))
+	return logged(err, log.Fields{"testID": testID}, "delete Test")
+}
+
+// Retrieve a "model/Test" with the specified TestID from the storage.
+func (s *sqliteStore) GetTestByTestId(testId model.TestID) (*model.Test, error) {
+	tests, err := queryTests(s.db, `SELECT data FROM tests WHERE id = ?`, string(testId))
+	if err := logged(err, log.Fields{"TestId": testId}, "GetTestByTestId"); err != nil || len(tests) == 0 {
+		return nil, err
+	}
+	return tests[0], nil
+}
+
+// Retrieve all "model/Test" stored in the storage.
+func (s *sqliteStore) GetAllTests() ([]*model.Test, error) {
+	tests, err := queryTests(s.db, `SELECT data FROM tests ORDER BY id`)
+	return tests, logged(err, nil, "GetAllTests")
+}
+
+// Retrieve all "model/Test" with the specified TestID prefix from the storage.
+func (s *sqliteStore) GetAllTestsWithPrefix(prefixStr string) ([]*model.Test, error) {
+	tests, err := queryTests(s.db,
+		`SELECT data FROM tests WHERE substr(id, 1, length(?1)) = ?1 ORDER BY id`,
+		prefixStr,
+	)
+	return tests, logged(err, nil, "GetAllTestsWithPrefix")
+}
+
+// Save a "model/Test" as its next revision if its current revision is expected, see AnyRevision.
+// The saved test is also added to the revisions of the Test, which are never modified.
+func (s *sqliteStore) SaveTest(test *model.Test, expected int) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		current, err := queryTests(tx, `SELECT data FROM tests WHERE id = ?`, string(test.ID))
+		if err != nil {
+			return err
+		}
+
+		revision := 0
+		if len(current) > 0 {
+			revision = current[0].Revision
+		}
+		if expected != AnyRevision && expected != revision {
+			return &RevisionConflictError{expected, revision}
+		}
+
+		// Revisions of a deleted Test are kept, so a Test created again continues after them
+		var latest int
+		if err := tx.QueryRow(
+			`SELECT COALESCE(MAX(revision), 0) FROM test_revisions WHERE test_id = ?`, string(test.ID),
+		).Scan(&latest); err != nil {
+			return err
+		}
+		if latest > revision {
+			revision = latest
+		}
+		test.Revision = revision + 1
+
+		enc, err := encode(test)
+		if err != nil {
+			return fmt.Errorf("failed to encode Test due to: %s", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO tests (id, name, project, data) VALUES (?, ?, ?, ?)`,
+			string(test.ID), test.Name, test.Project, enc,
+		); err != nil {
+			return err
+		}
+		return doAddSQLiteTestRevision(tx, test)
+	})
+	return logged(err, log.Fields{"test": test.ID}, "save Test")
+}
+
+// Add a revision of a "model/Test" to the storage, which fails if the revision exists.
+func (s *sqliteStore) AddTestRevision(test *model.Test) error {
+	return logged(doAddSQLiteTestRevision(s.db, test), log.Fields{"test": test.ID}, "add Test revision")
+}
+
+// Retrieve the revision of a "model/Test" with the specified TestID from the storage.
+func (s *sqliteStore) GetTestRevision(testID model.TestID, revision int) (*model.Test, error) {
+	tests, err := queryTests(s.db,
+		`SELECT data FROM test_revisions WHERE test_id = ? AND revision = ?`, string(testID), revision,
+	)
+	if err := logged(err, log.Fields{"testID": testID}, "GetTestRevision"); err != nil || len(tests) == 0 {
+		return nil, err
+	}
+	return tests[0], nil
+}
+
+// Retrieve all revisions of a "model/Test" with the specified TestID from the storage, oldest first.
+func (s *sqliteStore) GetTestRevisions(testID model.TestID) ([]*model.Test, error) {
+	tests, err := queryTests(s.db, `SELECT data FROM test_revisions WHERE test_id = ? ORDER BY revision`, string(testID))
+	return tests, logged(err, log.Fields{"testID": testID}, "GetTestRevisions")
+}
+
+// Retrieve the revisions of every "model/Test" stored in the storage, including deleted ones.
+func (s *sqliteStore) GetAllTestRevisions() ([]*model.Test, error) {
+	tests, err := queryTests(s.db, `SELECT data FROM test_revisions ORDER BY test_id, revision`)
+	return tests, logged(err, nil, "GetAllTestRevisions")
+}
+
+// Add a "model/TestInstance" to the storage.
+func (s *sqliteStore) AddTestInstance(testInstance *model.TestInstance) error {
+	err := doAddSQLiteTestInstance(s.db, testInstance)
+	return logged(err, log.Fields{"testInstance": testInstance}, "add TestInstance")
+}
+
+// Delete a "model/TestInstance" with the specified TestInstanceID from the storage.
+func (s *sqliteStore) DeleteTestInstance(testInstanceID model.TestInstanceID) error {
+	_, err := s.db.Exec(`DELETE FROM test_instances WHERE id = ?`, string(testInstanceID))
+	return logged(err, log.Fields{"testInstanceID": testInstanceID}, "delete TestInstance")
+}
+
+// Retrieve a "model/TestInstance" with the specified TestInstanceID from the storage.
+func (s *sqliteStore) GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance, error) {
+	instances, err := queryTestInstances(s.db, `SELECT data FROM test_instances WHERE id = ?`, string(testInstanceID))
+	if err := logged(err, log.Fields{"testInstanceID": testInstanceID}, "GetTestInstance"); err != nil || len(instances) == 0 {
+		return nil, err
+	}
+	return instances[0], nil
+}
+
+// Mark the "model/TestInstance" with the specified TestInstanceID as the baseline of its Test,
+// the previous baseline of the Test is unmarked.
+func (s *sqliteStore) SetBaseline(testInstanceID model.TestInstanceID) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		instances, err := queryTestInstances(tx, `SELECT data FROM test_instances WHERE id = ?`, string(testInstanceID))
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			return fmt.Errorf("TestInstance<%s> does not exist", testInstanceID)
+		}
+		instance := instances[0]
+
+		previous, err := queryTestInstances(
+			tx,
+			`SELECT data FROM test_instances WHERE test_id = ? AND baseline AND id != ?`,
+			string(instance.TestID), string(testInstanceID),
+		)
+		if err != nil {
+			return err
+		}
+		for _, other := range previous {
+			other.Baseline = false
+			if err := doAddSQLiteTestInstance(tx, other); err != nil {
+				return err
+			}
+		}
+
+		instance.Baseline = true
+		return doAddSQLiteTestInstance(tx, instance)
+	})
+	return logged(err, log.Fields{"testInstanceID": testInstanceID}, "SetBaseline")
+}
+
+// Retrieve the "model/TestInstance" marked as the baseline of the specified TestID, nil if there is none.
+func (s *sqliteStore) GetBaseline(testID model.TestID) (*model.TestInstance, error) {
+	instances, err := queryTestInstances(
+		s.db,
+		`SELECT data FROM test_instances WHERE test_id = ? AND baseline LIMIT 1`,
+		string(testID),
+	)
+	if err := logged(err, log.Fields{"testID": testID}, "GetBaseline"); err != nil || len(instances) == 0 {
+		return nil, err
+	}
+	return instances[0], nil
+}
+
+// Retrieve all "model/TestInstance" stored in the storage.
+func (s *sqliteStore) GetAllTestInstances() ([]*model.TestInstance, error) {
+	instances, err := queryTestInstances(s.db, `SELECT data FROM test_instances ORDER BY id`)
+	return instances, logged(err, nil, "GetAllTestInstances")
+}
+
+// Retrieve all "model/TestInstance" stored in the storage with only their identity, status and outcome,
+// which are read from their columns.
+func (s *sqliteStore) GetTestInstanceSummaries() ([]*model.TestInstance, error) {
+	instances, err := queryTestInstanceSummaries(s.db, `SELECT id, test_id, type, status, created_at, verdict, baseline FROM test_instances ORDER BY id`)
+	return instances, logged(err, nil, "GetTestInstanceSummaries")
+}
+
+// Retrieve an array of "model/TestInstance" with the specified array of TestInstanceID from the storage.
+func (s *sqliteStore) GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInstance, error) {
+	instances := make([]*model.TestInstance, 0)
+	for _, id := range testInstanceIDs {
+		instance, err := s.GetTestInstance(id)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+// Retrieve all "model/TestInstance" with specified TestID from the storage.
+func (s *sqliteStore) GetTestInstancesByTestID(testID model.TestID) ([]*model.TestInstance, error) {
+	instances, err := queryTestInstances(
+		s.db,
+		`SELECT data FROM test_instances WHERE test_id = ? ORDER BY created_at, id`,
+		string(testID),
+	)
+	return instances, logged(err, nil, "GetTestInstancesByTestID")
+}
+
+// Add a "model/TestSchedule" to the storage.
+func (s *sqliteStore) AddTestSchedule(testSchedule *model.TestSchedule) error {
+	enc, err := encode(testSchedule)
+	if err != nil {
+		return fmt.Errorf("failed to encode TestSchedule due to: %s", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO test_schedules (id, test_id, name, cron_spec, project, data) VALUES (?, ?, ?, ?, ?, ?)`,
+		string(testSchedule.ID), string(testSchedule.TestID), testSchedule.Name, testSchedule.CronSpec, testSchedule.Project, enc,
+	)
+	return logged(err, log.Fields{"testSchedule": testSchedule}, "add TestSchedule")
+}
+
+// Save a "model/TestSchedule" as its next revision if its current revision is expected, see AnyRevision.
+func (s *sqliteStore) SaveTestSchedule(testSchedule *model.TestSchedule, expected int) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		current, err := queryTestSchedules(tx, `SELECT data FROM test_schedules WHERE id = ?`, string(testSchedule.ID))
+		if err != nil {
+			return err
+		}
+
+		revision := 0
+		if len(current) > 0 {
+			revision = current[0].Revision
+		}
+		if expected != AnyRevision && expected != revision {
+			return &RevisionConflictError{expected, revision}
+		}
+		testSchedule.Revision = revision + 1
+
+		enc, err := encode(testSchedule)
+		if err != nil {
+			return fmt.Errorf("failed to encode TestSchedule due to: %s", err)
+		}
+		_, err = tx.Exec(
+			`INSERT OR REPLACE INTO test_schedules (id, test_id, name, cron_spec, project, data) VALUES (?, ?, ?, ?, ?, ?)`,
+			string(testSchedule.ID), string(testSchedule.TestID), testSchedule.Name, testSchedule.CronSpec, testSchedule.Project, enc,
+		)
+		return err
+	})
+	return logged(err, log.Fields{"testSchedule": testSchedule}, "save TestSchedule")
+}
+
+// Delete a "model/TestSchedule" with the specified TestScheduleID from the storage.
+func (s *sqliteStore) DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
+	_, err := s.db.Exec(`DELETE FROM test_schedules WHERE id = ?`, string(testScheduleID))
+	return logged(err, log.Fields{"testScheduleID": testScheduleID}, "delete TestSchedule")
+}
+
+// Retrieve a "model/TestSchedule" with the specified TestScheduleID from the storage.
+func (s *sqliteStore) GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule, error) {
+	schedules, err := queryTestSchedules(s.db, `SELECT data FROM test_schedules WHERE id = ?`, string(testScheduleID))
+	if err := logged(err, log.Fields{"testScheduleID": testScheduleID}, "GetTestSchedule"); err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return schedules[0], nil
+}
+
+// Retrieve all "model/TestSchedule" stored in the storage.
+func (s *sqliteStore) GetAllTestSchedules() ([]*model.TestSchedule, error) {
+	schedules, err := queryTestSchedules(s.db, `SELECT data FROM test_schedules ORDER BY id`)
+	return schedules, logged(err, nil, "GetAllTestSchedules")
+}
+
+// Retrieve an array of "model/TestSchedule" with the specified array of TestScheduleID from the storage.
+func (s *sqliteStore) GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSchedule, error) {
+	schedules := make([]*model.TestSchedule, 0)
+	for _, id := range testScheduleIDs {
+		schedule, err := s.GetTestSchedule(id)
+		if err != nil {
+			return nil, err
+		}
+		if schedule != nil {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+// Retrieve all "model/TestSchedule" with specified TestID from the storage.
+func (s *sqliteStore) GetTestSchedulesByTestID(testID model.TestID) ([]*model.TestSchedule, error) {
+	schedules, err := queryTestSchedules(s.db, `SELECT data FROM test_schedules WHERE test_id = ? ORDER BY id`, string(testID))
+	return schedules, logged(err, nil, "GetTestSchedulesByTestID")
+}
+
+// Add or replace a "model/Project" in the storage.
+func (s *sqliteStore) SaveProject(project *model.Project) error {
+	enc, err := encode(project)
+	if err != nil {
+		return fmt.Errorf("failed to encode Project due to: %s", err)
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO projects (name, data) VALUES (?, ?)`, project.Name, enc)
+	return logged(err, log.Fields{"project": project}, "save Project")
+}
+
+// Delete a "model/Project" with the specified name from the storage.
+func (s *sqliteStore) DeleteProject(name string) error {
+	_, err := s.db.Exec(`DELETE FROM projects WHERE name = ?`, model.ProjectName(name))
+	return logged(err, log.Fields{"project": name}, "delete Project")
+}
+
+// Retrieve a "model/Project" with the specified name from the storage.
+func (s *sqliteStore) GetProject(name string) (*model.Project, error) {
+	projects, err := s.queryProjects(`SELECT data FROM projects WHERE name = ?`, model.ProjectName(name))
+	if err := logged(err, log.Fields{"project": name}, "GetProject"); err != nil || len(projects) == 0 {
+		return nil, err
+	}
+	return projects[0], nil
+}
+
+// Retrieve all "model/Project" stored in the storage.
+func (s *sqliteStore) GetAllProjects() ([]*model.Project, error) {
+	projects, err := s.queryProjects(`SELECT data FROM projects ORDER BY name`)
+	return projects, logged(err, nil, "GetAllProjects")
+}
+
+// Retrieve all "model/Test" of the specified project from the storage.
+func (s *sqliteStore) GetTestsByProject(project string) ([]*model.Test, error) {
+	tests, err := queryTests(s.db, `SELECT data FROM tests WHERE project = ? ORDER BY id`, model.NormalizeProject(project))
+	return tests, logged(err, log.Fields{"project": project}, "GetTestsByProject")
+}
+
+// Retrieve all "model/TestInstance" of the specified project from the storage.
+func (s *sqliteStore) GetTestInstancesByProject(project string) ([]*model.TestInstance, error) {
+	instances, err := queryTestInstances(
+		s.db,
+		`SELECT data FROM test_instances WHERE project = ? ORDER BY created_at, id`,
+		model.NormalizeProject(project),
+	)
+	return instances, logged(err, log.Fields{"project": project}, "GetTestInstancesByProject")
+}
+
+// Retrieve all "model/TestSchedule" of the specified project from the storage.
+func (s *sqliteStore) GetTestSchedulesByProject(project string) ([]*model.TestSchedule, error) {
+	schedules, err := queryTestSchedules(
+		s.db,
+		`SELECT data FROM test_schedules WHERE project = ? ORDER BY id`,
+		model.NormalizeProject(project),
+	)
+	return schedules, logged(err, log.Fields{"project": project}, "GetTestSchedulesByProject")
+}
+
+// Append a "model/AuditEntry" to the storage, assigning its ID.
+func (s *sqliteStore) AddAuditEntry(entry *model.AuditEntry) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		var last int64
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM audit_log`).Scan(&last); err != nil {
+			return err
+		}
+		entry.ID = model.AuditID(last + 1)
+
+		enc, err := encode(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode AuditEntry due to: %s", err)
+		}
+		_, err = tx.Exec(
+			`INSERT INTO audit_log (id, time, actor, action, target, project, outcome, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			int64(entry.ID), entry.Time, entry.Actor, string(entry.Action), entry.Target,
+			model.NormalizeProject(entry.Project), string(entry.Outcome), enc,
+		)
+		return err
+	})
+	return logged(err, log.Fields{"entry": entry}, "add AuditEntry")
+}
+
+// Retrieve the "model/AuditEntry" selected by query from the storage, the latest first.
+func (s *sqliteStore) GetAuditEntries(query AuditQuery) ([]*model.AuditEntry, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	for _, condition := range []struct {
+		clause string
+		value  interface{}
+		set    bool
+	}{
+		{"id < ?", int64(query.Before), query.Before != 0},
+		{"actor = ?", query.Actor, query.Actor != ""},
+		{"action = ?", string(query.Action), query.Action != ""},
+		{"target = ?", query.Target, query.Target != ""},
+		{"time >= ?", query.Since, query.Since != 0},
+		{"time < ?", query.Until, query.Until != 0},
+	} {
+		if condition.set {
+			conditions = append(conditions, condition.clause)
+			args = append(args, condition.value)
+		}
+	}
+	if query.Projects != nil {
+		placeholders := []string{}
+		for _, project := range query.Projects {
+			placeholders = append(placeholders, "?")
+			args = append(args, model.NormalizeProject(project))
+		}
+		// No project matches an empty list
+		placeholders = append(placeholders, "NULL")
+		conditions = append(conditions, "project IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	statement := `SELECT data FROM audit_log`
+	if len(conditions) > 0 {
+		statement += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	statement += ` ORDER BY id DESC`
+	if query.Limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	entries := make([]*model.AuditEntry, 0)
+	err := queryData(s.db, statement, args, func(data []byte) error {
+		var entry *model.AuditEntry
+		if err := decode(&entry, data); err != nil {
+			return fmt.Errorf("failed to decode AuditEntry due to: %s", err)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, logged(err, log.Fields{"query": query}, "GetAuditEntries")
+}
+
+// Add or replace a "model/Webhook" in the storage.
+func (s *sqliteStore) SaveWebhook(webhook *model.Webhook) error {
+	enc, err := encode(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to encode Webhook due to: %s", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO webhooks (id, project, data) VALUES (?, ?, ?)`,
+		string(webhook.ID), model.NormalizeProject(webhook.Project), enc,
+	)
+	return logged(err, log.Fields{"webhookID": webhook.ID}, "save Webhook")
+}
+
+// Delete a "model/Webhook" with the specified WebhookID from the storage.
+func (s *sqliteStore) DeleteWebhook(webhookID model.WebhookID) error {
+	_, err := s.db.Exec(`DELETE FROM webhooks WHERE id = ?`, string(webhookID))
+	return logged(err, log.Fields{"webhookID": webhookID}, "delete Webhook")
+}
+
+// Retrieve a "model/Webhook" with the specified WebhookID from the storage.
+func (s *sqliteStore) GetWebhook(webhookID model.WebhookID) (*model.Webhook, error) {
+	webhooks, err := s.queryWebhooks(`SELECT data FROM webhooks WHERE id = ?`, string(webhookID))
+	if err := logged(err, log.Fields{"webhookID": webhookID}, "GetWebhook"); err != nil || len(webhooks) == 0 {
+		return nil, err
+	}
+	return webhooks[0], nil
+}
+
+// Retrieve all "model/Webhook" stored in the storage.
+func (s *sqliteStore) GetAllWebhooks() ([]*model.Webhook, error) {
+	webhooks, err := s.queryWebhooks(`SELECT data FROM webhooks ORDER BY id`)
+	return webhooks, logged(err, nil, "GetAllWebhooks")
+}
+
+// Append a "model/WebhookDelivery" to the storage, assigning its ID.
+func (s *sqliteStore) AddWebhookDelivery(delivery *model.WebhookDelivery) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		var last int64
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM webhook_deliveries`).Scan(&last); err != nil {
+			return err
+		}
+		delivery.ID = model.WebhookDeliveryID(last + 1)
+
+		enc, err := encode(delivery)
+		if err != nil {
+			return fmt.Errorf("failed to encode WebhookDelivery due to: %s", err)
+		}
+		_, err = tx.Exec(
+			`INSERT INTO webhook_deliveries (id, webhook_id, time, instance_id, delivered, data) VALUES (?, ?, ?, ?, ?, ?)`,
+			int64(delivery.ID), string(delivery.WebhookID), delivery.Time, string(delivery.InstanceID), delivery.Delivered, enc,
+		)
+		return err
+	})
+	return logged(err, log.Fields{"delivery": delivery}, "add WebhookDelivery")
+}
+
+// Retrieve the latest "model/WebhookDelivery" of the specified WebhookID from the storage, the latest first.
+func (s *sqliteStore) GetWebhookDeliveries(webhookID model.WebhookID, limit int) ([]*model.WebhookDelivery, error) {
+	statement := `SELECT data FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC`
+	args := []interface{}{string(webhookID)}
+	if limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0)
+	err := queryData(s.db, statement, args, func(data []byte) error {
+		var delivery *model.WebhookDelivery
+		if err := decode(&delivery, data); err != nil {
+			return fmt.Errorf("failed to decode WebhookDelivery due to: %s", err)
+		}
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	return deliveries, logged(err, log.Fields{"webhookID": webhookID}, "GetWebhookDeliveries")
+}
+
+// sqlExecutor is implemented by both *sql.DB and *sql.Tx
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Internal function used to add a "model/TestInstance" using the provided database or transaction.
+func doAddSQLiteTestInstance(db sqlExecutor, testInstance *model.TestInstance) error {
+	enc, err := encode(testInstance)
+	if err != nil {
+		return fmt.Errorf("failed to encode TestInstance due to: %s", err)
+	}
+	_, err = db.Exec(
+		`INSERT OR REPLACE INTO test_instances (id, test_id, type, status, created_at, verdict, baseline, project, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		string(testInstance.ID), string(testInstance.TestID), testInstance.Type, testInstance.Status,
+		testInstance.CreatedAt, string(testInstance.Verdict), testInstance.Baseline, testInstance.Project, enc,
+	)
+	return err
+}
+
+// Internal function used to add a revision of a "model/Test" using the provided database or transaction.
+func doAddSQLiteTestRevision(db sqlExecutor, test *model.Test) error {
+	enc, err := encode(test)
+	if err != nil {
+		return fmt.Errorf("failed to encode Test due to: %s", err)
+	}
+	// Revisions are never replaced, the primary key fails the insert instead
+	_, err = db.Exec(
+		`INSERT INTO test_revisions (test_id, revision, data) VALUES (?, ?, ?)`,
+		string(test.ID), test.Revision, enc,
+	)
+	return err
+}
+
+func (s *sqliteStore) queryJobs(query string, args ...interface{}) ([]*model.Job, error) {
+	jobs := make([]*model.Job, 0)
+	err := queryData(s.db, query, args, func(data []byte) error {
+		var job *model.Job
+		if err := decode(&job, data); err != nil {
+			return fmt.Errorf("failed to decode Job due to: %s", err)
+		}
+		jobs = append(jobs, job)
+		return nil
+	})
+	return jobs, err
+}
+
+func (s *sqliteStore) queryProjects(query string, args ...interface{}) ([]*model.Project, error) {
+	projects := make([]*model.Project, 0)
+	err := queryData(s.db, query, args, func(data []byte) error {
+		var project *model.Project
+		if err := decode(&project, data); err != nil {
+			return fmt.Errorf("failed to decode Project due to: %s", err)
+		}
+		projects = append(projects, project)
+		return nil
+	})
+	return projects, err
+}
+
+func (s *sqliteStore) queryWebhooks(query string, args ...interface{}) ([]*model.Webhook, error) {
+	webhooks := make([]*model.Webhook, 0)
+	err := queryData(s.db, query, args, func(data []byte) error {
+		var webhook *model.Webhook
+		if err := decode(&webhook, data); err != nil {
+			return fmt.Errorf("failed to decode Webhook due to: %s", err)
+		}
+		webhooks = append(webhooks, webhook)
+		return nil
+	})
+	return webhooks, err
+}
+
+func queryTests(db sqlExecutor, query string, args ...interface{}) ([]*model.Test, error) {
+	tests := make([]*model.Test, 0)
+	err := queryData(db, query, args, func(data []byte) error {
+		var test *model.Test
+		if err := decode(&test, data); err != nil {
+			return fmt.Errorf("failed to decode Test due to: %s", err)
+		}
+		tests = append(tests, test)
+		return nil
+	})
+	return tests, err
+}
+
+func queryTestInstances(db sqlExecutor, query string, args ...interface{}) ([]*model.TestInstance, error) {
+	instances := make([]*model.TestInstance, 0)
+	err := queryData(db, query, args, func(data []byte) error {
+		var instance *model.TestInstance
+		if err := decode(&instance, data); err != nil {
+			return fmt.Errorf("failed to decode TestInstance due to: %s", err)
+		}
+		instances = append(instances, instance)
+		return nil
+	})
+	return instances, err
+}
+
+// Internal function used to build a "model/TestInstance" from the summary columns of every row a query returns
+func queryTestInstanceSummaries(db sqlExecutor, query string, args ...interface{}) ([]*model.TestInstance, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := make([]*model.TestInstance, 0)
+	for rows.Next() {
+		var id, testID, verdict string
+		instance := &model.TestInstance{}
+		if err := rows.Scan(&id, &testID, &instance.Type, &instance.Status, &instance.CreatedAt, &verdict, &instance.Baseline); err != nil {
+			return nil, err
+		}
+		instance.ID = model.TestInstanceID(id)
+		instance.TestID = model.TestID(testID)
+		instance.Verdict = model.Verdict(verdict)
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+func queryTestSchedules(db sqlExecutor, query string, args ...interface{}) ([]*model.TestSchedule, error) {
+	schedules := make([]*model.TestSchedule, 0)
+	err := queryData(db, query, args, func(data []byte) error {
+		var schedule *model.TestSchedule
+		if err := decode(&schedule, data); err != nil {
+			return fmt.Errorf("failed to decode TestSchedule due to: %s", err)
+		}
+		schedules = append(schedules, schedule)
+		return nil
+	})
+	return schedules, err
+}
+
+// Internal function used to call decode with the data column of every row a query returns
+func queryData(db sqlExecutor, query string, args []interface{}, decode func(data []byte) error) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := decode(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Internal function used to run f in a transaction, which is rolled back if f fails
+func inTx(db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Internal function used to log a failed action with the given fields
+func logged(err error, fields log.Fields, action string) error {
+	if err != nil {
+		log.WithError(err).WithFields(fields).Error("Failed to " + action)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/t-bfame/diago/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The storage tests run against SQLite as well, as both backends must behave the same
+func TestSQLiteStore(t *testing.T) {
+	testBackend = config.StorageSQLite
+	defer func() { testBackend = config.StorageBolt }()
+
+	for name, test := range map[string]func(t *testing.T){
+		"AddAndGetJob":                       TestAddAndGetJob,
+		"AddAndGetJobWithRequest":            TestAddAndGetJobWithRequest,
+		"AddAndGetAllJobs":                   TestAddAndGetAllJobs,
+		"AddAndDeleteJob":                    TestAddAndDeleteJob,
+		"AddAndGetTest":                      TestAddAndGetTest,
+		"AddAndGetAllTests":                  TestAddAndGetAllTests,
+		"AddAndGetAllTestsWithPrefix":        TestAddAndGetAllTestsWithPrefix,
+		"GetAllTestsWithPrefixDoesNotReturn": TestGetAllTestsWithPrefixDoesNotReturn,
+		"AddAndDeleteTest":                   TestAddAndDeleteTest,
+		"AddAndGetTestInstance":              TestAddAndGetTestInstance,
+		"AddAndGetAllTestInstances":          TestAddAndGetAllTestInstances,
+		"AddAndGetTestInstanceSummaries":     TestAddAndGetTestInstanceSummaries,
+		"AddAndGetTestInstances":             TestAddAndGetTestInstances,
+		"AddAndGetTestInstancesByTestID":     TestAddAndGetTestInstancesByTestID,
+		"AddAndDeleteTestInstance":           TestAddAndDeleteTestInstance,
+		"SetAndGetBaseline":                  TestSetAndGetBaseline,
+		"AddAndGetTestSchedule":              TestAddAndGetTestSchedule,
+		"AddAndGetAllTestSchedules":          TestAddAndGetAllTestSchedules,
+		"AddAndGetTestSchedules":             TestAddAndGetTestSchedules,
+		"AddAndGetTestSchedulesByTestID":     TestAddAndGetTestSchedulesByTestID,
+		"AddAndDeleteTestSchedule":           TestAddAndDeleteTestSchedule,
+		"SaveTestAndGetRevisions":            TestSaveTestAndGetRevisions,
+		"SaveTestSchedule":                   TestSaveTestSchedule,
+		"SnapshotAndReadBundle":              TestSnapshotAndReadBundle,
+		"ExportAndReadBundle":                TestExportAndReadBundle,
+		"Import":                             TestImport,
+		"ImportBaseline":                     TestImportBaseline,
+		"SaveAndGetProject":                  TestSaveAndGetProject,
+		"GetByProject":                       TestGetByProject,
+		"ImportProjects":                     TestImportProjects,
+		"AddAndGetAuditEntries":              TestAddAndGetAuditEntries,
+		"SaveAndGetWebhook":                  TestSaveAndGetWebhook,
+		"AddAndGetWebhookDeliveries":         TestAddAndGetWebhookDeliveries,
+	} {
+		t.Run(name, test)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	testBackend = config.StorageSQLite
+	defer func() { testBackend = config.StorageBolt }()
+
+	initTestDB(t)
+	defer removeTestDB()
+
+	if err := AddTestInstance(testInstance1); err != nil {
+		t.Fatal("Failed to add test instance 1")
+	}
+	Close()
+
+	// Opening the database again must not apply migrations twice
+	initTestDB(t)
+
+	db, err := sql.Open("sqlite", testDBName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var version, versions int
+	if err := db.QueryRow(`SELECT MAX(version), COUNT(*) FROM schema_version`).Scan(&version, &versions); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(sqliteMigrations), version)
+	assert.Equal(t, len(sqliteMigrations), versions)
+
+	// Columns next to the encoded object can be queried directly
+	var status string
+	if err := db.QueryRow(
+		`SELECT status FROM test_instances WHERE test_id = ?`, string(testId1),
+	).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testInstance1.Status, status)
+
+	// A database migrated by a newer version cannot be opened
+	if _, err := db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, len(sqliteMigrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSQLiteStore(testDBName); err == nil {
+		t.Error("Expected a newer schema version to fail")
+	}
+}
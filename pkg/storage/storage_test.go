@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/t-bfame/diago/config"
 	"github.com/t-bfame/diago/pkg/model"
 
 	log "github.com/sirupsen/logrus"
@@ -15,6 +16,7 @@ const (
 
 	jobId1          model.JobID          = "job-id-1"
 	jobId2          model.JobID          = "job-id-2"
+	jobIdRequest    model.JobID          = "job-id-request"
 	testIdPrefix    string               = "test-id-"
 	testId1         model.TestID         = "test-id-1"
 	testId2         model.TestID         = "test-id-2"
@@ -37,6 +39,22 @@ var (
 		HTTPMethod: "GET",
 		HTTPUrl:    "localhost",
 	}
+	requestJob = &model.Job{
+		ID:             jobIdRequest,
+		Name:           "request",
+		Group:          "group",
+		Priority:       1,
+		Frequency:      10,
+		Duration:       100,
+		HTTPMethod:     "POST",
+		HTTPUrl:        "localhost",
+		HTTPHeaders:    map[string]string{"Content-Type": "application/json"},
+		HTTPQuery:      map[string]string{"page": "1"},
+		HTTPBody:       "e30=",
+		HTTPBodyBase64: true,
+		HTTPTimeout:    1000,
+		HTTPRedirects:  -1,
+	}
 	job2 = &model.Job{
 		ID:         jobId2,
 		Name:       "name",
@@ -48,6 +66,21 @@ var (
 		Duration:   100,
 		HTTPMethod: "GET",
 		HTTPUrl:    "localhost",
+		Scenario: []model.Step{
+			{
+				Name:    "login",
+				HTTPUrl: "localhost/login",
+				Extract: []model.Extraction{
+					{Name: "token", Source: model.ExtractJSON, Expression: "$.token"},
+				},
+				ThinkTime: 100,
+			},
+			{
+				Name:        "list",
+				HTTPUrl:     "localhost/items",
+				HTTPHeaders: map[string]string{"Authorization": "Bearer ${token}"},
+			},
+		},
 	}
 	test1 = &model.Test{
 		ID:   testId1,
@@ -105,6 +138,22 @@ func TestAddAndGetJob(t *testing.T) {
 	}
 }
 
+func TestAddAndGetJobWithRequest(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	if err := AddJob(requestJob); err != nil {
+		t.Error("Failed to add job with request")
+	}
+
+	retrievedJob, err := GetJobByJobId(jobIdRequest)
+	if err != nil {
+		t.Error("Error getting job with request")
+	} else {
+		assert.Equal(t, requestJob, retrievedJob)
+	}
+}
+
 func TestAddAndGetAllJobs(t *testing.T) {
 	initTestDB(t)
 	defer removeTestDB()
@@ -265,6 +314,40 @@ func TestAddAndGetAllTestInstances(t *testing.T) {
 	}
 }
 
+func TestAddAndGetTestInstanceSummaries(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	instance := &model.TestInstance{
+		ID:        testInstanceId1,
+		TestID:    testId1,
+		Type:      "typeA",
+		Status:    "done",
+		CreatedAt: 1618100600,
+		Verdict:   model.VerdictFailed,
+		Baseline:  true,
+		Events:    []model.InstanceEvent{{Time: 1618100600, Type: "reassign"}},
+	}
+	if err := AddTestInstance(instance); err != nil {
+		t.Error("Failed to add test instance")
+	}
+
+	summaries, err := GetTestInstanceSummaries()
+	if err != nil {
+		t.Error("Error getting test instance summaries")
+	} else {
+		assert.Equal(t, []*model.TestInstance{{
+			ID:        testInstanceId1,
+			TestID:    testId1,
+			Type:      "typeA",
+			Status:    "done",
+			CreatedAt: 1618100600,
+			Verdict:   model.VerdictFailed,
+			Baseline:  true,
+		}}, summaries)
+	}
+}
+
 func TestAddAndGetTestInstances(t *testing.T) {
 	initTestDB(t)
 	defer removeTestDB()
@@ -328,6 +411,42 @@ func TestAddAndDeleteTestInstance(t *testing.T) {
 	} else {
 		assert.Empty(t, retrievedTestInstances)
 	}
+
+	if err := DeleteTestInstance(testInstanceId1); err != nil {
+		t.Error("Expected deleting a missing test instance to succeed")
+	}
+}
+
+func TestSetAndGetBaseline(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	AddTestInstance(testInstance1)
+	AddTestInstance(testInstance2)
+
+	if baseline, err := GetBaseline(testId1); err != nil || baseline != nil {
+		t.Error("Expected no baseline before one is set")
+	}
+
+	if err := SetBaseline(testInstanceId1); err != nil {
+		t.Error("Failed to set test instance 1 as baseline")
+	}
+	if err := SetBaseline(testInstanceId2); err != nil {
+		t.Error("Failed to set test instance 2 as baseline")
+	}
+
+	baseline, err := GetBaseline(testId1)
+	if err != nil || baseline == nil {
+		t.Fatal("Error getting baseline")
+	}
+	assert.Equal(t, testInstanceId2, baseline.ID)
+
+	previous, _ := GetTestInstance(testInstanceId1)
+	assert.False(t, previous.Baseline)
+
+	if err := SetBaseline("unknown"); err == nil {
+		t.Error("Expected setting an unknown baseline to fail")
+	}
 }
 
 func TestAddAndGetTestSchedule(t *testing.T) {
@@ -430,14 +549,21 @@ func TestAddAndDeleteTestSchedule(t *testing.T) {
 	}
 }
 
+// testBackend is the storage backend the tests run against
+var testBackend = config.StorageBolt
+
 func initTestDB(t *testing.T) {
-	if err := InitDatabase(testDBName); err != nil {
+	if err := Init(testBackend, testDBName); err != nil {
 		t.Error("Failed to init database")
 	}
 }
 
 func removeTestDB() {
+	Close()
 	if err := os.Remove(testDBName); err != nil {
 		log.Error("Failed to remove testDB after running a test1")
 	}
+	// Write ahead log of SQLite
+	os.Remove(testDBName + "-wal")
+	os.Remove(testDBName + "-shm")
 }
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/t-bfame/diago/pkg/model"
+)
+
+// Store persists Diago objects. Getters return nil without an error for
+// objects which do not exist. Implementations must be safe for concurrent use.
+type Store interface {
+	AddJob(job *model.Job) error
+	DeleteJob(jobID model.JobID) error
+	GetJobByJobId(jobId model.JobID) (*model.Job, error)
+	GetAllJobs() ([]*model.Job, error)
+
+	AddTest(test *model.Test) error
+	DeleteTest(testID model.TestID) error
+	GetTestByTestId(testId model.TestID) (*model.Test, error)
+	GetAllTests() ([]*model.Test, error)
+	GetAllTestsWithPrefix(prefixStr string) ([]*model.Test, error)
+
+	SaveTest(test *model.Test, expected int) error
+	AddTestRevision(test *model.Test) error
+	GetTestRevision(testID model.TestID, revision int) (*model.Test, error)
+	GetTestRevisions(testID model.TestID) ([]*model.Test, error)
+	GetAllTestRevisions() ([]*model.Test, error)
+
+	AddTestInstance(testInstance *model.TestInstance) error
+	DeleteTestInstance(testInstanceID model.TestInstanceID) error
+	GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance, error)
+	SetBaseline(testInstanceID model.TestInstanceID) error
+	GetBaseline(testID model.TestID) (*model.TestInstance, error)
+	GetAllTestInstances() ([]*model.TestInstance, error)
+	GetTestInstanceSummaries() ([]*model.TestInstance, error)
+	GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInstance, error)
+	GetTestInstancesByTestID(testID model.TestID) ([]*model.TestInstance, error)
+
+	AddTestSchedule(testSchedule *model.TestSchedule) error
+	SaveTestSchedule(testSchedule *model.TestSchedule, expected int) error
+	DeleteTestSchedule(testScheduleID model.TestScheduleID) error
+	GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule, error)
+	GetAllTestSchedules() ([]*model.TestSchedule, error)
+	GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSchedule, error)
+	GetTestSchedulesByTestID(testID model.TestID) ([]*model.TestSchedule, error)
+
+	SaveProject(project *model.Project) error
+	DeleteProject(name string) error
+	GetProject(name string) (*model.Project, error)
+	GetAllProjects() ([]*model.Project, error)
+	GetTestsByProject(project string) ([]*model.Test, error)
+	GetTestInstancesByProject(project string) ([]*model.TestInstance, error)
+	GetTestSchedulesByProject(project string) ([]*model.TestSchedule, error)
+
+	AddAuditEntry(entry *model.AuditEntry) error
+	GetAuditEntries(query AuditQuery) ([]*model.AuditEntry, error)
+
+	SaveWebhook(webhook *model.Webhook) error
+	DeleteWebhook(webhookID model.WebhookID) error
+	GetWebhook(webhookID model.WebhookID) (*model.Webhook, error)
+	GetAllWebhooks() ([]*model.Webhook, error)
+	AddWebhookDelivery(delivery *model.WebhookDelivery) error
+	GetWebhookDeliveries(webhookID model.WebhookID, limit int) ([]*model.WebhookDelivery, error)
+
+	Snapshot(w io.Writer) error
+	Close() error
+}
+
+// store is the Store used by the package level functions, set by Init
+var store Store
+
+// Close the storage initialized with Init
+func Close() error {
+	if store == nil {
+		return fmt.Errorf("storage is not initialized")
+	}
+	err := store.Close()
+	store = nil
+	return err
+}
+
+// Add a "model/Job" to the storage.
+func AddJob(job *model.Job) error {
+	return store.AddJob(job)
+}
+
+// Delete a "model/Job" with the specified JobID from the storage.
+func DeleteJob(jobID model.JobID) error {
+	return store.DeleteJob(jobID)
+}
+
+// Retrieve a "model/Job" with the specified JobID from the storage.
+func GetJobByJobId(jobId model.JobID) (*model.Job, error) {
+	return store.GetJobByJobId(jobId)
+}
+
+// Retrieve all "model/Job" stored in the storage.
+func GetAllJobs() ([]*model.Job, error) {
+	return store.GetAllJobs()
+}
+
+// Add a "model/Test" to the storage.
+func AddTest(test *model.Test) error {
+	return store.AddTest(test)
+}
+
+// Delete a "model/Test" with the specified TestID from the storage.
+func DeleteTest(testID model.TestID) error {
+	return store.DeleteTest(testID)
+}
+
+// Retrieve a "model/Test" with the specified TestID from the storage.
+func GetTestByTestId(testId model.TestID) (*model.Test, error) {
+	return store.GetTestByTestId(testId)
+}
+
+// Retrieve all "model/Test" stored in the storage.
+func GetAllTests() ([]*model.Test, error) {
+	return store.GetAllTests()
+}
+
+// Retrieve all "model/Test" with the specified TestID prefix from the storage.
+func GetAllTestsWithPrefix(prefixStr string) ([]*model.Test, error) {
+	return store.GetAllTestsWithPrefix(prefixStr)
+}
+
+// Save a "model/Test" as its next revision if its current revision is expected, see AnyRevision.
+// The saved test is also added to the revisions of the Test, which are never modified.
+// Fails with a *RevisionConflictError if the current revision is not expected.
+func SaveTest(test *model.Test, expected int) error {
+	return store.SaveTest(test, expected)
+}
+
+// Add a revision of a "model/Test" to the storage, which fails if the revision exists.
+func AddTestRevision(test *model.Test) error {
+	return store.AddTestRevision(test)
+}
+
+// Retrieve the revision of a "model/Test" with the specified TestID from the storage.
+func GetTestRevision(testID model.TestID, revision int) (*model.Test, error) {
+	return store.GetTestRevision(testID, revision)
+}
+
+// Retrieve all revisions of a "model/Test" with the specified TestID from the storage, oldest first.
+func GetTestRevisions(testID model.TestID) ([]*model.Test, error) {
+	return store.GetTestRevisions(testID)
+}
+
+// Retrieve the revisions of every "model/Test" stored in the storage, including deleted ones.
+func GetAllTestRevisions() ([]*model.Test, error) {
+	return store.GetAllTestRevisions()
+}
+
+// Add a "model/TestInstance" to the storage.
+func AddTestInstance(testInstance *model.TestInstance) error {
+	return store.AddTestInstance(testInstance)
+}
+
+// Delete a "model/TestInstance" with the specified TestInstanceID from the storage.
+func DeleteTestInstance(testInstanceID model.TestInstanceID) error {
+	return store.DeleteTestInstance(testInstanceID)
+}
+
+// Retrieve a "model/TestInstance" with the specified TestInstanceID from the storage.
+func GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance, error) {
+	return store.GetTestInstance(testInstanceID)
+}
+
+// Mark the "model/TestInstance" with the specified TestInstanceID as the baseline of its Test,
+// the previous baseline of the Test is unmarked.
+func SetBaseline(testInstanceID model.TestInstanceID) error {
+	return store.SetBaseline(testInstanceID)
+}
+
+// Retrieve the "model/TestInstance" marked as the baseline of the specified TestID, nil if there is none.
+func GetBaseline(testID model.TestID) (*model.TestInstance, error) {
+	return store.GetBaseline(testID)
+}
+
+// Retrieve all "model/TestInstance" stored in the storage.
+func GetAllTestInstances() ([]*model.TestInstance, error) {
+	return store.GetAllTestInstances()
+}
+
+// Retrieve all "model/TestInstance" stored in the storage with only their identity, status and outcome,
+// which is cheaper than loading their metrics, events and results.
+func GetTestInstanceSummaries() ([]*model.TestInstance, error) {
+	return store.GetTestInstanceSummaries()
+}
+
+// Retrieve an array of "model/TestInstance" with the specified array of TestInstanceID from the storage.
+func GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInstance, error) {
+	return store.GetTestInstances(testInstanceIDs)
+}
+
+// Retrieve all "model/TestInstance" with specified TestID from the storage.
+func GetTestInstancesByTestID(testID model.TestID) ([]*model.TestInstance, error) {
+	return store.GetTestInstancesByTestID(testID)
+}
+
+// Add a "model/TestSchedule" to the storage.
+func AddTestSchedule(testSchedule *model.TestSchedule) error {
+	return store.AddTestSchedule(testSchedule)
+}
+
+// Save a "model/TestSchedule" as its next revision if its current revision is expected, see AnyRevision.
+// Fails with a *RevisionConflictError if the current revision is not expected.
+func SaveTestSchedule(testSchedule *model.TestSchedule, expected int) error {
+	return store.SaveTestSchedule(testSchedule, expected)
+}
+
+// Delete a "model/TestSchedule" with the specified TestScheduleID from the storage.
+func DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
+	return store.DeleteTestSchedule(testScheduleID)
+}
+
+// Retrieve a "model/TestSchedule" with the specified TestScheduleID from the storage.
+func GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule, error) {
+	return store.GetTestSchedule(testScheduleID)
+}
+
+// Retrieve all "model/TestSchedule" stored in the storage.
+func GetAllTestSchedules() ([]*model.TestSchedule, error) {
+	return store.GetAllTestSchedules()
+}
+
+// Retrieve an array of "model/TestSchedule" with the specified array of TestScheduleID from the storage.
+func GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSchedule, error) {
+	return store.GetTestSchedules(testScheduleIDs)
+}
+
+// Retrieve all "model/TestSchedule" with specified TestID from the storage.
+func GetTestSchedulesByTestID(testID model.TestID) ([]*model.TestSchedule, error) {
+	return store.GetTestSchedulesByTestID(testID)
+}
+
+// Add or replace a "model/Project" in the storage.
+func SaveProject(project *model.Project) error {
+	return store.SaveProject(project)
+}
+
+// Delete a "model/Project" with the specified name from the storage.
+func DeleteProject(name string) error {
+	return store.DeleteProject(name)
+}
+
+// Retrieve a "model/Project" with the specified name from the storage,
+// where an empty name is the "model/DefaultProject".
+func GetProject(name string) (*model.Project, error) {
+	return store.GetProject(name)
+}
+
+// Retrieve all "model/Project" stored in the storage.
+func GetAllProjects() ([]*model.Project, error) {
+	return store.GetAllProjects()
+}
+
+// Retrieve all "model/Test" of the specified project from the storage.
+func GetTestsByProject(project string) ([]*model.Test, error) {
+	return store.GetTestsByProject(project)
+}
+
+// Retrieve all "model/TestInstance" of the specified project from the storage.
+func GetTestInstancesByProject(project string) ([]*model.TestInstance, error) {
+	return store.GetTestInstancesByProject(project)
+}
+
+// Retrieve all "model/TestSchedule" of the specified project from the storage.
+func GetTestSchedulesByProject(project string) ([]*model.TestSchedule, error) {
+	return store.GetTestSchedulesByProject(project)
+}
+
+// Append a "model/AuditEntry" to the storage, assigning its ID.
+// Entries cannot be modified or deleted once added.
+func AddAuditEntry(entry *model.AuditEntry) error {
+	return store.AddAuditEntry(entry)
+}
+
+// Retrieve the "model/AuditEntry" selected by query from the storage, the latest first.
+func GetAuditEntries(query AuditQuery) ([]*model.AuditEntry, error) {
+	return store.GetAuditEntries(query)
+}
+
+// Add or replace a "model/Webhook" in the storage.
+func SaveWebhook(webhook *model.Webhook) error {
+	return store.SaveWebhook(webhook)
+}
+
+// Delete a "model/Webhook" with the specified WebhookID from the storage.
+// Its deliveries are kept.
+func DeleteWebhook(webhookID model.WebhookID) error {
+	return store.DeleteWebhook(webhookID)
+}
+
+// Retrieve a "model/Webhook" with the specified WebhookID from the storage.
+func GetWebhook(webhookID model.WebhookID) (*model.Webhook, error) {
+	return store.GetWebhook(webhookID)
+}
+
+// Retrieve all "model/Webhook" stored in the storage.
+func GetAllWebhooks() ([]*model.Webhook, error) {
+	return store.GetAllWebhooks()
+}
+
+// Append a "model/WebhookDelivery" to the storage, assigning its ID.
+func AddWebhookDelivery(delivery *model.WebhookDelivery) error {
+	return store.AddWebhookDelivery(delivery)
+}
+
+// Retrieve at most limit "model/WebhookDelivery" of the specified WebhookID from the storage,
+// the latest first. A limit of 0 retrieves all of them.
+func GetWebhookDeliveries(webhookID model.WebhookID, limit int) ([]*model.WebhookDelivery, error) {
+	return store.GetWebhookDeliveries(webhookID, limit)
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/t-bfame/diago/pkg/model"
-	"github.com/t-bfame/diago/pkg/tools"
 
 	"github.com/boltdb/bolt"
 	log "github.com/sirupsen/logrus"
@@ -23,20 +22,9 @@ func initStorageTest(db *bolt.DB) error {
 }
 
 // Add a "model/Test" to the storage.
-func AddTest(test *model.Test) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(TestBucketName))
-		if b == nil {
-			return fmt.Errorf("missing bucket '%s'", TestBucketName)
-		}
-		enc, err := tools.GobEncode(test)
-		if err != nil {
-			return fmt.Errorf("failed to encode Test due to: %s", err)
-		}
-		if err := b.Put([]byte(test.ID), enc); err != nil {
-			return err
-		}
-		return nil
+func (s *boltStore) AddTest(test *model.Test) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return doAddTest(tx, test)
 	}); err != nil {
 		log.WithError(err).WithField("test", test).Error("Failed to add Test")
 		return err
@@ -45,16 +33,20 @@ func AddTest(test *model.Test) error {
 }
 
 // Delete a "model/Test" with the specified TestID from the storage.
-func DeleteTest(testID model.TestID) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteTest(testID model.TestID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TestBucketName))
 		if b == nil {
 			return fmt.Errorf("missing bucket '%s'", TestBucketName)
 		}
+		current, err := doGetTest(tx, testID)
+		if err != nil || current == nil {
+			return err
+		}
 		if err := b.Delete([]byte(testID)); err != nil {
 			return err
 		}
-		return nil
+		return doRemoveProjectIndex(tx, IdxProject2TestIDBucketName, current.Project, string(testID))
 	}); err != nil {
 		log.WithError(err).WithField("testID", testID).Error("Failed to delete Test")
 		return err
@@ -63,16 +55,16 @@ func DeleteTest(testID model.TestID) error {
 }
 
 // Retrieve a "model/Test" with the specified TestID from the storage.
-func GetTestByTestId(testId model.TestID) (*model.Test, error) {
+func (s *boltStore) GetTestByTestId(testId model.TestID) (*model.Test, error) {
 	var result *model.Test
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		var err error
 		b := tx.Bucket([]byte(TestBucketName))
 		data := b.Get([]byte(testId))
 		if data == nil {
 			return nil
 		}
-		if err = tools.GobDecode(&result, data); err != nil {
+		if err = decode(&result, data); err != nil {
 			return fmt.Errorf("failed to decode Test due to: %s", err)
 		}
 		return nil
@@ -84,15 +76,15 @@ func GetTestByTestId(testId model.TestID) (*model.Test, error) {
 }
 
 // Retrieve all "model/Test" stored in the storage.
-func GetAllTests() ([]*model.Test, error) {
+func (s *boltStore) GetAllTests() ([]*model.Test, error) {
 	var tests = make([]*model.Test, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TestBucketName))
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var test *model.Test
-			if err := tools.GobDecode(&test, v); err != nil {
+			if err := decode(&test, v); err != nil {
 				return fmt.Errorf("failed to decode Test due to: %s", err)
 			}
 			tests = append(tests, test)
@@ -107,16 +99,16 @@ func GetAllTests() ([]*model.Test, error) {
 }
 
 // Retrieve all "model/Test" with the specified JobID prefix from the storage.
-func GetAllTestsWithPrefix(prefixStr string) ([]*model.Test, error) {
+func (s *boltStore) GetAllTestsWithPrefix(prefixStr string) ([]*model.Test, error) {
 	var tests = make([]*model.Test, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TestBucketName))
 		c := b.Cursor()
 
 		prefix := []byte(prefixStr)
 		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
 			var test *model.Test
-			if err := tools.GobDecode(&test, v); err != nil {
+			if err := decode(&test, v); err != nil {
 				return fmt.Errorf("failed to decode Test due to: %s", err)
 			}
 			tests = append(tests, test)
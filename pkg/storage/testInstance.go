@@ -1,12 +1,9 @@
 package storage
 
 import (
-	"encoding/gob"
 	"fmt"
 
-	"github.com/t-bfame/diago/pkg/metrics"
 	"github.com/t-bfame/diago/pkg/model"
-	"github.com/t-bfame/diago/pkg/tools"
 
 	"github.com/boltdb/bolt"
 	log "github.com/sirupsen/logrus"
@@ -33,21 +30,19 @@ func initStorageTestInstance(db *bolt.DB) error {
 	if err := db.Update(createInitBucketFunc(IdxTestID2TestInstanceIDBucketName)); err != nil {
 		return err
 	}
-	gob.Register(map[string]*metrics.Metrics{})
-	gob.Register(map[model.ChaosID]model.ChaosResult{})
 	return nil
 }
 
 // Add a "model/TestInstance" to the storage.
-func AddTestInstance(testInstance *model.TestInstance) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) AddTestInstance(testInstance *model.TestInstance) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		if err := doAddTestInstance(tx, testInstance); err != nil {
 			return err
 		}
 		if err := doAddTestInstanceIndex(tx, testInstance); err != nil {
 			return err
 		}
-		return nil
+		return doAddProjectIndex(tx, IdxProject2TestInstanceIDBucketName, testInstance.Project, string(testInstance.ID))
 	}); err != nil {
 		log.WithError(err).WithField("testInstance", testInstance).Error("Failed to add TestInstance")
 		return err
@@ -56,13 +51,17 @@ func AddTestInstance(testInstance *model.TestInstance) error {
 }
 
 // Delete a "model/TestInstance" with the specified TestInstanceID from the storage.
-func DeleteTestInstance(testInstanceID model.TestInstanceID) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteTestInstance(testInstanceID model.TestInstanceID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		var testID model.TestID
+		var project string
 		if instance, err := doGetTestInstance(tx, testInstanceID); err != nil {
 			return err
+		} else if instance == nil {
+			return nil
 		} else {
 			testID = instance.TestID
+			project = instance.Project
 		}
 
 		if err := doDeleteTestInstance(tx, testInstanceID); err != nil {
@@ -72,7 +71,7 @@ func DeleteTestInstance(testInstanceID model.TestInstanceID) error {
 		if err := doRemoveTestInstanceIndex(tx, testID, testInstanceID); err != nil {
 			return err
 		}
-		return nil
+		return doRemoveProjectIndex(tx, IdxProject2TestInstanceIDBucketName, project, string(testInstanceID))
 	}); err != nil {
 		log.WithError(err).WithField("testInstanceID", testInstanceID).Error("Failed to delete TestInstance")
 		return err
@@ -81,9 +80,9 @@ func DeleteTestInstance(testInstanceID model.TestInstanceID) error {
 }
 
 // Retrieve a "model/TestInstance" with the specified TestInstanceID from the storage.
-func GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance, error) {
+func (s *boltStore) GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance, error) {
 	var result *model.TestInstance
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		if instance, err := doGetTestInstance(tx, testInstanceID); err != nil {
 			return err
 		} else {
@@ -97,16 +96,75 @@ func GetTestInstance(testInstanceID model.TestInstanceID) (*model.TestInstance,
 	return result, nil
 }
 
+// Mark the "model/TestInstance" with the specified TestInstanceID as the baseline of its Test,
+// the previous baseline of the Test is unmarked.
+func (s *boltStore) SetBaseline(testInstanceID model.TestInstanceID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		instance, err := doGetTestInstance(tx, testInstanceID)
+		if err != nil {
+			return err
+		}
+		if instance == nil {
+			return fmt.Errorf("TestInstance<%s> does not exist", testInstanceID)
+		}
+
+		index, err := doGetTestInstanceIndex(tx, instance.TestID)
+		if err != nil {
+			return err
+		}
+
+		others := make(map[model.TestInstanceID]bool)
+		if index != nil {
+			others = index.TestInstanceIds
+		}
+		instances, err := doGetTestInstancesByIDMap(tx, others)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range instances {
+			if other.Baseline && other.ID != testInstanceID {
+				other.Baseline = false
+				if err := doAddTestInstance(tx, other); err != nil {
+					return err
+				}
+			}
+		}
+
+		instance.Baseline = true
+		return doAddTestInstance(tx, instance)
+	}); err != nil {
+		log.WithError(err).WithField("testInstanceID", testInstanceID).Error("Failed to SetBaseline")
+		return err
+	}
+	return nil
+}
+
+// Retrieve the "model/TestInstance" marked as the baseline of the specified TestID, nil if there is none.
+func (s *boltStore) GetBaseline(testID model.TestID) (*model.TestInstance, error) {
+	instances, err := s.GetTestInstancesByTestID(testID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instance := range instances {
+		if instance.Baseline {
+			return instance, nil
+		}
+	}
+	return nil, nil
+}
+
 // Retrieve all "model/TestInstance" stored in the storage.
-func GetAllTestInstances() ([]*model.TestInstance, error) {
+func (s *boltStore) GetAllTestInstances() ([]*model.TestInstance, error) {
 	var instances = make([]*model.TestInstance, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TestInstanceBucketName))
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var instance *model.TestInstance
-			if err := tools.GobDecode(&instance, v); err != nil {
+			if err := decode(&instance, v); err != nil {
 				return fmt.Errorf("failed to decode TestInstance due to: %s", err)
 			}
 			instances = append(instances, instance)
@@ -120,10 +178,52 @@ func GetAllTestInstances() ([]*model.TestInstance, error) {
 	return instances, nil
 }
 
+// testInstanceSummary is decoded instead of a "model/TestInstance" to skip its metrics, events and results
+type testInstanceSummary struct {
+	ID        model.TestInstanceID
+	TestID    model.TestID
+	Type      string
+	Status    string
+	CreatedAt int64
+	Verdict   model.Verdict
+	Baseline  bool
+}
+
+// Retrieve all "model/TestInstance" stored in the storage with only their identity, status and outcome.
+func (s *boltStore) GetTestInstanceSummaries() ([]*model.TestInstance, error) {
+	var instances = make([]*model.TestInstance, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TestInstanceBucketName))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var summary testInstanceSummary
+			if err := decode(&summary, v); err != nil {
+				return fmt.Errorf("failed to decode TestInstance due to: %s", err)
+			}
+			instances = append(instances, &model.TestInstance{
+				ID:        summary.ID,
+				TestID:    summary.TestID,
+				Type:      summary.Type,
+				Status:    summary.Status,
+				CreatedAt: summary.CreatedAt,
+				Verdict:   summary.Verdict,
+				Baseline:  summary.Baseline,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		log.WithError(err).Error("Failed to GetTestInstanceSummaries")
+		return nil, err
+	}
+	return instances, nil
+}
+
 // Retrieve an array of "model/TestInstance" with the specified array of TestInstanceID from the storage.
-func GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInstance, error) {
+func (s *boltStore) GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInstance, error) {
 	var result = make([]*model.TestInstance, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		if instances, err := doGetTestInstances(tx, testInstanceIDs); err != nil {
 			return err
 		} else {
@@ -138,9 +238,9 @@ func GetTestInstances(testInstanceIDs []model.TestInstanceID) ([]*model.TestInst
 }
 
 // Retrieve all "model/TestInstance" with specified TestID from the storage.
-func GetTestInstancesByTestID(testID model.TestID) ([]*model.TestInstance, error) {
+func (s *boltStore) GetTestInstancesByTestID(testID model.TestID) ([]*model.TestInstance, error) {
 	var result = make([]*model.TestInstance, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		var index *IdxTestID2TestInstanceID
 		if value, err := doGetTestInstanceIndex(tx, testID); err != nil {
 			return err
@@ -173,7 +273,7 @@ func doGetTestInstance(tx *bolt.Tx, testInstanceID model.TestInstanceID) (*model
 	if data == nil {
 		return nil, nil
 	}
-	if err := tools.GobDecode(&result, data); err != nil {
+	if err := decode(&result, data); err != nil {
 		return nil, fmt.Errorf("failed to decode TestInstance due to: %s", err)
 	}
 	return result, nil
@@ -185,7 +285,7 @@ func doAddTestInstance(tx *bolt.Tx, testInstance *model.TestInstance) error {
 	if b == nil {
 		return fmt.Errorf("missing bucket '%s'", TestInstanceBucketName)
 	}
-	enc, err := tools.GobEncode(testInstance)
+	enc, err := encode(testInstance)
 	if err != nil {
 		return fmt.Errorf("failed to encode TestInstance due to: %s", err)
 	}
@@ -220,7 +320,7 @@ func doGetTestInstanceIndex(tx *bolt.Tx, testID model.TestID) (*IdxTestID2TestIn
 	}
 
 	var index *IdxTestID2TestInstanceID
-	if err := tools.GobDecode(&index, data); err != nil {
+	if err := decode(&index, data); err != nil {
 		return nil, fmt.Errorf("failed to decode IdxTestID2TestInstanceID due to: %s", err)
 	}
 	return index, nil
@@ -251,7 +351,7 @@ func doAddTestInstanceIndex(tx *bolt.Tx, testInstance *model.TestInstance) error
 
 	index.TestInstanceIds[instanceID] = true
 
-	enc, err := tools.GobEncode(index)
+	enc, err := encode(index)
 	if err != nil {
 		return fmt.Errorf("failed to encode IdxTestID2TestInstanceID due to: %s", err)
 	}
@@ -281,7 +381,12 @@ func doRemoveTestInstanceIndex(tx *bolt.Tx, testID model.TestID, instanceID mode
 
 	delete(index.TestInstanceIds, instanceID)
 
-	enc, err := tools.GobEncode(index)
+	// Tests without instances are removed from the index
+	if len(index.TestInstanceIds) == 0 {
+		return b.Delete([]byte(testID))
+	}
+
+	enc, err := encode(index)
 	if err != nil {
 		return fmt.Errorf("failed to encode IdxTestID2TestInstanceID due to: %s", err)
 	}
@@ -302,7 +407,7 @@ func doGetTestInstances(tx *bolt.Tx, testInstanceIDs []model.TestInstanceID) ([]
 		if data == nil {
 			continue
 		}
-		if err := tools.GobDecode(&value, data); err != nil {
+		if err := decode(&value, data); err != nil {
 			return nil, fmt.Errorf("failed to decode TestInstance due to: %s", err)
 		}
 		instances = append(instances, value)
@@ -320,7 +425,7 @@ func doGetTestInstancesByIDMap(tx *bolt.Tx, testInstanceIDs map[model.TestInstan
 		if data == nil {
 			continue
 		}
-		if err := tools.GobDecode(&value, data); err != nil {
+		if err := decode(&value, data); err != nil {
 			return nil, fmt.Errorf("failed to decode TestInstance due to: %s", err)
 		}
 		instances = append(instances, value)
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/boltdb/bolt"
+	log "github.com/sirupsen/logrus"
+)
+
+// This is the boltDB bucket name for storing the revisions of "model/Test", in a nested bucket per TestID.
+const TestRevisionBucketName = "TestRevision"
+
+// AnyRevision saves an object regardless of its current revision
+const AnyRevision = -1
+
+// RevisionConflictError is returned when saving an object whose current revision is not the expected one
+type RevisionConflictError struct {
+	Expected int
+	Current  int
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("expected revision %d, but the current revision is %d", e.Expected, e.Current)
+}
+
+// Initializes boltDB for "model/Test" revision storage.
+func initStorageTestRevision(db *bolt.DB) error {
+	return db.Update(createInitBucketFunc(TestRevisionBucketName))
+}
+
+// Save a "model/Test" as its next revision if its current revision is expected, see AnyRevision.
+// The saved test is also added to the revisions of the Test, which are never modified.
+func (s *boltStore) SaveTest(test *model.Test, expected int) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		current, err := doGetTest(tx, test.ID)
+		if err != nil {
+			return err
+		}
+
+		revision := 0
+		if current != nil {
+			revision = current.Revision
+		}
+		if expected != AnyRevision && expected != revision {
+			return &RevisionConflictError{expected, revision}
+		}
+
+		// Revisions of a deleted Test are kept, so a Test created again continues after them
+		latest, err := doGetLatestTestRevision(tx, test.ID)
+		if err != nil {
+			return err
+		}
+		if latest > revision {
+			revision = latest
+		}
+		test.Revision = revision + 1
+
+		if err := doAddTest(tx, test); err != nil {
+			return err
+		}
+		return doAddTestRevision(tx, test)
+	}); err != nil {
+		log.WithError(err).WithField("test", test.ID).Error("Failed to save Test")
+		return err
+	}
+	return nil
+}
+
+// Add a revision of a "model/Test" to the storage, which fails if the revision exists.
+func (s *boltStore) AddTestRevision(test *model.Test) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return doAddTestRevision(tx, test)
+	}); err != nil {
+		log.WithError(err).WithField("test", test.ID).Error("Failed to add Test revision")
+		return err
+	}
+	return nil
+}
+
+// Retrieve the revision of a "model/Test" with the specified TestID from the storage.
+func (s *boltStore) GetTestRevision(testID model.TestID, revision int) (*model.Test, error) {
+	var result *model.Test
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TestRevisionBucketName)).Bucket([]byte(testID))
+		if b == nil {
+			return nil
+		}
+		data := b.Get(revisionKey(revision))
+		if data == nil {
+			return nil
+		}
+		if err := decode(&result, data); err != nil {
+			return fmt.Errorf("failed to decode Test due to: %s", err)
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("testID", testID).Error("Failed to GetTestRevision")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Retrieve all revisions of a "model/Test" with the specified TestID from the storage, oldest first.
+func (s *boltStore) GetTestRevisions(testID model.TestID) ([]*model.Test, error) {
+	var tests = make([]*model.Test, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TestRevisionBucketName)).Bucket([]byte(testID))
+		if b == nil {
+			return nil
+		}
+		return doAppendTestRevisions(b, &tests)
+	}); err != nil {
+		log.WithError(err).WithField("testID", testID).Error("Failed to GetTestRevisions")
+		return nil, err
+	}
+	return tests, nil
+}
+
+// Retrieve the revisions of every "model/Test" stored in the storage, including deleted ones.
+func (s *boltStore) GetAllTestRevisions() ([]*model.Test, error) {
+	var tests = make([]*model.Test, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TestRevisionBucketName))
+		return b.ForEach(func(testID, _ []byte) error {
+			return doAppendTestRevisions(b.Bucket(testID), &tests)
+		})
+	}); err != nil {
+		log.WithError(err).Error("Failed to GetAllTestRevisions")
+		return nil, err
+	}
+	return tests, nil
+}
+
+// Internal function used to retrieve a "model/Test" using the provided boltDB transaction.
+func doGetTest(tx *bolt.Tx, testID model.TestID) (*model.Test, error) {
+	var result *model.Test
+	b := tx.Bucket([]byte(TestBucketName))
+	if b == nil {
+		return nil, fmt.Errorf("missing bucket '%s'", TestBucketName)
+	}
+	data := b.Get([]byte(testID))
+	if data == nil {
+		return nil, nil
+	}
+	if err := decode(&result, data); err != nil {
+		return nil, fmt.Errorf("failed to decode Test due to: %s", err)
+	}
+	return result, nil
+}
+
+// Internal function used to add a "model/Test" using the provided boltDB transaction.
+func doAddTest(tx *bolt.Tx, test *model.Test) error {
+	b := tx.Bucket([]byte(TestBucketName))
+	if b == nil {
+		return fmt.Errorf("missing bucket '%s'", TestBucketName)
+	}
+	enc, err := encode(test)
+	if err != nil {
+		return fmt.Errorf("failed to encode Test due to: %s", err)
+	}
+	if err := b.Put([]byte(test.ID), enc); err != nil {
+		return err
+	}
+	// The project of a Test is part of its ID, so it never changes
+	return doAddProjectIndex(tx, IdxProject2TestIDBucketName, test.Project, string(test.ID))
+}
+
+// Internal function used to add a revision of a "model/Test" using the provided boltDB transaction.
+func doAddTestRevision(tx *bolt.Tx, test *model.Test) error {
+	root := tx.Bucket([]byte(TestRevisionBucketName))
+	if root == nil {
+		return fmt.Errorf("missing bucket '%s'", TestRevisionBucketName)
+	}
+	b, err := root.CreateBucketIfNotExists([]byte(test.ID))
+	if err != nil {
+		return err
+	}
+
+	key := revisionKey(test.Revision)
+	if b.Get(key) != nil {
+		return fmt.Errorf("revision %d of Test<%s> already exists", test.Revision, test.ID)
+	}
+
+	enc, err := encode(test)
+	if err != nil {
+		return fmt.Errorf("failed to encode Test due to: %s", err)
+	}
+	return b.Put(key, enc)
+}
+
+// Internal function used to retrieve the latest revision number of a "model/Test", 0 if it has none.
+func doGetLatestTestRevision(tx *bolt.Tx, testID model.TestID) (int, error) {
+	root := tx.Bucket([]byte(TestRevisionBucketName))
+	if root == nil {
+		return 0, fmt.Errorf("missing bucket '%s'", TestRevisionBucketName)
+	}
+	b := root.Bucket([]byte(testID))
+	if b == nil {
+		return 0, nil
+	}
+
+	k, _ := b.Cursor().Last()
+	if k == nil {
+		return 0, nil
+	}
+	return int(binary.BigEndian.Uint64(k)), nil
+}
+
+// Internal function used to decode the revisions of a Test stored in b, which are ordered by their key
+func doAppendTestRevisions(b *bolt.Bucket, tests *[]*model.Test) error {
+	return b.ForEach(func(_, v []byte) error {
+		var test *model.Test
+		if err := decode(&test, v); err != nil {
+			return fmt.Errorf("failed to decode Test due to: %s", err)
+		}
+		*tests = append(*tests, test)
+		return nil
+	})
+}
+
+// Internal function used to generate the key of a revision, which sorts the revisions by number
+func revisionKey(revision int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(revision))
+	return key
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveTestAndGetRevisions(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	test := *test1
+	if err := SaveTest(&test, 0); err != nil {
+		t.Fatal("Failed to save test 1")
+	}
+	assert.Equal(t, 1, test.Revision)
+
+	// A stale revision is rejected
+	stale := *test1
+	stale.Name = "stale"
+	err := SaveTest(&stale, 0)
+	if conflict, ok := err.(*RevisionConflictError); !ok || conflict.Current != 1 {
+		t.Fatalf("Expected a revision conflict, got %v", err)
+	}
+
+	updated := *test1
+	updated.Name = "updated"
+	if err := SaveTest(&updated, 1); err != nil {
+		t.Fatal("Failed to save revision 2 of test 1")
+	}
+	assert.Equal(t, 2, updated.Revision)
+
+	retrieved, err := GetTestByTestId(testId1)
+	if err != nil {
+		t.Fatal("Failed to get test 1")
+	}
+	assert.Equal(t, &updated, retrieved)
+
+	first, err := GetTestRevision(testId1, 1)
+	if err != nil || first == nil {
+		t.Fatal("Failed to get revision 1 of test 1")
+	}
+	assert.Equal(t, test1.Name, first.Name)
+
+	if missing, err := GetTestRevision(testId1, 3); err != nil || missing != nil {
+		t.Errorf("Expected revision 3 not to exist, got %v", missing)
+	}
+
+	// Revisions outlive their Test, which continues after them when created again
+	if err := DeleteTest(testId1); err != nil {
+		t.Fatal("Failed to delete test 1")
+	}
+	recreated := *test1
+	if err := SaveTest(&recreated, 0); err != nil {
+		t.Fatal("Failed to save test 1 again")
+	}
+	assert.Equal(t, 3, recreated.Revision)
+
+	revisions, err := GetTestRevisions(testId1)
+	if err != nil {
+		t.Fatal("Failed to get revisions of test 1")
+	}
+	if assert.Equal(t, 3, len(revisions)) {
+		for i, revision := range revisions {
+			assert.Equal(t, i+1, revision.Revision)
+		}
+	}
+
+	// Revisions are never modified
+	if err := AddTestRevision(first); err == nil {
+		t.Error("Expected adding an existing revision to fail")
+	}
+
+	other := *test2
+	if err := SaveTest(&other, AnyRevision); err != nil {
+		t.Fatal("Failed to save test 2")
+	}
+	all, err := GetAllTestRevisions()
+	if err != nil {
+		t.Fatal("Failed to get all revisions")
+	}
+	assert.Equal(t, 4, len(all))
+}
+
+func TestSaveTestSchedule(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	schedule := *testSchedule1
+	if err := SaveTestSchedule(&schedule, 0); err != nil {
+		t.Fatal("Failed to save test schedule 1")
+	}
+	assert.Equal(t, 1, schedule.Revision)
+
+	moved := schedule
+	moved.TestID = testId2
+	moved.CronSpec = "another-spec"
+	if err := SaveTestSchedule(&moved, 0); err == nil {
+		t.Fatal("Expected a revision conflict")
+	}
+	if err := SaveTestSchedule(&moved, 1); err != nil {
+		t.Fatal("Failed to save revision 2 of test schedule 1")
+	}
+
+	retrieved, err := GetTestSchedule(testScheduleId1)
+	if err != nil {
+		t.Fatal("Failed to get test schedule 1")
+	}
+	assert.Equal(t, &moved, retrieved)
+
+	// The schedule now belongs to test 2 only
+	if schedules, _ := GetTestSchedulesByTestID(testId1); len(schedules) != 0 {
+		t.Errorf("Expected no schedule of test 1, got %v", schedules)
+	}
+	if schedules, _ := GetTestSchedulesByTestID(testId2); len(schedules) != 1 {
+		t.Errorf("Expected a schedule of test 2, got %v", schedules)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/t-bfame/diago/pkg/model"
-	"github.com/t-bfame/diago/pkg/tools"
 
 	"github.com/boltdb/bolt"
 	log "github.com/sirupsen/logrus"
@@ -30,15 +29,15 @@ func initStorageTestSchedule(db *bolt.DB) error {
 	return nil
 }
 
-func AddTestSchedule(testSchedule *model.TestSchedule) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) AddTestSchedule(testSchedule *model.TestSchedule) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		if err := doAddTestSchedule(tx, testSchedule); err != nil {
 			return err
 		}
 		if err := doAddTestScheduleIndex(tx, testSchedule); err != nil {
 			return err
 		}
-		return nil
+		return doAddProjectIndex(tx, IdxProject2TestScheduleIDBucketName, testSchedule.Project, string(testSchedule.ID))
 	}); err != nil {
 		log.WithError(err).WithField("testSchedule", testSchedule).Error("Failed to add TestSchedule")
 		return err
@@ -46,13 +45,53 @@ func AddTestSchedule(testSchedule *model.TestSchedule) error {
 	return nil
 }
 
-func DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
-	if err := db.Update(func(tx *bolt.Tx) error {
+// Save a "model/TestSchedule" as its next revision if its current revision is expected, see AnyRevision.
+func (s *boltStore) SaveTestSchedule(testSchedule *model.TestSchedule, expected int) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		current, err := doGetTestSchedule(tx, testSchedule.ID)
+		if err != nil {
+			return err
+		}
+
+		revision := 0
+		if current != nil {
+			revision = current.Revision
+		}
+		if expected != AnyRevision && expected != revision {
+			return &RevisionConflictError{expected, revision}
+		}
+		testSchedule.Revision = revision + 1
+
+		// The schedule may run another Test now
+		if current != nil && current.TestID != testSchedule.TestID {
+			if err := doRemoveTestScheduleIndex(tx, current.TestID, testSchedule.ID); err != nil {
+				return err
+			}
+		}
+		if err := doAddTestSchedule(tx, testSchedule); err != nil {
+			return err
+		}
+		if err := doAddTestScheduleIndex(tx, testSchedule); err != nil {
+			return err
+		}
+		// The project of a TestSchedule is part of its ID, so it never changes
+		return doAddProjectIndex(tx, IdxProject2TestScheduleIDBucketName, testSchedule.Project, string(testSchedule.ID))
+	}); err != nil {
+		log.WithError(err).WithField("testSchedule", testSchedule).Error("Failed to save TestSchedule")
+		return err
+	}
+	return nil
+}
+
+func (s *boltStore) DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
 		var testID model.TestID
+		var project string
 		if instance, err := doGetTestSchedule(tx, testScheduleID); err != nil {
 			return err
 		} else {
 			testID = instance.TestID
+			project = instance.Project
 		}
 
 		if err := doDeleteTestSchedule(tx, testScheduleID); err != nil {
@@ -62,7 +101,7 @@ func DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
 		if err := doRemoveTestScheduleIndex(tx, testID, testScheduleID); err != nil {
 			return err
 		}
-		return nil
+		return doRemoveProjectIndex(tx, IdxProject2TestScheduleIDBucketName, project, string(testScheduleID))
 	}); err != nil {
 		log.WithError(err).WithField("testScheduleID", testScheduleID).Error("Failed to delete TestSchedule")
 		return err
@@ -70,9 +109,9 @@ func DeleteTestSchedule(testScheduleID model.TestScheduleID) error {
 	return nil
 }
 
-func GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule, error) {
+func (s *boltStore) GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule, error) {
 	var result *model.TestSchedule
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		if instance, err := doGetTestSchedule(tx, testScheduleID); err != nil {
 			return err
 		} else {
@@ -86,15 +125,15 @@ func GetTestSchedule(testScheduleID model.TestScheduleID) (*model.TestSchedule,
 	return result, nil
 }
 
-func GetAllTestSchedules() ([]*model.TestSchedule, error) {
+func (s *boltStore) GetAllTestSchedules() ([]*model.TestSchedule, error) {
 	var schedules = make([]*model.TestSchedule, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(TestScheduleBucketName))
 		c := b.Cursor()
 
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var schedule *model.TestSchedule
-			if err := tools.GobDecode(&schedule, v); err != nil {
+			if err := decode(&schedule, v); err != nil {
 				return fmt.Errorf("failed to decode TestSchedule due to: %s", err)
 			}
 			schedules = append(schedules, schedule)
@@ -108,9 +147,9 @@ func GetAllTestSchedules() ([]*model.TestSchedule, error) {
 	return schedules, nil
 }
 
-func GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSchedule, error) {
+func (s *boltStore) GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSchedule, error) {
 	var result = make([]*model.TestSchedule, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		if instances, err := doGetTestSchedules(tx, testScheduleIDs); err != nil {
 			return err
 		} else {
@@ -124,9 +163,9 @@ func GetTestSchedules(testScheduleIDs []model.TestScheduleID) ([]*model.TestSche
 	return result, nil
 }
 
-func GetTestSchedulesByTestID(testID model.TestID) ([]*model.TestSchedule, error) {
+func (s *boltStore) GetTestSchedulesByTestID(testID model.TestID) ([]*model.TestSchedule, error) {
 	var result = make([]*model.TestSchedule, 0)
-	if err := db.View(func(tx *bolt.Tx) error {
+	if err := s.db.View(func(tx *bolt.Tx) error {
 		var index *IdxTestID2TestScheduleID
 		if value, err := doGetTestScheduleIndex(tx, testID); err != nil {
 			return err
@@ -158,7 +197,7 @@ func doGetTestSchedule(tx *bolt.Tx, testScheduleID model.TestScheduleID) (*model
 	if data == nil {
 		return nil, nil
 	}
-	if err := tools.GobDecode(&result, data); err != nil {
+	if err := decode(&result, data); err != nil {
 		return nil, fmt.Errorf("failed to decode TestSchedule due to: %s", err)
 	}
 	return result, nil
@@ -169,7 +208,7 @@ func doAddTestSchedule(tx *bolt.Tx, testSchedule *model.TestSchedule) error {
 	if b == nil {
 		return fmt.Errorf("missing bucket '%s'", TestScheduleBucketName)
 	}
-	enc, err := tools.GobEncode(testSchedule)
+	enc, err := encode(testSchedule)
 	if err != nil {
 		return fmt.Errorf("failed to encode TestSchedule due to: %s", err)
 	}
@@ -202,7 +241,7 @@ func doGetTestScheduleIndex(tx *bolt.Tx, testID model.TestID) (*IdxTestID2TestSc
 	}
 
 	var index *IdxTestID2TestScheduleID
-	if err := tools.GobDecode(&index, data); err != nil {
+	if err := decode(&index, data); err != nil {
 		return nil, fmt.Errorf("failed to decode IdxTestID2TestScheduleID due to: %s", err)
 	}
 	return index, nil
@@ -232,7 +271,7 @@ func doAddTestScheduleIndex(tx *bolt.Tx, testSchedule *model.TestSchedule) error
 
 	index.TestScheduleIds[testScheduleID] = true
 
-	enc, err := tools.GobEncode(index)
+	enc, err := encode(index)
 	if err != nil {
 		return fmt.Errorf("failed to encode IdxTestID2TestScheduleID due to: %s", err)
 	}
@@ -261,7 +300,7 @@ func doRemoveTestScheduleIndex(tx *bolt.Tx, testID model.TestID, testScheduleID
 
 	delete(index.TestScheduleIds, testScheduleID)
 
-	enc, err := tools.GobEncode(index)
+	enc, err := encode(index)
 	if err != nil {
 		return fmt.Errorf("failed to encode IdxTestID2TestScheduleID due to: %s", err)
 	}
@@ -281,7 +320,7 @@ func doGetTestSchedules(tx *bolt.Tx, testScheduleIDs []model.TestScheduleID) ([]
 		if data == nil {
 			continue
 		}
-		if err := tools.GobDecode(&value, data); err != nil {
+		if err := decode(&value, data); err != nil {
 			return nil, fmt.Errorf("failed to decode TestSchedule due to: %s", err)
 		}
 		schedules = append(schedules, value)
@@ -298,7 +337,7 @@ func doGetTestSchedulesByIDMap(tx *bolt.Tx, testScheduleIDs map[model.TestSchedu
 		if data == nil {
 			continue
 		}
-		if err := tools.GobDecode(&value, data); err != nil {
+		if err := decode(&value, data); err != nil {
 			return nil, fmt.Errorf("failed to decode TestSchedule due to: %s", err)
 		}
 		schedules = append(schedules, value)
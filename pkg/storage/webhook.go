@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/boltdb/bolt"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// This is the boltDB bucket name for storing "model/Webhook".
+	WebhookBucketName = "Webhook"
+	// This is the boltDB bucket name for storing "model/WebhookDelivery", keyed by their ID in recording order.
+	WebhookDeliveryBucketName = "WebhookDelivery"
+)
+
+// Initializes boltDB for "model/Webhook" and "model/WebhookDelivery" storage.
+func initStorageWebhook(db *bolt.DB) error {
+	for _, bucketName := range []string{WebhookBucketName, WebhookDeliveryBucketName} {
+		if err := db.Update(createInitBucketFunc(bucketName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add or replace a "model/Webhook" in the storage.
+func (s *boltStore) SaveWebhook(webhook *model.Webhook) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebhookBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", WebhookBucketName)
+		}
+		enc, err := encode(webhook)
+		if err != nil {
+			return fmt.Errorf("failed to encode Webhook due to: %s", err)
+		}
+		return b.Put([]byte(webhook.ID), enc)
+	}); err != nil {
+		log.WithError(err).WithField("webhookID", webhook.ID).Error("Failed to save Webhook")
+		return err
+	}
+	return nil
+}
+
+// Delete a "model/Webhook" with the specified WebhookID from the storage.
+func (s *boltStore) DeleteWebhook(webhookID model.WebhookID) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebhookBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", WebhookBucketName)
+		}
+		return b.Delete([]byte(webhookID))
+	}); err != nil {
+		log.WithError(err).WithField("webhookID", webhookID).Error("Failed to delete Webhook")
+		return err
+	}
+	return nil
+}
+
+// Retrieve a "model/Webhook" with the specified WebhookID from the storage.
+func (s *boltStore) GetWebhook(webhookID model.WebhookID) (*model.Webhook, error) {
+	var result *model.Webhook
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(WebhookBucketName)).Get([]byte(webhookID))
+		if data == nil {
+			return nil
+		}
+		if err := decode(&result, data); err != nil {
+			return fmt.Errorf("failed to decode Webhook due to: %s", err)
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("webhookID", webhookID).Error("Failed to GetWebhook")
+		return nil, err
+	}
+	return result, nil
+}
+
+// Retrieve all "model/Webhook" stored in the storage.
+func (s *boltStore) GetAllWebhooks() ([]*model.Webhook, error) {
+	var webhooks = make([]*model.Webhook, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(WebhookBucketName)).ForEach(func(k, v []byte) error {
+			var webhook *model.Webhook
+			if err := decode(&webhook, v); err != nil {
+				return fmt.Errorf("failed to decode Webhook due to: %s", err)
+			}
+			webhooks = append(webhooks, webhook)
+			return nil
+		})
+	}); err != nil {
+		log.WithError(err).Error("Failed to GetAllWebhooks")
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Append a "model/WebhookDelivery" to the storage, assigning its ID.
+func (s *boltStore) AddWebhookDelivery(delivery *model.WebhookDelivery) error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(WebhookDeliveryBucketName))
+		if b == nil {
+			return fmt.Errorf("missing bucket '%s'", WebhookDeliveryBucketName)
+		}
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		delivery.ID = model.WebhookDeliveryID(id)
+		enc, err := encode(delivery)
+		if err != nil {
+			return fmt.Errorf("failed to encode WebhookDelivery due to: %s", err)
+		}
+		return b.Put(deliveryKey(delivery.ID), enc)
+	}); err != nil {
+		log.WithError(err).WithField("delivery", delivery).Error("Failed to add WebhookDelivery")
+		return err
+	}
+	return nil
+}
+
+// Retrieve the latest "model/WebhookDelivery" of the specified WebhookID from the storage, the latest first.
+func (s *boltStore) GetWebhookDeliveries(webhookID model.WebhookID, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries = make([]*model.WebhookDelivery, 0)
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(WebhookDeliveryBucketName)).Cursor()
+		for k, v := c.Last(); k != nil && (limit == 0 || len(deliveries) < limit); k, v = c.Prev() {
+			var delivery *model.WebhookDelivery
+			if err := decode(&delivery, v); err != nil {
+				return fmt.Errorf("failed to decode WebhookDelivery due to: %s", err)
+			}
+			if delivery.WebhookID == webhookID {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		return nil
+	}); err != nil {
+		log.WithError(err).WithField("webhookID", webhookID).Error("Failed to GetWebhookDeliveries")
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Internal function used to generate the key of a delivery, which sorts the deliveries by ID
+func deliveryKey(id model.WebhookDeliveryID) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
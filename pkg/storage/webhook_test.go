@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/t-bfame/diago/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndGetWebhook(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	webhook := &model.Webhook{
+		ID:      "team-a:chat",
+		Name:    "chat",
+		Project: "team-a",
+		URL:     "https://chat.example.com/hooks/1",
+		Events:  []model.WebhookEvent{model.WebhookDone, model.WebhookFailed},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Secret:  "secret",
+	}
+	if err := SaveWebhook(webhook); err != nil {
+		t.Fatal("Failed to save webhook")
+	}
+	other := &model.Webhook{ID: "ci", Name: "ci", URL: "https://ci.example.com"}
+	if err := SaveWebhook(other); err != nil {
+		t.Fatal("Failed to save webhook")
+	}
+
+	result, err := GetWebhook(webhook.ID)
+	if err != nil {
+		t.Fatal("Failed to get webhook")
+	}
+	assert.Equal(t, webhook, result)
+
+	all, err := GetAllWebhooks()
+	if err != nil {
+		t.Fatal("Failed to get all webhooks")
+	}
+	assert.ElementsMatch(t, []*model.Webhook{webhook, other}, all)
+
+	if err := DeleteWebhook(webhook.ID); err != nil {
+		t.Fatal("Failed to delete webhook")
+	}
+	if result, _ := GetWebhook(webhook.ID); result != nil {
+		t.Error("Expected the webhook to be deleted")
+	}
+}
+
+func TestAddAndGetWebhookDeliveries(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	deliveries := []*model.WebhookDelivery{
+		{WebhookID: "chat", Event: model.WebhookSubmitted, InstanceID: testInstanceId1, Time: 100, Attempts: 1, Status: 200, Delivered: true},
+		{WebhookID: "ci", Event: model.WebhookSubmitted, InstanceID: testInstanceId1, Time: 100, Attempts: 1, Status: 204, Delivered: true},
+		{WebhookID: "chat", Event: model.WebhookDone, InstanceID: testInstanceId1, Time: 200, Attempts: 3, Status: 503, Error: "503 Service Unavailable"},
+	}
+	for i, delivery := range deliveries {
+		if err := AddWebhookDelivery(delivery); err != nil {
+			t.Fatalf("Failed to add delivery %d", i)
+		}
+		assert.Equal(t, model.WebhookDeliveryID(i+1), delivery.ID)
+	}
+
+	chat, err := GetWebhookDeliveries("chat", 0)
+	if err != nil {
+		t.Fatal("Failed to get deliveries")
+	}
+	assert.Equal(t, []*model.WebhookDelivery{deliveries[2], deliveries[0]}, chat)
+
+	latest, _ := GetWebhookDeliveries("chat", 1)
+	assert.Equal(t, []*model.WebhookDelivery{deliveries[2]}, latest)
+
+	none, _ := GetWebhookDeliveries("missing", 0)
+	assert.Empty(t, none)
+}
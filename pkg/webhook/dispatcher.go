@@ -0,0 +1,266 @@
+// Package webhook delivers the InstanceSummaries of TestInstances entering a status to the
+// Webhooks subscribed to them, retrying failed deliveries and recording every delivery.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+const (
+	// SignatureHeader holds the hex HMAC-SHA256 of the timestamp and body of a request, see Sign
+	SignatureHeader = "X-Diago-Signature"
+	// TimestampHeader holds the Unix time a request was signed at
+	TimestampHeader = "X-Diago-Timestamp"
+	// EventHeader holds the WebhookEvent of a request
+	EventHeader = "X-Diago-Event"
+
+	// queueSize is the number of summaries a webhook can fall behind by
+	// before further summaries are dropped
+	queueSize = 64
+)
+
+// Options configure a Dispatcher
+type Options struct {
+	// Timeout of each request
+	Timeout time.Duration
+	// MaxAttempts is the number of requests sent before a delivery fails
+	MaxAttempts int
+	// Backoff is the wait before the first retry, which doubles with every retry
+	Backoff time.Duration
+}
+
+// Dispatcher delivers InstanceSummaries to the Webhooks subscribed to them. Every Webhook has
+// its own queue, so that summaries reach it in order and a slow endpoint only delays its own.
+// Queues only exist while summaries are waiting, and the Webhook is read again before each
+// delivery, so that updated and deleted Webhooks take effect on summaries already queued.
+type Dispatcher struct {
+	client  *http.Client
+	options Options
+
+	mux    sync.Mutex
+	queues map[m.WebhookID]chan *m.InstanceSummary
+}
+
+// NewDispatcher creates a Dispatcher with the given Options
+func NewDispatcher(options Options) *Dispatcher {
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 1
+	}
+	return &Dispatcher{
+		client:  &http.Client{Timeout: options.Timeout},
+		options: options,
+		queues:  map[m.WebhookID]chan *m.InstanceSummary{},
+	}
+}
+
+// Notify queues summary for delivery to every Webhook it matches, without waiting for the deliveries
+func (d *Dispatcher) Notify(summary *m.InstanceSummary) {
+	webhooks, err := sto.GetAllWebhooks()
+	if err != nil {
+		log.WithError(err).WithField("event", summary.Event).Error("Failed to retrieve webhooks")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Matches(summary) {
+			continue
+		}
+
+		if !d.enqueue(webhook.ID, summary) {
+			// Dropped summaries are recorded, so they are not lost silently
+			record(newDelivery(webhook, summary), fmt.Errorf("%d deliveries are already queued", queueSize))
+		}
+	}
+}
+
+// Deliver sends summary to webhook right away, retrying until it is accepted or every
+// attempt failed, and records the delivery
+func (d *Dispatcher) Deliver(webhook *m.Webhook, summary *m.InstanceSummary) *m.WebhookDelivery {
+	delivery := newDelivery(webhook, summary)
+
+	body, err := Render(webhook, summary)
+	if err != nil {
+		record(delivery, err)
+		return delivery
+	}
+
+	backoff := d.options.Backoff
+	for {
+		delivery.Attempts++
+		retry := false
+		delivery.Status, retry, err = d.send(webhook, summary.Event, body)
+		if err == nil {
+			delivery.Delivered = true
+			break
+		}
+		if !retry || delivery.Attempts >= d.options.MaxAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	record(delivery, err)
+	return delivery
+}
+
+// Internal function used to queue summary for the webhook with the given id, starting its
+// delivery goroutine if needed. Returns false if the queue is full.
+func (d *Dispatcher) enqueue(id m.WebhookID, summary *m.InstanceSummary) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	queue, exists := d.queues[id]
+	if !exists {
+		queue = make(chan *m.InstanceSummary, queueSize)
+		d.queues[id] = queue
+		go d.drain(id, queue)
+	}
+
+	select {
+	case queue <- summary:
+		return true
+	default:
+		return false
+	}
+}
+
+// Internal function used to deliver the summaries queued for a webhook, until none are left
+func (d *Dispatcher) drain(id m.WebhookID, queue chan *m.InstanceSummary) {
+	for {
+		var summary *m.InstanceSummary
+
+		// The queue is dropped under the lock, so that no summary is queued after the last one was taken
+		d.mux.Lock()
+		select {
+		case summary = <-queue:
+		default:
+			delete(d.queues, id)
+		}
+		d.mux.Unlock()
+
+		if summary == nil {
+			return
+		}
+
+		webhook, err := sto.GetWebhook(id)
+		if err != nil {
+			log.WithError(err).WithField("WebhookID", id).Error("Failed to retrieve webhook")
+			continue
+		}
+		// The webhook was deleted, or updated not to fire for the summary anymore
+		if webhook == nil || !webhook.Matches(summary) {
+			continue
+		}
+		d.Deliver(webhook, summary)
+	}
+}
+
+// Internal function used to send a single request, returning the status of the response
+// and whether a failure is worth retrying
+func (d *Dispatcher) send(webhook *m.Webhook, event m.WebhookEvent, body []byte) (int, bool, error) {
+	r, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+
+	contentType := webhook.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("User-Agent", "diago")
+	for name, value := range webhook.Headers {
+		r.Header.Set(name, value)
+	}
+	r.Header.Set(EventHeader, string(event))
+	if webhook.Secret != "" {
+		timestamp := time.Now().Unix()
+		r.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		r.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	}
+
+	response, err := d.client.Do(r)
+	if err != nil {
+		return 0, true, err
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response.StatusCode, false, nil
+	}
+	// Rejected payloads fail the same way again, unlike unavailable endpoints
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode == http.StatusRequestTimeout
+	return response.StatusCode, retry, fmt.Errorf("endpoint responded %s", response.Status)
+}
+
+// Render returns the body of the request notifying webhook of summary
+func Render(webhook *m.Webhook, summary *m.InstanceSummary) ([]byte, error) {
+	tmpl, err := webhook.ParseTemplate()
+	if err != nil {
+		return nil, err
+	} else if tmpl == nil {
+		return json.Marshal(summary)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, summary); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// Sign returns the signature of a request with the given body signed at timestamp with secret,
+// as sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body.
+// Endpoints verify it by computing the same and rejecting stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Internal function used to create the delivery of summary to webhook
+func newDelivery(webhook *m.Webhook, summary *m.InstanceSummary) *m.WebhookDelivery {
+	return &m.WebhookDelivery{
+		WebhookID:  webhook.ID,
+		Event:      summary.Event,
+		InstanceID: summary.InstanceID,
+		TestID:     summary.TestID,
+		Time:       time.Now().Unix(),
+	}
+}
+
+// Internal function used to record a delivery in the delivery log
+func record(delivery *m.WebhookDelivery, err error) {
+	logger := log.
+		WithField("WebhookID", delivery.WebhookID).
+		WithField("Event", delivery.Event).
+		WithField("TestInstanceID", delivery.InstanceID)
+	if err != nil {
+		delivery.Error = err.Error()
+		logger.WithError(err).WithField("Attempts", delivery.Attempts).Warn("Failed to deliver webhook")
+	}
+	if err := sto.AddWebhookDelivery(delivery); err != nil {
+		logger.WithError(err).Error("Failed to record webhook delivery")
+	}
+}
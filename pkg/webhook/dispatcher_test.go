@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	sto "github.com/t-bfame/diago/pkg/storage"
+)
+
+const testDBName = "webhookTest.db"
+
+func initTestDB(t *testing.T) {
+	if err := sto.InitDatabase(testDBName); err != nil {
+		t.Fatal("Failed to init database")
+	}
+}
+
+func removeTestDB() {
+	sto.Close()
+	os.Remove(testDBName)
+}
+
+// endpoint records the requests it receives and responds with the given statuses in turn,
+// once release is closed if set
+type endpoint struct {
+	mux      sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+	release  chan struct{}
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	e.mux.Lock()
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, string(body))
+	status := http.StatusOK
+	if len(e.statuses) > 0 {
+		status, e.statuses = e.statuses[0], e.statuses[1:]
+	}
+	e.mux.Unlock()
+
+	if e.release != nil {
+		<-e.release
+	}
+	w.WriteHeader(status)
+}
+
+func (e *endpoint) received() int {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	return len(e.requests)
+}
+
+// Internal function used to wait until cond holds
+func eventually(t *testing.T, cond func() bool, message string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	target := &endpoint{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	webhook := &m.Webhook{
+		ID:       "chat",
+		URL:      server.URL,
+		Secret:   "secret",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Template: `{"text": {{json .TestName}}}`,
+	}
+	summary := &m.InstanceSummary{Event: m.WebhookDone, InstanceID: "checkout-1", TestID: "checkout", TestName: "checkout"}
+
+	d := NewDispatcher(Options{MaxAttempts: 3, Backoff: time.Millisecond})
+	delivery := d.Deliver(webhook, summary)
+	if !delivery.Delivered || delivery.Attempts != 3 || delivery.Status != http.StatusOK || delivery.Error != "" {
+		t.Errorf("Expected the delivery to succeed on the third attempt, got %+v", delivery)
+	}
+
+	r, body := target.requests[2], target.bodies[2]
+	if body != `{"text": "checkout"}` {
+		t.Errorf("Expected the templated body, got %s", body)
+	}
+	if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get(EventHeader) != "done" ||
+		r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", r.Header)
+	}
+	timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if r.Header.Get(SignatureHeader) != Sign("secret", timestamp, []byte(body)) {
+		t.Errorf("Expected the body to be signed, got %s", r.Header.Get(SignatureHeader))
+	}
+
+	deliveries, _ := sto.GetWebhookDeliveries(webhook.ID, 0)
+	if len(deliveries) != 1 || deliveries[0].ID != delivery.ID || deliveries[0].InstanceID != "checkout-1" {
+		t.Errorf("Expected the delivery to be recorded, got %v", deliveries)
+	}
+}
+
+func TestDeliver_Failure(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	target := &endpoint{statuses: []int{http.StatusBadRequest, http.StatusInternalServerError}}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	d := NewDispatcher(Options{MaxAttempts: 3, Backoff: time.Millisecond})
+	summary := &m.InstanceSummary{Event: m.WebhookFailed}
+
+	// Rejected payloads are not retried
+	delivery := d.Deliver(&m.Webhook{ID: "chat", URL: server.URL}, summary)
+	if delivery.Delivered || delivery.Attempts != 1 || delivery.Status != http.StatusBadRequest || delivery.Error == "" {
+		t.Errorf("Expected a single failed attempt, got %+v", delivery)
+	}
+	if len(target.requests) != 1 || target.requests[0].Header.Get(SignatureHeader) != "" {
+		t.Errorf("Expected a single unsigned request, got %d", len(target.requests))
+	}
+
+	// Unreachable endpoints are retried until every attempt failed
+	server.Close()
+	delivery = d.Deliver(&m.Webhook{ID: "chat", URL: server.URL}, summary)
+	if delivery.Delivered || delivery.Attempts != 3 || delivery.Status != 0 {
+		t.Errorf("Expected 3 failed attempts, got %+v", delivery)
+	}
+
+	deliveries, _ := sto.GetWebhookDeliveries("chat", 0)
+	if len(deliveries) != 2 || deliveries[0].Attempts != 3 {
+		t.Errorf("Expected both deliveries to be recorded, got %v", deliveries)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	target := &endpoint{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	sto.SaveWebhook(&m.Webhook{ID: "all", URL: server.URL})
+	sto.SaveWebhook(&m.Webhook{ID: "failures", URL: server.URL, FailuresOnly: true})
+	sto.SaveWebhook(&m.Webhook{ID: "team-a:all", Project: "team-a", URL: server.URL})
+
+	d := NewDispatcher(Options{MaxAttempts: 1})
+	for _, event := range []m.WebhookEvent{m.WebhookSubmitted, m.WebhookRunning, m.WebhookAborted} {
+		d.Notify(&m.InstanceSummary{Event: event, InstanceID: "checkout-1", TestID: "checkout"})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		all, _ := sto.GetWebhookDeliveries("all", 0)
+		failures, _ := sto.GetWebhookDeliveries("failures", 0)
+		if len(all) == 3 && len(failures) == 1 {
+			// Summaries reach each webhook in order
+			if all[0].Event != m.WebhookAborted || all[1].Event != m.WebhookRunning || all[2].Event != m.WebhookSubmitted {
+				t.Errorf("Expected the deliveries in order, got %v", all)
+			}
+			if failures[0].Event != m.WebhookAborted {
+				t.Errorf("Expected only the abort to be delivered, got %s", failures[0].Event)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 4 deliveries, got %d and %d", len(all), len(failures))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if other, _ := sto.GetWebhookDeliveries("team-a:all", 0); len(other) != 0 {
+		t.Errorf("Expected no delivery to the webhook of another project, got %d", len(other))
+	}
+}
+
+func TestNotify_UpdatedWebhooks(t *testing.T) {
+	initTestDB(t)
+	defer removeTestDB()
+
+	old := &endpoint{release: make(chan struct{})}
+	oldServer := httptest.NewServer(old)
+	defer oldServer.Close()
+
+	updated := &endpoint{}
+	updatedServer := httptest.NewServer(updated)
+	defer updatedServer.Close()
+
+	sto.SaveWebhook(&m.Webhook{ID: "chat", URL: oldServer.URL})
+	sto.SaveWebhook(&m.Webhook{ID: "gone", URL: oldServer.URL})
+
+	d := NewDispatcher(Options{MaxAttempts: 1})
+	d.Notify(&m.InstanceSummary{Event: m.WebhookRunning, InstanceID: "checkout-1"})
+	eventually(t, func() bool { return old.received() == 2 }, "Expected both webhooks to be notified")
+
+	// Summaries queued behind the pending deliveries follow the changes of their webhook
+	d.Notify(&m.InstanceSummary{Event: m.WebhookDone, InstanceID: "checkout-1"})
+	sto.SaveWebhook(&m.Webhook{ID: "chat", URL: updatedServer.URL})
+	sto.DeleteWebhook("gone")
+	close(old.release)
+
+	eventually(t, func() bool {
+		d.mux.Lock()
+		defer d.mux.Unlock()
+		return len(d.queues) == 0
+	}, "Expected the queues to be dropped once drained")
+
+	if old.received() != 2 || updated.received() != 1 {
+		t.Errorf("Expected the second summary only at the updated URL, got %d and %d", old.received(), updated.received())
+	}
+	if deliveries, _ := sto.GetWebhookDeliveries("gone", 0); len(deliveries) != 1 {
+		t.Errorf("Expected no delivery to the deleted webhook after its deletion, got %d", len(deliveries))
+	}
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	pytypes "github.com/golang/protobuf/ptypes"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	pb "github.com/t-bfame/diago/proto-gen/worker"
+)
+
+// defaultTimeout is used for requests which don't specify a timeout
+const defaultTimeout = 30 * time.Second
+
+// All clients share connections
+var transport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 1000
+	return t
+}()
+
+type client struct {
+	*http.Client
+}
+
+// Internal function used to create a client with the timeout (in milliseconds)
+// and redirect policy of a request
+func newClient(timeout uint64, redirects int32) *client {
+	c := &http.Client{
+		Transport: transport,
+		Timeout:   defaultTimeout,
+	}
+
+	if timeout > 0 {
+		c.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+
+	switch {
+	case redirects < 0:
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	case redirects > 0:
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > int(redirects) {
+				return fmt.Errorf("stopped after %d redirects", redirects)
+			}
+			return nil
+		}
+	}
+
+	return &client{c}
+}
+
+// do sends r after replacing variable references with their values in vars.
+// The response body is only kept if keepBody is set, otherwise it is discarded.
+func (c *client) do(ctx context.Context, r *pb.HTTPRequest, vars map[string]string, keepBody bool) (*pb.Metrics, *http.Response, []byte) {
+	start := time.Now()
+	timestamp, _ := pytypes.TimestampProto(start)
+	metrics := &pb.Metrics{Timestamp: timestamp}
+
+	req, err := buildRequest(ctx, r, vars)
+	if err != nil {
+		metrics.Error = err.Error()
+		return metrics, nil, nil
+	}
+	metrics.BytesOut = uint64(req.ContentLength)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		metrics.Latency = int64(time.Since(start))
+		metrics.Error = err.Error()
+		return metrics, nil, nil
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	var read int64
+	if keepBody {
+		body, err = ioutil.ReadAll(resp.Body)
+		read = int64(len(body))
+	} else {
+		read, err = io.Copy(ioutil.Discard, resp.Body)
+	}
+
+	metrics.Latency = int64(time.Since(start))
+	metrics.Code = uint32(resp.StatusCode)
+	metrics.BytesIn = uint64(read)
+
+	if err != nil {
+		metrics.Error = err.Error()
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		metrics.Error = resp.Status
+	}
+
+	return metrics, resp, body
+}
+
+// Internal function used to create an http request out of its protobuf message
+func buildRequest(ctx context.Context, r *pb.HTTPRequest, vars map[string]string) (*http.Request, error) {
+	u, err := url.Parse(m.ExpandVariables(r.GetUrl(), vars))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.GetQuery()) > 0 {
+		query := u.Query()
+		for name, value := range r.GetQuery() {
+			query.Set(name, m.ExpandVariables(value, vars))
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	// Binary bodies can't contain variable references
+	body := r.GetBody()
+	if len(vars) > 0 && utf8.Valid(body) {
+		body = []byte(m.ExpandVariables(string(body), vars))
+	}
+
+	method := r.GetMethod()
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range r.GetHeaders() {
+		value = m.ExpandVariables(value, vars)
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
+}
+
+// Internal function used to extract the value of a variable from a response
+func extractValue(e *pb.Extraction, resp *http.Response, body []byte, pattern *regexp.Regexp) (string, error) {
+	switch m.ExtractionSource(e.GetSource()) {
+	case m.ExtractHeader:
+		if value := resp.Header.Get(e.GetExpression()); value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("header %s is not set", e.GetExpression())
+
+	case m.ExtractRegex:
+		match := pattern.FindSubmatch(body)
+		if match == nil {
+			return "", errors.New("body does not match")
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+
+	case m.ExtractJSON:
+		return extractJSON(e.GetExpression(), body)
+	}
+
+	return "", fmt.Errorf("unknown source %s", e.GetSource())
+}
+
+// Internal function used to select a value of a json document with a path like `$.items[0].id`
+func extractJSON(expr string, body []byte) (string, error) {
+	path, err := m.ParseJSONPath(expr)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", errors.New("body is not json")
+	}
+
+	for _, segment := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return "", fmt.Errorf("%s has no key %s", expr, segment)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", fmt.Errorf("%s has no element %s", expr, segment)
+			}
+			value = v[i]
+		default:
+			return "", fmt.Errorf("%s does not select a value", expr)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Config of a worker, set through the env variables a worker is provisioned with
+type Config struct {
+	Group    string `envconfig:"DIAGO_WORKER_GROUP" required:"true"`
+	Instance string `envconfig:"DIAGO_WORKER_GROUP_INSTANCE" required:"true"`
+
+	LeaderHost string `envconfig:"DIAGO_LEADER_HOST" required:"true"`
+	LeaderPort uint64 `envconfig:"DIAGO_LEADER_PORT" default:"5000"`
+
+	// Capacity is the frequency the worker registers with
+	Capacity uint64 `envconfig:"DIAGO_WORKER_GROUP_INSTANCE_CAPACITY" default:"200"`
+
+	// InactivityPeriod is the number of seconds without any job after
+	// which the worker exits, 0 keeps the worker running
+	InactivityPeriod uint64 `envconfig:"ALLOWED_INACTIVITY_PERIOD_SECONDS" default:"60"`
+
+	// ReconnectPeriod is the number of seconds the worker tries to connect
+	// to the leader again after losing its connection, 0 disables reconnecting
+	ReconnectPeriod uint64 `envconfig:"DIAGO_WORKER_RECONNECT_PERIOD_SECONDS" default:"60"`
+}
+
+// ConfigFromEnv reads the worker config from env variables
+func ConfigFromEnv() (*Config, error) {
+	var c Config
+
+	if err := envconfig.Process("", &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	m "github.com/t-bfame/diago/pkg/model"
+	pb "github.com/t-bfame/diago/proto-gen/worker"
+)
+
+// job is a Start assignment which sends requests at its frequency for its duration
+type job struct {
+	id        string
+	frequency uint64 // accessed atomically, changes with Rate messages
+	duration  time.Duration
+
+	request *pb.HTTPRequest
+	client  *client
+
+	scenario []*pb.Step
+	clients  []*client
+	patterns map[string]*regexp.Regexp
+
+	send func(*pb.Message) error
+	// generation of the stream the job was started on, see Worker.sender
+	generation uint64
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// Internal function used to create a job from a Start message
+func newJob(start *pb.Start, send func(*pb.Message) error) (*job, error) {
+	j := &job{
+		id:        start.GetJobId(),
+		frequency: start.GetFrequency(),
+		duration:  time.Duration(start.GetDuration()) * time.Second,
+		request:   start.GetRequest(),
+		scenario:  start.GetScenario(),
+		patterns:  make(map[string]*regexp.Regexp),
+		send:      send,
+		stopped:   make(chan struct{}),
+	}
+
+	if len(j.scenario) == 0 {
+		if j.request.GetUrl() == "" {
+			return nil, errors.New("Start has neither a request nor a scenario")
+		}
+		j.client = newClient(j.request.GetTimeout(), j.request.GetRedirects())
+		return j, nil
+	}
+
+	for _, step := range j.scenario {
+		j.clients = append(j.clients, newClient(step.GetRequest().GetTimeout(), 0))
+
+		for _, e := range step.GetExtract() {
+			if m.ExtractionSource(e.GetSource()) != m.ExtractRegex {
+				continue
+			}
+			re, err := regexp.Compile(e.GetExpression())
+			if err != nil {
+				return nil, err
+			}
+			j.patterns[e.GetExpression()] = re
+		}
+	}
+
+	return j, nil
+}
+
+func (j *job) setFrequency(frequency uint64) {
+	atomic.StoreUint64(&j.frequency, frequency)
+}
+
+func (j *job) stop() {
+	j.stopOnce.Do(func() {
+		close(j.stopped)
+	})
+}
+
+// run sends requests following an open model, i.e. a new request is started
+// at every tick regardless of whether the previous ones have completed.
+// Once the job ends, in flight requests are awaited and Finish is sent.
+func (j *job) run(ctx context.Context) {
+	var inflight sync.WaitGroup
+
+	var end <-chan time.Time
+	if j.duration > 0 {
+		timer := time.NewTimer(j.duration)
+		defer timer.Stop()
+		end = timer.C
+	}
+
+	tick := time.NewTimer(0)
+	defer tick.Stop()
+	next := time.Now()
+
+loop:
+	for {
+		select {
+		case <-tick.C:
+		case <-end:
+			break loop
+		case <-j.stopped:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+
+		now := time.Now()
+		frequency := atomic.LoadUint64(&j.frequency)
+		if frequency == 0 {
+			// paused until the rate changes
+			next = now
+			tick.Reset(100 * time.Millisecond)
+			continue
+		}
+
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			j.hit(ctx)
+		}()
+
+		// Don't try to catch up on ticks which were missed by more than a second
+		next = next.Add(time.Second / time.Duration(frequency))
+		if now.Sub(next) > time.Second {
+			next = now
+		}
+		tick.Reset(time.Until(next))
+	}
+
+	inflight.Wait()
+	j.send(finishMessage(j.id))
+}
+
+// hit runs the request or one iteration of the scenario of the job
+func (j *job) hit(ctx context.Context) {
+	if len(j.scenario) == 0 {
+		metrics, _, _ := j.client.do(ctx, j.request, nil, false)
+		metrics.JobId = j.id
+		j.send(metricsMessage(metrics))
+		return
+	}
+
+	vars := make(map[string]string)
+	for i, step := range j.scenario {
+		extract := len(step.GetExtract()) > 0
+		metrics, resp, body := j.clients[i].do(ctx, step.GetRequest(), vars, extract)
+		metrics.JobId = j.id
+		metrics.Step = step.GetName()
+
+		failed := metrics.Error != ""
+		if !failed {
+			for _, e := range step.GetExtract() {
+				value, err := extractValue(e, resp, body, j.patterns[e.GetExpression()])
+				if err != nil {
+					metrics.Error = "Unable to extract " + e.GetName() + ": " + err.Error()
+					failed = true
+					break
+				}
+				vars[e.GetName()] = value
+			}
+		}
+		j.send(metricsMessage(metrics))
+
+		// Following steps depend on this one, so the iteration ends here
+		if failed {
+			return
+		}
+
+		if think := step.GetThinkTime(); think > 0 && i < len(j.scenario)-1 {
+			select {
+			case <-time.After(time.Duration(think) * time.Millisecond):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func metricsMessage(metrics *pb.Metrics) *pb.Message {
+	return &pb.Message{
+		Payload: &pb.Message_Metrics{
+			Metrics: metrics,
+		},
+	}
+}
+
+func finishMessage(id string) *pb.Message {
+	return &pb.Message{
+		Payload: &pb.Message_Finish{
+			Finish: &pb.Finish{
+				JobId: id,
+			},
+		},
+	}
+}
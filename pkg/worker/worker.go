@@ -0,0 +1,316 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	pb "github.com/t-bfame/diago/proto-gen/worker"
+)
+
+const (
+	// dialTimeout limits how long a single attempt to connect to the leader takes
+	dialTimeout = 10 * time.Second
+	// maxBackoff is the longest wait between attempts to reconnect to the leader
+	maxBackoff = 10 * time.Second
+)
+
+// Worker generates load for the jobs the leader assigns to it
+type Worker struct {
+	config *Config
+
+	// stream is swapped on reconnect and stream.Send must not be called concurrently, both under sendmux
+	stream  pb.Worker_CoordinateClient
+	sendmux sync.Mutex
+
+	// generation of the stream, incremented whenever it is swapped
+	generation uint64
+
+	jobs   map[string]*job
+	jobmux sync.Mutex
+
+	// signalled whenever a job finishes
+	finished chan struct{}
+
+	// number of times the worker registered with a leader
+	sessions uint64
+}
+
+// errStreamReplaced is returned when sending messages of a job started on a previous stream
+var errStreamReplaced = errors.New("Stream the job was started on was replaced")
+
+// send sends a message to the leader
+func (w *Worker) send(msg *pb.Message) error {
+	w.sendmux.Lock()
+	defer w.sendmux.Unlock()
+
+	return w.stream.Send(msg)
+}
+
+// Internal function used to send the messages of jobs started on the given generation of the stream.
+// Messages are discarded once the stream was replaced, the leader reassigned the jobs meanwhile.
+func (w *Worker) sender(generation uint64) func(*pb.Message) error {
+	return func(msg *pb.Message) error {
+		w.sendmux.Lock()
+		defer w.sendmux.Unlock()
+
+		if w.generation != generation {
+			return errStreamReplaced
+		}
+		return w.stream.Send(msg)
+	}
+}
+
+// Run connects to the leader and runs assigned jobs until the connection
+// is closed, ctx is cancelled or the worker has been inactive for too long.
+// If the connection is lost, e.g. because the leader restarts, the worker
+// connects and registers again for up to its reconnect period.
+func (w *Worker) Run(ctx context.Context) error {
+	address := fmt.Sprintf("%s:%d", w.config.LeaderHost, w.config.LeaderPort)
+	period := time.Duration(w.config.ReconnectPeriod) * time.Second
+
+	lost := time.Now()
+	backoff := time.Second
+
+	for {
+		sessions := w.sessions
+		err := w.connect(ctx, address)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			return nil
+		}
+
+		if w.sessions != sessions {
+			lost = time.Now()
+			backoff = time.Second
+		}
+		if time.Since(lost)+backoff > period {
+			return err
+		}
+
+		log.WithError(err).WithField("retryIn", backoff).Warning("Lost connection to leader, reconnecting")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if backoff < maxBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+// Internal function used to connect to the leader for a single session
+func (w *Worker) connect(ctx context.Context, address string) error {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(dialCtx, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("Unable to connect to leader at %s: %s", address, err)
+	}
+	defer conn.Close()
+
+	return w.Coordinate(ctx, pb.NewWorkerClient(conn))
+}
+
+// Coordinate registers with the leader using the given client and runs assigned jobs
+func (w *Worker) Coordinate(ctx context.Context, client pb.WorkerClient) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.Coordinate(ctx)
+	if err != nil {
+		return err
+	}
+	// Hits of jobs from a previous session may still be sending
+	w.sendmux.Lock()
+	w.stream = stream
+	w.generation++
+	generation := w.generation
+	w.sendmux.Unlock()
+
+	err = w.send(&pb.Message{
+		Payload: &pb.Message_Register{
+			Register: &pb.Register{
+				Group:     w.config.Group,
+				Instance:  w.config.Instance,
+				Frequency: w.config.Capacity,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to register with leader: %s", err)
+	}
+
+	w.sessions++
+	log.WithField("group", w.config.Group).WithField("instance", w.config.Instance).Info("Registered with leader")
+
+	messages := make(chan *pb.Message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	inactivity := w.inactivityTimer()
+
+	for {
+		select {
+		case msg := <-messages:
+			w.handle(ctx, msg, generation)
+			inactivity.Stop()
+			if w.activeJobs() == 0 {
+				inactivity = w.inactivityTimer()
+			}
+
+		case <-w.finished:
+			if w.activeJobs() == 0 {
+				inactivity = w.inactivityTimer()
+			}
+
+		case <-inactivity.C:
+			log.WithField("period", w.config.InactivityPeriod).Info("Worker was inactive for too long, exiting")
+			w.stopJobs()
+
+			w.sendmux.Lock()
+			defer w.sendmux.Unlock()
+			return stream.CloseSend()
+
+		case err := <-errs:
+			w.stopJobs()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+
+		case <-ctx.Done():
+			w.stopJobs()
+			return ctx.Err()
+		}
+	}
+}
+
+// Internal function used to handle a message received from the leader on the given generation of the stream
+func (w *Worker) handle(ctx context.Context, msg *pb.Message, generation uint64) {
+	switch msg.Payload.(type) {
+	case *pb.Message_Start:
+		start := msg.GetStart()
+		j, err := newJob(start, w.sender(generation))
+		if err != nil {
+			log.WithError(err).WithField("jobID", start.GetJobId()).Error("Unable to start job")
+			w.send(finishMessage(start.GetJobId()))
+			return
+		}
+		j.generation = generation
+
+		// Jobs of a previous stream were stopped already, but may not have finished yet
+		w.jobmux.Lock()
+		if running, ok := w.jobs[j.id]; ok && running.generation == generation {
+			w.jobmux.Unlock()
+			log.WithField("jobID", j.id).Warning("Job is already running, ignoring start")
+			return
+		}
+		w.jobs[j.id] = j
+		w.jobmux.Unlock()
+
+		log.WithField("jobID", j.id).WithField("frequency", start.GetFrequency()).Info("Starting job")
+
+		go func() {
+			j.run(ctx)
+
+			w.jobmux.Lock()
+			if w.jobs[j.id] == j {
+				delete(w.jobs, j.id)
+			}
+			w.jobmux.Unlock()
+
+			log.WithField("jobID", j.id).Info("Finished job")
+			select {
+			case w.finished <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}()
+
+	case *pb.Message_Stop:
+		if j := w.job(msg.GetStop().GetJobId()); j != nil {
+			log.WithField("jobID", j.id).Info("Stopping job")
+			j.stop()
+		}
+
+	case *pb.Message_Rate:
+		rate := msg.GetRate()
+		if j := w.job(rate.GetJobId()); j != nil {
+			log.WithField("jobID", j.id).WithField("frequency", rate.GetFrequency()).Debug("Changing job rate")
+			j.setFrequency(rate.GetFrequency())
+		}
+
+	default:
+		log.WithField("recvdType", fmt.Sprintf("%T", msg.Payload)).Warning("Ignoring message with unexpected type")
+	}
+}
+
+func (w *Worker) job(id string) *job {
+	w.jobmux.Lock()
+	defer w.jobmux.Unlock()
+
+	return w.jobs[id]
+}
+
+func (w *Worker) activeJobs() int {
+	w.jobmux.Lock()
+	defer w.jobmux.Unlock()
+
+	return len(w.jobs)
+}
+
+func (w *Worker) stopJobs() {
+	w.jobmux.Lock()
+	defer w.jobmux.Unlock()
+
+	for _, j := range w.jobs {
+		j.stop()
+	}
+}
+
+// Internal function used to create the timer after which an idle worker exits
+func (w *Worker) inactivityTimer() *time.Timer {
+	if w.config.InactivityPeriod == 0 {
+		// never fires
+		t := time.NewTimer(time.Hour)
+		t.Stop()
+		return t
+	}
+
+	return time.NewTimer(time.Duration(w.config.InactivityPeriod) * time.Second)
+}
+
+// NewWorker creates a new Worker
+func NewWorker(config *Config) *Worker {
+	w := new(Worker)
+	w.config = config
+	w.jobs = make(map[string]*job)
+	w.finished = make(chan struct{})
+
+	return w
+}
@@ -0,0 +1,366 @@
+package worker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/t-bfame/diago/proto-gen/worker"
+)
+
+// leader is a fake leader which runs a single job on the first worker that registers
+type leader struct {
+	pb.UnimplementedWorkerServer
+
+	start    *pb.Start
+	register chan *pb.Register
+	metrics  chan *pb.Metrics
+	finished chan string
+}
+
+func (l *leader) Coordinate(stream pb.Worker_CoordinateServer) error {
+	msg, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	l.register <- msg.GetRegister()
+
+	if err := stream.Send(&pb.Message{Payload: &pb.Message_Start{Start: l.start}}); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		switch msg.Payload.(type) {
+		case *pb.Message_Metrics:
+			l.metrics <- msg.GetMetrics()
+		case *pb.Message_Finish:
+			l.finished <- msg.GetFinish().GetJobId()
+		}
+	}
+}
+
+func TestWorker_Coordinate(t *testing.T) {
+	var mux sync.Mutex
+	requests := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		requests++
+		mux.Unlock()
+
+		if r.Method != http.MethodPost || r.Header.Get("X-Test") != "yes" || r.URL.Query().Get("q") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	l := &leader{
+		start: &pb.Start{
+			JobId:     "job",
+			Frequency: 20,
+			Duration:  1,
+			Request: &pb.HTTPRequest{
+				Method:  http.MethodPost,
+				Url:     target.URL,
+				Headers: map[string]string{"X-Test": "yes"},
+				Query:   map[string]string{"q": "1"},
+				Body:    []byte("hello"),
+			},
+		},
+		register: make(chan *pb.Register, 1),
+		metrics:  make(chan *pb.Metrics, 100),
+		finished: make(chan string, 1),
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterWorkerServer(server, l)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewWorker(&Config{Group: "group", Instance: "abc123", Capacity: 50, InactivityPeriod: 1})
+	done := make(chan error)
+	go func() {
+		done <- w.Coordinate(context.Background(), pb.NewWorkerClient(conn))
+	}()
+
+	reg := <-l.register
+	if reg.GetGroup() != "group" || reg.GetInstance() != "abc123" || reg.GetFrequency() != 50 {
+		t.Errorf("unexpected registration %v", reg)
+	}
+
+	select {
+	case id := <-l.finished:
+		if id != "job" {
+			t.Errorf("got finish for %s, want job", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not finish")
+	}
+
+	if len(l.metrics) < 15 || len(l.metrics) > 25 {
+		t.Errorf("got %d results, want about 20", len(l.metrics))
+	}
+	for len(l.metrics) > 0 {
+		m := <-l.metrics
+		if m.GetJobId() != "job" || m.GetCode() != 200 || m.GetBytesIn() != 2 || m.GetBytesOut() != 5 || m.GetError() != "" {
+			t.Fatalf("unexpected result %v", m)
+		}
+	}
+
+	// worker exits after being inactive
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected worker to exit cleanly, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("worker did not exit after inactivity period")
+	}
+}
+
+// registrar is a fake leader which only accepts registrations
+type registrar struct {
+	pb.UnimplementedWorkerServer
+
+	register chan *pb.Register
+}
+
+func (r *registrar) Coordinate(stream pb.Worker_CoordinateServer) error {
+	msg, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	r.register <- msg.GetRegister()
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return nil
+		}
+	}
+}
+
+func serveLeader(t *testing.T, address string, r *registrar) (*grpc.Server, *net.TCPAddr) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterWorkerServer(server, r)
+	go server.Serve(lis)
+
+	return server, lis.Addr().(*net.TCPAddr)
+}
+
+func TestWorker_Reconnect(t *testing.T) {
+	r := &registrar{register: make(chan *pb.Register, 1)}
+	server, addr := serveLeader(t, "127.0.0.1:0", r)
+
+	w := NewWorker(&Config{
+		Group:           "group",
+		Instance:        "abc123",
+		LeaderHost:      "127.0.0.1",
+		LeaderPort:      uint64(addr.Port),
+		ReconnectPeriod: 10,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	receiveRegister := func() {
+		select {
+		case reg := <-r.register:
+			if reg.GetInstance() != "abc123" {
+				t.Errorf("unexpected registration %v", reg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("worker did not register")
+		}
+	}
+	receiveRegister()
+
+	// the leader restarts on the same address
+	server.Stop()
+	server, _ = serveLeader(t, addr.String(), r)
+	defer server.Stop()
+
+	receiveRegister()
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected worker to stop with its context, got %v", err)
+	}
+}
+
+// stream records the messages a worker sends on it
+type stream struct {
+	pb.Worker_CoordinateClient
+	sent []*pb.Message
+}
+
+func (s *stream) Send(msg *pb.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestWorker_ReplacedStream(t *testing.T) {
+	w := NewWorker(&Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &stream{}
+	w.stream, w.generation = first, 1
+
+	start := &pb.Message{Payload: &pb.Message_Start{Start: &pb.Start{
+		JobId:    "job",
+		Duration: 60,
+		Request:  &pb.HTTPRequest{Method: "GET", Url: "http://127.0.0.1:1"},
+	}}}
+	w.handle(ctx, start, 1)
+	running := w.job("job")
+
+	// starts of a job which is already running are ignored
+	w.handle(ctx, start, 1)
+	if w.job("job") != running {
+		t.Error("expected the running job to be kept")
+	}
+
+	// jobs of the previous stream do not report on the new one
+	second := &stream{}
+	w.sendmux.Lock()
+	w.stream, w.generation = second, 2
+	w.sendmux.Unlock()
+	w.stopJobs()
+	err := running.send(finishMessage("job"))
+	w.sendmux.Lock()
+	sent := len(second.sent)
+	w.sendmux.Unlock()
+	if err != errStreamReplaced || sent != 0 {
+		t.Errorf("expected the message of the previous stream to be discarded, got %v", err)
+	}
+
+	// the job may be started again on the new stream, before the stopped one finished
+	w.handle(ctx, start, 2)
+	if restarted := w.job("job"); restarted == running || restarted.generation != 2 {
+		t.Error("expected the job to be started on the new stream")
+	}
+	w.stopJobs()
+}
+
+func TestJob_Scenario(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"auth": {"token": "secret"}, "items": [{"id": 7}]}`))
+		case "/items/7":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	var results []*pb.Metrics
+	j, err := newJob(&pb.Start{
+		JobId:     "job",
+		Frequency: 1,
+		Scenario: []*pb.Step{
+			{
+				Name:    "login",
+				Request: &pb.HTTPRequest{Method: http.MethodPost, Url: target.URL + "/login"},
+				Extract: []*pb.Extraction{
+					{Name: "token", Source: "json", Expression: "$.auth.token"},
+					{Name: "item", Source: "json", Expression: "$.items[0].id"},
+				},
+			},
+			{
+				Name: "open",
+				Request: &pb.HTTPRequest{
+					Url:     target.URL + "/items/${item}",
+					Headers: map[string]string{"Authorization": "Bearer ${token}"},
+				},
+			},
+			{
+				Name:    "missing",
+				Request: &pb.HTTPRequest{Url: target.URL + "/missing"},
+			},
+			{
+				Name:    "unreached",
+				Request: &pb.HTTPRequest{Url: target.URL},
+			},
+		},
+	}, func(msg *pb.Message) error {
+		results = append(results, msg.GetMetrics())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.hit(context.Background())
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3 as the scenario ends at the first failure", len(results))
+	}
+	want := []struct {
+		step string
+		code uint32
+	}{{"login", 200}, {"open", 200}, {"missing", 404}}
+	for i, w := range want {
+		if results[i].GetStep() != w.step || results[i].GetCode() != w.code {
+			t.Errorf("got step %s with %d, want %s with %d", results[i].GetStep(), results[i].GetCode(), w.step, w.code)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	body := []byte(`{"a": {"b": [1.5, "two", {"c": true}]}}`)
+
+	tests := []struct {
+		expr  string
+		value string
+		fails bool
+	}{
+		{expr: "$.a.b[0]", value: "1.5"},
+		{expr: "$.a.b[1]", value: "two"},
+		{expr: "$.a.b[2].c", value: "true"},
+		{expr: "$.a.b[3]", fails: true},
+		{expr: "$.a.x", fails: true},
+	}
+
+	for _, test := range tests {
+		value, err := extractJSON(test.expr, body)
+		if test.fails && err == nil {
+			t.Errorf("expected %s to fail, got %s", test.expr, value)
+		}
+		if !test.fails && (err != nil || value != test.value) {
+			t.Errorf("got %s (%v) for %s, want %s", value, err, test.expr, test.value)
+		}
+	}
+}